@@ -198,6 +198,30 @@ CREATE TABLE IF NOT EXISTS `gf_admin_login_logs` (
     KEY `idx_login_ip` (`login_ip`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='登录记录表';
 
+-- 2.4 API Key 表（商户服务端调用）
+CREATE TABLE IF NOT EXISTS `gf_api_keys` (
+    `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT COMMENT 'API Key ID',
+    `name` varchar(100) NOT NULL COMMENT '名称',
+    `owner_id` varchar(64) NOT NULL COMMENT '所属商户/用户ID',
+    `prefix` varchar(20) NOT NULL COMMENT '密钥前缀(展示用)',
+    `key_hash` char(64) NOT NULL COMMENT '密钥哈希',
+    `previous_hash` char(64) DEFAULT NULL COMMENT '轮换前的密钥哈希',
+    `previous_expires_at` timestamp NULL DEFAULT NULL COMMENT '旧密钥失效时间',
+    `scopes` varchar(1000) NOT NULL DEFAULT '' COMMENT '授权范围(逗号分隔)',
+    `daily_quota` bigint(20) NOT NULL DEFAULT '0' COMMENT '每日调用配额,0表示不限',
+    `monthly_quota` bigint(20) NOT NULL DEFAULT '0' COMMENT '每月调用配额,0表示不限',
+    `expires_at` timestamp NULL DEFAULT NULL COMMENT '过期时间',
+    `revoked_at` timestamp NULL DEFAULT NULL COMMENT '吊销时间',
+    `created_by` varchar(64) DEFAULT NULL COMMENT '创建人',
+    `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_key_hash` (`key_hash`),
+    KEY `idx_previous_hash` (`previous_hash`),
+    KEY `idx_owner_id` (`owner_id`),
+    KEY `idx_expires_at` (`expires_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='API Key表';
+
 -- ============================================
 -- 3. 文件服务相关表
 -- ============================================
@@ -0,0 +1,122 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// 密钥格式：gf_ + 48 位十六进制随机数，前缀用于展示和排查
+const (
+	keyPrefix    = "gf_"
+	keyBytes     = 24
+	displayChars = 11
+)
+
+// Key API Key 模型，明文只在创建和轮换时返回一次，数据库只保存 SHA-256 哈希
+type Key struct {
+	ID                uint64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name              string     `json:"name" gorm:"type:varchar(100);not null;comment:名称"`
+	OwnerID           string     `json:"owner_id" gorm:"type:varchar(64);not null;index;comment:所属商户/用户ID"`
+	Prefix            string     `json:"prefix" gorm:"type:varchar(20);not null;comment:密钥前缀(展示用)"`
+	KeyHash           string     `json:"-" gorm:"type:char(64);uniqueIndex;not null;comment:密钥哈希"`
+	PreviousHash      *string    `json:"-" gorm:"type:char(64);index;comment:轮换前的密钥哈希"`
+	PreviousExpiresAt *time.Time `json:"previous_expires_at,omitempty" gorm:"comment:旧密钥失效时间"`
+	Scopes            string     `json:"scopes" gorm:"type:varchar(1000);not null;default:'';comment:授权范围(逗号分隔)"`
+	DailyQuota        int64      `json:"daily_quota" gorm:"default:0;comment:每日调用配额,0表示不限"`
+	MonthlyQuota      int64      `json:"monthly_quota" gorm:"default:0;comment:每月调用配额,0表示不限"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty" gorm:"index;comment:过期时间"`
+	RevokedAt         *time.Time `json:"revoked_at,omitempty" gorm:"comment:吊销时间"`
+	CreatedBy         string     `json:"created_by" gorm:"type:varchar(64);comment:创建人"`
+	CreatedAt         time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt         time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName 返回表名
+func (Key) TableName() string {
+	return "gf_api_keys"
+}
+
+// Generate 生成新的明文密钥，返回明文、展示前缀和哈希
+func Generate() (plain, prefix, hash string, err error) {
+	b := make([]byte, keyBytes)
+	if _, err = rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+	plain = keyPrefix + hex.EncodeToString(b)
+	return plain, plain[:displayChars], Hash(plain), nil
+}
+
+// Hash 计算密钥哈希，密钥本身是高熵随机数，不需要加盐慢哈希
+func Hash(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+// Active 密钥在指定时间是否可用（未吊销且未过期）
+func (k *Key) Active(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// ScopeList 授权范围列表
+func (k *Key) ScopeList() []string {
+	return SplitScopes(k.Scopes)
+}
+
+// SplitScopes 解析逗号分隔的授权范围
+func SplitScopes(s string) []string {
+	var scopes []string
+	for _, scope := range strings.Split(s, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// JoinScopes 规范化并合并授权范围
+func JoinScopes(scopes []string) string {
+	return strings.Join(SplitScopes(strings.Join(scopes, ",")), ",")
+}
+
+// Allows 是否允许访问指定路由
+// 路由范围格式为 "方法 路径"，方法可以是 *，路径以 /* 结尾时按前缀匹配，如 "GET /api/v1/merchant/*"
+// 范围 * 表示允许全部路由；不含路径的范围（如 orders:write）是权限名，不参与路由匹配
+func (k *Key) Allows(method, path string) bool {
+	for _, scope := range k.ScopeList() {
+		if scope == "*" {
+			return true
+		}
+		m, p, ok := strings.Cut(scope, " ")
+		if !ok {
+			continue
+		}
+		p = strings.TrimSpace(p)
+		if m != "*" && !strings.EqualFold(m, method) {
+			continue
+		}
+		if prefix, wildcard := strings.CutSuffix(p, "/*"); wildcard {
+			if path == prefix || strings.HasPrefix(path, prefix+"/") {
+				return true
+			}
+		} else if path == p {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope 是否拥有指定权限
+func (k *Key) HasScope(permission string) bool {
+	for _, scope := range k.ScopeList() {
+		if scope == "*" || scope == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package apikey
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	plain, prefix, hash, err := Generate()
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(plain, "gf_"))
+	require.True(t, strings.HasPrefix(plain, prefix))
+	require.Equal(t, Hash(plain), hash)
+	require.Len(t, hash, 64)
+
+	other, _, _, err := Generate()
+	require.NoError(t, err)
+	require.NotEqual(t, plain, other)
+}
+
+func TestKey_AllowsAndActive(t *testing.T) {
+	key := &Key{Scopes: JoinScopes([]string{" GET /api/v1/merchant/products/* ", "POST /api/v1/merchant/orders", "orders:write"})}
+
+	require.True(t, key.Allows("GET", "/api/v1/merchant/products"))
+	require.True(t, key.Allows("get", "/api/v1/merchant/products/42"))
+	require.False(t, key.Allows("GET", "/api/v1/merchant/productsx"))
+	require.False(t, key.Allows("DELETE", "/api/v1/merchant/products/42"))
+	require.True(t, key.Allows("POST", "/api/v1/merchant/orders"))
+	require.False(t, key.Allows("POST", "/api/v1/merchant/orders/1"))
+	require.True(t, key.HasScope("orders:write"))
+	require.False(t, key.HasScope("orders:read"))
+
+	require.True(t, (&Key{Scopes: "*"}).Allows("DELETE", "/anything"))
+
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	require.True(t, key.Active(now))
+	require.False(t, (&Key{ExpiresAt: &past}).Active(now))
+	require.False(t, (&Key{RevokedAt: &past}).Active(now))
+}
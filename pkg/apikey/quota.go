@@ -0,0 +1,166 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	pkgRedis "goweb/pkg/redis"
+)
+
+var (
+	ErrDailyQuotaExceeded   = errors.New("api key daily quota exceeded")
+	ErrMonthlyQuotaExceeded = errors.New("api key monthly quota exceeded")
+)
+
+// 计数器保留时间：日计数保留 90 天，月计数保留 400 天，供计费对账
+const (
+	dailyRetention   = 90 * 24 * time.Hour
+	monthlyRetention = 400 * 24 * time.Hour
+)
+
+// consumeScript 检查配额并原子地增加日/月计数，超出配额的请求不计数
+// 返回 {日计数, 月计数, 状态}，状态 0 成功、1 超出日配额、2 超出月配额
+var consumeScript = goredis.NewScript(`
+local daily = tonumber(redis.call('GET', KEYS[1]) or '0')
+local monthly = tonumber(redis.call('GET', KEYS[2]) or '0')
+local dailyLimit = tonumber(ARGV[1])
+local monthlyLimit = tonumber(ARGV[2])
+if dailyLimit > 0 and daily >= dailyLimit then
+	return {daily, monthly, 1}
+end
+if monthlyLimit > 0 and monthly >= monthlyLimit then
+	return {daily, monthly, 2}
+end
+daily = redis.call('INCR', KEYS[1])
+if daily == 1 then
+	redis.call('EXPIRE', KEYS[1], ARGV[3])
+end
+monthly = redis.call('INCR', KEYS[2])
+if monthly == 1 then
+	redis.call('EXPIRE', KEYS[2], ARGV[4])
+end
+redis.call('SET', KEYS[3], ARGV[5], 'EX', ARGV[4])
+return {daily, monthly, 0}
+`)
+
+// Usage 当前周期的调用量和配额，配额为 0 表示不限
+type Usage struct {
+	Daily        int64 `json:"daily"`
+	Monthly      int64 `json:"monthly"`
+	DailyQuota   int64 `json:"daily_quota"`
+	MonthlyQuota int64 `json:"monthly_quota"`
+}
+
+// Quota 基于 Redis 的每日/每月配额和调用计数，周期按 UTC 划分
+type Quota struct {
+	client *pkgRedis.Client
+}
+
+// NewQuota 创建配额计数器
+func NewQuota(client *pkgRedis.Client) *Quota {
+	return &Quota{client: client}
+}
+
+func dailyKey(id uint64, t time.Time) string {
+	return fmt.Sprintf("apikey:usage:%d:d:%s", id, t.UTC().Format("20060102"))
+}
+
+func monthlyKey(id uint64, t time.Time) string {
+	return fmt.Sprintf("apikey:usage:%d:m:%s", id, t.UTC().Format("200601"))
+}
+
+func lastUsedKey(id uint64) string {
+	return fmt.Sprintf("apikey:usage:%d:last", id)
+}
+
+// Consume 记录一次调用，超出配额时返回 ErrDailyQuotaExceeded 或 ErrMonthlyQuotaExceeded
+func (q *Quota) Consume(ctx context.Context, key *Key, now time.Time) (Usage, error) {
+	usage := Usage{DailyQuota: key.DailyQuota, MonthlyQuota: key.MonthlyQuota}
+	client := q.client.GetClient()
+	if client == nil {
+		return usage, fmt.Errorf("redis client is not initialized")
+	}
+
+	res, err := consumeScript.Run(ctx, client,
+		[]string{dailyKey(key.ID, now), monthlyKey(key.ID, now), lastUsedKey(key.ID)},
+		key.DailyQuota, key.MonthlyQuota,
+		int64(dailyRetention.Seconds()), int64(monthlyRetention.Seconds()),
+		now.Unix(),
+	).Int64Slice()
+	if err != nil {
+		return usage, err
+	}
+
+	usage.Daily, usage.Monthly = res[0], res[1]
+	switch res[2] {
+	case 1:
+		return usage, ErrDailyQuotaExceeded
+	case 2:
+		return usage, ErrMonthlyQuotaExceeded
+	}
+	return usage, nil
+}
+
+// DailyUsage 某一天的调用量
+type DailyUsage struct {
+	Date  string `json:"date"`
+	Count int64  `json:"count"`
+}
+
+// Report 某个月的调用量明细，用于计费
+type Report struct {
+	KeyID      uint64       `json:"key_id"`
+	Month      string       `json:"month"`
+	Total      int64        `json:"total"`
+	Days       []DailyUsage `json:"days"`
+	LastUsedAt *time.Time   `json:"last_used_at,omitempty"`
+}
+
+// Report 查询指定月份的调用量
+func (q *Quota) Report(ctx context.Context, id uint64, month time.Time) (*Report, error) {
+	client := q.client.GetClient()
+	if client == nil {
+		return nil, fmt.Errorf("redis client is not initialized")
+	}
+
+	start := time.Date(month.UTC().Year(), month.UTC().Month(), 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+
+	keys := []string{monthlyKey(id, start), lastUsedKey(id)}
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		keys = append(keys, dailyKey(id, d))
+	}
+	values, err := client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		KeyID: id,
+		Month: start.Format("2006-01"),
+		Total: toInt64(values[0]),
+		Days:  make([]DailyUsage, 0, len(values)-2),
+	}
+	if ts := toInt64(values[1]); ts > 0 {
+		t := time.Unix(ts, 0)
+		report.LastUsedAt = &t
+	}
+	for i, d := 0, start; d.Before(end); i, d = i+1, d.AddDate(0, 0, 1) {
+		report.Days = append(report.Days, DailyUsage{Date: d.Format("2006-01-02"), Count: toInt64(values[i+2])})
+	}
+	return report, nil
+}
+
+func toInt64(v interface{}) int64 {
+	s, ok := v.(string)
+	if !ok {
+		return 0
+	}
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
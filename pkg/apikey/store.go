@@ -0,0 +1,120 @@
+package apikey
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+
+	pkgRedis "goweb/pkg/redis"
+)
+
+// ErrNotFound 密钥不存在
+var ErrNotFound = errors.New("api key not found")
+
+// Store 按哈希查找密钥
+type Store interface {
+	Lookup(ctx context.Context, hash string) (*Key, error)
+}
+
+// DBStore 直接查询数据库，轮换宽限期内的旧密钥同样有效
+type DBStore struct {
+	db *gorm.DB
+}
+
+// NewDBStore 创建数据库存储
+func NewDBStore(db *gorm.DB) *DBStore {
+	return &DBStore{db: db}
+}
+
+// Lookup 按哈希查找密钥
+func (s *DBStore) Lookup(ctx context.Context, hash string) (*Key, error) {
+	var key Key
+	err := s.db.WithContext(ctx).
+		Where("key_hash = ? OR (previous_hash = ? AND previous_expires_at > ?)", hash, hash, time.Now()).
+		First(&key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// RedisStore Redis 中的密钥副本，供没有数据库连接的网关使用
+// admin-api 在创建、修改、轮换和吊销时同步写入，键的过期时间与密钥一致
+type RedisStore struct {
+	client *pkgRedis.Client
+}
+
+// NewRedisStore 创建 Redis 存储
+func NewRedisStore(client *pkgRedis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func hashKey(hash string) string {
+	return "apikey:hash:" + hash
+}
+
+// Lookup 按哈希查找密钥
+func (s *RedisStore) Lookup(ctx context.Context, hash string) (*Key, error) {
+	data, err := s.client.Get(ctx, hashKey(hash))
+	if errors.Is(err, goredis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var key Key
+	if err := json.Unmarshal([]byte(data), &key); err != nil {
+		return nil, fmt.Errorf("decode api key: %w", err)
+	}
+	return &key, nil
+}
+
+// Put 写入密钥，已失效的密钥直接删除
+func (s *RedisStore) Put(ctx context.Context, key *Key) error {
+	now := time.Now()
+	if !key.Active(now) {
+		return s.Delete(ctx, key)
+	}
+
+	data, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+
+	var ttl time.Duration
+	if key.ExpiresAt != nil {
+		ttl = key.ExpiresAt.Sub(now)
+	}
+	if err := s.client.Set(ctx, hashKey(key.KeyHash), data, ttl); err != nil {
+		return err
+	}
+
+	if key.PreviousHash == nil || key.PreviousExpiresAt == nil {
+		return nil
+	}
+	grace := key.PreviousExpiresAt.Sub(now)
+	if ttl > 0 && ttl < grace {
+		grace = ttl
+	}
+	if grace <= 0 {
+		return s.client.Del(ctx, hashKey(*key.PreviousHash))
+	}
+	return s.client.Set(ctx, hashKey(*key.PreviousHash), data, grace)
+}
+
+// Delete 删除密钥及轮换前的旧密钥
+func (s *RedisStore) Delete(ctx context.Context, key *Key) error {
+	keys := []string{hashKey(key.KeyHash)}
+	if key.PreviousHash != nil {
+		keys = append(keys, hashKey(*key.PreviousHash))
+	}
+	return s.client.Del(ctx, keys...)
+}
@@ -2,7 +2,7 @@ package circuit
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"sync"
 	"time"
 
@@ -31,6 +31,18 @@ func (s State) String() string {
 	}
 }
 
+var (
+	// ErrOpenState 熔断器处于开启状态，请求被拒绝
+	ErrOpenState = errors.New("circuit breaker is open")
+	// ErrTooManyRequests 熔断器处于半开状态且探测请求数已满
+	ErrTooManyRequests = errors.New("circuit breaker is half-open and max requests reached")
+)
+
+// IsRejected 判断错误是否为熔断器拒绝请求
+func IsRejected(err error) bool {
+	return errors.Is(err, ErrOpenState) || errors.Is(err, ErrTooManyRequests)
+}
+
 // Breaker 熔断器
 type Breaker struct {
 	name          string
@@ -40,6 +52,14 @@ type Breaker struct {
 	readyToTrip   func(counts Counts) bool
 	onStateChange func(name string, from State, to State)
 
+	// 滑动窗口失败率判定，window 为 nil 时使用 readyToTrip
+	window               window
+	minimumCalls         int
+	failureRateThreshold float64
+	slowCallDuration     time.Duration
+	ignoredErrors        []error
+	isIgnored            func(err error) bool
+
 	mu         sync.Mutex
 	state      State
 	generation uint64
@@ -49,11 +69,11 @@ type Breaker struct {
 
 // Counts 计数器
 type Counts struct {
-	Requests             uint32
-	TotalSuccesses       uint32
-	TotalFailures        uint32
-	ConsecutiveSuccesses uint32
-	ConsecutiveFailures  uint32
+	Requests             uint32 `json:"requests"`
+	TotalSuccesses       uint32 `json:"total_successes"`
+	TotalFailures        uint32 `json:"total_failures"`
+	ConsecutiveSuccesses uint32 `json:"consecutive_successes"`
+	ConsecutiveFailures  uint32 `json:"consecutive_failures"`
 }
 
 // Request 请求结果
@@ -70,8 +90,31 @@ type Config struct {
 	Timeout       time.Duration
 	ReadyToTrip   func(counts Counts) bool
 	OnStateChange func(name string, from State, to State)
+
+	// 滑动窗口，未设置时按 Interval 周期计数并由 ReadyToTrip 判定
+	// 设置后关闭状态下不再按 Interval 清空计数，由窗口内失败率判定是否熔断
+	WindowType           WindowType
+	WindowSize           int     // 计数窗口为调用次数（默认 100），时间窗口为秒数（默认 60）
+	MinimumCalls         int     // 窗口内调用数达到该值才计算失败率，默认 10（不超过计数窗口大小）
+	FailureRateThreshold float64 // 失败率阈值（百分比），默认 50
+
+	// SlowCallDuration 耗时超过该值的调用计为失败，0 表示不启用
+	SlowCallDuration time.Duration
+
+	// 忽略的错误既不计为成功也不计为失败，如参数校验错误、4xx
+	IgnoredErrors []error              // 按 errors.Is 匹配
+	IsIgnored     func(err error) bool // 自定义判断，如按错误类型匹配
 }
 
+// outcome 调用结果
+type outcome int
+
+const (
+	outcomeSuccess outcome = iota
+	outcomeFailure
+	outcomeIgnored
+)
+
 // DefaultConfig 默认配置
 func DefaultConfig(name string) *Config {
 	return &Config{
@@ -97,14 +140,43 @@ func NewBreaker(cfg *Config, log logger.Logger) *Breaker {
 	}
 
 	cb := &Breaker{
-		name:          cfg.Name,
-		maxRequests:   cfg.MaxRequests,
-		interval:      cfg.Interval,
-		timeout:       cfg.Timeout,
-		readyToTrip:   cfg.ReadyToTrip,
-		onStateChange: cfg.OnStateChange,
-		state:         StateClosed,
-		expiry:        time.Now().Add(cfg.Interval),
+		name:             cfg.Name,
+		maxRequests:      cfg.MaxRequests,
+		interval:         cfg.Interval,
+		timeout:          cfg.Timeout,
+		readyToTrip:      cfg.ReadyToTrip,
+		onStateChange:    cfg.OnStateChange,
+		slowCallDuration: cfg.SlowCallDuration,
+		ignoredErrors:    cfg.IgnoredErrors,
+		isIgnored:        cfg.IsIgnored,
+		state:            StateClosed,
+		expiry:           time.Now().Add(cfg.Interval),
+	}
+
+	if cfg.WindowType == WindowCount || cfg.WindowType == WindowTime {
+		size := cfg.WindowSize
+		if size <= 0 {
+			size = 100
+			if cfg.WindowType == WindowTime {
+				size = 60
+			}
+		}
+		cb.minimumCalls = cfg.MinimumCalls
+		if cb.minimumCalls <= 0 {
+			cb.minimumCalls = 10
+		}
+		if cfg.WindowType == WindowCount && cb.minimumCalls > size {
+			cb.minimumCalls = size
+		}
+		cb.failureRateThreshold = cfg.FailureRateThreshold
+		if cb.failureRateThreshold <= 0 || cb.failureRateThreshold > 100 {
+			cb.failureRateThreshold = 50
+		}
+		cb.window = newWindow(cfg.WindowType, size)
+		cb.expiry = time.Time{}
+	} else if cfg.WindowType != WindowNone && log != nil {
+		log.Warn("unknown circuit breaker window type, falling back to interval counting",
+			"name", cfg.Name, "window_type", string(cfg.WindowType))
 	}
 
 	return cb
@@ -120,13 +192,14 @@ func (cb *Breaker) Execute(req func() (interface{}, error)) (interface{}, error)
 	defer func() {
 		e := recover()
 		if e != nil {
-			cb.afterRequest(generation, false)
+			cb.afterRequest(generation, outcomeFailure)
 			panic(e)
 		}
 	}()
 
+	start := time.Now()
 	result, err := req()
-	cb.afterRequest(generation, err == nil)
+	cb.afterRequest(generation, cb.outcome(err, time.Since(start)))
 	return result, err
 }
 
@@ -140,13 +213,14 @@ func (cb *Breaker) ExecuteWithContext(ctx context.Context, req func(context.Cont
 	defer func() {
 		e := recover()
 		if e != nil {
-			cb.afterRequest(generation, false)
+			cb.afterRequest(generation, outcomeFailure)
 			panic(e)
 		}
 	}()
 
+	start := time.Now()
 	result, err := req(ctx)
-	cb.afterRequest(generation, err == nil)
+	cb.afterRequest(generation, cb.outcome(err, time.Since(start)))
 	return result, err
 }
 
@@ -160,10 +234,10 @@ func (cb *Breaker) beforeRequest() (uint64, error) {
 
 	switch state {
 	case StateOpen:
-		return generation, fmt.Errorf("circuit breaker is open")
+		return generation, ErrOpenState
 	case StateHalfOpen:
 		if cb.counts.Requests >= cb.maxRequests {
-			return generation, fmt.Errorf("circuit breaker is half-open and max requests reached")
+			return generation, ErrTooManyRequests
 		}
 	}
 
@@ -171,8 +245,32 @@ func (cb *Breaker) beforeRequest() (uint64, error) {
 	return generation, nil
 }
 
+// outcome 判定调用结果：忽略的错误、失败（含慢调用）或成功
+func (cb *Breaker) outcome(err error, elapsed time.Duration) outcome {
+	if err != nil {
+		if cb.ignored(err) {
+			return outcomeIgnored
+		}
+		return outcomeFailure
+	}
+	if cb.slowCallDuration > 0 && elapsed > cb.slowCallDuration {
+		return outcomeFailure
+	}
+	return outcomeSuccess
+}
+
+// ignored 是否为忽略的错误
+func (cb *Breaker) ignored(err error) bool {
+	for _, target := range cb.ignoredErrors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return cb.isIgnored != nil && cb.isIgnored(err)
+}
+
 // afterRequest 请求后处理
-func (cb *Breaker) afterRequest(before uint64, success bool) {
+func (cb *Breaker) afterRequest(before uint64, result outcome) {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 
@@ -183,10 +281,14 @@ func (cb *Breaker) afterRequest(before uint64, success bool) {
 		return
 	}
 
-	if success {
+	switch result {
+	case outcomeSuccess:
 		cb.onSuccess(state, now)
-	} else {
+	case outcomeFailure:
 		cb.onFailure(state, now)
+	default:
+		// 忽略的调用不计数，并归还半开状态下占用的探测名额
+		cb.counts.Requests--
 	}
 }
 
@@ -210,6 +312,12 @@ func (cb *Breaker) onSuccess(state State, now time.Time) {
 	switch state {
 	case StateClosed:
 		cb.counts.onSuccess()
+		if cb.window != nil {
+			cb.window.record(now, false)
+			if cb.shouldTrip(now) {
+				cb.setState(StateOpen, now)
+			}
+		}
 	case StateHalfOpen:
 		cb.counts.onSuccess()
 		if cb.counts.ConsecutiveSuccesses >= cb.maxRequests {
@@ -223,7 +331,10 @@ func (cb *Breaker) onFailure(state State, now time.Time) {
 	switch state {
 	case StateClosed:
 		cb.counts.onFailure()
-		if cb.readyToTrip(cb.counts) {
+		if cb.window != nil {
+			cb.window.record(now, true)
+		}
+		if cb.shouldTrip(now) {
 			cb.setState(StateOpen, now)
 		}
 	case StateHalfOpen:
@@ -231,6 +342,15 @@ func (cb *Breaker) onFailure(state State, now time.Time) {
 	}
 }
 
+// shouldTrip 关闭状态下是否应该熔断
+func (cb *Breaker) shouldTrip(now time.Time) bool {
+	if cb.window == nil {
+		return cb.readyToTrip(cb.counts)
+	}
+	calls, failures := cb.window.totals(now)
+	return calls >= cb.minimumCalls && float64(failures)*100 >= cb.failureRateThreshold*float64(calls)
+}
+
 // setState 设置状态
 func (cb *Breaker) setState(state State, now time.Time) {
 	if cb.state == state {
@@ -252,10 +372,14 @@ func (cb *Breaker) toNewGeneration(now time.Time) {
 	cb.generation++
 	cb.counts = Counts{}
 
+	if cb.window != nil {
+		cb.window.reset()
+	}
+
 	var zero time.Time
 	switch cb.state {
 	case StateClosed:
-		if cb.interval == 0 {
+		if cb.interval == 0 || cb.window != nil {
 			cb.expiry = zero
 		} else {
 			cb.expiry = now.Add(cb.interval)
@@ -267,6 +391,11 @@ func (cb *Breaker) toNewGeneration(now time.Time) {
 	}
 }
 
+// Name 获取熔断器名称
+func (cb *Breaker) Name() string {
+	return cb.name
+}
+
 // State 获取当前状态
 func (cb *Breaker) State() State {
 	cb.mu.Lock()
@@ -285,12 +414,35 @@ func (cb *Breaker) Counts() Counts {
 	return cb.counts
 }
 
+// FailureRate 当前失败率（0-1），使用滑动窗口时为窗口内的失败率
+func (cb *Breaker) FailureRate() float64 {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	now := time.Now()
+	_, _ = cb.currentState(now)
+	if cb.window == nil {
+		return cb.counts.FailureRate()
+	}
+	calls, failures := cb.window.totals(now)
+	if calls == 0 {
+		return 0
+	}
+	return float64(failures) / float64(calls)
+}
+
 // Reset 重置熔断器
 func (cb *Breaker) Reset() {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 
-	cb.setState(StateClosed, time.Now())
+	now := time.Now()
+	if cb.state == StateClosed {
+		// 已经是关闭状态时只清空计数
+		cb.toNewGeneration(now)
+		return
+	}
+	cb.setState(StateClosed, now)
 }
 
 // onRequest 请求计数
@@ -330,19 +482,34 @@ func (c *Counts) FailureRate() float64 {
 
 // BreakerManager 熔断器管理器
 type BreakerManager struct {
-	breakers map[string]*Breaker
-	mu       sync.RWMutex
-	logger   logger.Logger
+	breakers  map[string]*Breaker
+	mu        sync.RWMutex
+	logger    logger.Logger
+	newConfig func(name string) *Config
+	listeners []func(name string, from State, to State)
 }
 
 // NewBreakerManager 创建熔断器管理器
 func NewBreakerManager(log logger.Logger) *BreakerManager {
+	return NewBreakerManagerWithConfig(log, DefaultConfig)
+}
+
+// NewBreakerManagerWithConfig 创建熔断器管理器，新建熔断器时使用 newConfig 生成配置
+func NewBreakerManagerWithConfig(log logger.Logger, newConfig func(name string) *Config) *BreakerManager {
 	return &BreakerManager{
-		breakers: make(map[string]*Breaker),
-		logger:   log,
+		breakers:  make(map[string]*Breaker),
+		logger:    log,
+		newConfig: newConfig,
 	}
 }
 
+// OnStateChange 注册状态变化监听器，对之后创建的熔断器生效
+func (bm *BreakerManager) OnStateChange(fn func(name string, from State, to State)) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	bm.listeners = append(bm.listeners, fn)
+}
+
 // GetBreaker 获取熔断器
 func (bm *BreakerManager) GetBreaker(name string) *Breaker {
 	bm.mu.RLock()
@@ -362,7 +529,19 @@ func (bm *BreakerManager) GetBreaker(name string) *Breaker {
 	}
 
 	// 创建新的熔断器
-	cfg := DefaultConfig(name)
+	cfg := bm.newConfig(name)
+	cfg.Name = name
+	onStateChange := cfg.OnStateChange
+	listeners := append([]func(string, State, State){}, bm.listeners...)
+	cfg.OnStateChange = func(name string, from State, to State) {
+		bm.logger.Warn("circuit breaker state changed", "name", name, "from", from.String(), "to", to.String())
+		if onStateChange != nil {
+			onStateChange(name, from, to)
+		}
+		for _, fn := range listeners {
+			fn(name, from, to)
+		}
+	}
 	breaker = NewBreaker(cfg, bm.logger)
 	bm.breakers[name] = breaker
 
@@ -370,6 +549,14 @@ func (bm *BreakerManager) GetBreaker(name string) *Breaker {
 	return breaker
 }
 
+// RemoveBreaker 删除熔断器，下次获取时按最新配置重新创建
+func (bm *BreakerManager) RemoveBreaker(name string) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	delete(bm.breakers, name)
+}
+
 // ListBreakers 列出所有熔断器
 func (bm *BreakerManager) ListBreakers() map[string]*Breaker {
 	bm.mu.RLock()
@@ -383,7 +570,7 @@ func (bm *BreakerManager) ListBreakers() map[string]*Breaker {
 }
 
 // ResetBreaker 重置熔断器
-func (bm *BreakerManager) ResetBreaker(name string) {
+func (bm *BreakerManager) ResetBreaker(name string) bool {
 	bm.mu.RLock()
 	breaker, exists := bm.breakers[name]
 	bm.mu.RUnlock()
@@ -392,4 +579,5 @@ func (bm *BreakerManager) ResetBreaker(name string) {
 		breaker.Reset()
 		bm.logger.Info("circuit breaker reset", "name", name)
 	}
+	return exists
 }
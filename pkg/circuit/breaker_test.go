@@ -0,0 +1,87 @@
+package circuit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var errTest = errors.New("test failure")
+
+func call(cb *Breaker, err error) error {
+	_, e := cb.Execute(func() (interface{}, error) { return nil, err })
+	return e
+}
+
+func TestBreaker_CountWindowFailureRate(t *testing.T) {
+	cb := NewBreaker(&Config{
+		Name:                 "count",
+		MaxRequests:          1,
+		Timeout:              time.Minute,
+		WindowType:           WindowCount,
+		WindowSize:           10,
+		MinimumCalls:         4,
+		FailureRateThreshold: 50,
+	}, nil)
+
+	// 未达到最少调用数时不熔断
+	call(cb, errTest)
+	call(cb, errTest)
+	call(cb, errTest)
+	require.Equal(t, StateClosed, cb.State())
+
+	// 4 次调用中 3 次失败，失败率 75%
+	call(cb, nil)
+	require.Equal(t, StateOpen, cb.State())
+	require.ErrorIs(t, call(cb, nil), ErrOpenState)
+}
+
+func TestBreaker_CountWindowSlides(t *testing.T) {
+	cb := NewBreaker(&Config{
+		Name:                 "slide",
+		WindowType:           WindowCount,
+		WindowSize:           4,
+		MinimumCalls:         4,
+		FailureRateThreshold: 50,
+	}, nil)
+
+	// 早期的失败被后续成功挤出窗口
+	call(cb, errTest)
+	for i := 0; i < 4; i++ {
+		call(cb, nil)
+	}
+	call(cb, errTest)
+	require.Equal(t, StateClosed, cb.State())
+	require.InDelta(t, 0.25, cb.FailureRate(), 0.001)
+}
+
+func TestBreaker_SlowCallsAndIgnoredErrors(t *testing.T) {
+	errValidation := errors.New("validation failed")
+	cb := NewBreaker(&Config{
+		Name:             "slow",
+		MaxRequests:      1,
+		Timeout:          time.Minute,
+		SlowCallDuration: 10 * time.Millisecond,
+		IgnoredErrors:    []error{errValidation},
+		ReadyToTrip: func(counts Counts) bool {
+			return counts.ConsecutiveFailures >= 2
+		},
+	}, nil)
+
+	// 忽略的错误不计数
+	for i := 0; i < 5; i++ {
+		require.ErrorIs(t, call(cb, errValidation), errValidation)
+	}
+	require.Equal(t, uint32(0), cb.Counts().Requests)
+
+	// 慢调用计为失败
+	for i := 0; i < 2; i++ {
+		cb.Execute(func() (interface{}, error) {
+			time.Sleep(20 * time.Millisecond)
+			return nil, nil
+		})
+	}
+	require.Equal(t, StateOpen, cb.State())
+}
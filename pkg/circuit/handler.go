@@ -0,0 +1,63 @@
+package circuit
+
+import (
+	"sort"
+
+	"github.com/gin-gonic/gin"
+
+	"goweb/pkg/response"
+)
+
+// BreakerStatus 熔断器状态快照
+type BreakerStatus struct {
+	Name        string  `json:"name"`
+	State       string  `json:"state"`
+	Counts      Counts  `json:"counts"`
+	FailureRate float64 `json:"failure_rate"`
+}
+
+// Handler 熔断器管理接口
+type Handler struct {
+	manager *BreakerManager
+}
+
+// NewHandler 创建熔断器管理接口
+func NewHandler(manager *BreakerManager) *Handler {
+	return &Handler{manager: manager}
+}
+
+// RegisterRoutes 注册管理接口
+//
+//	GET  /breakers              列出熔断器状态和计数
+//	POST /breakers/:name/reset  手动重置熔断器
+func (h *Handler) RegisterRoutes(r gin.IRouter) {
+	r.GET("/breakers", h.List)
+	r.POST("/breakers/:name/reset", h.Reset)
+}
+
+// List 列出熔断器状态
+func (h *Handler) List(c *gin.Context) {
+	breakers := h.manager.ListBreakers()
+	list := make([]BreakerStatus, 0, len(breakers))
+	for name, breaker := range breakers {
+		list = append(list, BreakerStatus{
+			Name:        name,
+			State:       breaker.State().String(),
+			Counts:      breaker.Counts(),
+			FailureRate: breaker.FailureRate(),
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	response.Success(c, list)
+}
+
+// Reset 重置熔断器
+func (h *Handler) Reset(c *gin.Context) {
+	name := c.Param("name")
+	if !h.manager.ResetBreaker(name) {
+		response.NotFound(c, "circuit breaker not found")
+		return
+	}
+	response.Success(c, gin.H{"name": name})
+}
@@ -0,0 +1,55 @@
+package circuit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"goweb/pkg/logger"
+)
+
+func TestHandler_ListAndReset(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	bm := NewBreakerManager(logger.New("test", "error", "stdout", ""))
+	for i := 0; i < 5; i++ {
+		call(bm.GetBreaker("user-api"), errTest)
+	}
+	call(bm.GetBreaker("order-api"), nil)
+
+	r := gin.New()
+	NewHandler(bm).RegisterRoutes(r)
+	do := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+	list := func() []BreakerStatus {
+		w := do(http.MethodGet, "/breakers")
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp struct {
+			Data []BreakerStatus `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp.Data
+	}
+
+	breakers := list()
+	require.Len(t, breakers, 2)
+	require.Equal(t, "order-api", breakers[0].Name)
+	require.Equal(t, StateClosed.String(), breakers[0].State)
+	require.EqualValues(t, 1, breakers[0].Counts.TotalSuccesses)
+	require.Equal(t, "user-api", breakers[1].Name)
+	require.Equal(t, StateOpen.String(), breakers[1].State)
+
+	// 手动重置后恢复关闭状态
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/breakers/user-api/reset").Code)
+	breakers = list()
+	require.Equal(t, StateClosed.String(), breakers[1].State)
+	require.NoError(t, call(bm.GetBreaker("user-api"), nil))
+
+	require.Contains(t, do(http.MethodPost, "/breakers/unknown/reset").Body.String(), `"code":404`)
+}
@@ -0,0 +1,28 @@
+package circuit
+
+import (
+	"goweb/pkg/monitor"
+)
+
+// RegisterMetrics 将管理器中熔断器的状态变化上报到 Prometheus
+//   - circuit_breaker_state：当前状态（0 关闭、1 开启、2 半开）
+//   - circuit_breaker_transitions_total：状态切换次数
+//
+// 需要在创建熔断器之前调用
+func RegisterMetrics(bm *BreakerManager, metrics *monitor.Metrics, serviceName string) {
+	state := metrics.CreateCustomGauge(
+		"circuit_breaker_state",
+		"Circuit breaker state (0 closed, 1 open, 2 half-open)",
+		[]string{"name", "service"},
+	)
+	transitions := metrics.CreateCustomCounter(
+		"circuit_breaker_transitions_total",
+		"Total number of circuit breaker state transitions",
+		[]string{"name", "from", "to", "service"},
+	)
+
+	bm.OnStateChange(func(name string, from State, to State) {
+		state.WithLabelValues(name, serviceName).Set(float64(to))
+		transitions.WithLabelValues(name, from.String(), to.String(), serviceName).Inc()
+	})
+}
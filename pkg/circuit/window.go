@@ -0,0 +1,106 @@
+package circuit
+
+import "time"
+
+// WindowType 滑动窗口类型
+type WindowType string
+
+const (
+	WindowNone  WindowType = ""      // 不使用滑动窗口，按 Interval 周期计数
+	WindowCount WindowType = "count" // 最近 N 次调用
+	WindowTime  WindowType = "time"  // 最近 N 秒内的调用
+)
+
+// window 滑动窗口，统计窗口内的调用数和失败数
+type window interface {
+	record(now time.Time, failed bool)
+	totals(now time.Time) (calls, failures int)
+	reset()
+}
+
+// newWindow 创建滑动窗口
+func newWindow(t WindowType, size int) window {
+	switch t {
+	case WindowCount:
+		return &countWindow{outcomes: make([]bool, size)}
+	case WindowTime:
+		return &timeWindow{buckets: make([]bucket, size)}
+	default:
+		return nil
+	}
+}
+
+// countWindow 基于调用次数的滑动窗口（环形缓冲区）
+type countWindow struct {
+	outcomes []bool // true 表示失败
+	next     int
+	calls    int
+	failures int
+}
+
+func (w *countWindow) record(now time.Time, failed bool) {
+	if w.calls == len(w.outcomes) {
+		// 窗口已满，覆盖最早的一次调用
+		if w.outcomes[w.next] {
+			w.failures--
+		}
+	} else {
+		w.calls++
+	}
+	w.outcomes[w.next] = failed
+	if failed {
+		w.failures++
+	}
+	w.next = (w.next + 1) % len(w.outcomes)
+}
+
+func (w *countWindow) totals(now time.Time) (int, int) {
+	return w.calls, w.failures
+}
+
+func (w *countWindow) reset() {
+	w.next, w.calls, w.failures = 0, 0, 0
+}
+
+// bucket 时间窗口中一秒的统计
+type bucket struct {
+	second   int64
+	calls    int
+	failures int
+}
+
+// timeWindow 基于时间的滑动窗口，每秒一个桶
+type timeWindow struct {
+	buckets []bucket
+}
+
+func (w *timeWindow) record(now time.Time, failed bool) {
+	sec := now.Unix()
+	b := &w.buckets[sec%int64(len(w.buckets))]
+	if b.second != sec {
+		*b = bucket{second: sec}
+	}
+	b.calls++
+	if failed {
+		b.failures++
+	}
+}
+
+func (w *timeWindow) totals(now time.Time) (int, int) {
+	sec := now.Unix()
+	size := int64(len(w.buckets))
+	calls, failures := 0, 0
+	for _, b := range w.buckets {
+		if b.calls > 0 && sec-b.second < size {
+			calls += b.calls
+			failures += b.failures
+		}
+	}
+	return calls, failures
+}
+
+func (w *timeWindow) reset() {
+	for i := range w.buckets {
+		w.buckets[i] = bucket{}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -15,8 +16,14 @@ import (
 
 // Config 配置管理器
 type Config struct {
-	viper *viper.Viper
-	env   string
+	mu          sync.RWMutex
+	viper       *viper.Viper
+	env         string
+	envDir      string
+	serviceName string
+
+	watchOnce sync.Once
+	callbacks []func()
 }
 
 // New 创建配置管理器
@@ -34,16 +41,6 @@ func New() *Config {
 	// 忽略错误，允许不提供 .env 文件
 	_ = godotenv.Load()
 
-	v := viper.New()
-
-	// 设置环境变量前缀
-	v.SetEnvPrefix("GOEASE")
-	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
-	v.AutomaticEnv()
-
-	// 设置默认值
-	setDefaults(v)
-
 	// 优先从环境变量获取环境名称，如果没有则使用默认值
 	env := os.Getenv("GOEASE_APP_ENV")
 	if env == "" {
@@ -71,29 +68,51 @@ func New() *Config {
 		panic(fmt.Errorf("config file not found: %s, please set GOEASE_APP_ENV environment variable (dev/test/prod)", baseConfigPath))
 	}
 
+	v, err := load(envDir, serviceName)
+	if err != nil {
+		panic(err)
+	}
+
+	// 更新环境变量（从配置文件读取）
+	if v.IsSet("app.env") {
+		env = v.GetString("app.env")
+	}
+
+	return &Config{
+		viper:       v,
+		env:         env,
+		envDir:      envDir,
+		serviceName: serviceName,
+	}
+}
+
+// load 从环境目录加载完整配置
+func load(envDir, serviceName string) (*viper.Viper, error) {
+	v := viper.New()
+
+	// 设置环境变量前缀
+	v.SetEnvPrefix("GOEASE")
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	// 设置默认值
+	setDefaults(v)
+
 	// 1. 读取基础配置（base.yaml 必须存在且最先加载）
 	v.SetConfigName("base")
 	v.SetConfigType("yaml")
 	v.AddConfigPath(envDir)
 	if err := v.ReadInConfig(); err != nil {
-		panic(fmt.Errorf("fatal error reading base config: %w", err))
+		return nil, fmt.Errorf("fatal error reading base config: %w", err)
 	}
 
 	// 2. 自动加载环境目录下的所有其他 YAML 配置文件
 	// 这样添加新配置文件时，无需修改代码，只需创建文件即可
 	if err := loadConfigFiles(v, envDir, serviceName); err != nil {
-		panic(fmt.Errorf("fatal error loading config files: %w", err))
+		return nil, fmt.Errorf("fatal error loading config files: %w", err)
 	}
 
-	// 更新环境变量（从配置文件读取）
-	if v.IsSet("app.env") {
-		env = v.GetString("app.env")
-	}
-
-	return &Config{
-		viper: v,
-		env:   env,
-	}
+	return v, nil
 }
 
 // setDefaults 设置默认值
@@ -164,6 +183,8 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("rate_limit.rps", 100)
 	v.SetDefault("rate_limit.burst", 200)
 	v.SetDefault("rate_limit.window", "1m")
+	v.SetDefault("rate_limit.type", "token_bucket")
+	v.SetDefault("rate_limit.fail_mode", "open")
 
 	// CORS配置
 	v.SetDefault("cors.enabled", true)
@@ -184,6 +205,13 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("gateway.timeout", "30s")
 	v.SetDefault("gateway.retry_count", 3)
 	v.SetDefault("gateway.retry_delay", "1s")
+	v.SetDefault("gateway.transport.dial_timeout", "5s")
+	v.SetDefault("gateway.transport.keep_alive", "30s")
+	v.SetDefault("gateway.transport.tls_handshake_timeout", "5s")
+	v.SetDefault("gateway.transport.idle_conn_timeout", "90s")
+	v.SetDefault("gateway.transport.max_idle_conns", 512)
+	v.SetDefault("gateway.transport.max_idle_conns_per_host", 64)
+	v.SetDefault("gateway.transport.max_conns_per_host", 0)
 }
 
 // loadConfigFiles 自动加载环境目录下的所有 YAML 配置文件
@@ -251,54 +279,61 @@ func loadConfigFiles(v *viper.Viper, envDir string, serviceName string) error {
 	return nil
 }
 
+// v 获取当前生效的 viper 实例（热更新时会整体替换）
+func (c *Config) v() *viper.Viper {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.viper
+}
+
 // GetString 获取字符串配置
 func (c *Config) GetString(key string) string {
-	return c.viper.GetString(key)
+	return c.v().GetString(key)
 }
 
 // GetInt 获取整数配置
 func (c *Config) GetInt(key string) int {
-	return c.viper.GetInt(key)
+	return c.v().GetInt(key)
 }
 
 // GetInt64 获取64位整数配置
 func (c *Config) GetInt64(key string) int64 {
-	return c.viper.GetInt64(key)
+	return c.v().GetInt64(key)
 }
 
 // GetBool 获取布尔配置
 func (c *Config) GetBool(key string) bool {
-	return c.viper.GetBool(key)
+	return c.v().GetBool(key)
 }
 
 // GetDuration 获取时间配置
 func (c *Config) GetDuration(key string) time.Duration {
-	return c.viper.GetDuration(key)
+	return c.v().GetDuration(key)
 }
 
 // GetStringSlice 获取字符串切片配置
 func (c *Config) GetStringSlice(key string) []string {
-	return c.viper.GetStringSlice(key)
+	return c.v().GetStringSlice(key)
 }
 
 // Get 获取任意类型配置
 func (c *Config) Get(key string) interface{} {
-	return c.viper.Get(key)
+	return c.v().Get(key)
 }
 
 // Set 设置配置
 func (c *Config) Set(key string, value interface{}) {
-	c.viper.Set(key, value)
+	c.v().Set(key, value)
 }
 
 // IsSet 检查配置是否设置
 func (c *Config) IsSet(key string) bool {
-	return c.viper.IsSet(key)
+	return c.v().IsSet(key)
 }
 
 // AllSettings 获取所有配置
 func (c *Config) AllSettings() map[string]interface{} {
-	return c.viper.AllSettings()
+	return c.v().AllSettings()
 }
 
 // GetEnv 获取环境
@@ -323,19 +358,81 @@ func (c *Config) IsTest() bool {
 
 // GetConfigFile 获取配置文件路径
 func (c *Config) GetConfigFile() string {
-	return c.viper.ConfigFileUsed()
+	return c.v().ConfigFileUsed()
 }
 
-// WatchConfig 监听配置文件变化
+// WatchConfig 监听配置目录变化
+// 环境目录下任一 YAML 文件变化时重新加载全部配置（base.yaml + 其他文件），
+// 加载成功后整体替换并依次触发 OnConfigChange 回调；加载失败时保留旧配置
 func (c *Config) WatchConfig() {
-	c.viper.WatchConfig()
+	c.watchOnce.Do(func() {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "config: failed to create watcher: %v\n", err)
+			return
+		}
+		if err := watcher.Add(c.envDir); err != nil {
+			fmt.Fprintf(os.Stderr, "config: failed to watch %s: %v\n", c.envDir, err)
+			watcher.Close()
+			return
+		}
+		go c.watchLoop(watcher)
+	})
+}
+
+// watchLoop 处理文件事件，编辑器保存时通常会产生多个事件，合并后再重新加载
+func (c *Config) watchLoop(watcher *fsnotify.Watcher) {
+	defer watcher.Close()
+
+	var timer *time.Timer
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if !strings.HasSuffix(event.Name, ".yaml") && !strings.HasSuffix(event.Name, ".yml") {
+				continue
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) == 0 {
+				continue
+			}
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.AfterFunc(200*time.Millisecond, c.reload)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(os.Stderr, "config: watcher error: %v\n", err)
+		}
+	}
+}
+
+// reload 重新加载配置并通知监听者
+func (c *Config) reload() {
+	v, err := load(c.envDir, c.serviceName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config: reload failed, keeping previous config: %v\n", err)
+		return
+	}
+
+	c.mu.Lock()
+	c.viper = v
+	callbacks := append([]func(){}, c.callbacks...)
+	c.mu.Unlock()
+
+	for _, fn := range callbacks {
+		fn()
+	}
 }
 
 // OnConfigChange 配置文件变化回调
 func (c *Config) OnConfigChange(fn func()) {
-	c.viper.OnConfigChange(func(e fsnotify.Event) {
-		fn()
-	})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.callbacks = append(c.callbacks, fn)
 }
 
 // SaveConfig 保存配置到文件
@@ -351,17 +448,17 @@ func (c *Config) SaveConfig() error {
 		return err
 	}
 
-	return c.viper.WriteConfigAs(configFile)
+	return c.v().WriteConfigAs(configFile)
 }
 
 // Unmarshal 解析配置到结构体
 func (c *Config) Unmarshal(key string, rawVal interface{}) error {
-	return c.viper.UnmarshalKey(key, rawVal)
+	return c.v().UnmarshalKey(key, rawVal)
 }
 
 // UnmarshalAll 解析所有配置到结构体
 func (c *Config) UnmarshalAll(rawVal interface{}) error {
-	return c.viper.Unmarshal(rawVal)
+	return c.v().Unmarshal(rawVal)
 }
 
 // DatabaseConfig 数据库配置
@@ -7,10 +7,14 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	neturl "net/url"
+	"strings"
 	"time"
 
+	"goweb/pkg/circuit"
 	"goweb/pkg/config"
 	"goweb/pkg/logger"
+	"goweb/pkg/resilience"
 )
 
 // Client Gateway 客户端
@@ -19,8 +23,15 @@ type Client struct {
 	httpClient *http.Client
 	logger     logger.Logger
 	timeout    time.Duration
+	breakers   *circuit.BreakerManager // 每个后端服务一个熔断器
+	fallback   FallbackFunc
+	pipeline   *resilience.Pipeline // 重试、超时、舱壁等容错策略
+	signer     *Signer              // 请求签名，为空时不签名
 }
 
+// FallbackFunc 熔断时的降级处理，err 为熔断器返回的错误
+type FallbackFunc func(ctx context.Context, req *Request, err error) (*Response, error)
+
 // NewClient 创建 Gateway 客户端
 func NewClient(cfg *config.Config, log logger.Logger) *Client {
 	timeout := cfg.GetDuration("gateway.timeout")
@@ -33,13 +44,37 @@ func NewClient(cfg *config.Config, log logger.Logger) *Client {
 		httpClient: &http.Client{
 			Timeout: timeout,
 		},
-		logger:  log,
-		timeout: timeout,
+		logger:   log,
+		timeout:  timeout,
+		breakers: circuit.NewBreakerManager(log),
 	}
 }
 
+// Breakers 熔断器管理器，可用于注册监控指标和管理接口
+func (c *Client) Breakers() *circuit.BreakerManager {
+	return c.breakers
+}
+
+// SetPipeline 设置容错策略，每次调用（含重试）都会经过熔断器
+// 调用会按 HTTP 方法标记幂等性，POST 等非幂等请求默认不重试
+func (c *Client) SetPipeline(p *resilience.Pipeline) {
+	c.pipeline = p
+}
+
+// SetFallback 设置熔断时的降级处理，未设置时直接返回熔断错误
+func (c *Client) SetFallback(fn FallbackFunc) {
+	c.fallback = fn
+}
+
+// SetSigner 设置请求签名，用于调用需要签名的开放接口
+func (c *Client) SetSigner(s *Signer) {
+	c.signer = s
+}
+
 // Request 请求结构
 type Request struct {
+	BaseURL string // 请求地址，为空时使用 gateway.base_url（服务发现时为解析到的实例地址）
+	Service string // 熔断器名称，为空时按路径中的服务名划分
 	Method  string
 	Path    string
 	Headers map[string]string
@@ -53,30 +88,106 @@ type Response struct {
 	Message string      `json:"message"`
 	Data    interface{} `json:"data"`
 	TraceID string      `json:"trace_id"`
+
+	StatusCode int             `json:"-"` // HTTP 状态码
+	Header     http.Header     `json:"-"` // 响应头
+	Raw        json.RawMessage `json:"-"` // 原始响应体，用于按具体类型解析 data
+}
+
+// HTTPError Gateway 返回的 HTTP 错误状态
+type HTTPError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("http error: %d, message: %s", e.StatusCode, e.Message)
+}
+
+// Retryable 5xx、408 和 429 可以重试
+func (e *HTTPError) Retryable() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusTooManyRequests
 }
 
 // Call 调用 Gateway 服务
 func (c *Client) Call(ctx context.Context, req *Request) (*Response, error) {
+	if c.pipeline == nil {
+		return c.call(ctx, req)
+	}
+
+	result, err := c.pipeline.Execute(resilience.WithMethod(ctx, req.Method), func(ctx context.Context) (interface{}, error) {
+		return c.call(ctx, req)
+	})
+	response, _ := result.(*Response)
+	return response, err
+}
+
+// call 按后端服务熔断：连接失败和 5xx 计入失败次数，熔断期间走降级处理
+func (c *Client) call(ctx context.Context, req *Request) (*Response, error) {
+	name := req.Service
+	if name == "" {
+		name = breakerName(req.Path)
+	}
+	breaker := c.breakers.GetBreaker(name)
+
+	var response *Response
+	var callErr error
+	_, err := breaker.ExecuteWithContext(ctx, func(ctx context.Context) (interface{}, error) {
+		var status int
+		response, status, callErr = c.do(ctx, req)
+		// 4xx 和调用方取消不代表后端故障
+		if callErr == nil || (status >= 400 && status < 500) || ctx.Err() != nil {
+			return nil, nil
+		}
+		return nil, callErr
+	})
+	if circuit.IsRejected(err) {
+		c.logger.Warn("circuit breaker rejected gateway call", "breaker", breaker.Name(), "method", req.Method, "path", req.Path)
+		if c.fallback != nil {
+			return c.fallback(ctx, req, err)
+		}
+		return nil, err
+	}
+	return response, callErr
+}
+
+// breakerName 按路径中的服务名划分熔断器，如 /api/v1/user/1 对应 user
+func breakerName(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) >= 3 && segments[0] == "api" && strings.HasPrefix(segments[1], "v") {
+		return segments[2]
+	}
+	return segments[0]
+}
+
+// do 发送请求，返回响应、HTTP 状态码和错误
+func (c *Client) do(ctx context.Context, req *Request) (*Response, int, error) {
 	// 构建完整URL
-	url := c.baseURL + req.Path
-	if req.Query != nil && len(req.Query) > 0 {
-		url += "?"
-		first := true
+	baseURL := c.baseURL
+	if req.BaseURL != "" {
+		baseURL = req.BaseURL
+	}
+	url := baseURL + req.Path
+	if len(req.Query) > 0 {
+		query := neturl.Values{}
 		for k, v := range req.Query {
-			if !first {
-				url += "&"
-			}
-			url += fmt.Sprintf("%s=%s", k, v)
-			first = false
+			query.Set(k, v)
+		}
+		sep := "?"
+		if strings.Contains(req.Path, "?") {
+			sep = "&"
 		}
+		url += sep + query.Encode()
 	}
 
 	// 准备请求体
 	var bodyReader io.Reader
+	var bodyBytes []byte
 	if req.Body != nil {
-		bodyBytes, err := json.Marshal(req.Body)
+		var err error
+		bodyBytes, err = json.Marshal(req.Body)
 		if err != nil {
-			return nil, fmt.Errorf("marshal request body: %w", err)
+			return nil, 0, fmt.Errorf("marshal request body: %w", err)
 		}
 		bodyReader = bytes.NewReader(bodyBytes)
 	}
@@ -84,7 +195,7 @@ func (c *Client) Call(ctx context.Context, req *Request) (*Response, error) {
 	// 创建HTTP请求
 	httpReq, err := http.NewRequestWithContext(ctx, req.Method, url, bodyReader)
 	if err != nil {
-		return nil, fmt.Errorf("create request: %w", err)
+		return nil, 0, fmt.Errorf("create request: %w", err)
 	}
 
 	// 设置请求头
@@ -103,32 +214,44 @@ func (c *Client) Call(ctx context.Context, req *Request) (*Response, error) {
 		}
 	}
 
+	// 签名放在最后，每次重试重新生成时间戳和 nonce
+	if c.signer != nil {
+		if err := c.signer.sign(httpReq, bodyBytes); err != nil {
+			return nil, 0, fmt.Errorf("sign request: %w", err)
+		}
+	}
+
 	// 发送请求
 	c.logger.Debug("calling gateway", "method", req.Method, "url", url)
 	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("send request: %w", err)
+		return nil, 0, fmt.Errorf("send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// 读取响应
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("read response: %w", err)
+		return nil, resp.StatusCode, fmt.Errorf("read response: %w", err)
 	}
 
-	// 解析响应
-	var response Response
-	if err := json.Unmarshal(respBody, &response); err != nil {
-		return nil, fmt.Errorf("unmarshal response: %w", err)
+	// 解析响应，HEAD 请求和空响应体没有统一响应格式
+	response := Response{StatusCode: resp.StatusCode, Header: resp.Header, Raw: respBody}
+	if len(bytes.TrimSpace(respBody)) > 0 && req.Method != http.MethodHead {
+		if err := json.Unmarshal(respBody, &response); err != nil {
+			if resp.StatusCode >= 400 {
+				return nil, resp.StatusCode, &HTTPError{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+			}
+			return nil, resp.StatusCode, fmt.Errorf("unmarshal response: %w", err)
+		}
 	}
 
 	// 检查HTTP状态码
 	if resp.StatusCode >= 400 {
-		return &response, fmt.Errorf("http error: %d, message: %s", resp.StatusCode, response.Message)
+		return &response, resp.StatusCode, &HTTPError{StatusCode: resp.StatusCode, Message: response.Message}
 	}
 
-	return &response, nil
+	return &response, resp.StatusCode, nil
 }
 
 // Get 发送GET请求
@@ -0,0 +1,72 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"goweb/pkg/circuit"
+	"goweb/pkg/config"
+	"goweb/pkg/logger"
+)
+
+func TestClient_BreakerOpensPerService(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		switch r.URL.Path {
+		case "/api/v1/user/1":
+			w.WriteHeader(http.StatusBadGateway)
+		case "/api/v1/user/missing":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code":404,"message":"not found"}`))
+		default:
+			w.Write([]byte(`{"code":0,"message":"success"}`))
+		}
+	}))
+	defer srv.Close()
+
+	t.Chdir("../..") // config.New 读取 configs/dev
+	cfg := config.New()
+	cfg.Set("gateway.base_url", srv.URL)
+	client := NewClient(cfg, logger.New("test", "error", "stdout", ""))
+	ctx := context.Background()
+
+	// 4xx 不计入失败
+	for i := 0; i < 10; i++ {
+		_, err := client.Get(ctx, "/api/v1/user/missing", nil, nil)
+		require.Error(t, err)
+	}
+	require.Equal(t, circuit.StateClosed, client.Breakers().GetBreaker("user").State())
+
+	// 连续 5 次 5xx 后熔断，不再请求后端
+	for i := 0; i < 5; i++ {
+		_, err := client.GetUser(ctx, "1")
+		var httpErr *HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		require.Equal(t, http.StatusBadGateway, httpErr.StatusCode)
+	}
+	sent := calls.Load()
+	_, err := client.GetUser(ctx, "1")
+	require.ErrorIs(t, err, circuit.ErrOpenState)
+	require.Equal(t, sent, calls.Load())
+
+	// 其他服务不受影响
+	_, err = client.GetOrder(ctx, "1")
+	require.NoError(t, err)
+	sent = calls.Load()
+
+	// 设置降级处理后熔断期间返回降级响应
+	client.SetFallback(func(ctx context.Context, req *Request, err error) (*Response, error) {
+		require.ErrorIs(t, err, circuit.ErrOpenState)
+		return &Response{Message: "cached user"}, nil
+	})
+	resp, err := client.GetUser(ctx, "1")
+	require.NoError(t, err)
+	require.Equal(t, "cached user", resp.Message)
+	require.Equal(t, sent, calls.Load())
+}
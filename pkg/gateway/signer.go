@@ -0,0 +1,87 @@
+package gateway
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"time"
+
+	"goweb/pkg/middleware"
+)
+
+// Signer 按开放接口签名规则为请求签名（与 middleware.VerifySignature 对应）
+// 可用于 Gateway 客户端（SetSigner），也可通过 Transport 用于调用合作方接口的任意 http.Client
+type Signer struct {
+	appID  string
+	secret string
+}
+
+// NewSigner 创建签名器
+func NewSigner(appID, secret string) *Signer {
+	return &Signer{appID: appID, secret: secret}
+}
+
+// Sign 为请求签名，会读取请求体并放回
+func (s *Signer) Sign(req *http.Request) error {
+	body, err := peekBody(req)
+	if err != nil {
+		return err
+	}
+	return s.sign(req, body)
+}
+
+// peekBody 读取请求体，优先使用 GetBody，否则读出后放回
+func peekBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return body, nil
+}
+
+func (s *Signer) sign(req *http.Request, body []byte) error {
+	return middleware.SignRequest(req, s.appID, s.secret, body, time.Now())
+}
+
+// Transport 返回自动签名的 RoundTripper，base 为空时使用 http.DefaultTransport
+// 每次发送（包括重试和重定向）都会重新生成时间戳和 nonce
+func (s *Signer) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &signingTransport{signer: s, base: base}
+}
+
+type signingTransport struct {
+	signer *Signer
+	base   http.RoundTripper
+}
+
+// RoundTrip 不能修改调用方的请求，签名在副本上进行
+func (t *signingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	clone := req.Clone(req.Context())
+	if err := t.signer.Sign(clone); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.base.RoundTrip(clone)
+}
@@ -0,0 +1,114 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"goweb/pkg/middleware"
+)
+
+type memoryNonces struct {
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+func (m *memoryNonces) Use(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.seen[key] {
+		return false, nil
+	}
+	m.seen[key] = true
+	return true, nil
+}
+
+func TestSigner_VerifiedByMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.VerifySignature(middleware.SignatureConfig{
+		Secrets: middleware.StaticSecrets{"partner": "s3cret"},
+		Nonces:  &memoryNonces{seen: map[string]bool{}},
+	}))
+	r.POST("/open/orders", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, c.GetString("app_id")+":"+string(body))
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	client := &http.Client{Transport: NewSigner("partner", "s3cret").Transport(nil)}
+	resp, err := client.Post(srv.URL+"/open/orders?b=2&a=1&a=0", "application/json", strings.NewReader(`{"id":1}`))
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.Equal(t, `partner:{"id":1}`, string(body))
+
+	// 重放同一个已签名请求
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/open/orders", strings.NewReader(`{"id":2}`))
+	require.NoError(t, NewSigner("partner", "s3cret").Sign(req))
+	replay := req.Clone(context.Background())
+	replay.Body, _ = req.GetBody()
+
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.Equal(t, `partner:{"id":2}`, string(body))
+
+	resp, err = http.DefaultClient.Do(replay)
+	require.NoError(t, err)
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.Contains(t, string(body), "重复的请求")
+
+	// 篡改请求体
+	req, _ = http.NewRequest(http.MethodPost, srv.URL+"/open/orders", strings.NewReader(`{"id":3}`))
+	require.NoError(t, NewSigner("partner", "s3cret").Sign(req))
+	req.Body = io.NopCloser(strings.NewReader(`{"id":4}`))
+	req.ContentLength = 8
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.Contains(t, string(body), "签名错误")
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errors.New("connection reset") }
+
+func TestVerifySignature_FailsClosed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	serve := func(cfg middleware.SignatureConfig, body string, reader io.Reader) string {
+		r := gin.New()
+		r.Use(middleware.VerifySignature(cfg))
+		r.POST("/open/orders", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+		req := httptest.NewRequest(http.MethodPost, "/open/orders", strings.NewReader(body))
+		require.NoError(t, NewSigner("partner", "s3cret").Sign(req))
+		if reader != nil {
+			req.Body = io.NopCloser(reader)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+	secrets := middleware.StaticSecrets{"partner": "s3cret"}
+
+	// 没有 nonce 存储时不接受签名请求
+	require.Contains(t, serve(middleware.SignatureConfig{Secrets: secrets}, `{}`, nil), `"code":503`)
+
+	cfg := middleware.SignatureConfig{Secrets: secrets, Nonces: &memoryNonces{seen: map[string]bool{}}, MaxBodyBytes: 8}
+	require.Contains(t, serve(cfg, `{"id":123456}`, nil), `"code":413`)
+	require.Contains(t, serve(cfg, `{"id":1}`, failingReader{}), `"code":400`)
+	require.Equal(t, "ok", serve(cfg, `{"id":1}`, nil))
+}
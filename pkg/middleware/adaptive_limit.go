@@ -0,0 +1,403 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"goweb/pkg/monitor"
+)
+
+// Priority 请求优先级，负载高时先拒绝低优先级的请求
+type Priority int
+
+const (
+	PriorityLow      Priority = iota // 批量、后台任务
+	PriorityNormal                   // 普通请求
+	PriorityHigh                     // 核心业务
+	PriorityCritical                 // 健康检查、管理接口，最后拒绝
+)
+
+// String 优先级名称
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityHigh:
+		return "high"
+	case PriorityCritical:
+		return "critical"
+	default:
+		return "normal"
+	}
+}
+
+// ParsePriority 解析优先级名称（low / normal / high / critical），为空时为 normal
+func ParsePriority(s string) (Priority, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "low":
+		return PriorityLow, nil
+	case "", "normal":
+		return PriorityNormal, nil
+	case "high":
+		return PriorityHigh, nil
+	case "critical":
+		return PriorityCritical, nil
+	default:
+		return PriorityNormal, fmt.Errorf("unknown priority %q (use low, normal, high or critical)", s)
+	}
+}
+
+// 并发限制的调整算法
+const (
+	AdaptiveAIMD     = "aimd"     // 加性增、乘性减：过载时按比例降低，并发接近上限时加一
+	AdaptiveGradient = "gradient" // 梯度：按长期延迟与短期延迟的比值调整
+)
+
+// AdaptiveLimitConfig 自适应并发限制配置
+type AdaptiveLimitConfig struct {
+	Name             string        `mapstructure:"name" json:"name"`                           // 指标中的名称
+	Algorithm        string        `mapstructure:"algorithm" json:"algorithm"`                 // aimd / gradient，默认 gradient
+	InitialLimit     int           `mapstructure:"initial_limit" json:"initial_limit"`         // 初始并发限制，默认 100
+	MinLimit         int           `mapstructure:"min_limit" json:"min_limit"`                 // 最小并发限制，默认 10
+	MaxLimit         int           `mapstructure:"max_limit" json:"max_limit"`                 // 最大并发限制，默认 1000
+	LatencyThreshold time.Duration `mapstructure:"latency_threshold" json:"latency_threshold"` // 首字节延迟超过该值（或上游返回 503/504）视为过载，默认 1s
+	BackoffRatio     float64       `mapstructure:"backoff_ratio" json:"backoff_ratio"`         // 过载时限制的缩小比例，默认 0.9
+	Tolerance        float64       `mapstructure:"tolerance" json:"tolerance"`                 // gradient：短期延迟超过长期延迟的倍数才降低限制，默认 1.5
+	Smoothing        float64       `mapstructure:"smoothing" json:"smoothing"`                 // gradient：每次调整的平滑系数，默认 0.2
+	RetryAfter       time.Duration `mapstructure:"retry_after" json:"retry_after"`             // 拒绝时的 Retry-After，默认 1s
+
+	// 各优先级可使用的并发比例（相对当前限制），默认 low 0.7、normal 1.0、high 1.25、critical 2.0
+	PriorityShares map[Priority]float64 `mapstructure:"-" json:"-"`
+}
+
+// Normalize 填充默认值
+func (c *AdaptiveLimitConfig) Normalize() error {
+	switch c.Algorithm {
+	case "":
+		c.Algorithm = AdaptiveGradient
+	case AdaptiveAIMD, AdaptiveGradient:
+	default:
+		return fmt.Errorf("unknown adaptive limit algorithm %q (use aimd or gradient)", c.Algorithm)
+	}
+	if c.Name == "" {
+		c.Name = "default"
+	}
+	if c.MinLimit <= 0 {
+		c.MinLimit = 10
+	}
+	if c.MaxLimit <= 0 {
+		c.MaxLimit = 1000
+	}
+	if c.MaxLimit < c.MinLimit {
+		return fmt.Errorf("max_limit must not be less than min_limit")
+	}
+	if c.InitialLimit <= 0 {
+		c.InitialLimit = 100
+	}
+	c.InitialLimit = min(max(c.InitialLimit, c.MinLimit), c.MaxLimit)
+	if c.LatencyThreshold <= 0 {
+		c.LatencyThreshold = time.Second
+	}
+	if c.BackoffRatio <= 0 || c.BackoffRatio >= 1 {
+		c.BackoffRatio = 0.9
+	}
+	if c.Tolerance < 1 {
+		c.Tolerance = 1.5
+	}
+	if c.Smoothing <= 0 || c.Smoothing > 1 {
+		c.Smoothing = 0.2
+	}
+	if c.RetryAfter <= 0 {
+		c.RetryAfter = time.Second
+	}
+	if c.PriorityShares == nil {
+		c.PriorityShares = map[Priority]float64{
+			PriorityLow:      0.7,
+			PriorityNormal:   1.0,
+			PriorityHigh:     1.25,
+			PriorityCritical: 2.0,
+		}
+	}
+	return nil
+}
+
+// AdaptiveLimiter 自适应并发限制
+// 统计正在处理的请求数和每个请求的延迟，按 AIMD 或梯度算法调整并发限制；
+// 超过优先级对应的并发上限时拒绝请求（503 + Retry-After），低优先级的请求先被拒绝
+type AdaptiveLimiter struct {
+	cfg      AdaptiveLimitConfig
+	inflight atomic.Int64
+	limit    atomic.Int64 // 当前并发限制（取整），供 Acquire 无锁读取
+
+	mu       sync.Mutex
+	estimate float64 // 并发限制的精确值
+	shortRTT float64 // 短期平均延迟（秒）
+	longRTT  float64 // 长期平均延迟（秒）
+
+	metrics *AdaptiveLimitMetrics
+}
+
+// NewAdaptiveLimiter 创建自适应并发限制，metrics 为 nil 时不上报指标
+func NewAdaptiveLimiter(cfg AdaptiveLimitConfig, metrics *AdaptiveLimitMetrics) (*AdaptiveLimiter, error) {
+	if err := cfg.Normalize(); err != nil {
+		return nil, err
+	}
+	l := &AdaptiveLimiter{
+		cfg:      cfg,
+		estimate: float64(cfg.InitialLimit),
+		metrics:  metrics,
+	}
+	l.limit.Store(int64(cfg.InitialLimit))
+	l.metrics.setLimit(cfg.Name, cfg.InitialLimit)
+	return l, nil
+}
+
+// Limit 当前并发限制
+func (l *AdaptiveLimiter) Limit() int {
+	return int(l.limit.Load())
+}
+
+// Inflight 正在处理的请求数
+func (l *AdaptiveLimiter) Inflight() int {
+	return int(l.inflight.Load())
+}
+
+// Acquire 获取并发名额，超过优先级对应的上限时返回 false；成功时必须调用 release 报告请求结果，
+// latency 小于 0 时只归还名额，不参与限制的调整
+func (l *AdaptiveLimiter) Acquire(p Priority) (release func(latency time.Duration, overloaded bool), ok bool) {
+	share, found := l.cfg.PriorityShares[p]
+	if !found {
+		share = 1
+	}
+	ceiling := max(int64(math.Floor(float64(l.limit.Load())*share)), 1)
+	for {
+		cur := l.inflight.Load()
+		if cur >= ceiling {
+			l.metrics.shed(l.cfg.Name, p)
+			return nil, false
+		}
+		if l.inflight.CompareAndSwap(cur, cur+1) {
+			l.metrics.setInflight(l.cfg.Name, cur+1)
+			return func(latency time.Duration, overloaded bool) {
+				n := l.inflight.Add(-1)
+				l.metrics.setInflight(l.cfg.Name, n)
+				if latency >= 0 {
+					l.update(latency, overloaded, cur+1)
+				}
+			}, true
+		}
+	}
+}
+
+// update 根据一个请求的延迟调整并发限制，inflight 为该请求开始时的并发数
+func (l *AdaptiveLimiter) update(latency time.Duration, overloaded bool, inflight int64) {
+	overloaded = overloaded || latency > l.cfg.LatencyThreshold
+	rtt := latency.Seconds()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	estimate := l.estimate
+	switch {
+	case overloaded:
+		estimate *= l.cfg.BackoffRatio
+	case l.cfg.Algorithm == AdaptiveAIMD:
+		// 并发未用到一半时说明瓶颈不在本服务，不再放大
+		if float64(inflight)*2 >= estimate {
+			estimate++
+		}
+	default:
+		if l.longRTT == 0 {
+			l.shortRTT, l.longRTT = rtt, rtt
+		}
+		l.shortRTT = l.shortRTT*0.9 + rtt*0.1
+		l.longRTT = l.longRTT*0.995 + rtt*0.005
+		// 负载下降后长期延迟跟随短期延迟回落，避免基线偏高
+		if l.longRTT > l.shortRTT*2 {
+			l.longRTT *= 0.95
+		}
+		if float64(inflight)*2 < estimate {
+			break
+		}
+		gradient := 1.0
+		if l.shortRTT > 0 {
+			gradient = max(0.5, min(1.0, l.cfg.Tolerance*l.longRTT/l.shortRTT))
+		}
+		target := estimate*gradient + math.Sqrt(estimate)
+		estimate = estimate*(1-l.cfg.Smoothing) + target*l.cfg.Smoothing
+	}
+	estimate = min(max(estimate, float64(l.cfg.MinLimit)), float64(l.cfg.MaxLimit))
+	l.estimate = estimate
+
+	if limit := int64(estimate); limit != l.limit.Load() {
+		l.limit.Store(limit)
+		l.metrics.setLimit(l.cfg.Name, int(limit))
+	}
+}
+
+// localResponseKey 标记响应由本地生成（熔断 fallback、限流器不可用等），不代表上游过载
+const localResponseKey = "adaptive_limit_local"
+
+// MarkLocalResponse 标记当前请求的响应由本地生成，自适应并发限制不把它的状态码和延迟计入统计
+func MarkLocalResponse(c *gin.Context) {
+	c.Set(localResponseKey, true)
+}
+
+// Middleware 自适应并发限制中间件，所有请求使用相同的优先级；WebSocket 升级请求不计入并发
+// 延迟取读完请求体到写出响应头的时间，流式响应和大文件上传下载不会被计为慢请求；
+// 上游返回 503/504 视为过载，MarkLocalResponse 标记的本地响应不参与调整
+func (l *AdaptiveLimiter) Middleware(priority Priority) gin.HandlerFunc {
+	retryAfter := strconv.Itoa(max(ceilSeconds(l.cfg.RetryAfter), 1))
+	return func(c *gin.Context) {
+		if c.IsWebsocket() {
+			c.Next()
+			return
+		}
+
+		release, ok := l.Acquire(priority)
+		if !ok {
+			c.Header("Retry-After", retryAfter)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+				"code":    503,
+				"message": "Server is busy, please retry later",
+			})
+			return
+		}
+
+		timing := &latencyTiming{start: time.Now().UnixNano()}
+		writer := c.Writer
+		c.Writer = &firstByteWriter{ResponseWriter: writer, timing: timing}
+		if c.Request.Body != nil && c.Request.Body != http.NoBody {
+			c.Request.Body = &bodyEOFReader{ReadCloser: c.Request.Body, timing: timing}
+		}
+		defer func() {
+			c.Writer = writer
+			if c.GetBool(localResponseKey) {
+				release(-1, false)
+				return
+			}
+			status := c.Writer.Status()
+			release(timing.latency(), status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout)
+		}()
+		c.Next()
+	}
+}
+
+// latencyTiming 记录请求开始、读完请求体和写出响应头的时间（UnixNano）
+// 请求体可能由 Transport 在其他 goroutine 中读取，因此使用原子操作
+type latencyTiming struct {
+	start     int64
+	bodyRead  atomic.Int64
+	firstByte atomic.Int64
+}
+
+// latency 从读完请求体（未读请求体时为请求开始）到写出响应头的时间，没有写出响应时到当前为止
+func (t *latencyTiming) latency() time.Duration {
+	from, to := max(t.start, t.bodyRead.Load()), t.firstByte.Load()
+	if to == 0 {
+		to = time.Now().UnixNano()
+	}
+	return time.Duration(max(to-from, 0))
+}
+
+// firstByteWriter 记录写出响应头的时间
+type firstByteWriter struct {
+	gin.ResponseWriter
+	timing *latencyTiming
+}
+
+func (w *firstByteWriter) mark() {
+	w.timing.firstByte.CompareAndSwap(0, time.Now().UnixNano())
+}
+
+func (w *firstByteWriter) WriteHeader(code int) {
+	w.mark()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *firstByteWriter) WriteHeaderNow() {
+	w.mark()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *firstByteWriter) Write(data []byte) (int, error) {
+	w.mark()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *firstByteWriter) WriteString(s string) (int, error) {
+	w.mark()
+	return w.ResponseWriter.WriteString(s)
+}
+
+// bodyEOFReader 记录读完请求体的时间
+type bodyEOFReader struct {
+	io.ReadCloser
+	timing *latencyTiming
+}
+
+func (r *bodyEOFReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if err == io.EOF {
+		r.timing.bodyRead.CompareAndSwap(0, time.Now().UnixNano())
+	}
+	return n, err
+}
+
+// AdaptiveLimitMetrics 自适应并发限制的监控指标，nil 时不记录
+type AdaptiveLimitMetrics struct {
+	service  string
+	limit    *prometheus.GaugeVec
+	inflight *prometheus.GaugeVec
+	shedded  *prometheus.CounterVec
+}
+
+// NewAdaptiveLimitMetrics 通过 monitor.Metrics 注册自适应并发限制指标
+func NewAdaptiveLimitMetrics(m *monitor.Metrics, serviceName string) *AdaptiveLimitMetrics {
+	return &AdaptiveLimitMetrics{
+		service: serviceName,
+		limit: m.CreateCustomGauge(
+			"adaptive_concurrency_limit",
+			"Current adaptive concurrency limit",
+			[]string{"name", "service"},
+		),
+		inflight: m.CreateCustomGauge(
+			"adaptive_concurrency_inflight",
+			"Number of requests currently counted by the adaptive concurrency limiter",
+			[]string{"name", "service"},
+		),
+		shedded: m.CreateCustomCounter(
+			"adaptive_concurrency_shed_total",
+			"Total number of requests shed by the adaptive concurrency limiter",
+			[]string{"name", "priority", "service"},
+		),
+	}
+}
+
+func (m *AdaptiveLimitMetrics) setLimit(name string, limit int) {
+	if m != nil {
+		m.limit.WithLabelValues(name, m.service).Set(float64(limit))
+	}
+}
+
+func (m *AdaptiveLimitMetrics) setInflight(name string, n int64) {
+	if m != nil {
+		m.inflight.WithLabelValues(name, m.service).Set(float64(n))
+	}
+}
+
+func (m *AdaptiveLimitMetrics) shed(name string, p Priority) {
+	if m != nil {
+		m.shedded.WithLabelValues(name, p.String(), m.service).Inc()
+	}
+}
@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdaptiveLimiter_ShedsLowPriorityFirst(t *testing.T) {
+	limiter, err := NewAdaptiveLimiter(AdaptiveLimitConfig{Algorithm: AdaptiveAIMD, InitialLimit: 10, MinLimit: 2, MaxLimit: 20}, nil)
+	require.NoError(t, err)
+
+	var releases []func(time.Duration, bool)
+	for i := 0; i < 7; i++ {
+		release, ok := limiter.Acquire(PriorityLow)
+		require.True(t, ok)
+		releases = append(releases, release)
+	}
+	_, ok := limiter.Acquire(PriorityLow)
+	require.False(t, ok, "low 只能使用 70% 的并发")
+	for i := 0; i < 3; i++ {
+		release, ok := limiter.Acquire(PriorityNormal)
+		require.True(t, ok)
+		releases = append(releases, release)
+	}
+	_, ok = limiter.Acquire(PriorityNormal)
+	require.False(t, ok)
+	release, ok := limiter.Acquire(PriorityCritical)
+	require.True(t, ok)
+	releases = append(releases, release)
+	require.Equal(t, 11, limiter.Inflight())
+
+	// 过载（超过延迟阈值或上游 503/504）时乘性减
+	releases[0](2*time.Second, false)
+	require.Equal(t, 9, limiter.Limit())
+	releases[1](10*time.Millisecond, true)
+	require.Equal(t, 8, limiter.Limit())
+	// 开始时并发不到限制的一半，不增加
+	releases[2](10*time.Millisecond, false)
+	require.Equal(t, 8, limiter.Limit())
+	// 并发较高时加性增
+	releases[10](10*time.Millisecond, false)
+	require.Equal(t, 9, limiter.Limit())
+	for _, release := range releases[3:10] {
+		release(10*time.Millisecond, false)
+	}
+	require.Equal(t, 0, limiter.Inflight())
+}
+
+func TestAdaptiveLimiter_Middleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter, err := NewAdaptiveLimiter(AdaptiveLimitConfig{InitialLimit: 1, MinLimit: 1, MaxLimit: 1, RetryAfter: 3 * time.Second}, nil)
+	require.NoError(t, err)
+
+	release, ok := limiter.Acquire(PriorityNormal)
+	require.True(t, ok)
+	defer release(0, false)
+
+	r := gin.New()
+	r.GET("/orders", limiter.Middleware(PriorityNormal), func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/healthz", limiter.Middleware(PriorityCritical), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders", nil))
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, "3", w.Header().Get("Retry-After"))
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, 1, limiter.Inflight())
+}
+
+// slowBody 第一次读取前等待，模拟慢速上传
+type slowBody struct {
+	delay time.Duration
+	data  []byte
+}
+
+func (b *slowBody) Read(p []byte) (int, error) {
+	if b.delay > 0 {
+		time.Sleep(b.delay)
+		b.delay = 0
+	}
+	if len(b.data) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, b.data)
+	b.data = b.data[n:]
+	return n, nil
+}
+
+func TestAdaptiveLimiter_MiddlewareLatency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter, err := NewAdaptiveLimiter(AdaptiveLimitConfig{
+		Algorithm: AdaptiveAIMD, InitialLimit: 10, MinLimit: 1, MaxLimit: 10, LatencyThreshold: 50 * time.Millisecond,
+	}, nil)
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.Use(limiter.Middleware(PriorityNormal))
+	r.GET("/slow", func(c *gin.Context) {
+		time.Sleep(100 * time.Millisecond)
+		c.Status(http.StatusOK)
+	})
+	r.GET("/stream", func(c *gin.Context) {
+		c.Writer.WriteHeader(http.StatusOK)
+		c.Writer.Flush()
+		time.Sleep(100 * time.Millisecond)
+		c.Writer.Write([]byte("data: done\n\n"))
+	})
+	r.POST("/upload", func(c *gin.Context) {
+		io.Copy(io.Discard, c.Request.Body)
+		c.Status(http.StatusOK)
+	})
+	r.GET("/fallback", func(c *gin.Context) {
+		MarkLocalResponse(c)
+		c.Status(http.StatusServiceUnavailable)
+	})
+	r.GET("/overloaded", func(c *gin.Context) { c.Status(http.StatusServiceUnavailable) })
+	do := func(method, path string, body io.Reader) {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, path, body))
+	}
+
+	// 流式响应按首字节计时，慢速上传不计读取请求体的时间，本地生成的 503 不计入
+	do(http.MethodGet, "/stream", nil)
+	do(http.MethodPost, "/upload", &slowBody{delay: 100 * time.Millisecond, data: []byte("file")})
+	do(http.MethodGet, "/fallback", nil)
+	require.Equal(t, 10, limiter.Limit())
+	require.Equal(t, 0, limiter.Inflight())
+
+	// 首字节前超过延迟阈值或上游返回 503 视为过载
+	do(http.MethodGet, "/slow", nil)
+	require.Equal(t, 9, limiter.Limit())
+	do(http.MethodGet, "/overloaded", nil)
+	require.Equal(t, 8, limiter.Limit())
+}
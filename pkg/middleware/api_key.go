@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"goweb/pkg/apikey"
+	"goweb/pkg/response"
+)
+
+// APIKeyHeader 商户服务端调用时携带 API Key 的请求头
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyConfig API Key 认证配置
+type APIKeyConfig struct {
+	Store    apikey.Store
+	Quota    *apikey.Quota                   // 调用配额，为空时不限额
+	FailMode string                          // 配额计数出错（Redis 不可用）时的处理方式，见 RateLimitFailOpen，默认 open
+	OnError  func(c *gin.Context, err error) // 配额计数出错时回调，用于记录日志和指标
+}
+
+// APIKeyAuth API Key 认证中间件
+// 校验密钥有效期和路由授权范围，按每日/每月配额计数
+// 认证成功后将密钥所属商户存储为 api_key_owner_id（与登录用户的 user_id 区分，不能冒充用户），
+// 并从请求中删除密钥，避免转发给后端服务
+func APIKeyAuth(config APIKeyConfig) gin.HandlerFunc {
+	store, quota := config.Store, config.Quota
+	return func(c *gin.Context) {
+		plain := c.GetHeader(APIKeyHeader)
+		if plain == "" {
+			response.Unauthorized(c, "缺少API Key")
+			c.Abort()
+			return
+		}
+
+		key, err := store.Lookup(c.Request.Context(), apikey.Hash(plain))
+		if err != nil {
+			if errors.Is(err, apikey.ErrNotFound) {
+				response.Unauthorized(c, "API Key无效")
+			} else {
+				response.InternalError(c, "API Key校验失败")
+			}
+			c.Abort()
+			return
+		}
+
+		now := time.Now()
+		if !key.Active(now) {
+			response.Unauthorized(c, "API Key已过期或已吊销")
+			c.Abort()
+			return
+		}
+		if !key.Allows(c.Request.Method, c.Request.URL.Path) {
+			response.Forbidden(c, "API Key无权访问该接口")
+			c.Abort()
+			return
+		}
+
+		if quota != nil {
+			usage, err := quota.Consume(c.Request.Context(), key, now)
+			switch {
+			case errors.Is(err, apikey.ErrDailyQuotaExceeded):
+				c.Header("Retry-After", strconv.Itoa(secondsUntil(nextDay(now), now)))
+				response.Error(c, http.StatusTooManyRequests, "API Key今日调用次数已用完")
+				c.Abort()
+				return
+			case errors.Is(err, apikey.ErrMonthlyQuotaExceeded):
+				c.Header("Retry-After", strconv.Itoa(secondsUntil(nextMonth(now), now)))
+				response.Error(c, http.StatusTooManyRequests, "API Key本月调用次数已用完")
+				c.Abort()
+				return
+			case err != nil:
+				if config.OnError != nil {
+					config.OnError(c, err)
+				}
+				if config.FailMode == RateLimitFailClosed {
+					MarkLocalResponse(c)
+					c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+						"code":    503,
+						"message": "API Key quota unavailable",
+					})
+					return
+				}
+				// 放行但不计数
+			default:
+				setQuotaHeaders(c, usage)
+			}
+		}
+
+		c.Request.Header.Del(APIKeyHeader)
+		c.Set("api_key_owner_id", key.OwnerID)
+		c.Set("api_key_id", key.ID)
+		c.Set("api_key_scopes", key.ScopeList())
+		c.Next()
+	}
+}
+
+// setQuotaHeaders 返回剩余配额
+func setQuotaHeaders(c *gin.Context, usage apikey.Usage) {
+	if usage.DailyQuota > 0 {
+		c.Header("X-Quota-Daily-Remaining", strconv.FormatInt(max(usage.DailyQuota-usage.Daily, 0), 10))
+	}
+	if usage.MonthlyQuota > 0 {
+		c.Header("X-Quota-Monthly-Remaining", strconv.FormatInt(max(usage.MonthlyQuota-usage.Monthly, 0), 10))
+	}
+}
+
+func nextDay(now time.Time) time.Time {
+	t := now.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+}
+
+func nextMonth(now time.Time) time.Time {
+	t := now.UTC()
+	return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func secondsUntil(t, now time.Time) int {
+	return int(t.Sub(now).Seconds()) + 1
+}
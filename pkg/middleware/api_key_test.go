@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"goweb/pkg/apikey"
+	"goweb/pkg/config"
+	"goweb/pkg/logger"
+	pkgRedis "goweb/pkg/redis"
+)
+
+type memoryKeys map[string]*apikey.Key
+
+func (m memoryKeys) Lookup(ctx context.Context, hash string) (*apikey.Key, error) {
+	if key, ok := m[hash]; ok {
+		return key, nil
+	}
+	return nil, apikey.ErrNotFound
+}
+
+func TestAPIKeyAuth_SeparatePrincipalAndQuotaFailMode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// 端口 1 上没有 Redis，配额计数失败
+	unreachable := pkgRedis.NewClient(&config.RedisConfig{
+		Enabled: true, Host: "127.0.0.1", Port: 1, DialTimeout: 100 * time.Millisecond,
+	}, logger.New("test", "error", "stdout", ""))
+	defer unreachable.Close()
+	store := memoryKeys{apikey.Hash("gf_secret"): {ID: 7, OwnerID: "42", Scopes: "GET /orders", DailyQuota: 100}}
+
+	const secret = "identity-secret"
+	backend := gin.New()
+	backend.Use(GatewayIdentity(secret))
+	backend.GET("/orders", func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString("user_id")+"|"+c.GetString("api_key_owner_id")+"|"+c.GetString("api_key_id"))
+	})
+
+	serve := func(failMode string) (*httptest.ResponseRecorder, int) {
+		var errs int
+		r := gin.New()
+		r.Use(APIKeyAuth(APIKeyConfig{
+			Store:    store,
+			Quota:    apikey.NewQuota(unreachable),
+			FailMode: failMode,
+			OnError:  func(c *gin.Context, err error) { errs++ },
+		}))
+		r.GET("/orders", func(c *gin.Context) {
+			// 模拟网关转发：签名身份请求头后交给后端服务
+			require.Empty(t, c.GetString("user_id"))
+			h := http.Header{}
+			SignIdentity(h, secret, Identity{
+				APIKeyOwner: c.GetString("api_key_owner_id"),
+				APIKeyID:    contextString(c, "api_key_id"),
+			}, http.MethodGet, "/orders", time.Now())
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			req.Header = h
+			backend.ServeHTTP(c.Writer, req)
+		})
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+		req.Header.Set(APIKeyHeader, "gf_secret")
+		r.ServeHTTP(w, req)
+		return w, errs
+	}
+
+	// 放行时后端只能看到 API Key 调用方，不会被当作 user_id 为 42 的用户
+	w, errs := serve(RateLimitFailOpen)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "|42|7", w.Body.String())
+	require.Equal(t, 1, errs)
+
+	w, errs = serve(RateLimitFailClosed)
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, 1, errs)
+
+	// API Key 调用方不能通过 GatewayAuth（要求登录用户）
+	auth := gin.New()
+	auth.Use(GatewayAuth(secret))
+	auth.GET("/orders", func(c *gin.Context) { c.Status(http.StatusOK) })
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	SignIdentity(req.Header, secret, Identity{APIKeyOwner: "42", APIKeyID: "7"}, http.MethodGet, "/orders", time.Now())
+	w = httptest.NewRecorder()
+	auth.ServeHTTP(w, req)
+	require.Contains(t, w.Body.String(), `"code":401`)
+}
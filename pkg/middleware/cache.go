@@ -2,9 +2,14 @@ package middleware
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,17 +17,21 @@ import (
 
 // CacheConfig 缓存配置
 type CacheConfig struct {
-	Duration time.Duration
-	KeyFunc  func(*gin.Context) string
-	SkipFunc func(*gin.Context) bool
+	Duration    time.Duration
+	KeyFunc     func(*gin.Context) string
+	SkipFunc    func(*gin.Context) bool
+	Store       CacheStore                  // 缓存存储，为空时使用该中间件独享的 LRUCacheStore
+	TagFunc     func(*gin.Context) []string // 缓存条目的标签，处理函数中也可以用 SetCacheTags 添加
+	MaxBodySize int64                       // 可缓存的最大响应体，默认 1MB
 }
 
 // DefaultCacheConfig 默认缓存配置
 func DefaultCacheConfig() *CacheConfig {
 	return &CacheConfig{
-		Duration: 5 * time.Minute,
-		KeyFunc:  defaultCacheKeyFunc,
-		SkipFunc: func(c *gin.Context) bool { return false },
+		Duration:    5 * time.Minute,
+		KeyFunc:     defaultCacheKeyFunc,
+		SkipFunc:    func(c *gin.Context) bool { return false },
+		MaxBodySize: 1 << 20,
 	}
 }
 
@@ -33,131 +42,242 @@ func defaultCacheKeyFunc(c *gin.Context) string {
 }
 
 // CacheEntry 缓存条目
+// Vary 不为空时该条目只记录响应的 Vary 请求头，实际响应按这些请求头的取值保存在各自的变体键下
 type CacheEntry struct {
-	Data      []byte      `json:"data"`
-	Headers   http.Header `json:"headers"`
-	Status    int         `json:"status"`
+	Data      []byte      `json:"data,omitempty"`
+	Headers   http.Header `json:"headers,omitempty"`
+	Status    int         `json:"status,omitempty"`
 	Timestamp time.Time   `json:"timestamp"`
+	Tags      []string    `json:"tags,omitempty"`
+	Vary      []string    `json:"vary,omitempty"`
 }
 
-// MemoryCache 内存缓存
-type MemoryCache struct {
-	entries map[string]*CacheEntry
-}
-
-// NewMemoryCache 创建内存缓存
-func NewMemoryCache() *MemoryCache {
-	return &MemoryCache{
-		entries: make(map[string]*CacheEntry),
+// size 估算条目占用的内存
+func (e *CacheEntry) size() int64 {
+	n := int64(len(e.Data)) + 64
+	for k, v := range e.Headers {
+		n += int64(len(k))
+		for _, s := range v {
+			n += int64(len(s))
+		}
 	}
-}
-
-// Get 获取缓存
-func (c *MemoryCache) Get(key string) (*CacheEntry, bool) {
-	entry, exists := c.entries[key]
-	if !exists {
-		return nil, false
+	for _, t := range e.Tags {
+		n += int64(len(t))
 	}
-	return entry, true
+	return n
 }
 
-// Set 设置缓存
-func (c *MemoryCache) Set(key string, entry *CacheEntry) {
-	c.entries[key] = entry
-}
+// cacheTagsKey 处理函数添加的缓存标签在上下文中的键
+const cacheTagsKey = "cache_tags"
 
-// Delete 删除缓存
-func (c *MemoryCache) Delete(key string) {
-	delete(c.entries, key)
+// SetCacheTags 为当前请求的响应添加缓存标签（如 articles、user:42），写操作后可按标签失效
+func SetCacheTags(c *gin.Context, tags ...string) {
+	existing, _ := c.Get(cacheTagsKey)
+	list, _ := existing.([]string)
+	c.Set(cacheTagsKey, append(list, tags...))
 }
 
-// Clear 清空缓存
-func (c *MemoryCache) Clear() {
-	c.entries = make(map[string]*CacheEntry)
+// 不随缓存保存的响应头
+var uncachedHeaders = map[string]bool{
+	"Connection":        true,
+	"Keep-Alive":        true,
+	"Transfer-Encoding": true,
+	"Date":              true,
+	"Set-Cookie":        true,
+	"X-Cache":           true,
+	"X-Request-Id":      true,
 }
 
-// 全局内存缓存实例
-var globalCache = NewMemoryCache()
-
-// Cache 缓存中间件
+// Cache 缓存中间件，只缓存 GET 请求的 2xx 响应
+// 保留原始的 Content-Type 和响应头；响应带 Set-Cookie、Cache-Control: no-store/private 或 Vary: * 时不缓存，
+// 其他 Vary 请求头的取值参与缓存键
 func Cache(config *CacheConfig) gin.HandlerFunc {
 	if config == nil {
 		config = DefaultCacheConfig()
 	}
+	store := config.Store
+	if store == nil {
+		store = NewLRUCacheStore(0, 0)
+	}
+	maxBody := config.MaxBodySize
+	if maxBody <= 0 {
+		maxBody = 1 << 20
+	}
 
 	return func(c *gin.Context) {
 		// 检查是否跳过缓存
-		if config.SkipFunc(c) {
+		if c.Request.Method != http.MethodGet || (config.SkipFunc != nil && config.SkipFunc(c)) {
 			c.Next()
 			return
 		}
 
 		// 生成缓存键
 		key := config.KeyFunc(c)
+		ctx := c.Request.Context()
 
-		// 尝试从缓存获取
-		if entry, exists := globalCache.Get(key); exists {
-			// 检查是否过期
-			if time.Since(entry.Timestamp) < config.Duration {
-				// 设置响应头
-				for k, v := range entry.Headers {
-					for _, val := range v {
-						c.Header(k, val)
-					}
-				}
-				c.Header("X-Cache", "HIT")
-				c.Data(entry.Status, "application/json", entry.Data)
-				c.Abort()
-				return
+		// 尝试从缓存获取，Vary 响应再按请求头取值查找变体
+		entry, err := store.Get(ctx, key)
+		if err == nil && entry != nil && len(entry.Vary) > 0 {
+			entry, err = store.Get(ctx, varyKey(key, entry.Vary, c.Request.Header))
+		}
+		if err == nil && entry != nil {
+			// 设置响应头
+			h := c.Writer.Header()
+			for k, v := range entry.Headers {
+				h[k] = v
 			}
+			c.Header("X-Cache", "HIT")
+			c.Status(entry.Status)
+			c.Writer.Write(entry.Data)
+			c.Abort()
+			return
 		}
 
 		// 使用自定义响应写入器
 		writer := &responseWriter{
 			ResponseWriter: c.Writer,
 			body:           &bytes.Buffer{},
+			limit:          maxBody,
 		}
 		c.Writer = writer
+		c.Header("X-Cache", "MISS")
 
 		c.Next()
+		c.Writer = writer.ResponseWriter
 
 		// 只缓存成功的响应
-		if writer.Status() >= 200 && writer.Status() < 300 {
-			// 创建缓存条目
-			entry := &CacheEntry{
-				Data:      writer.body.Bytes(),
-				Headers:   writer.Header().Clone(),
-				Status:    writer.Status(),
-				Timestamp: time.Now(),
+		status := writer.Status()
+		if status < 200 || status >= 300 || writer.overflow {
+			return
+		}
+		headers, vary, ok := cacheableHeaders(writer.Header())
+		if !ok {
+			return
+		}
+
+		// 创建缓存条目
+		entry = &CacheEntry{
+			Data:      writer.body.Bytes(),
+			Headers:   headers,
+			Status:    status,
+			Timestamp: time.Now(),
+		}
+		if config.TagFunc != nil {
+			entry.Tags = append(entry.Tags, config.TagFunc(c)...)
+		}
+		if tags, ok := c.Get(cacheTagsKey); ok {
+			entry.Tags = append(entry.Tags, tags.([]string)...)
+		}
+
+		// 存储到缓存
+		if len(vary) > 0 {
+			marker := &CacheEntry{Timestamp: entry.Timestamp, Tags: entry.Tags, Vary: vary}
+			if err := store.Set(ctx, key, marker, config.Duration); err != nil {
+				return
 			}
+			key = varyKey(key, vary, c.Request.Header)
+		}
+		_ = store.Set(ctx, key, entry, config.Duration)
+	}
+}
 
-			// 存储到缓存
-			globalCache.Set(key, entry)
+// cacheableHeaders 需要随缓存保存的响应头和 Vary 请求头，响应不可缓存时返回 false
+func cacheableHeaders(header http.Header) (http.Header, []string, bool) {
+	if header.Get("Set-Cookie") != "" {
+		return nil, nil, false
+	}
+	cc := strings.ToLower(header.Get("Cache-Control"))
+	if strings.Contains(cc, "no-store") || strings.Contains(cc, "private") {
+		return nil, nil, false
+	}
+
+	var vary []string
+	for _, v := range header.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "*" {
+				return nil, nil, false
+			}
+			if name != "" {
+				vary = append(vary, name)
+			}
 		}
+	}
+	sort.Strings(vary)
 
-		// 设置缓存状态头
-		c.Header("X-Cache", "MISS")
+	stored := make(http.Header, len(header))
+	for k, v := range header {
+		if !uncachedHeaders[k] {
+			stored[k] = v
+		}
+	}
+	return stored, vary, true
+}
+
+// varyKey Vary 响应的变体缓存键
+func varyKey(key string, vary []string, header http.Header) string {
+	h := sha256.New()
+	for _, name := range vary {
+		h.Write([]byte(name + "=" + strings.Join(header.Values(name), ",") + "\n"))
 	}
+	return key + "#" + hex.EncodeToString(h.Sum(nil)[:16])
 }
 
-// responseWriter 自定义响应写入器
+// responseWriter 自定义响应写入器，超过上限后不再保存响应体
 type responseWriter struct {
 	gin.ResponseWriter
-	body *bytes.Buffer
+	body     *bytes.Buffer
+	limit    int64
+	overflow bool
 }
 
 // Write 写入响应体
 func (w *responseWriter) Write(data []byte) (int, error) {
-	w.body.Write(data)
+	w.capture(data)
 	return w.ResponseWriter.Write(data)
 }
 
 // WriteString 写入字符串
 func (w *responseWriter) WriteString(s string) (int, error) {
-	w.body.WriteString(s)
+	w.capture([]byte(s))
 	return w.ResponseWriter.WriteString(s)
 }
 
+func (w *responseWriter) capture(data []byte) {
+	if w.overflow {
+		return
+	}
+	if int64(w.body.Len()+len(data)) > w.limit {
+		w.overflow = true
+		w.body.Reset()
+		return
+	}
+	w.body.Write(data)
+}
+
+// InvalidateCache 写操作成功（非 GET 请求且状态码为 2xx）后按标签失效缓存
+// tagFunc 返回需要失效的标签，处理函数也可以用 SetCacheTags 指定
+func InvalidateCache(store CacheStore, tagFunc func(*gin.Context) []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		status := c.Writer.Status()
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead || status < 200 || status >= 300 {
+			return
+		}
+		var tags []string
+		if tagFunc != nil {
+			tags = tagFunc(c)
+		}
+		if v, ok := c.Get(cacheTagsKey); ok {
+			tags = append(tags, v.([]string)...)
+		}
+		if len(tags) > 0 {
+			_ = store.InvalidateTags(context.WithoutCancel(c.Request.Context()), tags...)
+		}
+	}
+}
+
 // CacheByQuery 基于查询参数的缓存中间件
 func CacheByQuery(duration time.Duration) gin.HandlerFunc {
 	return Cache(&CacheConfig{
@@ -231,10 +351,13 @@ func ETag() gin.HandlerFunc {
 	}
 }
 
-// ClearCache 清空缓存中间件
-func ClearCache() gin.HandlerFunc {
+// ClearCache 清空缓存的处理函数
+func ClearCache(store CacheStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		globalCache.Clear()
+		if err := store.Clear(c.Request.Context()); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to clear cache"})
+			return
+		}
 		c.JSON(http.StatusOK, gin.H{"message": "Cache cleared"})
 	}
 }
@@ -0,0 +1,442 @@
+package middleware
+
+import (
+	"container/list"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"goweb/pkg/logger"
+	pkgRedis "goweb/pkg/redis"
+)
+
+// 进程内响应缓存的默认容量
+const (
+	DefaultCacheMaxEntries = 10000
+	DefaultCacheMaxBytes   = 64 << 20
+)
+
+// CacheStore 响应缓存存储
+type CacheStore interface {
+	// Get 获取未过期的缓存，不存在时返回 nil
+	Get(ctx context.Context, key string) (*CacheEntry, error)
+	// Set 保存缓存，ttl 后过期
+	Set(ctx context.Context, key string, entry *CacheEntry, ttl time.Duration) error
+	// Delete 删除缓存
+	Delete(ctx context.Context, keys ...string) error
+	// InvalidateTags 删除带有任一标签的缓存
+	InvalidateTags(ctx context.Context, tags ...string) error
+	// Clear 清空缓存
+	Clear(ctx context.Context) error
+}
+
+// LRUCacheStore 进程内的响应缓存，按条目数和响应体总大小限制容量，超出时淘汰最久未使用的条目
+type LRUCacheStore struct {
+	maxEntries int
+	maxBytes   int64
+
+	mu    sync.Mutex
+	bytes int64
+	items map[string]*list.Element
+	lru   *list.List                     // 最近使用的在前
+	tags  map[string]map[string]struct{} // 标签 -> 缓存键
+}
+
+type lruItem struct {
+	key       string
+	entry     *CacheEntry
+	expiresAt time.Time
+}
+
+// NewLRUCacheStore 创建进程内响应缓存，参数不大于 0 时使用默认容量
+func NewLRUCacheStore(maxEntries int, maxBytes int64) *LRUCacheStore {
+	if maxEntries <= 0 {
+		maxEntries = DefaultCacheMaxEntries
+	}
+	if maxBytes <= 0 {
+		maxBytes = DefaultCacheMaxBytes
+	}
+	return &LRUCacheStore{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		items:      make(map[string]*list.Element),
+		lru:        list.New(),
+		tags:       make(map[string]map[string]struct{}),
+	}
+}
+
+// Get 获取未过期的缓存
+func (s *LRUCacheStore) Get(ctx context.Context, key string) (*CacheEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.items[key]
+	if !ok {
+		return nil, nil
+	}
+	item := e.Value.(*lruItem)
+	if !time.Now().Before(item.expiresAt) {
+		s.remove(e)
+		return nil, nil
+	}
+	s.lru.MoveToFront(e)
+	return item.entry, nil
+}
+
+// Set 保存缓存，单个条目超过总容量时不保存
+func (s *LRUCacheStore) Set(ctx context.Context, key string, entry *CacheEntry, ttl time.Duration) error {
+	size := entry.size()
+	if size > s.maxBytes || ttl <= 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.items[key]; ok {
+		s.remove(e)
+	}
+	s.items[key] = s.lru.PushFront(&lruItem{key: key, entry: entry, expiresAt: time.Now().Add(ttl)})
+	s.bytes += size
+	for _, tag := range entry.Tags {
+		keys, ok := s.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			s.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+
+	for s.lru.Len() > s.maxEntries || s.bytes > s.maxBytes {
+		s.remove(s.lru.Back())
+	}
+	return nil
+}
+
+// Delete 删除缓存
+func (s *LRUCacheStore) Delete(ctx context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		if e, ok := s.items[key]; ok {
+			s.remove(e)
+		}
+	}
+	return nil
+}
+
+// InvalidateTags 删除带有任一标签的缓存
+func (s *LRUCacheStore) InvalidateTags(ctx context.Context, tags ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, tag := range tags {
+		for key := range s.tags[tag] {
+			if e, ok := s.items[key]; ok {
+				s.remove(e)
+			}
+		}
+		delete(s.tags, tag)
+	}
+	return nil
+}
+
+// Clear 清空缓存
+func (s *LRUCacheStore) Clear(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items = make(map[string]*list.Element)
+	s.lru.Init()
+	s.tags = make(map[string]map[string]struct{})
+	s.bytes = 0
+	return nil
+}
+
+// Len 当前缓存的条目数
+func (s *LRUCacheStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+func (s *LRUCacheStore) remove(e *list.Element) {
+	item := e.Value.(*lruItem)
+	s.lru.Remove(e)
+	delete(s.items, item.key)
+	s.bytes -= item.entry.size()
+	for _, tag := range item.entry.Tags {
+		if keys, ok := s.tags[tag]; ok {
+			delete(keys, item.key)
+			if len(keys) == 0 {
+				delete(s.tags, tag)
+			}
+		}
+	}
+}
+
+// cacheSetScript 保存缓存并登记标签，标签集合的过期时间不短于其中任一缓存
+// KEYS: 缓存键、标签集合...；ARGV: 缓存内容、过期时间（毫秒）
+var cacheSetScript = goredis.NewScript(`
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+local ttl = tonumber(ARGV[2])
+for i = 2, #KEYS do
+	redis.call('SADD', KEYS[i], KEYS[1])
+	if redis.call('PTTL', KEYS[i]) < ttl then
+		redis.call('PEXPIRE', KEYS[i], ARGV[2])
+	end
+end
+return 1
+`)
+
+// cacheInvalidateScript 删除标签集合中的缓存和标签集合本身，返回删除的缓存数
+var cacheInvalidateScript = goredis.NewScript(`
+local deleted = 0
+for i = 1, #KEYS do
+	local keys = redis.call('SMEMBERS', KEYS[i])
+	for j = 1, #keys, 500 do
+		deleted = deleted + redis.call('DEL', unpack(keys, j, math.min(j + 499, #keys)))
+	end
+	redis.call('DEL', KEYS[i])
+end
+return deleted
+`)
+
+// RedisCacheStore 基于 Redis 的响应缓存，多个实例共享
+// 键格式：<prefix>entry:<缓存键>，标签集合 <prefix>tag:<标签>
+// 标签集合和缓存键在 Lua 脚本中一起访问，Redis Cluster 下需要用 {hash tag} 前缀让它们落在同一个槽
+type RedisCacheStore struct {
+	client *pkgRedis.Client
+	prefix string
+}
+
+// NewRedisCacheStore 创建 Redis 响应缓存，prefix 为空时使用 httpcache:
+func NewRedisCacheStore(client *pkgRedis.Client, prefix string) *RedisCacheStore {
+	if prefix == "" {
+		prefix = "httpcache:"
+	}
+	return &RedisCacheStore{client: client, prefix: prefix}
+}
+
+func (s *RedisCacheStore) entryKey(key string) string {
+	return s.prefix + "entry:" + key
+}
+
+func (s *RedisCacheStore) tagKey(tag string) string {
+	return s.prefix + "tag:" + tag
+}
+
+// Get 获取缓存
+func (s *RedisCacheStore) Get(ctx context.Context, key string) (*CacheEntry, error) {
+	rdb, err := redisClient(s.client)
+	if err != nil {
+		return nil, err
+	}
+	data, err := rdb.Get(ctx, s.entryKey(key)).Bytes()
+	if errors.Is(err, goredis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entry CacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("decode cache entry %s: %w", key, err)
+	}
+	return &entry, nil
+}
+
+// Set 保存缓存并登记标签
+func (s *RedisCacheStore) Set(ctx context.Context, key string, entry *CacheEntry, ttl time.Duration) error {
+	rdb, err := redisClient(s.client)
+	if err != nil {
+		return err
+	}
+	if ttl <= 0 {
+		return nil
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	keys := []string{s.entryKey(key)}
+	for _, tag := range entry.Tags {
+		keys = append(keys, s.tagKey(tag))
+	}
+	return cacheSetScript.Run(ctx, rdb, keys, data, ttl.Milliseconds()).Err()
+}
+
+// Delete 删除缓存
+func (s *RedisCacheStore) Delete(ctx context.Context, keys ...string) error {
+	rdb, err := redisClient(s.client)
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+	entryKeys := make([]string, len(keys))
+	for i, key := range keys {
+		entryKeys[i] = s.entryKey(key)
+	}
+	return rdb.Del(ctx, entryKeys...).Err()
+}
+
+// InvalidateTags 删除带有任一标签的缓存
+func (s *RedisCacheStore) InvalidateTags(ctx context.Context, tags ...string) error {
+	rdb, err := redisClient(s.client)
+	if err != nil || len(tags) == 0 {
+		return err
+	}
+	keys := make([]string, len(tags))
+	for i, tag := range tags {
+		keys[i] = s.tagKey(tag)
+	}
+	return cacheInvalidateScript.Run(ctx, rdb, keys).Err()
+}
+
+// Clear 清空缓存（SCAN 删除前缀下的所有键）
+func (s *RedisCacheStore) Clear(ctx context.Context) error {
+	if _, err := redisClient(s.client); err != nil {
+		return err
+	}
+	_, err := pkgRedis.NewCache(s.client, s.prefix).DeletePattern(ctx, "*")
+	return err
+}
+
+// 缓存失效消息的操作
+const (
+	cacheOpDelete = "delete"
+	cacheOpTags   = "tags"
+	cacheOpClear  = "clear"
+)
+
+// cacheInvalidation 通过 Redis 发布的缓存失效消息
+type cacheInvalidation struct {
+	Node   string   `json:"node"`
+	Op     string   `json:"op"`
+	Values []string `json:"values,omitempty"`
+}
+
+// SyncedCacheStore 在本地存储（通常是 LRUCacheStore）之上，把删除、按标签失效和清空通过 Redis 发布订阅同步到其他实例
+// 订阅断开期间错过的失效消息无法补发，本地缓存最多在 TTL 后过期
+type SyncedCacheStore struct {
+	CacheStore
+	client  *pkgRedis.Client
+	channel string
+	node    string
+	logger  logger.Logger
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// NewSyncedCacheStore 创建同步失效的缓存存储并开始订阅 channel，Redis 未启用时只在本实例内失效
+func NewSyncedCacheStore(local CacheStore, client *pkgRedis.Client, channel string, log logger.Logger) *SyncedCacheStore {
+	node := make([]byte, 8)
+	_, _ = rand.Read(node)
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &SyncedCacheStore{
+		CacheStore: local,
+		client:     client,
+		channel:    channel,
+		node:       hex.EncodeToString(node),
+		logger:     log,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+	}
+	if client == nil || !client.IsEnabled() {
+		close(s.done)
+		return s
+	}
+	pubsub := client.GetClient().Subscribe(ctx, channel)
+	go s.subscribe(ctx, pubsub)
+	return s
+}
+
+// Delete 删除本地缓存并通知其他实例
+func (s *SyncedCacheStore) Delete(ctx context.Context, keys ...string) error {
+	if err := s.CacheStore.Delete(ctx, keys...); err != nil {
+		return err
+	}
+	return s.publish(ctx, cacheOpDelete, keys)
+}
+
+// InvalidateTags 按标签删除本地缓存并通知其他实例
+func (s *SyncedCacheStore) InvalidateTags(ctx context.Context, tags ...string) error {
+	if err := s.CacheStore.InvalidateTags(ctx, tags...); err != nil {
+		return err
+	}
+	return s.publish(ctx, cacheOpTags, tags)
+}
+
+// Clear 清空本地缓存并通知其他实例
+func (s *SyncedCacheStore) Clear(ctx context.Context) error {
+	if err := s.CacheStore.Clear(ctx); err != nil {
+		return err
+	}
+	return s.publish(ctx, cacheOpClear, nil)
+}
+
+// Close 停止订阅
+func (s *SyncedCacheStore) Close() error {
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+func (s *SyncedCacheStore) publish(ctx context.Context, op string, values []string) error {
+	if s.client == nil || !s.client.IsEnabled() {
+		return nil
+	}
+	msg, err := json.Marshal(cacheInvalidation{Node: s.node, Op: op, Values: values})
+	if err != nil {
+		return err
+	}
+	if err := s.client.GetClient().Publish(ctx, s.channel, msg).Err(); err != nil {
+		return fmt.Errorf("publish cache invalidation: %w", err)
+	}
+	return nil
+}
+
+// subscribe 应用其他实例发布的失效消息
+func (s *SyncedCacheStore) subscribe(ctx context.Context, pubsub *goredis.PubSub) {
+	defer close(s.done)
+	defer pubsub.Close()
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case m, ok := <-ch:
+			if !ok {
+				return
+			}
+			var msg cacheInvalidation
+			if err := json.Unmarshal([]byte(m.Payload), &msg); err != nil {
+				s.logger.Warn("invalid cache invalidation message", "channel", s.channel, "error", err)
+				continue
+			}
+			if msg.Node == s.node {
+				continue
+			}
+			var err error
+			switch msg.Op {
+			case cacheOpDelete:
+				err = s.CacheStore.Delete(ctx, msg.Values...)
+			case cacheOpTags:
+				err = s.CacheStore.InvalidateTags(ctx, msg.Values...)
+			case cacheOpClear:
+				err = s.CacheStore.Clear(ctx)
+			}
+			if err != nil {
+				s.logger.Warn("apply cache invalidation failed", "op", msg.Op, "error", err)
+			}
+		}
+	}
+}
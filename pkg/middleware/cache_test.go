@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_PreservesHeadersAndVary(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := NewLRUCacheStore(0, 0)
+	calls := 0
+
+	r := gin.New()
+	r.GET("/articles", Cache(&CacheConfig{Duration: time.Minute, KeyFunc: defaultCacheKeyFunc, Store: store}), func(c *gin.Context) {
+		calls++
+		SetCacheTags(c, "articles")
+		c.Header("Vary", "Accept-Language")
+		c.Header("X-Total", "2")
+		c.Data(http.StatusOK, "text/csv", []byte(c.GetHeader("Accept-Language")))
+	})
+	r.POST("/articles", InvalidateCache(store, func(c *gin.Context) []string { return []string{"articles"} }), func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+	do := func(method, lang string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/articles", nil)
+		req.Header.Set("Accept-Language", lang)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	require.Equal(t, "MISS", do(http.MethodGet, "en").Header().Get("X-Cache"))
+	w := do(http.MethodGet, "en")
+	require.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	require.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+	require.Equal(t, "2", w.Header().Get("X-Total"))
+	require.Equal(t, "en", w.Body.String())
+
+	// Vary 请求头取值不同时分别缓存
+	w = do(http.MethodGet, "zh")
+	require.Equal(t, "MISS", w.Header().Get("X-Cache"))
+	require.Equal(t, "zh", w.Body.String())
+	require.Equal(t, 2, calls)
+
+	// 写操作成功后按标签失效
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "en").Code)
+	require.Equal(t, "MISS", do(http.MethodGet, "en").Header().Get("X-Cache"))
+	require.Equal(t, 3, calls)
+}
+
+func TestCache_SkipsUncacheableResponses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Cache(nil))
+	r.GET("/private", func(c *gin.Context) {
+		c.Header("Cache-Control", "private")
+		c.String(http.StatusOK, "ok")
+	})
+	r.GET("/cookie", func(c *gin.Context) {
+		c.SetCookie("sid", "1", 0, "/", "", false, true)
+		c.String(http.StatusOK, "ok")
+	})
+	for _, path := range []string{"/private", "/cookie", "/private", "/cookie"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, "MISS", w.Header().Get("X-Cache"), path)
+	}
+}
+
+func TestLRUCacheStore_Bounded(t *testing.T) {
+	ctx := context.Background()
+	store := NewLRUCacheStore(2, 0)
+	for _, key := range []string{"a", "b", "c"} {
+		require.NoError(t, store.Set(ctx, key, &CacheEntry{Data: []byte(key), Tags: []string{"t:" + key}}, time.Minute))
+	}
+	require.Equal(t, 2, store.Len())
+	entry, _ := store.Get(ctx, "a")
+	require.Nil(t, entry)
+
+	require.NoError(t, store.InvalidateTags(ctx, "t:b"))
+	entry, _ = store.Get(ctx, "b")
+	require.Nil(t, entry)
+	entry, _ = store.Get(ctx, "c")
+	require.NotNil(t, entry)
+
+	// 按字节数限制
+	small := NewLRUCacheStore(0, 300)
+	for _, key := range []string{"a", "b", "c"} {
+		require.NoError(t, small.Set(ctx, key, &CacheEntry{Data: make([]byte, 100)}, time.Minute))
+	}
+	require.Equal(t, 1, small.Len())
+}
@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"goweb/pkg/response"
+)
+
+// 网关转发给后端服务的身份请求头
+const (
+	HeaderUserID            = "X-User-Id"
+	HeaderUsername          = "X-Username"
+	HeaderAPIKeyOwner       = "X-Api-Key-Owner" // API Key 所属商户，与 X-User-Id 是不同类型的调用方
+	HeaderAPIKeyID          = "X-Api-Key-Id"
+	HeaderRequestID         = "X-Request-Id"
+	HeaderIdentityTimestamp = "X-Identity-Timestamp"
+	HeaderIdentitySignature = "X-Identity-Signature"
+)
+
+// DefaultIdentityMaxSkew 身份签名的默认有效期
+const DefaultIdentityMaxSkew = 5 * time.Minute
+
+var (
+	ErrIdentityUnsigned  = errors.New("identity headers are not signed")
+	ErrIdentitySignature = errors.New("invalid identity signature")
+	ErrIdentityExpired   = errors.New("identity signature expired")
+)
+
+// Identity 网关认证后的调用方身份
+// 登录用户设置 UserID/Username，API Key 调用设置 APIKeyOwner/APIKeyID，都为空表示匿名请求
+type Identity struct {
+	UserID      string
+	Username    string
+	APIKeyOwner string
+	APIKeyID    string
+	RequestID   string
+}
+
+// Anonymous 是否为匿名请求（既没有登录用户也没有 API Key）
+func (id Identity) Anonymous() bool {
+	return id.UserID == "" && id.Username == "" && id.APIKeyOwner == "" && id.APIKeyID == ""
+}
+
+// SignIdentity 写入身份请求头并签名（网关使用）
+// 签名覆盖用户、请求ID、请求方法、路径（转义后的形式，如 URL.EscapedPath）和时间戳，
+// 截获的身份请求头不能用于其他接口；后端服务用 GatewayIdentity/GatewayAuth 校验
+func SignIdentity(h http.Header, secret string, id Identity, method, path string, now time.Time) {
+	StripIdentity(h)
+	if id.UserID != "" {
+		h.Set(HeaderUserID, id.UserID)
+	}
+	if id.Username != "" {
+		h.Set(HeaderUsername, id.Username)
+	}
+	if id.APIKeyOwner != "" {
+		h.Set(HeaderAPIKeyOwner, id.APIKeyOwner)
+	}
+	if id.APIKeyID != "" {
+		h.Set(HeaderAPIKeyID, id.APIKeyID)
+	}
+	if id.RequestID != "" {
+		h.Set(HeaderRequestID, id.RequestID)
+	}
+	ts := strconv.FormatInt(now.Unix(), 10)
+	h.Set(HeaderIdentityTimestamp, ts)
+	h.Set(HeaderIdentitySignature, identitySignature(secret, id, method, path, ts))
+}
+
+// StripIdentity 删除身份请求头，防止客户端伪造
+func StripIdentity(h http.Header) {
+	h.Del(HeaderUserID)
+	h.Del(HeaderUsername)
+	h.Del(HeaderAPIKeyOwner)
+	h.Del(HeaderAPIKeyID)
+	h.Del(HeaderIdentityTimestamp)
+	h.Del(HeaderIdentitySignature)
+}
+
+// VerifyIdentity 校验请求的身份请求头签名，签名必须与请求的方法和路径匹配
+// 没有签名且没有身份请求头时返回匿名身份
+func VerifyIdentity(r *http.Request, secret string, maxSkew time.Duration, now time.Time) (Identity, error) {
+	h := r.Header
+	id := Identity{
+		UserID:      h.Get(HeaderUserID),
+		Username:    h.Get(HeaderUsername),
+		APIKeyOwner: h.Get(HeaderAPIKeyOwner),
+		APIKeyID:    h.Get(HeaderAPIKeyID),
+		RequestID:   h.Get(HeaderRequestID),
+	}
+
+	signature := h.Get(HeaderIdentitySignature)
+	if signature == "" {
+		if !id.Anonymous() {
+			return Identity{}, ErrIdentityUnsigned
+		}
+		return Identity{RequestID: id.RequestID}, nil
+	}
+
+	ts := h.Get(HeaderIdentityTimestamp)
+	expected := identitySignature(secret, id, r.Method, r.URL.EscapedPath(), ts)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return Identity{}, ErrIdentitySignature
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return Identity{}, ErrIdentitySignature
+	}
+	skew := now.Sub(time.Unix(unix, 0))
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > maxSkew {
+		return Identity{}, ErrIdentityExpired
+	}
+	return id, nil
+}
+
+// identitySignature 计算身份签名：HMAC-SHA256(v2\n方法\n路径\n用户ID\n用户名\nAPI Key 商户\nAPI Key ID\n请求ID\n时间戳)
+func identitySignature(secret string, id Identity, method, path, ts string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strings.Join([]string{"v2", method, path, id.UserID, id.Username, id.APIKeyOwner, id.APIKeyID, id.RequestID, ts}, "\n")))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// GatewayIdentity 校验网关签名的身份请求头（后端服务使用）
+// 签名有效时将用户信息（user_id/username）或 API Key 调用方（api_key_owner_id/api_key_id）存储到上下文，
+// 匿名请求直接放行，伪造或过期的身份返回 401
+func GatewayIdentity(secret string) gin.HandlerFunc {
+	return gatewayIdentity(secret, false)
+}
+
+// GatewayAuth 与 GatewayIdentity 相同，但要求请求已登录（API Key 调用方不是登录用户），可替代 JWTAuth
+func GatewayAuth(secret string) gin.HandlerFunc {
+	return gatewayIdentity(secret, true)
+}
+
+func gatewayIdentity(secret string, required bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := VerifyIdentity(c.Request, secret, DefaultIdentityMaxSkew, time.Now())
+		if err != nil {
+			response.Unauthorized(c, "网关身份校验失败: "+err.Error())
+			c.Abort()
+			return
+		}
+
+		if id.UserID == "" && required {
+			response.Unauthorized(c, "缺少认证令牌")
+			c.Abort()
+			return
+		}
+
+		if id.UserID != "" {
+			c.Set("user_id", id.UserID)
+			c.Set("username", id.Username)
+		}
+		if id.APIKeyOwner != "" {
+			c.Set("api_key_owner_id", id.APIKeyOwner)
+			c.Set("api_key_id", id.APIKeyID)
+		}
+		c.Next()
+	}
+}
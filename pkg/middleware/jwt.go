@@ -1,7 +1,6 @@
 package middleware
 
 import (
-	"context"
 	"fmt"
 	"strings"
 
@@ -16,6 +15,7 @@ import (
 type JWTClaims struct {
 	UserID   string `json:"user_id"`
 	Username string `json:"username"`
+	Role     string `json:"role,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -34,57 +34,79 @@ func JWTAuthWithRedis(secret string, redisClient *pkgRedis.Client) gin.HandlerFu
 			return
 		}
 
-		// 检查Bearer前缀
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == authHeader {
-			response.Unauthorized(c, "认证令牌格式错误")
+		if msg := authenticate(c, authHeader, secret, redisClient); msg != "" {
+			response.Unauthorized(c, msg)
 			c.Abort()
 			return
 		}
+		c.Next()
+	}
+}
 
-		// 检查token是否在黑名单中
-		if redisClient != nil {
-			ctx := context.Background()
-			blacklistKey := fmt.Sprintf("token:blacklist:%s", tokenString)
-			exists, err := redisClient.Exists(ctx, blacklistKey)
-			if err == nil && exists {
-				response.Unauthorized(c, "认证令牌已失效，请重新登录")
-				c.Abort()
-				return
-			}
-		}
-
-		// 解析JWT
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte(secret), nil
-		})
-
-		if err != nil {
-			response.Unauthorized(c, "认证令牌解析失败: "+err.Error())
-			c.Abort()
+// OptionalJWTAuthWithRedis 可选JWT认证中间件
+// 未携带令牌时按匿名用户放行，携带了令牌则必须有效
+func OptionalJWTAuthWithRedis(secret string, redisClient *pkgRedis.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" {
+			c.Next()
 			return
 		}
 
-		if !token.Valid {
-			response.Unauthorized(c, "认证令牌无效")
+		if msg := authenticate(c, authHeader, secret, redisClient); msg != "" {
+			response.Unauthorized(c, msg)
 			c.Abort()
 			return
 		}
+		c.Next()
+	}
+}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			response.Unauthorized(c, "认证令牌解析失败")
-			c.Abort()
-			return
-		}
+// authenticate 校验令牌并将用户信息存储到上下文，失败时返回错误提示
+func authenticate(c *gin.Context, authHeader, secret string, redisClient *pkgRedis.Client) string {
+	// 检查Bearer前缀
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if tokenString == authHeader {
+		return "认证令牌格式错误"
+	}
 
-		// 将用户信息存储到上下文
-		if userID, ok := claims["user_id"].(string); ok {
-			c.Set("user_id", userID)
+	// 检查token是否在黑名单中
+	if redisClient != nil {
+		ctx := c.Request.Context()
+		blacklistKey := fmt.Sprintf("token:blacklist:%s", tokenString)
+		exists, err := redisClient.Exists(ctx, blacklistKey)
+		if err == nil && exists {
+			return "认证令牌已失效，请重新登录"
 		}
-		if username, ok := claims["username"].(string); ok {
-			c.Set("username", username)
-		}
-		c.Next()
 	}
+
+	// 解析JWT
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	}, jwt.WithValidMethods([]string{"HS256", "HS384", "HS512"}))
+
+	if err != nil {
+		return "认证令牌解析失败: " + err.Error()
+	}
+
+	if !token.Valid {
+		return "认证令牌无效"
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return "认证令牌解析失败"
+	}
+
+	// 将用户信息存储到上下文
+	if userID, ok := claims["user_id"].(string); ok {
+		c.Set("user_id", userID)
+	}
+	if username, ok := claims["username"].(string); ok {
+		c.Set("username", username)
+	}
+	if role, ok := claims["role"].(string); ok {
+		c.Set("role", role)
+	}
+	return ""
 }
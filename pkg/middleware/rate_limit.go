@@ -9,6 +9,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/time/rate"
+
+	pkgRedis "goweb/pkg/redis"
 )
 
 // RateLimiter 限流器接口
@@ -85,40 +87,82 @@ func (s *SlidingWindowLimiter) Allow(key string) bool {
 	return true
 }
 
+// 限流算法
+// token_bucket / sliding_window 的状态保存在进程内，redis_gcra / redis_sliding_window 保存在 Redis 中，多实例共享限额
+const (
+	RateLimitTokenBucket        = "token_bucket"
+	RateLimitSlidingWindow      = "sliding_window"
+	RateLimitRedisGCRA          = "redis_gcra"
+	RateLimitRedisSlidingWindow = "redis_sliding_window"
+)
+
+// Redis 不可用时的处理方式
+const (
+	RateLimitFailOpen   = "open"   // 放行请求
+	RateLimitFailClosed = "closed" // 拒绝请求（503）
+)
+
 // RateLimitConfig 限流配置
 type RateLimitConfig struct {
-	Type    string                    // "token_bucket" 或 "sliding_window"
-	RPS     int                       // 每秒请求数
-	Burst   int                       // 突发请求数
-	Window  time.Duration             // 滑动窗口大小
-	Limit   int                       // 窗口内最大请求数
-	KeyFunc func(*gin.Context) string // 限流键生成函数
-	Message string                    // 限流时的错误消息
+	Type     string                          // 限流算法，见 RateLimitTokenBucket 等，默认 token_bucket
+	RPS      int                             // 每秒请求数
+	Burst    int                             // 突发请求数
+	Window   time.Duration                   // 滑动窗口大小
+	Limit    int                             // 窗口内最大请求数，不大于 0 时按 RPS * Window 计算
+	KeyFunc  func(*gin.Context) string       // 限流键生成函数（token_bucket 为全局限流，不使用）
+	Message  string                          // 限流时的错误消息
+	Redis    *pkgRedis.Client                // redis_* 限流使用的 Redis
+	Prefix   string                          // Redis 键前缀，默认 DefaultRateLimitPrefix
+	FailMode string                          // Redis 不可用时的处理方式，默认 open
+	OnError  func(c *gin.Context, err error) // Redis 出错时回调，用于记录日志
 }
 
 // DefaultRateLimitConfig 默认限流配置
 func DefaultRateLimitConfig() *RateLimitConfig {
 	return &RateLimitConfig{
-		Type:    "token_bucket",
-		RPS:     100,
-		Burst:   200,
-		KeyFunc: func(c *gin.Context) string { return c.ClientIP() },
-		Message: "Too many requests",
+		Type:     RateLimitTokenBucket,
+		RPS:      100,
+		Burst:    200,
+		KeyFunc:  func(c *gin.Context) string { return c.ClientIP() },
+		Message:  "Too many requests",
+		FailMode: RateLimitFailOpen,
 	}
 }
 
+// windowLimit 滑动窗口内最大请求数，未设置 Limit 时按 RPS * Window 计算（至少 1）
+func (c *RateLimitConfig) windowLimit() int {
+	if c.Limit > 0 {
+		return c.Limit
+	}
+	window := c.Window
+	if window < time.Millisecond {
+		window = time.Second
+	}
+	return max(int(float64(c.RPS)*window.Seconds()), 1)
+}
+
 // RateLimit 限流中间件
 func RateLimit(config *RateLimitConfig) gin.HandlerFunc {
 	if config == nil {
 		config = DefaultRateLimitConfig()
 	}
 
+	keyFunc := config.KeyFunc
+	if keyFunc == nil {
+		keyFunc = func(c *gin.Context) string { return c.ClientIP() }
+	}
+
 	var limiter RateLimiter
 	var slidingLimiter *SlidingWindowLimiter
+	var keyedLimiter KeyedRateLimiter
 
 	switch config.Type {
-	case "sliding_window":
-		slidingLimiter = NewSlidingWindowLimiter(config.Window, config.Limit)
+	case RateLimitSlidingWindow:
+		slidingLimiter = NewSlidingWindowLimiter(config.Window, config.windowLimit())
+	case RateLimitRedisGCRA:
+		keyedLimiter = NewRedisGCRALimiter(config.Redis, config.Prefix, config.RPS, config.Burst)
+	case RateLimitRedisSlidingWindow:
+		keyedLimiter = NewRedisSlidingWindowLimiter(config.Redis, config.Prefix, config.Window, config.windowLimit())
 	default:
 		limiter = NewTokenBucketLimiter(config.RPS, config.Burst)
 	}
@@ -126,8 +170,27 @@ func RateLimit(config *RateLimitConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var allowed bool
 
-		if slidingLimiter != nil {
-			key := config.KeyFunc(c)
+		if keyedLimiter != nil {
+			result, err := keyedLimiter.Allow(c.Request.Context(), keyFunc(c))
+			if err != nil {
+				if config.OnError != nil {
+					config.OnError(c, err)
+				}
+				if config.FailMode == RateLimitFailClosed {
+					MarkLocalResponse(c)
+					c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+						"code":    503,
+						"message": "Rate limiter unavailable",
+					})
+					return
+				}
+				c.Next()
+				return
+			}
+			allowed = result.Allowed
+			setRateLimitHeaders(c, result)
+		} else if slidingLimiter != nil {
+			key := keyFunc(c)
 			allowed = slidingLimiter.Allow(key)
 		} else {
 			allowed = limiter.Allow()
@@ -146,23 +209,14 @@ func RateLimit(config *RateLimitConfig) gin.HandlerFunc {
 	}
 }
 
-// IPRateLimit IP限流中间件
+// IPRateLimit IP限流中间件，每个 IP 的限流状态在空闲后过期，最多保存 DefaultLimiterCapacity 个 IP
 func IPRateLimit(rps int, burst int) gin.HandlerFunc {
-	limiters := make(map[string]*rate.Limiter)
-	mu := sync.RWMutex{}
+	limiter := NewLocalGCRALimiter(RateLimitRule{Limit: rps, Period: time.Second, Burst: burst}, DefaultLimiterCapacity)
 
 	return func(c *gin.Context) {
-		ip := c.ClientIP()
-
-		mu.Lock()
-		limiter, exists := limiters[ip]
-		if !exists {
-			limiter = rate.NewLimiter(rate.Limit(rps), burst)
-			limiters[ip] = limiter
-		}
-		mu.Unlock()
-
-		if !limiter.Allow() {
+		result, _ := limiter.Allow(c.Request.Context(), c.ClientIP())
+		setRateLimitHeaders(c, result)
+		if !result.Allowed {
 			c.JSON(http.StatusTooManyRequests, gin.H{
 				"code":    429,
 				"message": "Too many requests from this IP",
@@ -175,10 +229,9 @@ func IPRateLimit(rps int, burst int) gin.HandlerFunc {
 	}
 }
 
-// UserRateLimit 用户限流中间件（需要JWT中间件先执行）
+// UserRateLimit 用户限流中间件（需要JWT中间件先执行），限流状态保存方式同 IPRateLimit
 func UserRateLimit(rps int, burst int) gin.HandlerFunc {
-	limiters := make(map[string]*rate.Limiter)
-	mu := sync.RWMutex{}
+	limiter := NewLocalGCRALimiter(RateLimitRule{Limit: rps, Period: time.Second, Burst: burst}, DefaultLimiterCapacity)
 
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
@@ -187,17 +240,9 @@ func UserRateLimit(rps int, burst int) gin.HandlerFunc {
 			return
 		}
 
-		userIDStr := fmt.Sprintf("%v", userID)
-
-		mu.Lock()
-		limiter, exists := limiters[userIDStr]
-		if !exists {
-			limiter = rate.NewLimiter(rate.Limit(rps), burst)
-			limiters[userIDStr] = limiter
-		}
-		mu.Unlock()
-
-		if !limiter.Allow() {
+		result, _ := limiter.Allow(c.Request.Context(), fmt.Sprintf("%v", userID))
+		setRateLimitHeaders(c, result)
+		if !result.Allowed {
 			c.JSON(http.StatusTooManyRequests, gin.H{
 				"code":    429,
 				"message": "Too many requests from this user",
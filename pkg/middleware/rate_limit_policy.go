@@ -0,0 +1,224 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	pkgRedis "goweb/pkg/redis"
+)
+
+// 限流策略的身份维度
+const (
+	PolicyKeyIP     = "ip"      // 客户端 IP
+	PolicyKeyUser   = "user"    // 登录用户 user_id，未登录的请求不限流
+	PolicyKeyAPIKey = "api_key" // API Key，未使用 API Key 的请求不限流
+	PolicyKeyRole   = "role"    // 角色，同一角色共享限额；未登录为 anonymous
+)
+
+// AnonymousRole 未登录请求的角色
+const AnonymousRole = "anonymous"
+
+// RateLimitPolicy 限流策略，匹配路由和方法的请求按身份分别计数，同时满足所有限额才放行
+type RateLimitPolicy struct {
+	Name    string          `mapstructure:"name" json:"name"`
+	Routes  []string        `mapstructure:"routes" json:"routes"`   // 路径模式：精确路径、/prefix/*（含前缀本身）或 path.Match 通配，为空匹配全部
+	Methods []string        `mapstructure:"methods" json:"methods"` // HTTP 方法，为空匹配全部
+	Key     string          `mapstructure:"key" json:"key"`         // 身份维度 ip / user / api_key / role，默认 ip
+	Roles   []string        `mapstructure:"roles" json:"roles"`     // 只对这些角色生效，为空不限
+	Limits  []RateLimitRule `mapstructure:"limits" json:"limits"`   // 多个限额，如每秒 10 次且每天 10000 次
+	Message string          `mapstructure:"message" json:"message"` // 限流时的错误消息
+}
+
+// Validate 检查策略配置
+func (p *RateLimitPolicy) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("rate limit policy name is required")
+	}
+	switch p.Key {
+	case "", PolicyKeyIP, PolicyKeyUser, PolicyKeyAPIKey, PolicyKeyRole:
+	default:
+		return fmt.Errorf("rate limit policy %s: invalid key %q", p.Name, p.Key)
+	}
+	if len(p.Limits) == 0 {
+		return fmt.Errorf("rate limit policy %s: limits is required", p.Name)
+	}
+	for _, l := range p.Limits {
+		if l.Limit <= 0 || l.Period <= 0 {
+			return fmt.Errorf("rate limit policy %s: limit and period must be positive", p.Name)
+		}
+	}
+	for _, r := range p.Routes {
+		if _, err := path.Match(r, "/"); err != nil {
+			return fmt.Errorf("rate limit policy %s: invalid route %q: %w", p.Name, r, err)
+		}
+	}
+	return nil
+}
+
+// Matches 请求方法和路径是否匹配策略
+func (p *RateLimitPolicy) Matches(method, reqPath string) bool {
+	if len(p.Methods) > 0 && !containsFold(p.Methods, method) {
+		return false
+	}
+	if len(p.Routes) == 0 {
+		return true
+	}
+	for _, pattern := range p.Routes {
+		if matchRoute(pattern, reqPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// identity 请求在策略身份维度上的标识，为空时策略不生效
+func (p *RateLimitPolicy) identity(c *gin.Context) string {
+	role := c.GetString("role")
+	if role == "" && contextString(c, "user_id") == "" && contextString(c, "api_key_owner_id") == "" {
+		role = AnonymousRole
+	}
+	if len(p.Roles) > 0 && !containsFold(p.Roles, role) {
+		return ""
+	}
+
+	switch p.Key {
+	case PolicyKeyUser:
+		return contextString(c, "user_id")
+	case PolicyKeyAPIKey:
+		return contextString(c, "api_key_id")
+	case PolicyKeyRole:
+		return role
+	default:
+		return c.ClientIP()
+	}
+}
+
+// PolicyRateLimitConfig 策略限流配置
+type PolicyRateLimitConfig struct {
+	Policies []RateLimitPolicy
+	Redis    *pkgRedis.Client                // 非空时在 Redis 中计数（GCRA），多实例共享限额；否则在进程内计数
+	Prefix   string                          // Redis 键前缀，默认 DefaultRateLimitPrefix
+	Capacity int                             // 进程内每个限额最多保存的键数，默认 DefaultLimiterCapacity
+	FailMode string                          // Redis 不可用时的处理方式，默认 open
+	OnError  func(c *gin.Context, err error) // Redis 出错时回调，用于记录日志
+}
+
+// PolicyRateLimit 按策略限流中间件，需要在认证中间件之后执行
+// 请求匹配的所有策略的所有限额同时检查，全部允许时才扣减，被拒绝的请求不消耗任何限额；
+// 响应头返回剩余额度最少的限额（X-RateLimit-*），被拒绝时返回 429 和 Retry-After
+func PolicyRateLimit(config PolicyRateLimitConfig) (gin.HandlerFunc, error) {
+	for _, p := range config.Policies {
+		if err := p.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	var limiter QuotaLimiter = NewLocalQuotaLimiter(config.Capacity)
+	if config.Redis != nil {
+		limiter = NewRedisQuotaLimiter(config.Redis, config.Prefix)
+	}
+
+	return func(c *gin.Context) {
+		var quotas []RateLimitQuota
+		var messages []string
+		for _, p := range config.Policies {
+			if !p.Matches(c.Request.Method, c.Request.URL.Path) {
+				continue
+			}
+			id := p.identity(c)
+			if id == "" {
+				continue
+			}
+			for i, rule := range p.Limits {
+				quotas = append(quotas, RateLimitQuota{Rule: rule, Key: "policy:" + p.Name + ":" + strconv.Itoa(i) + ":" + id})
+				messages = append(messages, p.Message)
+			}
+		}
+		if len(quotas) == 0 {
+			c.Next()
+			return
+		}
+
+		results, err := limiter.AllowAll(c.Request.Context(), quotas)
+		if err != nil {
+			if config.OnError != nil {
+				config.OnError(c, err)
+			}
+			if config.FailMode == RateLimitFailClosed {
+				MarkLocalResponse(c)
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+					"code":    503,
+					"message": "Rate limiter unavailable",
+				})
+				return
+			}
+			c.Next()
+			return
+		}
+
+		var tightest, rejected *RateLimitResult
+		var message string
+		for i, result := range results {
+			if !result.Allowed {
+				if rejected == nil || result.RetryAfter > rejected.RetryAfter {
+					rejected, message = result, messages[i]
+				}
+			} else if tightest == nil || result.Remaining < tightest.Remaining {
+				tightest = result
+			}
+		}
+
+		if rejected != nil {
+			setRateLimitHeaders(c, rejected)
+			if message == "" {
+				message = "Too many requests"
+			}
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"code":    429,
+				"message": message,
+			})
+			return
+		}
+		if tightest != nil {
+			setRateLimitHeaders(c, tightest)
+		}
+		c.Next()
+	}, nil
+}
+
+// matchRoute 路径是否匹配模式：/prefix/* 匹配前缀本身及其下所有路径，其他模式按 path.Match 匹配
+func matchRoute(pattern, reqPath string) bool {
+	if pattern == "*" || pattern == "/*" {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "/*"); ok && !strings.ContainsAny(prefix, "*?[") {
+		return reqPath == prefix || strings.HasPrefix(reqPath, prefix+"/")
+	}
+	ok, _ := path.Match(pattern, reqPath)
+	return ok
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// contextString 上下文中的值（user_id 等可能是字符串或数字）
+func contextString(c *gin.Context, key string) string {
+	v, ok := c.Get(key)
+	if !ok || v == nil {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,278 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	pkgRedis "goweb/pkg/redis"
+)
+
+// DefaultRateLimitPrefix Redis 限流键前缀
+const DefaultRateLimitPrefix = "ratelimit:"
+
+// errRedisDisabled Redis 未启用，分布式限流按 FailMode 处理
+var errRedisDisabled = errors.New("redis is not enabled")
+
+// RateLimitResult 一次限流判断的结果
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int           // 限额：GCRA 为 burst，滑动窗口为窗口内最大请求数
+	Remaining  int           // 剩余可用请求数
+	RetryAfter time.Duration // 被拒绝时距下次允许的时间
+	ResetAfter time.Duration // 额度完全恢复的时间
+}
+
+// KeyedRateLimiter 按键限流的限流器，分布式实现的状态保存在 Redis 中，多实例共享限额
+type KeyedRateLimiter interface {
+	Allow(ctx context.Context, key string) (*RateLimitResult, error)
+}
+
+// gcraScript GCRA（通用信元速率算法），只保存理论到达时间（TAT），时间取 Redis 服务器时间（微秒）避免实例间时钟偏差
+// ARGV: 请求间隔（微秒）、burst；返回 {是否允许, 剩余, 重试等待（微秒）, 恢复时间（微秒）}
+var gcraScript = goredis.NewScript(`
+redis.replicate_commands()
+local emission = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+
+local tat = tonumber(redis.call('GET', KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+local new_tat = tat + emission
+local diff = now - (new_tat - emission * burst)
+if diff < 0 then
+	return {0, 0, -diff, tat - now}
+end
+
+local reset = new_tat - now
+redis.call('SET', KEYS[1], string.format('%d', new_tat), 'PX', string.format('%d', math.ceil(reset / 1000)))
+return {1, math.floor(diff / emission), 0, reset}
+`)
+
+// gcraQuotaScript 多个限额的 GCRA：全部允许时才写入新的 TAT，任一限额拒绝时都不扣减
+// KEYS: 每个限额的计数键；ARGV: 每个限额依次为请求间隔（微秒）、burst；
+// 返回每个限额的 {是否允许, 剩余, 重试等待（微秒）, 恢复时间（微秒）}，整体被拒绝时未拒绝的限额返回未扣减时的剩余
+var gcraQuotaScript = goredis.NewScript(`
+redis.replicate_commands()
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+
+local results = {}
+local new_tats = {}
+local allowed = true
+for i = 1, #KEYS do
+	local emission = tonumber(ARGV[i * 2 - 1])
+	local burst = tonumber(ARGV[i * 2])
+	local tat = tonumber(redis.call('GET', KEYS[i]) or now)
+	if tat < now then
+		tat = now
+	end
+	local new_tat = tat + emission
+	local diff = now - (new_tat - emission * burst)
+	if diff < 0 then
+		allowed = false
+		results[i] = {0, 0, -diff, tat - now}
+	else
+		new_tats[i] = new_tat
+		results[i] = {1, math.floor(diff / emission), 0, new_tat - now}
+	end
+end
+
+local out = {}
+for i = 1, #KEYS do
+	local r = results[i]
+	if allowed then
+		redis.call('SET', KEYS[i], string.format('%d', new_tats[i]), 'PX', string.format('%d', math.ceil(r[4] / 1000)))
+	elseif r[1] == 1 then
+		r[2] = math.min(r[2] + 1, tonumber(ARGV[i * 2]))
+	end
+	for j = 1, 4 do
+		out[#out + 1] = r[j]
+	end
+end
+return out
+`)
+
+// slidingWindowScript 滑动窗口日志，有序集合记录窗口内每个请求的时间（Redis 服务器时间，微秒）
+// ARGV: 窗口（微秒）、窗口内最大请求数、请求唯一标识；返回值同 gcraScript
+var slidingWindowScript = goredis.NewScript(`
+redis.replicate_commands()
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+if count < limit then
+	redis.call('ZADD', KEYS[1], string.format('%d', now), ARGV[3])
+	redis.call('PEXPIRE', KEYS[1], string.format('%d', math.ceil(window / 1000)))
+	return {1, limit - count - 1, 0, window}
+end
+
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+local newest = redis.call('ZRANGE', KEYS[1], -1, -1, 'WITHSCORES')
+return {0, 0, tonumber(oldest[2]) + window - now, tonumber(newest[2]) + window - now}
+`)
+
+// RedisGCRALimiter 基于 Redis 的 GCRA 限流器，按 RateLimitRule 的平均速率放行，允许 Burst 个突发请求
+type RedisGCRALimiter struct {
+	client   *pkgRedis.Client
+	prefix   string
+	emission time.Duration // 请求间隔
+	burst    int
+}
+
+// NewRedisGCRALimiter 创建每秒 rps 个请求的 GCRA 限流器，prefix 为空时使用 DefaultRateLimitPrefix
+func NewRedisGCRALimiter(client *pkgRedis.Client, prefix string, rps, burst int) *RedisGCRALimiter {
+	return NewRedisRuleLimiter(client, prefix, RateLimitRule{Limit: rps, Period: time.Second, Burst: burst})
+}
+
+// NewRedisRuleLimiter 按限额规则创建 GCRA 限流器，如每天 1000 次
+func NewRedisRuleLimiter(client *pkgRedis.Client, prefix string, rule RateLimitRule) *RedisGCRALimiter {
+	if prefix == "" {
+		prefix = DefaultRateLimitPrefix
+	}
+	rule = rule.normalize()
+	return &RedisGCRALimiter{
+		client:   client,
+		prefix:   prefix,
+		emission: rule.emission(),
+		burst:    rule.Burst,
+	}
+}
+
+// Allow 消耗一个请求额度
+func (l *RedisGCRALimiter) Allow(ctx context.Context, key string) (*RateLimitResult, error) {
+	rdb, err := redisClient(l.client)
+	if err != nil {
+		return nil, err
+	}
+	emission := float64(l.emission) / float64(time.Microsecond)
+	res, err := gcraScript.Run(ctx, rdb, []string{l.prefix + key}, emission, l.burst).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("rate limit %s: %w", key, err)
+	}
+	return newRateLimitResult(res, l.burst)
+}
+
+// RedisQuotaLimiter 基于 Redis 的多限额 GCRA 限流器，所有限额在一个脚本中检查和扣减
+type RedisQuotaLimiter struct {
+	client *pkgRedis.Client
+	prefix string
+}
+
+// NewRedisQuotaLimiter 创建多限额限流器，prefix 为空时使用 DefaultRateLimitPrefix
+func NewRedisQuotaLimiter(client *pkgRedis.Client, prefix string) *RedisQuotaLimiter {
+	if prefix == "" {
+		prefix = DefaultRateLimitPrefix
+	}
+	return &RedisQuotaLimiter{client: client, prefix: prefix}
+}
+
+// AllowAll 检查所有限额，全部允许时才扣减
+func (l *RedisQuotaLimiter) AllowAll(ctx context.Context, quotas []RateLimitQuota) ([]*RateLimitResult, error) {
+	rdb, err := redisClient(l.client)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(quotas))
+	args := make([]interface{}, 0, len(quotas)*2)
+	bursts := make([]int, len(quotas))
+	for i, q := range quotas {
+		rule := q.Rule.normalize()
+		keys[i] = l.prefix + q.Key
+		bursts[i] = rule.Burst
+		args = append(args, float64(rule.emission())/float64(time.Microsecond), rule.Burst)
+	}
+	res, err := gcraQuotaScript.Run(ctx, rdb, keys, args...).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("rate limit %v: %w", keys, err)
+	}
+	if len(res) != len(quotas)*4 {
+		return nil, fmt.Errorf("unexpected rate limit script result: %v", res)
+	}
+	results := make([]*RateLimitResult, len(quotas))
+	for i := range quotas {
+		if results[i], err = newRateLimitResult(res[i*4:i*4+4], bursts[i]); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// RedisSlidingWindowLimiter 基于 Redis 的滑动窗口限流器，任意 window 时间内最多 limit 个请求
+type RedisSlidingWindowLimiter struct {
+	client *pkgRedis.Client
+	prefix string
+	window time.Duration
+	limit  int
+
+	node string        // 实例标识，与 seq 组成请求唯一标识
+	seq  atomic.Uint64 // 请求序号
+}
+
+// NewRedisSlidingWindowLimiter 创建滑动窗口限流器，prefix 为空时使用 DefaultRateLimitPrefix
+func NewRedisSlidingWindowLimiter(client *pkgRedis.Client, prefix string, window time.Duration, limit int) *RedisSlidingWindowLimiter {
+	if prefix == "" {
+		prefix = DefaultRateLimitPrefix
+	}
+	if window < time.Millisecond {
+		window = time.Second
+	}
+	node := make([]byte, 8)
+	_, _ = rand.Read(node)
+	return &RedisSlidingWindowLimiter{
+		client: client,
+		prefix: prefix,
+		window: window,
+		limit:  max(limit, 1),
+		node:   hex.EncodeToString(node),
+	}
+}
+
+// Allow 消耗一个请求额度
+func (l *RedisSlidingWindowLimiter) Allow(ctx context.Context, key string) (*RateLimitResult, error) {
+	rdb, err := redisClient(l.client)
+	if err != nil {
+		return nil, err
+	}
+	member := l.node + ":" + strconv.FormatUint(l.seq.Add(1), 10)
+	res, err := slidingWindowScript.Run(ctx, rdb, []string{l.prefix + key}, l.window.Microseconds(), l.limit, member).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("rate limit %s: %w", key, err)
+	}
+	return newRateLimitResult(res, l.limit)
+}
+
+// redisClient 已启用的 Redis 客户端
+func redisClient(client *pkgRedis.Client) (goredis.UniversalClient, error) {
+	if client == nil || !client.IsEnabled() {
+		return nil, errRedisDisabled
+	}
+	return client.GetClient(), nil
+}
+
+// newRateLimitResult 解析限流脚本的返回值
+func newRateLimitResult(res []int64, limit int) (*RateLimitResult, error) {
+	if len(res) != 4 {
+		return nil, fmt.Errorf("unexpected rate limit script result: %v", res)
+	}
+	return &RateLimitResult{
+		Allowed:    res[0] == 1,
+		Limit:      limit,
+		Remaining:  int(res[1]),
+		RetryAfter: time.Duration(res[2]) * time.Microsecond,
+		ResetAfter: time.Duration(res[3]) * time.Microsecond,
+	}, nil
+}
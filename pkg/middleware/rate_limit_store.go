@@ -0,0 +1,246 @@
+package middleware
+
+import (
+	"container/list"
+	"context"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultLimiterCapacity 进程内限流器最多保存的键数
+const DefaultLimiterCapacity = 100000
+
+// RateLimitRule 限额规则：Period 时间内最多 Limit 个请求，最多允许 Burst 个突发请求（默认等于 Limit）
+type RateLimitRule struct {
+	Limit  int           `mapstructure:"limit" json:"limit"`
+	Period time.Duration `mapstructure:"period" json:"period"`
+	Burst  int           `mapstructure:"burst" json:"burst"`
+}
+
+func (r RateLimitRule) normalize() RateLimitRule {
+	r.Limit = max(r.Limit, 1)
+	if r.Period <= 0 {
+		r.Period = time.Second
+	}
+	if r.Burst <= 0 {
+		r.Burst = r.Limit
+	}
+	return r
+}
+
+// emission 平均请求间隔
+func (r RateLimitRule) emission() time.Duration {
+	return max(r.Period/time.Duration(r.Limit), 1)
+}
+
+// LocalGCRALimiter 进程内的 GCRA 限流器
+// 每个键只保存理论到达时间（TAT），TAT 之后状态与新键相同，因此按 TAT 过期；
+// 键数超过容量时淘汰最久未使用的键（被淘汰的键额度提前恢复）
+type LocalGCRALimiter struct {
+	emission time.Duration
+	burst    int
+
+	mu    sync.Mutex
+	store *tatStore
+}
+
+// NewLocalGCRALimiter 创建进程内 GCRA 限流器，capacity 不大于 0 时使用 DefaultLimiterCapacity
+func NewLocalGCRALimiter(rule RateLimitRule, capacity int) *LocalGCRALimiter {
+	rule = rule.normalize()
+	if capacity <= 0 {
+		capacity = DefaultLimiterCapacity
+	}
+	return &LocalGCRALimiter{
+		emission: rule.emission(),
+		burst:    rule.Burst,
+		store:    newTATStore(capacity),
+	}
+}
+
+// Allow 消耗一个请求额度
+func (l *LocalGCRALimiter) Allow(ctx context.Context, key string) (*RateLimitResult, error) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	tat, ok := l.store.get(key, now)
+	if !ok {
+		tat = now
+	}
+	result, newTAT := gcra(tat, now, l.emission, l.burst)
+	if result.Allowed {
+		l.store.set(key, newTAT, now)
+	}
+	return result, nil
+}
+
+// gcra 在理论到达时间 tat 的基础上判断是否再放行一个请求，返回结果和放行后的 TAT
+func gcra(tat, now time.Time, emission time.Duration, burst int) (*RateLimitResult, time.Time) {
+	newTAT := tat.Add(emission)
+	diff := now.Sub(newTAT.Add(-emission * time.Duration(burst)))
+	if diff < 0 {
+		return &RateLimitResult{Limit: burst, RetryAfter: -diff, ResetAfter: tat.Sub(now)}, newTAT
+	}
+	return &RateLimitResult{
+		Allowed:    true,
+		Limit:      burst,
+		Remaining:  int(diff / emission),
+		ResetAfter: newTAT.Sub(now),
+	}, newTAT
+}
+
+// RateLimitQuota 一个限额及其计数键
+type RateLimitQuota struct {
+	Rule RateLimitRule
+	Key  string
+}
+
+// QuotaLimiter 同时检查多个限额：全部允许时才一起扣减，任一限额拒绝时都不扣减，
+// 被拒绝的请求不会消耗其他限额（如每秒限额拒绝的请求不占用每天的限额）
+type QuotaLimiter interface {
+	// AllowAll 按 quotas 的顺序返回每个限额的结果；整体被拒绝时，未拒绝的限额返回未扣减时的剩余额度
+	AllowAll(ctx context.Context, quotas []RateLimitQuota) ([]*RateLimitResult, error)
+}
+
+// LocalQuotaLimiter 进程内的多限额 GCRA 限流器，每个限额规则最多保存 capacity 个键
+type LocalQuotaLimiter struct {
+	capacity int
+
+	mu     sync.Mutex
+	stores map[RateLimitRule]*tatStore
+}
+
+// NewLocalQuotaLimiter 创建进程内多限额限流器，capacity 不大于 0 时使用 DefaultLimiterCapacity
+func NewLocalQuotaLimiter(capacity int) *LocalQuotaLimiter {
+	if capacity <= 0 {
+		capacity = DefaultLimiterCapacity
+	}
+	return &LocalQuotaLimiter{capacity: capacity, stores: make(map[RateLimitRule]*tatStore)}
+}
+
+// AllowAll 检查所有限额，全部允许时才扣减
+func (l *LocalQuotaLimiter) AllowAll(ctx context.Context, quotas []RateLimitQuota) ([]*RateLimitResult, error) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	results := make([]*RateLimitResult, len(quotas))
+	tats := make([]time.Time, len(quotas))
+	allowed := true
+	for i, q := range quotas {
+		rule := q.Rule.normalize()
+		tat, ok := l.store(rule).get(q.Key, now)
+		if !ok {
+			tat = now
+		}
+		results[i], tats[i] = gcra(tat, now, rule.emission(), rule.Burst)
+		allowed = allowed && results[i].Allowed
+	}
+
+	for i, q := range quotas {
+		if !allowed {
+			uncharged(results[i])
+			continue
+		}
+		l.store(q.Rule.normalize()).set(q.Key, tats[i], now)
+	}
+	return results, nil
+}
+
+func (l *LocalQuotaLimiter) store(rule RateLimitRule) *tatStore {
+	s, ok := l.stores[rule]
+	if !ok {
+		s = newTATStore(l.capacity)
+		l.stores[rule] = s
+	}
+	return s
+}
+
+// uncharged 整体被拒绝时，本身允许的限额没有扣减，剩余额度加回本次请求
+func uncharged(r *RateLimitResult) {
+	if r.Allowed {
+		r.Remaining = min(r.Remaining+1, r.Limit)
+	}
+}
+
+// Len 当前保存的键数
+func (l *LocalGCRALimiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.store.items.Len()
+}
+
+// tatStore 有界的 TAT 存储，按最近使用排序，过期条目在访问和写入时清理
+type tatStore struct {
+	capacity int
+	keys     map[string]*list.Element
+	items    *list.List // 最近使用的在前
+}
+
+type tatEntry struct {
+	key string
+	tat time.Time
+}
+
+func newTATStore(capacity int) *tatStore {
+	return &tatStore{
+		capacity: capacity,
+		keys:     make(map[string]*list.Element),
+		items:    list.New(),
+	}
+}
+
+// get 返回未过期的 TAT
+func (s *tatStore) get(key string, now time.Time) (time.Time, bool) {
+	e, ok := s.keys[key]
+	if !ok {
+		return time.Time{}, false
+	}
+	entry := e.Value.(*tatEntry)
+	if !entry.tat.After(now) {
+		s.remove(e)
+		return time.Time{}, false
+	}
+	return entry.tat, true
+}
+
+// set 写入 TAT，先清理队尾的过期条目，仍超过容量时淘汰最久未使用的条目
+func (s *tatStore) set(key string, tat, now time.Time) {
+	if e, ok := s.keys[key]; ok {
+		e.Value.(*tatEntry).tat = tat
+		s.items.MoveToFront(e)
+		return
+	}
+	s.keys[key] = s.items.PushFront(&tatEntry{key: key, tat: tat})
+
+	for e := s.items.Back(); e != nil && s.items.Len() > 1; e = s.items.Back() {
+		if s.items.Len() <= s.capacity && e.Value.(*tatEntry).tat.After(now) {
+			break
+		}
+		s.remove(e)
+	}
+}
+
+func (s *tatStore) remove(e *list.Element) {
+	delete(s.keys, e.Value.(*tatEntry).key)
+	s.items.Remove(e)
+}
+
+// setRateLimitHeaders 写入 X-RateLimit-Limit / X-RateLimit-Remaining / X-RateLimit-Reset（秒），
+// 被拒绝时写入 Retry-After（秒）
+func setRateLimitHeaders(c *gin.Context, r *RateLimitResult) {
+	c.Header("X-RateLimit-Limit", strconv.Itoa(r.Limit))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(max(r.Remaining, 0)))
+	c.Header("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(r.ResetAfter)))
+	if !r.Allowed {
+		c.Header("Retry-After", strconv.Itoa(max(ceilSeconds(r.RetryAfter), 1)))
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
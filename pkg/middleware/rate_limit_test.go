@@ -0,0 +1,229 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+
+	"goweb/pkg/config"
+	"goweb/pkg/logger"
+	pkgRedis "goweb/pkg/redis"
+)
+
+func TestRateLimit_RedisFailMode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// 端口 1 上没有 Redis，连接立即被拒绝
+	unreachable := pkgRedis.NewClient(&config.RedisConfig{
+		Enabled: true, Host: "127.0.0.1", Port: 1, DialTimeout: 100 * time.Millisecond,
+	}, logger.New("test", "error", "stdout", ""))
+	defer unreachable.Close()
+
+	serve := func(cfg *RateLimitConfig) int {
+		r := gin.New()
+		r.Use(RateLimit(cfg))
+		r.GET("/ping", func(c *gin.Context) { c.Status(http.StatusOK) })
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ping", nil))
+		return w.Code
+	}
+
+	for _, typ := range []string{RateLimitRedisGCRA, RateLimitRedisSlidingWindow} {
+		var errs int
+		cfg := DefaultRateLimitConfig()
+		cfg.Type = typ
+		cfg.Window, cfg.Limit = time.Second, 10
+		cfg.Redis = unreachable
+		cfg.OnError = func(c *gin.Context, err error) { errs++ }
+
+		require.Equal(t, http.StatusOK, serve(cfg), typ)
+		cfg.FailMode = RateLimitFailClosed
+		require.Equal(t, http.StatusServiceUnavailable, serve(cfg), typ)
+		require.Equal(t, 2, errs, typ)
+
+		// 未启用 Redis 同样按 FailMode 处理
+		cfg.Redis = nil
+		require.Equal(t, http.StatusServiceUnavailable, serve(cfg), typ)
+	}
+}
+
+func TestRateLimit_WindowLimitFromRPS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// 未设置 Limit 时按 RPS * Window 计算，而不是每个窗口 1 个请求
+	require.Equal(t, 30, (&RateLimitConfig{RPS: 10, Window: 3 * time.Second}).windowLimit())
+	require.Equal(t, 10, (&RateLimitConfig{RPS: 10}).windowLimit())
+	require.Equal(t, 5, (&RateLimitConfig{RPS: 10, Window: time.Second, Limit: 5}).windowLimit())
+
+	cfg := DefaultRateLimitConfig()
+	cfg.Type, cfg.RPS, cfg.Window = RateLimitSlidingWindow, 2, time.Minute
+	r := gin.New()
+	r.Use(RateLimit(cfg))
+	r.GET("/ping", func(c *gin.Context) { c.Status(http.StatusOK) })
+	codes := make([]int, 0, 121)
+	for i := 0; i < 121; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ping", nil))
+		codes = append(codes, w.Code)
+	}
+	require.Equal(t, http.StatusOK, codes[119])
+	require.Equal(t, http.StatusTooManyRequests, codes[120])
+}
+
+func TestPolicyRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limit, err := PolicyRateLimit(PolicyRateLimitConfig{Policies: []RateLimitPolicy{
+		{Name: "login", Routes: []string{"/api/v1/admin/login"}, Methods: []string{"post"},
+			Limits: []RateLimitRule{{Limit: 3, Period: time.Second}, {Limit: 2, Period: time.Hour}}, Message: "slow down"},
+		{Name: "users", Routes: []string{"/api/v1/users/*"}, Key: PolicyKeyUser,
+			Limits: []RateLimitRule{{Limit: 1, Period: time.Minute}}},
+	}})
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if id := c.GetHeader("X-User"); id != "" {
+			c.Set("user_id", id)
+		}
+	}, limit)
+	r.Any("/*path", func(c *gin.Context) { c.Status(http.StatusOK) })
+	do := func(method, path, user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-User", user)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// 两个限额取剩余最少的一个返回（每小时 2 次，即每 30 分钟恢复一次）
+	w := do(http.MethodPost, "/api/v1/admin/login", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+	require.Equal(t, "1", w.Header().Get("X-RateLimit-Remaining"))
+	require.Equal(t, "1800", w.Header().Get("X-RateLimit-Reset"))
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/api/v1/admin/login", "").Code)
+	w = do(http.MethodPost, "/api/v1/admin/login", "")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Contains(t, w.Body.String(), "slow down")
+	require.Equal(t, "1800", w.Header().Get("Retry-After"))
+	require.Equal(t, http.StatusOK, do(http.MethodGet, "/api/v1/admin/login", "").Code)
+
+	// 按用户计数，未登录的请求不受该策略限制
+	require.Equal(t, http.StatusOK, do(http.MethodGet, "/api/v1/users/1", "42").Code)
+	require.Equal(t, http.StatusTooManyRequests, do(http.MethodGet, "/api/v1/users", "42").Code)
+	require.Equal(t, http.StatusOK, do(http.MethodGet, "/api/v1/users/1", "43").Code)
+	require.Empty(t, do(http.MethodGet, "/api/v1/users/1", "").Header().Get("X-RateLimit-Limit"))
+
+	_, err = PolicyRateLimit(PolicyRateLimitConfig{Policies: []RateLimitPolicy{{Name: "bad", Key: "tenant", Limits: []RateLimitRule{{Limit: 1, Period: time.Second}}}}})
+	require.Error(t, err)
+}
+
+func TestPolicyRateLimit_RejectedRequestsDoNotConsume(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limit, err := PolicyRateLimit(PolicyRateLimitConfig{Policies: []RateLimitPolicy{
+		{Name: "login", Limits: []RateLimitRule{{Limit: 5, Period: 500 * time.Millisecond}, {Limit: 9, Period: time.Hour}}},
+	}})
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.Use(limit)
+	r.POST("/login", func(c *gin.Context) { c.Status(http.StatusOK) })
+	do := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/login", nil))
+		return w
+	}
+
+	for i := 0; i < 5; i++ {
+		require.Equal(t, http.StatusOK, do().Code)
+	}
+	// 被短周期限额拒绝的请求不消耗每小时的限额
+	for i := 0; i < 3; i++ {
+		require.Equal(t, http.StatusTooManyRequests, do().Code)
+	}
+	time.Sleep(600 * time.Millisecond)
+	w := do()
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "9", w.Header().Get("X-RateLimit-Limit"))
+	require.Equal(t, "3", w.Header().Get("X-RateLimit-Remaining"))
+}
+
+func TestLocalQuotaLimiter_AllOrNothing(t *testing.T) {
+	limiter := NewLocalQuotaLimiter(0)
+	second := RateLimitQuota{Rule: RateLimitRule{Limit: 1, Period: time.Hour}, Key: "a:second"}
+	day := RateLimitQuota{Rule: RateLimitRule{Limit: 10, Period: 24 * time.Hour}, Key: "a:day"}
+
+	results, err := limiter.AllowAll(context.Background(), []RateLimitQuota{second, day})
+	require.NoError(t, err)
+	require.True(t, results[0].Allowed && results[1].Allowed)
+	require.Equal(t, 9, results[1].Remaining)
+
+	// 整体被拒绝时其他限额不扣减，返回未扣减时的剩余
+	for i := 0; i < 3; i++ {
+		results, err = limiter.AllowAll(context.Background(), []RateLimitQuota{second, day})
+		require.NoError(t, err)
+		require.False(t, results[0].Allowed)
+		require.True(t, results[1].Allowed)
+		require.Equal(t, 9, results[1].Remaining)
+	}
+	results, err = limiter.AllowAll(context.Background(), []RateLimitQuota{day})
+	require.NoError(t, err)
+	require.Equal(t, 8, results[0].Remaining)
+}
+
+func TestPolicyRateLimit_RoleFromJWT(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limit, err := PolicyRateLimit(PolicyRateLimitConfig{Policies: []RateLimitPolicy{
+		{Name: "operators", Key: PolicyKeyRole, Roles: []string{"operator"},
+			Limits: []RateLimitRule{{Limit: 1, Period: time.Minute}}},
+	}})
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.Use(JWTAuth("secret"), limit)
+	r.GET("/orders", func(c *gin.Context) { c.Status(http.StatusOK) })
+	do := func(userID, role string) int {
+		claims := jwt.MapClaims{"user_id": userID, "exp": time.Now().Add(time.Hour).Unix()}
+		if role != "" {
+			claims["role"] = role
+		}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// 同一角色的不同用户共享限额，其他角色和没有角色的用户不受限制
+	require.Equal(t, http.StatusOK, do("1", "operator"))
+	require.Equal(t, http.StatusTooManyRequests, do("2", "operator"))
+	require.Equal(t, http.StatusOK, do("3", "admin"))
+	require.Equal(t, http.StatusOK, do("4", ""))
+}
+
+func TestLocalGCRALimiter_Bounded(t *testing.T) {
+	limiter := NewLocalGCRALimiter(RateLimitRule{Limit: 1, Period: time.Hour}, 2)
+	for _, ip := range []string{"a", "b", "c"} {
+		result, _ := limiter.Allow(context.Background(), ip)
+		require.True(t, result.Allowed)
+	}
+	require.Equal(t, 2, limiter.Len())
+	// a 已被淘汰，额度提前恢复；c 仍被限流
+	result, _ := limiter.Allow(context.Background(), "a")
+	require.True(t, result.Allowed)
+	result, _ = limiter.Allow(context.Background(), "c")
+	require.False(t, result.Allowed)
+
+	// 状态在 TAT 之后过期
+	short := NewLocalGCRALimiter(RateLimitRule{Limit: 1, Period: 10 * time.Millisecond}, 0)
+	short.Allow(context.Background(), "a")
+	time.Sleep(20 * time.Millisecond)
+	short.Allow(context.Background(), "b")
+	require.Equal(t, 1, short.Len())
+}
@@ -13,6 +13,11 @@ func Recovery(log logger.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		defer func() {
 			if r := recover(); r != nil {
+				// http.ErrAbortHandler 用于中断已开始写入的响应（如反向代理转发中途失败），
+				// 交给 net/http 断开连接
+				if r == http.ErrAbortHandler {
+					panic(r)
+				}
 				log.Error("panic recovered", zap.Any("error", r))
 				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
 					"code":    500,
@@ -0,0 +1,271 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	pkgRedis "goweb/pkg/redis"
+	"goweb/pkg/response"
+)
+
+// 开放接口请求签名的请求头
+const (
+	HeaderAppID     = "X-App-Id"
+	HeaderTimestamp = "X-Timestamp"
+	HeaderNonce     = "X-Nonce"
+	HeaderSignature = "X-Signature"
+)
+
+// SignatureAlgorithm 签名算法标识，作为规范请求的第一行
+const SignatureAlgorithm = "GF1-HMAC-SHA256"
+
+// 签名校验默认值
+const (
+	DefaultSignatureMaxSkew = 5 * time.Minute
+	DefaultSignatureMaxBody = 10 << 20
+	minNonceLength          = 8
+	maxNonceLength          = 64
+)
+
+// ErrUnknownApp 应用不存在
+var ErrUnknownApp = errors.New("unknown app id")
+
+// SecretProvider 按应用ID查找签名密钥
+type SecretProvider interface {
+	Secret(ctx context.Context, appID string) (string, error)
+}
+
+// StaticSecrets 配置文件中的应用密钥
+type StaticSecrets map[string]string
+
+// Secret 按应用ID查找签名密钥
+func (s StaticSecrets) Secret(ctx context.Context, appID string) (string, error) {
+	secret, ok := s[appID]
+	if !ok || secret == "" {
+		return "", ErrUnknownApp
+	}
+	return secret, nil
+}
+
+// NonceStore 记录已使用的 nonce，Use 在 nonce 首次出现时返回 true
+type NonceStore interface {
+	Use(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+// RedisNonceStore 基于 Redis SETNX 的 nonce 存储，多实例共享
+type RedisNonceStore struct {
+	client *pkgRedis.Client
+}
+
+// NewRedisNonceStore 创建 Redis nonce 存储
+func NewRedisNonceStore(client *pkgRedis.Client) *RedisNonceStore {
+	return &RedisNonceStore{client: client}
+}
+
+// Use 记录 nonce，已存在时返回 false
+func (s *RedisNonceStore) Use(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	client := s.client.GetClient()
+	if client == nil {
+		return false, errors.New("redis client is not initialized")
+	}
+	return client.SetNX(ctx, "signature:nonce:"+key, 1, ttl).Result()
+}
+
+// CanonicalRequest 构造待签名的规范请求
+//
+//	GF1-HMAC-SHA256
+//	方法
+//	转义后的路径
+//	按键和值排序的查询参数
+//	应用ID
+//	时间戳（Unix 秒）
+//	nonce
+//	请求体 SHA-256（十六进制）
+func CanonicalRequest(method, path string, query url.Values, appID, timestamp, nonce string, body []byte) string {
+	if path == "" {
+		path = "/"
+	}
+	sum := sha256.Sum256(body)
+	return strings.Join([]string{
+		SignatureAlgorithm,
+		strings.ToUpper(method),
+		path,
+		canonicalQuery(query),
+		appID,
+		timestamp,
+		nonce,
+		hex.EncodeToString(sum[:]),
+	}, "\n")
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// ComputeSignature 计算规范请求的 HMAC-SHA256 签名（十六进制）
+func ComputeSignature(secret, canonical string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(canonical))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignRequest 为请求生成时间戳和 nonce 并写入签名请求头，body 为请求体原文
+func SignRequest(req *http.Request, appID, secret string, body []byte, now time.Time) error {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	nonce := hex.EncodeToString(b)
+	ts := strconv.FormatInt(now.Unix(), 10)
+
+	canonical := CanonicalRequest(req.Method, req.URL.EscapedPath(), req.URL.Query(), appID, ts, nonce, body)
+	req.Header.Set(HeaderAppID, appID)
+	req.Header.Set(HeaderTimestamp, ts)
+	req.Header.Set(HeaderNonce, nonce)
+	req.Header.Set(HeaderSignature, ComputeSignature(secret, canonical))
+	return nil
+}
+
+// SignatureConfig 请求签名校验配置
+type SignatureConfig struct {
+	Secrets      SecretProvider
+	Nonces       NonceStore    // nonce 存储，为空时无法防重放，所有签名请求返回 503
+	MaxSkew      time.Duration // 时间戳允许的偏差，默认 5 分钟
+	MaxBodyBytes int64         // 参与签名的请求体上限，默认 10MB
+}
+
+// VerifySignature 开放接口请求签名校验中间件
+// 拒绝签名错误、时间戳超出允许偏差和 nonce 重复的请求，通过后将应用ID存储到上下文 app_id；
+// 没有 nonce 存储时不接受签名请求（返回 503），不会在没有重放保护的情况下放行
+func VerifySignature(cfg SignatureConfig) gin.HandlerFunc {
+	if cfg.Nonces == nil {
+		return func(c *gin.Context) {
+			response.Error(c, http.StatusServiceUnavailable, "签名校验不可用")
+			c.Abort()
+		}
+	}
+	if cfg.MaxSkew <= 0 {
+		cfg.MaxSkew = DefaultSignatureMaxSkew
+	}
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = DefaultSignatureMaxBody
+	}
+
+	return func(c *gin.Context) {
+		appID := c.GetHeader(HeaderAppID)
+		ts := c.GetHeader(HeaderTimestamp)
+		nonce := c.GetHeader(HeaderNonce)
+		signature := c.GetHeader(HeaderSignature)
+		if appID == "" || ts == "" || nonce == "" || signature == "" {
+			response.Unauthorized(c, "缺少签名参数")
+			c.Abort()
+			return
+		}
+		if len(nonce) < minNonceLength || len(nonce) > maxNonceLength {
+			response.Unauthorized(c, "nonce长度无效")
+			c.Abort()
+			return
+		}
+
+		unix, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			response.Unauthorized(c, "时间戳格式错误")
+			c.Abort()
+			return
+		}
+		if skew := time.Since(time.Unix(unix, 0)); skew > cfg.MaxSkew || skew < -cfg.MaxSkew {
+			response.Unauthorized(c, "请求已过期，请校准时间后重试")
+			c.Abort()
+			return
+		}
+
+		ctx := c.Request.Context()
+		secret, err := cfg.Secrets.Secret(ctx, appID)
+		if err != nil {
+			if errors.Is(err, ErrUnknownApp) {
+				response.Unauthorized(c, "应用不存在")
+			} else {
+				response.InternalError(c, "签名校验失败")
+			}
+			c.Abort()
+			return
+		}
+
+		body, err := readBody(c, cfg.MaxBodyBytes)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				response.Error(c, http.StatusRequestEntityTooLarge, "请求体过大")
+			} else {
+				response.BadRequest(c, "读取请求体失败")
+			}
+			c.Abort()
+			return
+		}
+
+		canonical := CanonicalRequest(c.Request.Method, c.Request.URL.EscapedPath(), c.Request.URL.Query(), appID, ts, nonce, body)
+		if !hmac.Equal([]byte(strings.ToLower(signature)), []byte(ComputeSignature(secret, canonical))) {
+			response.Unauthorized(c, "签名错误")
+			c.Abort()
+			return
+		}
+
+		// 签名通过后再记录 nonce，避免伪造请求占用 nonce
+		fresh, err := cfg.Nonces.Use(ctx, appID+":"+nonce, 2*cfg.MaxSkew)
+		if err != nil {
+			response.InternalError(c, "签名校验失败")
+			c.Abort()
+			return
+		}
+		if !fresh {
+			response.Unauthorized(c, "重复的请求")
+			c.Abort()
+			return
+		}
+
+		c.Set("app_id", appID)
+		c.Next()
+	}
+}
+
+// readBody 读取请求体并放回，供后续处理和转发使用，超过 limit 时返回 *http.MaxBytesError
+func readBody(c *gin.Context, limit int64) ([]byte, error) {
+	if c.Request.Body == nil || c.Request.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, limit))
+	c.Request.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
@@ -1,6 +1,7 @@
 package monitor
 
 import (
+	"sync"
 	"time"
 
 	"goweb/pkg/logger"
@@ -34,6 +35,7 @@ type Metrics struct {
 	cacheOperations *prometheus.CounterVec
 
 	// 自定义指标
+	customMu         sync.Mutex
 	customCounters   map[string]*prometheus.CounterVec
 	customGauges     map[string]*prometheus.GaugeVec
 	customHistograms map[string]*prometheus.HistogramVec
@@ -223,6 +225,9 @@ func (m *Metrics) RecordCacheOperation(operation, cacheType, status, serviceName
 
 // CreateCustomCounter 创建自定义计数器
 func (m *Metrics) CreateCustomCounter(name, help string, labels []string) *prometheus.CounterVec {
+	m.customMu.Lock()
+	defer m.customMu.Unlock()
+
 	if counter, exists := m.customCounters[name]; exists {
 		return counter
 	}
@@ -240,6 +245,9 @@ func (m *Metrics) CreateCustomCounter(name, help string, labels []string) *prome
 
 // CreateCustomGauge 创建自定义仪表
 func (m *Metrics) CreateCustomGauge(name, help string, labels []string) *prometheus.GaugeVec {
+	m.customMu.Lock()
+	defer m.customMu.Unlock()
+
 	if gauge, exists := m.customGauges[name]; exists {
 		return gauge
 	}
@@ -257,6 +265,9 @@ func (m *Metrics) CreateCustomGauge(name, help string, labels []string) *prometh
 
 // CreateCustomHistogram 创建自定义直方图
 func (m *Metrics) CreateCustomHistogram(name, help string, labels []string, buckets []float64) *prometheus.HistogramVec {
+	m.customMu.Lock()
+	defer m.customMu.Unlock()
+
 	if histogram, exists := m.customHistograms[name]; exists {
 		return histogram
 	}
@@ -3,24 +3,34 @@ package redis
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
 )
 
-// Cache Redis 缓存实现
+// ErrCacheMiss 缓存不存在
+var ErrCacheMiss = errors.New("key not found")
+
+// Cache Redis 缓存实现，通过 NewCacheWithOptions 创建时可在前面加一层进程内缓存
 type Cache struct {
 	client *Client
 	prefix string
+
+	opts   CacheOptions
+	flight *singleflight.Group
+	local  *localCache // 为空时不使用本地缓存
+	node   string      // 本实例标识，忽略自己发布的失效消息
+	cancel context.CancelFunc
+	done   chan struct{}
 }
 
 // NewCache 创建 Redis 缓存
 func NewCache(client *Client, prefix string) *Cache {
-	return &Cache{
-		client: client,
-		prefix: prefix,
-	}
+	return NewCacheWithOptions(client, prefix, CacheOptions{})
 }
 
 // Set 设置缓存
@@ -45,7 +55,11 @@ func (c *Cache) Set(ctx context.Context, key string, value interface{}, expirati
 		}
 	}
 
-	return c.client.client.Set(ctx, key, data, expiration).Err()
+	if err := c.client.client.Set(ctx, key, data, expiration).Err(); err != nil {
+		return err
+	}
+	c.invalidateLocal(ctx, []string{strings.TrimPrefix(key, c.prefix)}, false)
+	return nil
 }
 
 // Get 获取缓存
@@ -58,7 +72,7 @@ func (c *Cache) Get(ctx context.Context, key string, dest interface{}) error {
 	data, err := c.client.client.Get(ctx, key).Result()
 	if err != nil {
 		if err == redis.Nil {
-			return fmt.Errorf("key not found")
+			return ErrCacheMiss
 		}
 		return err
 	}
@@ -75,14 +89,14 @@ func (c *Cache) Get(ctx context.Context, key string, dest interface{}) error {
 	return nil
 }
 
-// Delete 删除缓存
+// Delete 删除缓存，同时删除各实例的本地缓存
 func (c *Cache) Delete(ctx context.Context, key string) error {
-	if !c.client.IsEnabled() {
+	c.invalidateLocal(ctx, []string{key}, false)
+	if !c.redisEnabled() {
 		return nil
 	}
 
-	key = c.prefix + key
-	return c.client.client.Del(ctx, key).Err()
+	return c.client.client.Del(ctx, c.prefix+key).Err()
 }
 
 // Exists 检查键是否存在
@@ -96,23 +110,57 @@ func (c *Cache) Exists(ctx context.Context, key string) (bool, error) {
 	return result > 0, err
 }
 
-// Clear 清空缓存
+// Clear 清空前缀下的所有缓存（SCAN 遍历，不阻塞 Redis），同时清空各实例的本地缓存
 func (c *Cache) Clear(ctx context.Context) error {
-	if !c.client.IsEnabled() {
-		return nil
+	c.invalidateLocal(ctx, nil, true)
+	_, err := c.DeletePattern(ctx, "*")
+	return err
+}
+
+// DeletePattern 删除匹配 glob 模式的缓存，使用 SCAN 遍历，返回删除的数量
+// 模式中的字面量部分应先经过 EscapePattern 转义
+func (c *Cache) DeletePattern(ctx context.Context, pattern string) (int64, error) {
+	if !c.redisEnabled() {
+		return 0, nil
 	}
 
-	pattern := c.prefix + "*"
-	keys, err := c.client.client.Keys(ctx, pattern).Result()
-	if err != nil {
+	var deleted int64
+	iter := c.client.client.Scan(ctx, 0, EscapePattern(c.prefix)+pattern, 500).Iterator()
+	batch := make([]string, 0, 500)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		n, err := c.client.client.Del(ctx, batch...).Result()
+		deleted += n
+		batch = batch[:0]
 		return err
 	}
-
-	if len(keys) > 0 {
-		return c.client.client.Del(ctx, keys...).Err()
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) == cap(batch) {
+			if err := flush(); err != nil {
+				return deleted, err
+			}
+		}
 	}
+	if err := iter.Err(); err != nil {
+		return deleted, err
+	}
+	return deleted, flush()
+}
 
-	return nil
+// EscapePattern 转义 glob 特殊字符
+func EscapePattern(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
 }
 
 // GetTTL 获取键的过期时间
@@ -0,0 +1,423 @@
+package redis
+
+import (
+	"container/list"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	mrand "math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+
+	"goweb/pkg/logger"
+)
+
+// ErrNotFound 数据不存在，loader 返回该错误（可包装）时结果会被缓存 NegativeTTL，GetOrLoad 同样返回该错误
+var ErrNotFound = errors.New("not found")
+
+// CacheMetrics 缓存命中统计，*monitor.Metrics 实现了该接口
+type CacheMetrics interface {
+	RecordCacheHit(cacheType, keyPattern, serviceName string)
+	RecordCacheMiss(cacheType, keyPattern, serviceName string)
+}
+
+// CacheOptions 两级缓存配置
+type CacheOptions struct {
+	Name        string        // 指标中的 key_pattern，默认为前缀
+	Service     string        // 指标中的服务名
+	LocalSize   int           // 进程内 LRU 的最大条目数，0 表示不使用本地缓存
+	LocalTTL    time.Duration // 本地缓存的最长有效期，默认 1 分钟；错过失效消息时最多在此之后读到新值
+	Jitter      float64       // TTL 随机浮动比例，默认 0.1（±10%），负数表示不浮动
+	NegativeTTL time.Duration // 不存在的结果的缓存时间，默认 30 秒，负数表示不缓存
+	Beta        float64       // 提前刷新系数（XFetch），默认 1，越大越早刷新，负数表示不提前刷新
+	Channel     string        // 本地缓存失效通知的频道，默认 <前缀>__invalidate
+	Metrics     CacheMetrics
+	Logger      logger.Logger
+}
+
+func (o *CacheOptions) normalize(prefix string) {
+	if o.Name == "" {
+		o.Name = prefix
+	}
+	if o.LocalTTL <= 0 {
+		o.LocalTTL = time.Minute
+	}
+	if o.Jitter == 0 {
+		o.Jitter = 0.1
+	}
+	if o.NegativeTTL == 0 {
+		o.NegativeTTL = 30 * time.Second
+	}
+	if o.Beta == 0 {
+		o.Beta = 1
+	}
+	if o.Channel == "" {
+		o.Channel = prefix + "__invalidate"
+	}
+}
+
+// NewCacheWithOptions 创建两级缓存：进程内 LRU 在前、Redis 在后
+// 开启本地缓存且 Redis 可用时订阅失效频道，Delete / Clear 以及后台刷新会通知其他实例删除本地缓存；用完需要 Close
+func NewCacheWithOptions(client *Client, prefix string, opts CacheOptions) *Cache {
+	opts.normalize(prefix)
+	c := &Cache{
+		client: client,
+		prefix: prefix,
+		opts:   opts,
+		flight: &singleflight.Group{},
+	}
+	if opts.LocalSize > 0 {
+		c.local = newLocalCache(opts.LocalSize)
+		node := make([]byte, 8)
+		_, _ = rand.Read(node)
+		c.node = hex.EncodeToString(node)
+		if c.redisEnabled() {
+			ctx, cancel := context.WithCancel(context.Background())
+			c.cancel = cancel
+			c.done = make(chan struct{})
+			go c.subscribe(ctx, client.client.Subscribe(ctx, opts.Channel))
+		}
+	}
+	return c
+}
+
+// Close 停止订阅失效频道
+func (c *Cache) Close() error {
+	if c.cancel != nil {
+		c.cancel()
+		<-c.done
+	}
+	return nil
+}
+
+// cachedValue GetOrLoad 保存的值
+type cachedValue struct {
+	Value    json.RawMessage `json:"v,omitempty"`
+	NotFound bool            `json:"nf,omitempty"`
+	Delta    int64           `json:"d"` // 加载耗时（毫秒），用于提前刷新
+	Expiry   int64           `json:"e"` // 过期时间（Unix 毫秒），0 表示不过期
+}
+
+// shouldRefresh 按 XFetch 算法决定是否提前刷新：越接近过期、加载越慢，刷新概率越高
+func (v *cachedValue) shouldRefresh(beta float64) bool {
+	if beta <= 0 || v.Delta <= 0 || v.Expiry == 0 {
+		return false
+	}
+	early := float64(v.Delta) * beta * -math.Log(1-mrand.Float64())
+	return time.Now().UnixMilli()+int64(early) >= v.Expiry
+}
+
+// GetOrLoad 读取缓存，不存在时调用 loader 加载并写入缓存
+// 依次查找本地缓存和 Redis；同一个键的并发加载只执行一次 loader，临近过期时在后台提前刷新。
+// loader 返回 ErrNotFound 时缓存空结果 NegativeTTL；Redis 出错时直接调用 loader，不影响业务。
+func GetOrLoad[T any](ctx context.Context, c *Cache, key string, ttl time.Duration, loader func(ctx context.Context) (T, error)) (T, error) {
+	var zero T
+	load := func(ctx context.Context) ([]byte, error) {
+		value, err := loader(ctx)
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal value: %w", err)
+		}
+		return data, nil
+	}
+
+	v, err := c.getOrLoad(ctx, key, ttl, load)
+	if err != nil {
+		return zero, err
+	}
+	if v.NotFound {
+		return zero, ErrNotFound
+	}
+	var value T
+	if err := json.Unmarshal(v.Value, &value); err != nil {
+		return zero, fmt.Errorf("failed to unmarshal cached value: %w", err)
+	}
+	return value, nil
+}
+
+func (c *Cache) getOrLoad(ctx context.Context, key string, ttl time.Duration, load func(context.Context) ([]byte, error)) (*cachedValue, error) {
+	if c.local != nil {
+		if v := c.local.get(key); v != nil {
+			c.recordHit("local")
+			c.maybeRefresh(ctx, key, ttl, v, load)
+			return v, nil
+		}
+		c.recordMiss("local")
+	}
+
+	if c.redisEnabled() {
+		if v, err := c.getValue(ctx, key); err == nil {
+			c.recordHit("redis")
+			c.setLocal(key, v)
+			c.maybeRefresh(ctx, key, ttl, v, load)
+			return v, nil
+		} else if !errors.Is(err, ErrCacheMiss) {
+			c.logWarn("cache get failed, loading from source", key, err)
+		}
+		c.recordMiss("redis")
+	}
+
+	ch := c.flight.DoChan(key, func() (interface{}, error) {
+		return c.load(context.WithoutCancel(ctx), key, ttl, load)
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*cachedValue), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// load 调用 loader 并写入 Redis 和本地缓存
+func (c *Cache) load(ctx context.Context, key string, ttl time.Duration, load func(context.Context) ([]byte, error)) (*cachedValue, error) {
+	start := time.Now()
+	data, err := load(ctx)
+	v := &cachedValue{Value: data, Delta: time.Since(start).Milliseconds()}
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		if c.opts.NegativeTTL < 0 {
+			return &cachedValue{NotFound: true}, nil
+		}
+		v.Value, v.NotFound, ttl = nil, true, c.opts.NegativeTTL
+	}
+
+	ttl = c.jitter(ttl)
+	if ttl > 0 {
+		v.Expiry = time.Now().Add(ttl).UnixMilli()
+	}
+	if err := c.setValue(ctx, key, v, ttl); err != nil {
+		c.logWarn("cache set failed", key, err)
+	}
+	c.setLocal(key, v)
+	return v, nil
+}
+
+// maybeRefresh 需要提前刷新时在后台重新加载，其他实例的本地缓存随之失效
+func (c *Cache) maybeRefresh(ctx context.Context, key string, ttl time.Duration, v *cachedValue, load func(context.Context) ([]byte, error)) {
+	if !v.shouldRefresh(c.opts.Beta) {
+		return
+	}
+	c.flight.DoChan(key, func() (interface{}, error) {
+		v, err := c.load(context.WithoutCancel(ctx), key, ttl, load)
+		if err == nil {
+			c.publish(context.WithoutCancel(ctx), []string{key}, false)
+		}
+		return v, err
+	})
+}
+
+func (c *Cache) getValue(ctx context.Context, key string) (*cachedValue, error) {
+	var data []byte
+	if err := c.Get(ctx, key, &data); err != nil {
+		return nil, err
+	}
+	var v cachedValue
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("invalid cached value: %w", err)
+	}
+	return &v, nil
+}
+
+func (c *Cache) setValue(ctx context.Context, key string, v *cachedValue, ttl time.Duration) error {
+	if !c.redisEnabled() {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.client.client.Set(ctx, c.prefix+key, data, ttl).Err()
+}
+
+func (c *Cache) redisEnabled() bool {
+	return c.client != nil && c.client.IsEnabled()
+}
+
+// jitter 为 TTL 加上随机浮动，避免同时写入的键同时过期
+func (c *Cache) jitter(ttl time.Duration) time.Duration {
+	if c.opts.Jitter <= 0 || ttl <= 0 {
+		return ttl
+	}
+	delta := float64(ttl) * c.opts.Jitter * (mrand.Float64()*2 - 1)
+	return max(ttl+time.Duration(delta), time.Millisecond)
+}
+
+func (c *Cache) setLocal(key string, v *cachedValue) {
+	if c.local == nil {
+		return
+	}
+	expiry := time.Now().Add(c.opts.LocalTTL)
+	if v.Expiry > 0 && v.Expiry < expiry.UnixMilli() {
+		expiry = time.UnixMilli(v.Expiry)
+	}
+	c.local.set(key, v, expiry)
+}
+
+func (c *Cache) recordHit(tier string) {
+	if c.opts.Metrics != nil {
+		c.opts.Metrics.RecordCacheHit(tier, c.opts.Name, c.opts.Service)
+	}
+}
+
+func (c *Cache) recordMiss(tier string) {
+	if c.opts.Metrics != nil {
+		c.opts.Metrics.RecordCacheMiss(tier, c.opts.Name, c.opts.Service)
+	}
+}
+
+func (c *Cache) logWarn(msg, key string, err error) {
+	if c.opts.Logger != nil {
+		c.opts.Logger.Warn(msg, "key", c.prefix+key, "error", err)
+	}
+}
+
+// cacheInvalidation 本地缓存失效消息
+type cacheInvalidation struct {
+	Node  string   `json:"node"`
+	Keys  []string `json:"keys,omitempty"`
+	Clear bool     `json:"clear,omitempty"`
+}
+
+// invalidateLocal 删除本实例的本地缓存并通知其他实例
+func (c *Cache) invalidateLocal(ctx context.Context, keys []string, clear bool) {
+	if c.local == nil {
+		return
+	}
+	if clear {
+		c.local.clear()
+	} else {
+		c.local.delete(keys...)
+	}
+	c.publish(ctx, keys, clear)
+}
+
+func (c *Cache) publish(ctx context.Context, keys []string, clear bool) {
+	if c.local == nil || !c.redisEnabled() {
+		return
+	}
+	msg, _ := json.Marshal(cacheInvalidation{Node: c.node, Keys: keys, Clear: clear})
+	if err := c.client.client.Publish(ctx, c.opts.Channel, msg).Err(); err != nil {
+		c.logWarn("publish cache invalidation failed", c.opts.Channel, err)
+	}
+}
+
+// subscribe 应用其他实例发布的失效消息
+func (c *Cache) subscribe(ctx context.Context, pubsub *redis.PubSub) {
+	defer close(c.done)
+	defer pubsub.Close()
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case m, ok := <-ch:
+			if !ok {
+				return
+			}
+			var msg cacheInvalidation
+			if err := json.Unmarshal([]byte(m.Payload), &msg); err != nil {
+				c.logWarn("invalid cache invalidation message", c.opts.Channel, err)
+				continue
+			}
+			if msg.Node == c.node {
+				continue
+			}
+			if msg.Clear {
+				c.local.clear()
+			} else {
+				c.local.delete(msg.Keys...)
+			}
+		}
+	}
+}
+
+// localCache 进程内 LRU 缓存，条目到期或超过容量时淘汰
+type localCache struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type localItem struct {
+	key    string
+	value  *cachedValue
+	expiry time.Time
+}
+
+func newLocalCache(size int) *localCache {
+	return &localCache{size: size, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (l *localCache) get(key string) *cachedValue {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.items[key]
+	if !ok {
+		return nil
+	}
+	item := e.Value.(*localItem)
+	if !time.Now().Before(item.expiry) {
+		l.ll.Remove(e)
+		delete(l.items, key)
+		return nil
+	}
+	l.ll.MoveToFront(e)
+	return item.value
+}
+
+func (l *localCache) set(key string, v *cachedValue, expiry time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.items[key]; ok {
+		e.Value = &localItem{key: key, value: v, expiry: expiry}
+		l.ll.MoveToFront(e)
+		return
+	}
+	l.items[key] = l.ll.PushFront(&localItem{key: key, value: v, expiry: expiry})
+	for l.ll.Len() > l.size {
+		oldest := l.ll.Back()
+		l.ll.Remove(oldest)
+		delete(l.items, oldest.Value.(*localItem).key)
+	}
+}
+
+func (l *localCache) delete(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range keys {
+		if e, ok := l.items[key]; ok {
+			l.ll.Remove(e)
+			delete(l.items, key)
+		}
+	}
+}
+
+func (l *localCache) clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ll.Init()
+	l.items = make(map[string]*list.Element)
+}
+
+func (l *localCache) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.ll.Len()
+}
@@ -0,0 +1,134 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"goweb/pkg/config"
+	"goweb/pkg/logger"
+)
+
+type countingMetrics struct {
+	mu     sync.Mutex
+	hits   map[string]int
+	misses map[string]int
+}
+
+func (m *countingMetrics) RecordCacheHit(cacheType, keyPattern, serviceName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hits[cacheType]++
+}
+
+func (m *countingMetrics) RecordCacheMiss(cacheType, keyPattern, serviceName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.misses[cacheType]++
+}
+
+func newLocalOnlyCache(t *testing.T, metrics CacheMetrics) *Cache {
+	client := NewClient(&config.RedisConfig{Enabled: false}, logger.New("test", "error", "stdout", ""))
+	c := NewCacheWithOptions(client, "test:", CacheOptions{LocalSize: 2, Metrics: metrics})
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestGetOrLoad_SingleflightAndLocalCache(t *testing.T) {
+	metrics := &countingMetrics{hits: map[string]int{}, misses: map[string]int{}}
+	c := newLocalOnlyCache(t, metrics)
+	var loads atomic.Int32
+	loader := func(ctx context.Context) ([]string, error) {
+		loads.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		return []string{"menu:list", "menu:create"}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes, err := GetOrLoad(context.Background(), c, "perms:1", time.Minute, loader)
+			require.NoError(t, err)
+			require.Equal(t, []string{"menu:list", "menu:create"}, codes)
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int32(1), loads.Load())
+
+	_, err := GetOrLoad(context.Background(), c, "perms:1", time.Minute, loader)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), loads.Load())
+	require.Equal(t, 1, metrics.hits["local"])
+	require.Equal(t, 10, metrics.misses["local"])
+
+	// 删除后重新加载
+	require.NoError(t, c.Delete(context.Background(), "perms:1"))
+	_, err = GetOrLoad(context.Background(), c, "perms:1", time.Minute, loader)
+	require.NoError(t, err)
+	require.Equal(t, int32(2), loads.Load())
+}
+
+func TestGetOrLoad_NegativeCaching(t *testing.T) {
+	c := newLocalOnlyCache(t, nil)
+	var loads int
+	loader := func(ctx context.Context) (int, error) {
+		loads++
+		return 0, ErrNotFound
+	}
+	for i := 0; i < 3; i++ {
+		_, err := GetOrLoad(context.Background(), c, "user:404", time.Minute, loader)
+		require.ErrorIs(t, err, ErrNotFound)
+	}
+	require.Equal(t, 1, loads)
+
+	// 其他错误不缓存
+	failing := func(ctx context.Context) (int, error) {
+		loads++
+		return 0, errors.New("db down")
+	}
+	for i := 0; i < 2; i++ {
+		_, err := GetOrLoad(context.Background(), c, "user:500", time.Minute, failing)
+		require.EqualError(t, err, "db down")
+	}
+	require.Equal(t, 3, loads)
+}
+
+func TestGetOrLoad_EarlyRefresh(t *testing.T) {
+	c := newLocalOnlyCache(t, nil)
+	refreshed := make(chan struct{})
+	loader := func(ctx context.Context) (string, error) {
+		close(refreshed)
+		return "new", nil
+	}
+
+	// 已到期的值一定会被刷新，刷新期间仍返回旧值
+	c.local.set("tree", &cachedValue{Value: []byte(`"old"`), Delta: 100, Expiry: time.Now().UnixMilli()}, time.Now().Add(time.Second))
+	value, err := GetOrLoad(context.Background(), c, "tree", time.Minute, loader)
+	require.NoError(t, err)
+	require.Equal(t, "old", value)
+
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatal("value was not refreshed")
+	}
+	require.Eventually(t, func() bool {
+		value, _ := GetOrLoad(context.Background(), c, "tree", time.Minute, loader)
+		return value == "new"
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestCache_Jitter(t *testing.T) {
+	c := newLocalOnlyCache(t, nil)
+	for i := 0; i < 100; i++ {
+		ttl := c.jitter(time.Minute)
+		require.InDelta(t, float64(time.Minute), float64(ttl), float64(6*time.Second))
+	}
+}
@@ -0,0 +1,263 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"goweb/pkg/validator"
+)
+
+// permanentError 不需要重试的错误，消息直接进入死信队列
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent 标记为不可重试的错误（如消息格式错误），处理器返回后消息直接进入死信队列
+func Permanent(err error) error {
+	if err == nil || IsPermanent(err) {
+		return err
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent 是否为不可重试的错误
+func IsPermanent(err error) bool {
+	var pe *permanentError
+	return errors.As(err, &pe)
+}
+
+// Upcaster 把消息内容从 from 版本升级到 from+1 版本
+type Upcaster func(data map[string]interface{}) (map[string]interface{}, error)
+
+// TypedHandler 类型化的消息处理器，payload 已经过升级、解码和校验
+type TypedHandler[T any] func(ctx context.Context, msg *Message, payload T) error
+
+// Contract 主题的消息契约：消息类型、Schema 名称和当前版本
+type Contract struct {
+	Topic     string
+	Schema    string
+	Version   int
+	Type      reflect.Type
+	upcasters map[int]Upcaster
+}
+
+// ContractRegistry 主题到消息契约的注册表
+type ContractRegistry struct {
+	mu        sync.RWMutex
+	contracts map[string]*Contract
+	validator *validator.Validator
+}
+
+// NewContractRegistry 创建消息契约注册表
+func NewContractRegistry() *ContractRegistry {
+	return &ContractRegistry{
+		contracts: make(map[string]*Contract),
+		validator: validator.NewValidator(),
+	}
+}
+
+// DefaultContracts 默认的消息契约注册表，NewQueue 创建的队列使用它
+var DefaultContracts = NewContractRegistry()
+
+// RegisterContract 注册主题的消息类型、Schema 名称和当前版本（从 1 开始）
+// 重复注册相同的契约不报错；同一主题注册不同的契约返回错误
+func RegisterContract[T any](r *ContractRegistry, topic, schema string, version int) error {
+	if topic == "" || schema == "" {
+		return fmt.Errorf("contract topic and schema are required")
+	}
+	if version < 1 {
+		return fmt.Errorf("contract %s: version must be at least 1", schema)
+	}
+	typ := reflect.TypeFor[T]()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c, ok := r.contracts[topic]; ok {
+		if c.Schema == schema && c.Version == version && c.Type == typ {
+			return nil
+		}
+		return fmt.Errorf("topic %s already has contract %s v%d (%s)", topic, c.Schema, c.Version, c.Type)
+	}
+	r.contracts[topic] = &Contract{
+		Topic:     topic,
+		Schema:    schema,
+		Version:   version,
+		Type:      typ,
+		upcasters: make(map[int]Upcaster),
+	}
+	return nil
+}
+
+// RegisterUpcaster 注册从 from 版本升级到 from+1 版本的转换，消费时旧版本消息逐级升级到当前版本
+func (r *ContractRegistry) RegisterUpcaster(topic string, from int, up Upcaster) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.contracts[topic]
+	if !ok {
+		return fmt.Errorf("no contract registered for topic %s", topic)
+	}
+	if from < 1 || from >= c.Version {
+		return fmt.Errorf("contract %s: upcaster version %d out of range [1, %d)", c.Schema, from, c.Version)
+	}
+	c.upcasters[from] = up
+	return nil
+}
+
+// Contract 主题的消息契约
+func (r *ContractRegistry) Contract(topic string) (*Contract, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, ok := r.contracts[topic]
+	return c, ok
+}
+
+// Topics 已注册契约的主题
+func (r *ContractRegistry) Topics() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	topics := make([]string, 0, len(r.contracts))
+	for topic := range r.contracts {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// contractFor 主题的契约，并检查类型是否为 T
+func contractFor[T any](r *ContractRegistry, topic string) (*Contract, error) {
+	c, ok := r.Contract(topic)
+	if !ok {
+		return nil, fmt.Errorf("no contract registered for topic %s", topic)
+	}
+	if typ := reflect.TypeFor[T](); c.Type != typ {
+		return nil, fmt.Errorf("topic %s expects %s, got %s", topic, c.Type, typ)
+	}
+	return c, nil
+}
+
+// validate 校验消息内容（结构体的 validate 标签）
+func (r *ContractRegistry) validate(c *Contract, payload interface{}) error {
+	typ := c.Type
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+	if err := r.validator.Validate(payload); err != nil {
+		if details := r.validator.GetValidationErrors(err); len(details) > 0 {
+			return fmt.Errorf("validate %s v%d: %s", c.Schema, c.Version, details.Error())
+		}
+		return fmt.Errorf("validate %s v%d: %w", c.Schema, c.Version, err)
+	}
+	return nil
+}
+
+// Publish 按主题的契约校验并发布类型化消息，消息中带 Schema 名称和版本
+func Publish[T any](ctx context.Context, q *RedisQueue, topic string, payload T) error {
+	message, err := newTypedMessage(q, topic, payload)
+	if err != nil {
+		return err
+	}
+	return q.publish(ctx, message)
+}
+
+// PublishWithDelay 按主题的契约校验并延迟发布类型化消息
+func PublishWithDelay[T any](ctx context.Context, q *RedisQueue, topic string, payload T, delay time.Duration) error {
+	message, err := newTypedMessage(q, topic, payload)
+	if err != nil {
+		return err
+	}
+	return q.publishWithDelay(ctx, message, delay)
+}
+
+func newTypedMessage[T any](q *RedisQueue, topic string, payload T) (*Message, error) {
+	c, err := contractFor[T](q.contracts, topic)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.contracts.validate(c, payload); err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s: %w", c.Schema, err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("contract %s must encode as a JSON object: %w", c.Schema, err)
+	}
+
+	message := q.newMessage(topic, data)
+	message.Schema, message.Version = c.Schema, c.Version
+	return message, nil
+}
+
+// Subscribe 订阅类型化消息：旧版本消息先逐级升级，再解码并校验后调用 handler
+// Schema 不匹配、版本不支持、解码或校验失败的消息不重试，直接进入死信队列并记录原因
+func Subscribe[T any](ctx context.Context, q *RedisQueue, topic string, handler TypedHandler[T], opts SubscribeOptions) error {
+	if _, err := contractFor[T](q.contracts, topic); err != nil {
+		return err
+	}
+	return q.SubscribeWithOptions(ctx, topic, func(ctx context.Context, msg *Message) error {
+		payload, err := Decode[T](q.contracts, msg)
+		if err != nil {
+			return err
+		}
+		return handler(ctx, msg, payload)
+	}, opts)
+}
+
+// Decode 按主题的契约升级、解码并校验消息，失败时返回不可重试的错误
+// 没有 Schema 标记的旧消息视为版本 1；解码成功后 msg 的内容和版本更新为当前版本
+func Decode[T any](r *ContractRegistry, msg *Message) (T, error) {
+	var payload T
+	c, err := contractFor[T](r, msg.Topic)
+	if err != nil {
+		return payload, Permanent(err)
+	}
+	if msg.Schema != "" && msg.Schema != c.Schema {
+		return payload, Permanent(fmt.Errorf("schema mismatch: got %s, want %s", msg.Schema, c.Schema))
+	}
+
+	version := msg.Version
+	if version == 0 {
+		version = 1
+	}
+	if version > c.Version {
+		return payload, Permanent(fmt.Errorf("unsupported %s version %d (latest %d)", c.Schema, version, c.Version))
+	}
+	data := msg.Data
+	for ; version < c.Version; version++ {
+		up, ok := c.upcasters[version]
+		if !ok {
+			return payload, Permanent(fmt.Errorf("no upcaster for %s v%d -> v%d", c.Schema, version, version+1))
+		}
+		if data, err = up(data); err != nil {
+			return payload, Permanent(fmt.Errorf("upcast %s v%d -> v%d: %w", c.Schema, version, version+1, err))
+		}
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return payload, Permanent(fmt.Errorf("decode %s v%d: %w", c.Schema, c.Version, err))
+	}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return payload, Permanent(fmt.Errorf("decode %s v%d: %w", c.Schema, c.Version, err))
+	}
+	if err := r.validate(c, payload); err != nil {
+		return payload, Permanent(err)
+	}
+
+	msg.Data, msg.Schema, msg.Version = data, c.Schema, c.Version
+	return payload, nil
+}
@@ -0,0 +1,77 @@
+package redis
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type orderReminder struct {
+	OrderID string `json:"order_id" validate:"required"`
+	Channel string `json:"channel" validate:"required,oneof=sms email"`
+}
+
+func newTestContracts(t *testing.T) *ContractRegistry {
+	r := NewContractRegistry()
+	require.NoError(t, RegisterContract[orderReminder](r, "order.reminder", "order.reminder", 2))
+	// v1 没有 channel 字段，默认短信
+	require.NoError(t, r.RegisterUpcaster("order.reminder", 1, func(data map[string]interface{}) (map[string]interface{}, error) {
+		data["channel"] = "sms"
+		return data, nil
+	}))
+	return r
+}
+
+func TestRegisterContract(t *testing.T) {
+	r := newTestContracts(t)
+	require.NoError(t, RegisterContract[orderReminder](r, "order.reminder", "order.reminder", 2))
+	require.Error(t, RegisterContract[orderReminder](r, "order.reminder", "order.reminder", 3))
+	require.Error(t, RegisterContract[string](r, "order.reminder", "order.reminder", 2))
+	require.Error(t, r.RegisterUpcaster("order.reminder", 2, nil))
+	require.Equal(t, []string{"order.reminder"}, r.Topics())
+}
+
+func TestNewTypedMessage(t *testing.T) {
+	q := &RedisQueue{contracts: newTestContracts(t)}
+	msg, err := newTypedMessage(q, "order.reminder", orderReminder{OrderID: "42", Channel: "email"})
+	require.NoError(t, err)
+	require.Equal(t, "order.reminder", msg.Schema)
+	require.Equal(t, 2, msg.Version)
+	require.Equal(t, map[string]interface{}{"order_id": "42", "channel": "email"}, msg.Data)
+
+	// 发布前同样校验
+	_, err = newTypedMessage(q, "order.reminder", orderReminder{OrderID: "42"})
+	require.ErrorContains(t, err, "channel is required")
+	_, err = newTypedMessage(q, "order.reminder", "42")
+	require.Error(t, err)
+}
+
+func TestDecode(t *testing.T) {
+	r := newTestContracts(t)
+
+	// 未标记版本的旧消息视为 v1，升级后解码
+	msg := &Message{Topic: "order.reminder", Data: map[string]interface{}{"order_id": "42"}}
+	payload, err := Decode[orderReminder](r, msg)
+	require.NoError(t, err)
+	require.Equal(t, orderReminder{OrderID: "42", Channel: "sms"}, payload)
+	require.Equal(t, 2, msg.Version)
+
+	for name, tc := range map[string]struct {
+		msg    *Message
+		reason string
+	}{
+		"invalid":  {&Message{Topic: "order.reminder", Schema: "order.reminder", Version: 2, Data: map[string]interface{}{"channel": "fax"}}, "order_id is required"},
+		"type":     {&Message{Topic: "order.reminder", Version: 2, Data: map[string]interface{}{"order_id": 42, "channel": "sms"}}, "decode order.reminder v2"},
+		"future":   {&Message{Topic: "order.reminder", Version: 3, Data: map[string]interface{}{}}, "unsupported order.reminder version 3"},
+		"schema":   {&Message{Topic: "order.reminder", Schema: "order.created", Version: 2}, "schema mismatch"},
+		"no topic": {&Message{Topic: "order.unknown"}, "no contract registered"},
+	} {
+		_, err := Decode[orderReminder](r, tc.msg)
+		require.ErrorContains(t, err, tc.reason, name)
+		require.True(t, IsPermanent(err), name)
+	}
+
+	require.False(t, IsPermanent(errors.New("db down")))
+	require.Nil(t, Permanent(nil))
+}
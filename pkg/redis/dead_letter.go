@@ -0,0 +1,309 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrDeadLetterNotFound 死信消息不存在（可能已被重放或删除）
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// errQueueDisabled Redis 未启用时管理接口返回的错误
+var errQueueDisabled = errors.New("redis not enabled")
+
+// deadLetterAuditMaxLen 死信操作审计记录的保留条数（近似）
+const deadLetterAuditMaxLen = 10000
+
+// DeadLetter 死信消息
+type DeadLetter struct {
+	ID       string    `json:"id"` // 死信队列中的 Stream ID
+	Topic    string    `json:"topic"`
+	Message  *Message  `json:"message"`
+	Error    string    `json:"error"` // 最后一次处理失败的原因
+	FailedAt time.Time `json:"failed_at"`
+	Retry    int       `json:"retry"` // 进入死信队列前的重试次数
+}
+
+// DeadLetterTopic 有死信消息的主题
+type DeadLetterTopic struct {
+	Topic string `json:"topic"`
+	Count int64  `json:"count"`
+}
+
+// DeadLetterAudit 死信操作审计记录
+type DeadLetterAudit struct {
+	Action   string                 `json:"action"` // replay / replay_all / purge / purge_all
+	Topic    string                 `json:"topic"`
+	IDs      []string               `json:"ids,omitempty"`
+	Count    int64                  `json:"count"`
+	Edited   bool                   `json:"edited,omitempty"`           // 重放前修改了消息内容
+	Before   map[string]interface{} `json:"before,omitempty"`           // 修改前的消息内容
+	After    map[string]interface{} `json:"after,omitempty"`            // 修改后的消息内容
+	Operator string                 `json:"operator"`                   // 通过认证的身份
+	Claimed  string                 `json:"claimed_operator,omitempty"` // 调用方自称的操作人（X-Operator），未经校验
+	IP       string                 `json:"ip"`
+	Time     time.Time              `json:"time"`
+}
+
+// deadLetterEntry sendToDeadLetterQueue 写入的内容
+type deadLetterEntry struct {
+	OriginalMessage *Message  `json:"original_message"`
+	Error           string    `json:"error"`
+	FailedAt        time.Time `json:"failed_at"`
+}
+
+// replayScript 死信消息存在时写回原主题并从死信队列删除，保证同一条消息只重放一次
+// KEYS[1] 死信队列，KEYS[2] 原主题；ARGV[1] 死信 ID，ARGV[2] 消息内容
+var replayScript = redis.NewScript(`
+if #redis.call('XRANGE', KEYS[1], ARGV[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('XADD', KEYS[2], '*', 'message', ARGV[2])
+redis.call('XDEL', KEYS[1], ARGV[1])
+return 1
+`)
+
+// deadLetterKey 主题的死信队列键
+func (q *RedisQueue) deadLetterKey(topic string) string {
+	return q.prefix + "mq:dead-letter:" + topic
+}
+
+// DeadLetterTopics 有死信消息的主题及消息数
+func (q *RedisQueue) DeadLetterTopics(ctx context.Context) ([]DeadLetterTopic, error) {
+	if !q.client.IsEnabled() {
+		return nil, errQueueDisabled
+	}
+
+	prefix := q.deadLetterKey("")
+	var topics []DeadLetterTopic
+	iter := q.client.client.ScanType(ctx, 0, EscapePattern(prefix)+"*", 500, "stream").Iterator()
+	for iter.Next(ctx) {
+		count, err := q.client.client.XLen(ctx, iter.Val()).Result()
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			topics = append(topics, DeadLetterTopic{Topic: strings.TrimPrefix(iter.Val(), prefix), Count: count})
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(topics, func(i, j int) bool { return topics[i].Topic < topics[j].Topic })
+	return topics, nil
+}
+
+// DeadLetters 按时间顺序分页读取死信消息，cursor 为上一页返回的 next（第一页为空），没有更多时 next 为空
+func (q *RedisQueue) DeadLetters(ctx context.Context, topic, cursor string, limit int64) ([]DeadLetter, string, error) {
+	if !q.client.IsEnabled() {
+		return nil, "", errQueueDisabled
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+
+	start := "-"
+	if cursor != "" {
+		start = "(" + cursor
+	}
+	entries, err := q.client.client.XRangeN(ctx, q.deadLetterKey(topic), start, "+", limit+1).Result()
+	if err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if int64(len(entries)) > limit {
+		entries = entries[:limit]
+		next = entries[limit-1].ID
+	}
+	letters := make([]DeadLetter, 0, len(entries))
+	for _, e := range entries {
+		letters = append(letters, parseDeadLetter(topic, e))
+	}
+	return letters, next, nil
+}
+
+// GetDeadLetter 读取一条死信消息
+func (q *RedisQueue) GetDeadLetter(ctx context.Context, topic, id string) (*DeadLetter, error) {
+	if !q.client.IsEnabled() {
+		return nil, errQueueDisabled
+	}
+
+	entries, err := q.client.client.XRange(ctx, q.deadLetterKey(topic), id, id).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, ErrDeadLetterNotFound
+	}
+	letter := parseDeadLetter(topic, entries[0])
+	return &letter, nil
+}
+
+// ReplayDeadLetter 把死信消息重新发布到原主题并从死信队列删除，重试次数清零
+// data 不为空时替换消息内容（修正错误数据后重放）
+func (q *RedisQueue) ReplayDeadLetter(ctx context.Context, topic, id string, data map[string]interface{}) (*DeadLetter, error) {
+	letter, err := q.GetDeadLetter(ctx, topic, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.replay(ctx, letter, data); err != nil {
+		return nil, err
+	}
+	return letter, nil
+}
+
+// ReplayDeadLetters 重放主题下的所有死信消息，返回重放的数量；重放期间新进入死信队列的消息不会被重放
+func (q *RedisQueue) ReplayDeadLetters(ctx context.Context, topic string) (int64, error) {
+	if !q.client.IsEnabled() {
+		return 0, errQueueDisabled
+	}
+
+	key := q.deadLetterKey(topic)
+	last, err := q.client.client.XRevRangeN(ctx, key, "+", "-", 1).Result()
+	if err != nil || len(last) == 0 {
+		return 0, err
+	}
+	end := last[0].ID
+
+	var replayed int64
+	start := "-"
+	for {
+		entries, err := q.client.client.XRangeN(ctx, key, start, end, 100).Result()
+		if err != nil {
+			return replayed, err
+		}
+		for _, e := range entries {
+			letter := parseDeadLetter(topic, e)
+			if err := q.replay(ctx, &letter, nil); err != nil {
+				if errors.Is(err, ErrDeadLetterNotFound) {
+					continue
+				}
+				return replayed, err
+			}
+			replayed++
+		}
+		if len(entries) < 100 {
+			return replayed, nil
+		}
+		start = "(" + entries[len(entries)-1].ID
+	}
+}
+
+// replay 重放一条死信消息
+func (q *RedisQueue) replay(ctx context.Context, letter *DeadLetter, data map[string]interface{}) error {
+	message := *letter.Message
+	if data != nil {
+		message.Data = data
+	}
+	message.Topic = letter.Topic
+	message.Retry = 0
+	if message.MaxRetry <= 0 {
+		message.MaxRetry = 3
+	}
+	messageBytes, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	ok, err := replayScript.Run(ctx, q.client.client,
+		[]string{q.deadLetterKey(letter.Topic), q.streamKey(letter.Topic)},
+		letter.ID, string(messageBytes)).Int()
+	if err != nil {
+		return fmt.Errorf("failed to replay message: %w", err)
+	}
+	if ok == 0 {
+		return ErrDeadLetterNotFound
+	}
+	q.client.logger.Info("dead letter replayed", "topic", letter.Topic, "dead_letter_id", letter.ID, "message_id", message.ID)
+	return nil
+}
+
+// PurgeDeadLetters 删除死信消息，ids 为空时删除主题下的所有死信消息，返回删除的数量
+func (q *RedisQueue) PurgeDeadLetters(ctx context.Context, topic string, ids ...string) (int64, error) {
+	if !q.client.IsEnabled() {
+		return 0, errQueueDisabled
+	}
+
+	key := q.deadLetterKey(topic)
+	if len(ids) > 0 {
+		return q.client.client.XDel(ctx, key, ids...).Result()
+	}
+	var length *redis.IntCmd
+	_, err := q.client.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		length = pipe.XLen(ctx, key)
+		pipe.Del(ctx, key)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return length.Val(), nil
+}
+
+// RecordDeadLetterAudit 记录死信操作，保留最近约 10000 条
+func (q *RedisQueue) RecordDeadLetterAudit(ctx context.Context, audit DeadLetterAudit) error {
+	if !q.client.IsEnabled() {
+		return errQueueDisabled
+	}
+	if audit.Time.IsZero() {
+		audit.Time = time.Now()
+	}
+	data, err := json.Marshal(audit)
+	if err != nil {
+		return err
+	}
+	return q.client.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: q.prefix + "mq:dead-letter-audit",
+		MaxLen: deadLetterAuditMaxLen,
+		Approx: true,
+		Values: map[string]interface{}{"audit": string(data)},
+	}).Err()
+}
+
+// DeadLetterAudits 最近的死信操作审计记录，按时间倒序
+func (q *RedisQueue) DeadLetterAudits(ctx context.Context, limit int64) ([]DeadLetterAudit, error) {
+	if !q.client.IsEnabled() {
+		return nil, errQueueDisabled
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+
+	entries, err := q.client.client.XRevRangeN(ctx, q.prefix+"mq:dead-letter-audit", "+", "-", limit).Result()
+	if err != nil {
+		return nil, err
+	}
+	audits := make([]DeadLetterAudit, 0, len(entries))
+	for _, e := range entries {
+		var audit DeadLetterAudit
+		if s, ok := e.Values["audit"].(string); ok && json.Unmarshal([]byte(s), &audit) == nil {
+			audits = append(audits, audit)
+		}
+	}
+	return audits, nil
+}
+
+// parseDeadLetter 解析死信队列中的一条记录，格式异常时保留原始内容
+func parseDeadLetter(topic string, e redis.XMessage) DeadLetter {
+	letter := DeadLetter{ID: e.ID, Topic: topic}
+	raw, _ := e.Values["message"].(string)
+	var entry deadLetterEntry
+	if err := json.Unmarshal([]byte(raw), &entry); err != nil || entry.OriginalMessage == nil {
+		letter.Message = &Message{Topic: topic, Data: map[string]interface{}{"raw": raw}}
+		letter.Error = "invalid dead letter entry"
+		return letter
+	}
+	letter.Message = entry.OriginalMessage
+	letter.Error = entry.Error
+	letter.FailedAt = entry.FailedAt
+	letter.Retry = entry.OriginalMessage.Retry
+	return letter
+}
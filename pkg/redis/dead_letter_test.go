@@ -0,0 +1,33 @@
+package redis
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDeadLetter(t *testing.T) {
+	failedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	data, err := json.Marshal(map[string]interface{}{
+		"original_message": &Message{ID: "m1", Topic: "order.reminder", Data: map[string]interface{}{"order_id": "42"}, Retry: 3, MaxRetry: 3},
+		"error":            "order not found",
+		"failed_at":        failedAt,
+	})
+	require.NoError(t, err)
+
+	letter := parseDeadLetter("order.reminder", redis.XMessage{ID: "1-0", Values: map[string]interface{}{"message": string(data)}})
+	require.Equal(t, "1-0", letter.ID)
+	require.Equal(t, "m1", letter.Message.ID)
+	require.Equal(t, "42", letter.Message.Data["order_id"])
+	require.Equal(t, "order not found", letter.Error)
+	require.Equal(t, 3, letter.Retry)
+	require.True(t, failedAt.Equal(letter.FailedAt))
+
+	// 格式异常的记录保留原始内容，便于修改后重放
+	letter = parseDeadLetter("order.reminder", redis.XMessage{ID: "2-0", Values: map[string]interface{}{"message": "{oops"}})
+	require.Equal(t, "{oops", letter.Message.Data["raw"])
+	require.Equal(t, "order.reminder", letter.Message.Topic)
+}
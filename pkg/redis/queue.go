@@ -31,6 +31,8 @@ type Message struct {
 	Timestamp time.Time              `json:"timestamp"`
 	Retry     int                    `json:"retry"`
 	MaxRetry  int                    `json:"max_retry"`
+	Schema    string                 `json:"schema,omitempty"`  // 消息契约名称，Publish[T] 发布的消息才有
+	Version   int                    `json:"version,omitempty"` // 消息契约版本
 }
 
 // MessageHandler 消息处理器
@@ -41,24 +43,32 @@ type RedisQueue struct {
 	client        *Client
 	prefix        string
 	delayedWorker *DelayedWorker
+	contracts     *ContractRegistry
 }
 
-// NewQueue 创建 Redis 消息队列
+// NewQueue 创建 Redis 消息队列，使用 DefaultContracts 中的消息契约
 func NewQueue(client *Client, prefix string) *RedisQueue {
 	return &RedisQueue{
 		client:        client,
 		prefix:        prefix,
 		delayedWorker: NewDelayedWorker(client, time.Second),
+		contracts:     DefaultContracts,
 	}
 }
 
-// Publish 发布消息
-func (q *RedisQueue) Publish(ctx context.Context, topic string, data map[string]interface{}) error {
-	if !q.client.IsEnabled() {
-		return nil
-	}
+// Contracts 队列使用的消息契约注册表
+func (q *RedisQueue) Contracts() *ContractRegistry {
+	return q.contracts
+}
 
-	message := &Message{
+// SetContracts 替换消息契约注册表
+func (q *RedisQueue) SetContracts(r *ContractRegistry) {
+	q.contracts = r
+}
+
+// newMessage 创建消息
+func (q *RedisQueue) newMessage(topic string, data map[string]interface{}) *Message {
+	return &Message{
 		ID:        q.generateMessageID(),
 		Topic:     topic,
 		Data:      data,
@@ -66,6 +76,18 @@ func (q *RedisQueue) Publish(ctx context.Context, topic string, data map[string]
 		Retry:     0,
 		MaxRetry:  3,
 	}
+}
+
+// Publish 发布消息
+func (q *RedisQueue) Publish(ctx context.Context, topic string, data map[string]interface{}) error {
+	return q.publish(ctx, q.newMessage(topic, data))
+}
+
+func (q *RedisQueue) publish(ctx context.Context, message *Message) error {
+	if !q.client.IsEnabled() {
+		return nil
+	}
+	topic := message.Topic
 
 	messageBytes, err := json.Marshal(message)
 	if err != nil {
@@ -73,7 +95,7 @@ func (q *RedisQueue) Publish(ctx context.Context, topic string, data map[string]
 	}
 
 	// 发布到 Redis Stream
-	streamKey := q.prefix + "mq:" + topic
+	streamKey := q.streamKey(topic)
 	_, err = q.client.client.XAdd(ctx, &redis.XAddArgs{
 		Stream: streamKey,
 		Values: map[string]interface{}{
@@ -90,103 +112,81 @@ func (q *RedisQueue) Publish(ctx context.Context, topic string, data map[string]
 	return nil
 }
 
-// Subscribe 订阅消息
+// Subscribe 使用默认配置订阅消息，见 SubscribeWithOptions
 func (q *RedisQueue) Subscribe(ctx context.Context, topic string, handler MessageHandler) error {
-	if !q.client.IsEnabled() {
-		return nil
-	}
-
-	streamKey := q.prefix + "mq:" + topic
-	groupName := fmt.Sprintf("consumer-group-%s", topic)
-	consumerName := fmt.Sprintf("consumer-%d", time.Now().UnixNano())
-
-	// 创建消费者组
-	err := q.client.client.XGroupCreateMkStream(ctx, streamKey, groupName, "0").Err()
-	if err != nil && err.Error() != "BUSYGROUP Consumer Group name already exists" {
-		return fmt.Errorf("failed to create consumer group: %w", err)
-	}
+	return q.SubscribeWithOptions(ctx, topic, handler, SubscribeOptions{})
+}
 
-	q.client.logger.Info("started consuming messages", "topic", topic, "group", groupName, "consumer", consumerName)
-
-	for {
-		select {
-		case <-ctx.Done():
-			q.client.logger.Info("stopped consuming messages", "topic", topic)
-			return ctx.Err()
-		default:
-			// 读取消息
-			streams, err := q.client.client.XReadGroup(ctx, &redis.XReadGroupArgs{
-				Group:    groupName,
-				Consumer: consumerName,
-				Streams:  []string{streamKey, ">"},
-				Count:    1,
-				Block:    time.Second * 5,
-			}).Result()
-
-			if err != nil {
-				if err == redis.Nil {
-					continue // 没有消息，继续等待
-				}
-				q.client.logger.Error("failed to read message", err, "topic", topic)
-				time.Sleep(time.Second)
-				continue
-			}
+// streamKey 主题对应的 Stream 键
+func (q *RedisQueue) streamKey(topic string) string {
+	return q.prefix + "mq:" + topic
+}
 
-			for _, stream := range streams {
-				for _, message := range stream.Messages {
-					if err := q.processMessage(ctx, message, handler); err != nil {
-						q.client.logger.Error("failed to process message", err, "topic", topic, "message_id", message.ID)
-					}
-				}
-			}
-		}
-	}
+// groupName 主题的消费者组名称
+func (q *RedisQueue) groupName(topic string) string {
+	return fmt.Sprintf("consumer-group-%s", topic)
 }
 
 // processMessage 处理消息
-func (q *RedisQueue) processMessage(ctx context.Context, redisMsg redis.XMessage, handler MessageHandler) error {
-	messageData, exists := redisMsg.Values["message"]
-	if !exists {
-		return fmt.Errorf("message data not found")
-	}
+// 失败时重新发布（重试次数加一）或在达到最大重试次数后发送到死信队列，并确认原消息，避免留在待确认列表中
+func (q *RedisQueue) processMessage(ctx context.Context, topic string, redisMsg redis.XMessage, handler MessageHandler) error {
+	streamKey := q.streamKey(topic)
+	groupName := q.groupName(topic)
 
 	var message Message
-	if err := json.Unmarshal([]byte(messageData.(string)), &message); err != nil {
-		return fmt.Errorf("failed to unmarshal message: %w", err)
+	messageData, ok := redisMsg.Values["message"].(string)
+	if !ok {
+		err := fmt.Errorf("message data not found")
+		q.sendToDeadLetterQueue(ctx, &Message{ID: redisMsg.ID, Topic: topic, Data: redisMsg.Values}, err, redisMsg.ID)
+		return err
+	}
+	if err := json.Unmarshal([]byte(messageData), &message); err != nil {
+		err = fmt.Errorf("failed to unmarshal message: %w", err)
+		q.sendToDeadLetterQueue(ctx, &Message{ID: redisMsg.ID, Topic: topic, Data: map[string]interface{}{"raw": messageData}}, err, redisMsg.ID)
+		return err
+	}
+	if message.Topic == "" {
+		message.Topic = topic
 	}
 
 	// 处理消息
 	if err := handler(ctx, &message); err != nil {
 		// 处理失败，增加重试次数
 		message.Retry++
-		if message.Retry < message.MaxRetry {
-			// 重新发布消息
+		if message.Retry < message.MaxRetry && !IsPermanent(err) {
+			// 重新发布消息并确认原消息
 			messageBytes, _ := json.Marshal(message)
-			q.client.client.XAdd(ctx, &redis.XAddArgs{
-				Stream: q.prefix + "mq:" + message.Topic,
-				Values: map[string]interface{}{
-					"message": string(messageBytes),
-				},
+			_, perr := q.client.client.TxPipelined(context.WithoutCancel(ctx), func(pipe redis.Pipeliner) error {
+				pipe.XAdd(ctx, &redis.XAddArgs{
+					Stream: streamKey,
+					Values: map[string]interface{}{
+						"message": string(messageBytes),
+					},
+				})
+				pipe.XAck(ctx, streamKey, groupName, redisMsg.ID)
+				return nil
 			})
+			if perr != nil {
+				q.client.logger.Error("failed to requeue message", perr, "topic", topic, "message_id", message.ID)
+			}
 			q.client.logger.Warn("message processing failed, retrying", "topic", message.Topic, "message_id", message.ID, "retry", message.Retry)
 		} else {
-			// 达到最大重试次数，记录到死信队列
-			q.sendToDeadLetterQueue(ctx, &message, err)
+			// 达到最大重试次数或不可重试（如消息格式错误），记录到死信队列
+			q.sendToDeadLetterQueue(ctx, &message, err, redisMsg.ID)
 		}
 		return err
 	}
 
 	// 处理成功，确认消息
-	streamKey := q.prefix + "mq:" + message.Topic
-	groupName := fmt.Sprintf("consumer-group-%s", message.Topic)
-	q.client.client.XAck(ctx, streamKey, groupName, redisMsg.ID)
+	q.client.client.XAck(context.WithoutCancel(ctx), streamKey, groupName, redisMsg.ID)
 
 	q.client.logger.Info("message processed successfully", "topic", message.Topic, "message_id", message.ID)
 	return nil
 }
 
-// sendToDeadLetterQueue 发送到死信队列
-func (q *RedisQueue) sendToDeadLetterQueue(ctx context.Context, message *Message, err error) {
+// sendToDeadLetterQueue 发送到死信队列并确认原消息
+func (q *RedisQueue) sendToDeadLetterQueue(ctx context.Context, message *Message, err error, streamID string) {
+	ctx = context.WithoutCancel(ctx)
 	deadLetterData := map[string]interface{}{
 		"original_message": message,
 		"error":            err.Error(),
@@ -194,12 +194,20 @@ func (q *RedisQueue) sendToDeadLetterQueue(ctx context.Context, message *Message
 	}
 
 	deadLetterBytes, _ := json.Marshal(deadLetterData)
-	q.client.client.XAdd(ctx, &redis.XAddArgs{
-		Stream: q.prefix + "mq:dead-letter:" + message.Topic,
-		Values: map[string]interface{}{
-			"message": string(deadLetterBytes),
-		},
+	_, perr := q.client.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: q.prefix + "mq:dead-letter:" + message.Topic,
+			Values: map[string]interface{}{
+				"message": string(deadLetterBytes),
+			},
+		})
+		pipe.XAck(ctx, q.streamKey(message.Topic), q.groupName(message.Topic), streamID)
+		return nil
 	})
+	if perr != nil {
+		q.client.logger.Error("failed to send message to dead letter queue", perr, "topic", message.Topic, "message_id", message.ID)
+		return
+	}
 
 	q.client.logger.Error("message sent to dead letter queue", err, "topic", message.Topic, "message_id", message.ID)
 }
@@ -215,8 +223,7 @@ func (q *RedisQueue) GetQueueLength(ctx context.Context, topic string) (int64, e
 		return 0, nil
 	}
 
-	streamKey := q.prefix + "mq:" + topic
-	return q.client.client.XLen(ctx, streamKey).Result()
+	return q.client.client.XLen(ctx, q.streamKey(topic)).Result()
 }
 
 // PurgeQueue 清空队列
@@ -225,24 +232,20 @@ func (q *RedisQueue) PurgeQueue(ctx context.Context, topic string) error {
 		return nil
 	}
 
-	streamKey := q.prefix + "mq:" + topic
-	return q.client.client.Del(ctx, streamKey).Err()
+	return q.client.client.Del(ctx, q.streamKey(topic)).Err()
 }
 
 // PublishWithDelay 延迟发布消息
 func (q *RedisQueue) PublishWithDelay(ctx context.Context, topic string, data map[string]interface{}, delay time.Duration) error {
+	return q.publishWithDelay(ctx, q.newMessage(topic, data), delay)
+}
+
+func (q *RedisQueue) publishWithDelay(ctx context.Context, message *Message, delay time.Duration) error {
 	if !q.client.IsEnabled() {
 		return nil
 	}
-
-	message := &Message{
-		ID:        q.generateMessageID(),
-		Topic:     topic,
-		Data:      data,
-		Timestamp: time.Now().Add(delay),
-		Retry:     0,
-		MaxRetry:  3,
-	}
+	topic := message.Topic
+	message.Timestamp = time.Now().Add(delay)
 
 	messageBytes, err := json.Marshal(message)
 	if err != nil {
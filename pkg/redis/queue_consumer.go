@@ -0,0 +1,328 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SubscribeOptions 消费者配置
+type SubscribeOptions struct {
+	Consumer          string        `mapstructure:"consumer" json:"consumer"`                     // 消费者名称，默认 <主机名>；重启后沿用同一名称以继续处理自己未确认的消息
+	Workers           int           `mapstructure:"workers" json:"workers"`                       // 并发处理的消息数，默认 1
+	BatchSize         int           `mapstructure:"batch_size" json:"batch_size"`                 // 每次最多读取的消息数（不超过空闲的 worker 数），默认 10
+	Block             time.Duration `mapstructure:"block" json:"block"`                           // 没有消息时阻塞等待的时间，默认 5s
+	VisibilityTimeout time.Duration `mapstructure:"visibility_timeout" json:"visibility_timeout"` // 处理超时；超过该时间未确认的消息会被其他消费者认领，默认 1m
+	ClaimInterval     time.Duration `mapstructure:"claim_interval" json:"claim_interval"`         // 认领超时消息（XAUTOCLAIM）的间隔，默认 VisibilityTimeout 的一半
+	TrimInterval      time.Duration `mapstructure:"trim_interval" json:"trim_interval"`           // 删除所有消费者组都已确认的消息的间隔，默认 1m，负数表示不删除
+	ShutdownTimeout   time.Duration `mapstructure:"shutdown_timeout" json:"shutdown_timeout"`     // 停止时等待处理中消息的时间，默认 30s
+}
+
+// Normalize 填充默认值
+func (o *SubscribeOptions) Normalize() {
+	if o.Consumer == "" {
+		o.Consumer = defaultConsumerName()
+	}
+	if o.Workers <= 0 {
+		o.Workers = 1
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = 10
+	}
+	if o.Block <= 0 {
+		o.Block = 5 * time.Second
+	}
+	if o.VisibilityTimeout <= 0 {
+		o.VisibilityTimeout = time.Minute
+	}
+	if o.ClaimInterval <= 0 {
+		o.ClaimInterval = o.VisibilityTimeout / 2
+	}
+	if o.TrimInterval == 0 {
+		o.TrimInterval = time.Minute
+	}
+	if o.ShutdownTimeout <= 0 {
+		o.ShutdownTimeout = 30 * time.Second
+	}
+}
+
+// defaultConsumerName 默认消费者名称：主机名（容器中为 Pod 名），可通过 QUEUE_CONSUMER_NAME 环境变量覆盖
+func defaultConsumerName() string {
+	if name := os.Getenv("QUEUE_CONSUMER_NAME"); name != "" {
+		return name
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return host
+	}
+	return "consumer"
+}
+
+// SubscribeWithOptions 按配置订阅消息，阻塞直到 ctx 取消
+// 启动时先处理本消费者名下未确认的消息，之后定期认领其他消费者超过 VisibilityTimeout 未确认的消息（如进程崩溃时正在处理的消息）；
+// ctx 取消后不再读取新消息，等待处理中的消息完成（最多 ShutdownTimeout），未完成的消息留在待确认列表中由之后的消费者认领
+func (q *RedisQueue) SubscribeWithOptions(ctx context.Context, topic string, handler MessageHandler, opts SubscribeOptions) error {
+	if !q.client.IsEnabled() {
+		return nil
+	}
+	opts.Normalize()
+
+	streamKey := q.streamKey(topic)
+	groupName := q.groupName(topic)
+
+	// 创建消费者组
+	err := q.client.client.XGroupCreateMkStream(ctx, streamKey, groupName, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group: %w", err)
+	}
+
+	c := &streamConsumer{
+		queue:   q,
+		topic:   topic,
+		stream:  streamKey,
+		group:   groupName,
+		opts:    opts,
+		handler: handler,
+		slots:   make(chan struct{}, opts.Workers),
+	}
+	// 处理中的消息不随 ctx 取消，停止时有机会完成
+	handlerCtx, cancelHandlers := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelHandlers()
+
+	q.client.logger.Info("started consuming messages", "topic", topic, "group", groupName, "consumer", opts.Consumer,
+		"workers", opts.Workers, "batch_size", opts.BatchSize)
+
+	c.run(ctx, handlerCtx)
+
+	// 等待处理中的消息
+	done := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(opts.ShutdownTimeout):
+		q.client.logger.Warn("shutdown timeout, abandoning in-flight messages", "topic", topic, "timeout", opts.ShutdownTimeout)
+		cancelHandlers()
+		<-done
+	}
+
+	q.client.logger.Info("stopped consuming messages", "topic", topic)
+	return ctx.Err()
+}
+
+// streamConsumer 一个主题的消费循环
+type streamConsumer struct {
+	queue   *RedisQueue
+	topic   string
+	stream  string
+	group   string
+	opts    SubscribeOptions
+	handler MessageHandler
+
+	slots chan struct{} // 空闲的 worker
+	wg    sync.WaitGroup
+}
+
+func (c *streamConsumer) run(ctx, handlerCtx context.Context) {
+	log := c.queue.client.logger
+
+	// 先处理本消费者上次退出时未确认的消息
+	pending := "0"
+	claimCursor := "0-0"
+	nextClaim := time.Now()
+	nextTrim := time.Now().Add(c.opts.TrimInterval)
+
+	for ctx.Err() == nil {
+		if c.opts.TrimInterval > 0 && time.Now().After(nextTrim) {
+			if err := c.queue.trimAcknowledged(ctx, c.stream); err != nil && ctx.Err() == nil {
+				log.Warn("failed to trim stream", "topic", c.topic, "error", err)
+			}
+			nextTrim = time.Now().Add(c.opts.TrimInterval)
+		}
+
+		n := c.acquire(ctx)
+		if n == 0 {
+			return
+		}
+
+		var messages []redis.XMessage
+		var err error
+		switch {
+		case pending != "":
+			messages, err = c.read(ctx, pending, n, -1)
+			if err == nil {
+				if len(messages) == 0 {
+					pending = ""
+				} else {
+					pending = messages[len(messages)-1].ID
+				}
+			}
+		case time.Now().After(nextClaim):
+			messages, claimCursor, err = c.queue.client.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+				Stream:   c.stream,
+				Group:    c.group,
+				Consumer: c.opts.Consumer,
+				MinIdle:  c.opts.VisibilityTimeout,
+				Start:    claimCursor,
+				Count:    int64(n),
+			}).Result()
+			// 游标回到 0-0 表示一轮扫描结束，等待下一个周期
+			if err != nil || claimCursor == "0-0" {
+				claimCursor = "0-0"
+				nextClaim = time.Now().Add(c.opts.ClaimInterval)
+			}
+			if len(messages) > 0 {
+				log.Warn("claimed idle pending messages", "topic", c.topic, "consumer", c.opts.Consumer, "count", len(messages))
+			}
+		default:
+			messages, err = c.read(ctx, ">", n, c.opts.Block)
+		}
+
+		if err != nil && !errors.Is(err, redis.Nil) {
+			c.release(n)
+			if ctx.Err() != nil {
+				return
+			}
+			log.Error("failed to read message", err, "topic", c.topic)
+			sleepContext(ctx, time.Second)
+			continue
+		}
+
+		for _, message := range messages {
+			// 已被删除的消息只剩 ID，直接确认
+			if len(message.Values) == 0 {
+				c.queue.client.client.XAck(ctx, c.stream, c.group, message.ID)
+				c.release(1)
+				continue
+			}
+			c.wg.Add(1)
+			go func(message redis.XMessage) {
+				defer c.wg.Done()
+				defer c.release(1)
+				msgCtx, cancel := context.WithTimeout(handlerCtx, c.opts.VisibilityTimeout)
+				defer cancel()
+				if err := c.queue.processMessage(msgCtx, c.topic, message, c.handler); err != nil {
+					log.Error("failed to process message", err, "topic", c.topic, "message_id", message.ID)
+				}
+			}(message)
+			n--
+		}
+		c.release(n)
+	}
+}
+
+// read 读取消息，id 为 ">" 时读取新消息，否则读取本消费者待确认的消息
+func (c *streamConsumer) read(ctx context.Context, id string, count int, block time.Duration) ([]redis.XMessage, error) {
+	streams, err := c.queue.client.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    c.group,
+		Consumer: c.opts.Consumer,
+		Streams:  []string{c.stream, id},
+		Count:    int64(count),
+		Block:    block,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	var messages []redis.XMessage
+	for _, stream := range streams {
+		messages = append(messages, stream.Messages...)
+	}
+	return messages, nil
+}
+
+// acquire 等待至少一个空闲 worker，返回本次可以读取的消息数（不超过 BatchSize），ctx 取消时返回 0
+func (c *streamConsumer) acquire(ctx context.Context) int {
+	select {
+	case c.slots <- struct{}{}:
+	case <-ctx.Done():
+		return 0
+	}
+	n := 1
+	for n < c.opts.BatchSize {
+		select {
+		case c.slots <- struct{}{}:
+			n++
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+func (c *streamConsumer) release(n int) {
+	for i := 0; i < n; i++ {
+		<-c.slots
+	}
+}
+
+// trimAcknowledged 删除所有消费者组都已确认的消息
+func (q *RedisQueue) trimAcknowledged(ctx context.Context, streamKey string) error {
+	groups, err := q.client.client.XInfoGroups(ctx, streamKey).Result()
+	if err != nil || len(groups) == 0 {
+		return err
+	}
+	minID := ""
+	for _, g := range groups {
+		// 有待确认消息时保留最早的待确认消息，否则保留最后投递的消息之后的消息
+		id := g.LastDeliveredID
+		if g.Pending > 0 {
+			summary, err := q.client.client.XPending(ctx, streamKey, g.Name).Result()
+			if err != nil {
+				return err
+			}
+			id = summary.Lower
+		}
+		if minID == "" || compareStreamID(id, minID) < 0 {
+			minID = id
+		}
+	}
+	if minID == "" || minID == "0-0" {
+		return nil
+	}
+	return q.client.client.XTrimMinIDApprox(ctx, streamKey, minID, 0).Err()
+}
+
+// compareStreamID 比较两个 Stream 消息 ID（<毫秒>-<序号>）
+func compareStreamID(a, b string) int {
+	am, as := parseStreamID(a)
+	bm, bs := parseStreamID(b)
+	switch {
+	case am != bm:
+		if am < bm {
+			return -1
+		}
+		return 1
+	case as != bs:
+		if as < bs {
+			return -1
+		}
+		return 1
+	default:
+		return 0
+	}
+}
+
+func parseStreamID(id string) (ms, seq uint64) {
+	msPart, seqPart, _ := strings.Cut(id, "-")
+	ms, _ = strconv.ParseUint(msPart, 10, 64)
+	seq, _ = strconv.ParseUint(seqPart, 10, 64)
+	return ms, seq
+}
+
+// sleepContext 等待 d 或 ctx 取消
+func sleepContext(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,43 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscribeOptions_Normalize(t *testing.T) {
+	opts := SubscribeOptions{Consumer: "worker-1", VisibilityTimeout: 2 * time.Minute, TrimInterval: -1}
+	opts.Normalize()
+	require.Equal(t, "worker-1", opts.Consumer)
+	require.Equal(t, 1, opts.Workers)
+	require.Equal(t, 10, opts.BatchSize)
+	require.Equal(t, time.Minute, opts.ClaimInterval)
+	require.Equal(t, time.Duration(-1), opts.TrimInterval)
+
+	t.Setenv("QUEUE_CONSUMER_NAME", "pod-a")
+	opts = SubscribeOptions{}
+	opts.Normalize()
+	require.Equal(t, "pod-a", opts.Consumer)
+}
+
+func TestStreamConsumer_AcquireBoundedByIdleWorkers(t *testing.T) {
+	c := &streamConsumer{opts: SubscribeOptions{BatchSize: 10}, slots: make(chan struct{}, 3)}
+	require.Equal(t, 3, c.acquire(context.Background()))
+	c.release(2)
+	require.Equal(t, 2, c.acquire(context.Background()))
+
+	// 没有空闲 worker 时等待，ctx 取消后返回 0
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.Equal(t, 0, c.acquire(ctx))
+}
+
+func TestCompareStreamID(t *testing.T) {
+	require.Equal(t, -1, compareStreamID("1700000000000-5", "1700000000001-0"))
+	require.Equal(t, -1, compareStreamID("1700000000000-5", "1700000000000-12"))
+	require.Equal(t, 1, compareStreamID("1700000000000-12", "1700000000000-5"))
+	require.Equal(t, 0, compareStreamID("0-0", "0-0"))
+}
@@ -0,0 +1,116 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrBulkheadFull 并发数和排队数都已满
+var ErrBulkheadFull = errors.New("resilience: bulkhead is full")
+
+// BulkheadConfig 舱壁配置
+type BulkheadConfig struct {
+	MaxConcurrent int           // 最大并发数，默认 10
+	MaxQueue      int           // 最大排队数，0 表示不排队
+	MaxWait       time.Duration // 排队最长等待时间，0 表示只受 ctx 限制
+}
+
+// Bulkhead 舱壁隔离，限制对同一依赖的并发调用，避免拖垮调用方
+type Bulkhead struct {
+	name    string
+	cfg     BulkheadConfig
+	sem     chan struct{}
+	active  atomic.Int64
+	queued  atomic.Int64
+	metrics *Metrics
+}
+
+// NewBulkhead 创建舱壁
+func NewBulkhead(name string, cfg BulkheadConfig, metrics *Metrics) *Bulkhead {
+	if cfg.MaxConcurrent <= 0 {
+		cfg.MaxConcurrent = 10
+	}
+	if cfg.MaxQueue < 0 {
+		cfg.MaxQueue = 0
+	}
+	return &Bulkhead{
+		name:    name,
+		cfg:     cfg,
+		sem:     make(chan struct{}, cfg.MaxConcurrent),
+		metrics: metrics,
+	}
+}
+
+// Execute 获取并发名额后执行调用，名额不足时排队，队列已满时返回 ErrBulkheadFull
+func (b *Bulkhead) Execute(ctx context.Context, fn Func) (interface{}, error) {
+	if err := b.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer b.release()
+
+	return fn(ctx)
+}
+
+// Active 正在执行的调用数
+func (b *Bulkhead) Active() int64 {
+	return b.active.Load()
+}
+
+// Queued 正在排队的调用数
+func (b *Bulkhead) Queued() int64 {
+	return b.queued.Load()
+}
+
+// acquire 获取并发名额
+func (b *Bulkhead) acquire(ctx context.Context) error {
+	select {
+	case b.sem <- struct{}{}:
+		b.active.Add(1)
+		b.report()
+		return nil
+	default:
+	}
+
+	if b.queued.Add(1) > int64(b.cfg.MaxQueue) {
+		b.queued.Add(-1)
+		b.metrics.bulkheadReject(b.name)
+		return ErrBulkheadFull
+	}
+	b.report()
+	defer func() {
+		b.queued.Add(-1)
+		b.report()
+	}()
+
+	var timeout <-chan time.Time
+	if b.cfg.MaxWait > 0 {
+		timer := time.NewTimer(b.cfg.MaxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case b.sem <- struct{}{}:
+		b.active.Add(1)
+		return nil
+	case <-timeout:
+		b.metrics.bulkheadReject(b.name)
+		return ErrBulkheadFull
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release 归还并发名额
+func (b *Bulkhead) release() {
+	b.active.Add(-1)
+	<-b.sem
+	b.report()
+}
+
+// report 上报当前并发数和排队数
+func (b *Bulkhead) report() {
+	b.metrics.bulkheadState(b.name, b.active.Load(), b.queued.Load())
+}
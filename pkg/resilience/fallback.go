@@ -0,0 +1,28 @@
+package resilience
+
+import "context"
+
+// Fallback 调用失败时的降级处理
+type Fallback struct {
+	name     string
+	fallback func(ctx context.Context, err error) (interface{}, error)
+	when     func(err error) bool
+	metrics  *Metrics
+}
+
+// NewFallback 创建降级策略
+// when 为空时所有错误都降级，例如只在熔断时降级：when = circuit.IsRejected
+func NewFallback(name string, fallback func(ctx context.Context, err error) (interface{}, error), when func(err error) bool, metrics *Metrics) *Fallback {
+	return &Fallback{name: name, fallback: fallback, when: when, metrics: metrics}
+}
+
+// Execute 执行调用，失败且满足条件时返回降级结果
+func (f *Fallback) Execute(ctx context.Context, fn Func) (interface{}, error) {
+	result, err := fn(ctx)
+	if err == nil || (f.when != nil && !f.when(err)) {
+		return result, err
+	}
+
+	f.metrics.fallback(f.name)
+	return f.fallback(ctx, err)
+}
@@ -0,0 +1,94 @@
+package resilience
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"goweb/pkg/monitor"
+)
+
+// Metrics 容错策略的监控指标，nil 时不记录
+type Metrics struct {
+	service          string
+	retries          *prometheus.CounterVec
+	timeouts         *prometheus.CounterVec
+	bulkheadRejected *prometheus.CounterVec
+	bulkheadActive   *prometheus.GaugeVec
+	bulkheadQueued   *prometheus.GaugeVec
+	fallbacks        *prometheus.CounterVec
+}
+
+// NewMetrics 通过 monitor.Metrics 注册容错策略指标
+func NewMetrics(m *monitor.Metrics, serviceName string) *Metrics {
+	return &Metrics{
+		service: serviceName,
+		retries: m.CreateCustomCounter(
+			"resilience_retries_total",
+			"Total number of retry decisions by outcome",
+			[]string{"name", "outcome", "service"},
+		),
+		timeouts: m.CreateCustomCounter(
+			"resilience_timeouts_total",
+			"Total number of calls that timed out",
+			[]string{"name", "service"},
+		),
+		bulkheadRejected: m.CreateCustomCounter(
+			"resilience_bulkhead_rejected_total",
+			"Total number of calls rejected by a bulkhead",
+			[]string{"name", "service"},
+		),
+		bulkheadActive: m.CreateCustomGauge(
+			"resilience_bulkhead_active",
+			"Number of calls currently running inside a bulkhead",
+			[]string{"name", "service"},
+		),
+		bulkheadQueued: m.CreateCustomGauge(
+			"resilience_bulkhead_queued",
+			"Number of calls waiting for a bulkhead slot",
+			[]string{"name", "service"},
+		),
+		fallbacks: m.CreateCustomCounter(
+			"resilience_fallbacks_total",
+			"Total number of fallback invocations",
+			[]string{"name", "service"},
+		),
+	}
+}
+
+// 重试结果
+const (
+	retryOutcomeRetried   = "retried"          // 发起了一次重试
+	retryOutcomeExhausted = "exhausted"        // 达到最大次数仍失败
+	retryOutcomeBudget    = "budget_exhausted" // 重试预算不足
+	retryOutcomeRecovered = "recovered"        // 重试后成功
+)
+
+func (m *Metrics) retry(name, outcome string) {
+	if m != nil {
+		m.retries.WithLabelValues(name, outcome, m.service).Inc()
+	}
+}
+
+func (m *Metrics) timeout(name string) {
+	if m != nil {
+		m.timeouts.WithLabelValues(name, m.service).Inc()
+	}
+}
+
+func (m *Metrics) bulkheadReject(name string) {
+	if m != nil {
+		m.bulkheadRejected.WithLabelValues(name, m.service).Inc()
+	}
+}
+
+func (m *Metrics) bulkheadState(name string, active, queued int64) {
+	if m != nil {
+		m.bulkheadActive.WithLabelValues(name, m.service).Set(float64(active))
+		m.bulkheadQueued.WithLabelValues(name, m.service).Set(float64(queued))
+	}
+}
+
+func (m *Metrics) fallback(name string) {
+	if m != nil {
+		m.fallbacks.WithLabelValues(name, m.service).Inc()
+	}
+}
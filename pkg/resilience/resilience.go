@@ -0,0 +1,128 @@
+// Package resilience 提供可组合的容错策略：重试、超时、舱壁隔离、降级，
+// 与 pkg/circuit 的熔断器共用 ExecuteWithContext 的调用签名
+package resilience
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"goweb/pkg/circuit"
+)
+
+// Func 受保护的调用，与 circuit.Breaker.ExecuteWithContext 的参数一致
+type Func func(ctx context.Context) (interface{}, error)
+
+// Policy 容错策略
+type Policy interface {
+	Execute(ctx context.Context, fn Func) (interface{}, error)
+}
+
+// PolicyFunc 函数形式的策略
+type PolicyFunc func(ctx context.Context, fn Func) (interface{}, error)
+
+// Execute 执行调用
+func (f PolicyFunc) Execute(ctx context.Context, fn Func) (interface{}, error) {
+	return f(ctx, fn)
+}
+
+// Pipeline 策略组合，按传入顺序由外向内包装调用
+// 推荐顺序：Fallback → Retry → Timeout → Bulkhead → Breaker，
+// 即每次重试单独计时、单独占用并发名额、单独计入熔断统计
+type Pipeline struct {
+	policies []Policy
+}
+
+// NewPipeline 创建策略组合
+func NewPipeline(policies ...Policy) *Pipeline {
+	return &Pipeline{policies: policies}
+}
+
+// Execute 依次经过所有策略执行调用
+func (p *Pipeline) Execute(ctx context.Context, fn Func) (interface{}, error) {
+	wrapped := fn
+	for i := len(p.policies) - 1; i >= 0; i-- {
+		policy, next := p.policies[i], wrapped
+		wrapped = func(ctx context.Context) (interface{}, error) {
+			return policy.Execute(ctx, next)
+		}
+	}
+	return wrapped(ctx)
+}
+
+// Breaker 将熔断器包装为策略
+func Breaker(cb *circuit.Breaker) Policy {
+	return PolicyFunc(func(ctx context.Context, fn Func) (interface{}, error) {
+		return cb.ExecuteWithContext(ctx, fn)
+	})
+}
+
+type idempotentKey struct{}
+
+// WithIdempotent 标记本次调用是否幂等，非幂等调用只重试 MarkRetryable 标记过的错误
+func WithIdempotent(ctx context.Context, idempotent bool) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, idempotent)
+}
+
+// WithMethod 按 HTTP 方法标记本次调用是否幂等
+func WithMethod(ctx context.Context, method string) context.Context {
+	return WithIdempotent(ctx, IdempotentMethod(method))
+}
+
+// Idempotent 调用是否幂等，未标记时视为幂等
+func Idempotent(ctx context.Context) bool {
+	if v, ok := ctx.Value(idempotentKey{}).(bool); ok {
+		return v
+	}
+	return true
+}
+
+// IdempotentMethod HTTP 方法是否幂等
+func IdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryableError 标记为可安全重试的错误
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string   { return e.err.Error() }
+func (e *retryableError) Unwrap() error   { return e.err }
+func (e *retryableError) Retryable() bool { return true }
+
+// MarkRetryable 标记错误可以安全重试（如请求尚未发出的连接错误），非幂等调用也会重试
+func MarkRetryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &retryableError{err: err}
+}
+
+// permanentError 标记为不可重试的错误
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string   { return e.err.Error() }
+func (e *permanentError) Unwrap() error   { return e.err }
+func (e *permanentError) Retryable() bool { return false }
+
+// Permanent 标记错误不可重试，如参数校验失败
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// isMarkedRetryable 错误是否经 MarkRetryable 标记
+func isMarkedRetryable(err error) bool {
+	var re *retryableError
+	return errors.As(err, &re)
+}
@@ -0,0 +1,139 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var errTransient = errors.New("transient")
+
+func failing(calls *int, failures int) Func {
+	return func(ctx context.Context) (interface{}, error) {
+		*calls++
+		if *calls <= failures {
+			return nil, errTransient
+		}
+		return "ok", nil
+	}
+}
+
+func TestRetry_IdempotentOnly(t *testing.T) {
+	retry := NewRetry("test", RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond}, nil)
+
+	calls := 0
+	result, err := retry.Execute(WithMethod(context.Background(), "GET"), failing(&calls, 2))
+	require.NoError(t, err)
+	require.Equal(t, "ok", result)
+	require.Equal(t, 3, calls)
+
+	// 非幂等调用不重试
+	calls = 0
+	_, err = retry.Execute(WithMethod(context.Background(), "POST"), failing(&calls, 2))
+	require.ErrorIs(t, err, errTransient)
+	require.Equal(t, 1, calls)
+
+	// 标记为可重试的错误即使非幂等也重试
+	calls = 0
+	_, err = retry.Execute(WithMethod(context.Background(), "POST"), func(ctx context.Context) (interface{}, error) {
+		calls++
+		if calls == 1 {
+			return nil, MarkRetryable(errTransient)
+		}
+		return "ok", nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, calls)
+
+	// Permanent 错误不重试
+	calls = 0
+	_, err = retry.Execute(context.Background(), func(ctx context.Context) (interface{}, error) {
+		calls++
+		return nil, Permanent(errTransient)
+	})
+	require.ErrorIs(t, err, errTransient)
+	require.Equal(t, 1, calls)
+}
+
+func TestRetry_Budget(t *testing.T) {
+	budget := NewRetryBudget(0, 0, time.Second)
+	retry := NewRetry("test", RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, Budget: budget}, nil)
+
+	calls := 0
+	_, err := retry.Execute(context.Background(), failing(&calls, 2))
+	require.ErrorIs(t, err, errTransient)
+	require.Equal(t, 1, calls)
+}
+
+func TestRetry_JitterDefault(t *testing.T) {
+	// 未设置时默认 0.2，抖动范围为 ±20%
+	retry := NewRetry("test", RetryConfig{}, nil)
+	require.Equal(t, 0.2, retry.cfg.Jitter)
+	spread := map[time.Duration]bool{}
+	for i := 0; i < 50; i++ {
+		d := retry.jitter(time.Second)
+		require.GreaterOrEqual(t, d, 800*time.Millisecond)
+		require.LessOrEqual(t, d, 1200*time.Millisecond)
+		spread[d] = true
+	}
+	require.Greater(t, len(spread), 1)
+
+	// 负数表示不抖动
+	retry = NewRetry("test", RetryConfig{Jitter: -1}, nil)
+	require.Equal(t, time.Second, retry.jitter(time.Second))
+}
+
+func TestBulkhead_QueueAndReject(t *testing.T) {
+	bulkhead := NewBulkhead("test", BulkheadConfig{MaxConcurrent: 1, MaxQueue: 1, MaxWait: time.Second}, nil)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go bulkhead.Execute(context.Background(), func(ctx context.Context) (interface{}, error) {
+		close(started)
+		<-release
+		return nil, nil
+	})
+	<-started
+
+	queued := make(chan error, 1)
+	go func() {
+		_, err := bulkhead.Execute(context.Background(), func(ctx context.Context) (interface{}, error) {
+			return nil, nil
+		})
+		queued <- err
+	}()
+	require.Eventually(t, func() bool { return bulkhead.Queued() == 1 }, time.Second, time.Millisecond)
+
+	// 并发和队列都已满
+	_, err := bulkhead.Execute(context.Background(), func(ctx context.Context) (interface{}, error) {
+		return nil, nil
+	})
+	require.ErrorIs(t, err, ErrBulkheadFull)
+
+	close(release)
+	require.NoError(t, <-queued)
+}
+
+func TestPipeline_TimeoutWithFallback(t *testing.T) {
+	pipeline := NewPipeline(
+		NewFallback("test", func(ctx context.Context, err error) (interface{}, error) {
+			return "fallback", nil
+		}, func(err error) bool { return errors.Is(err, ErrTimeout) }, nil),
+		NewRetry("test", RetryConfig{MaxAttempts: 2, InitialBackoff: time.Millisecond}, nil),
+		NewTimeout("test", 10*time.Millisecond, nil),
+	)
+
+	var calls atomic.Int32
+	result, err := pipeline.Execute(context.Background(), func(ctx context.Context) (interface{}, error) {
+		calls.Add(1)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	require.NoError(t, err)
+	require.Equal(t, "fallback", result)
+	require.Equal(t, int32(2), calls.Load())
+}
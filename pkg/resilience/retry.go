@@ -0,0 +1,213 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"goweb/pkg/circuit"
+)
+
+// RetryConfig 重试配置
+type RetryConfig struct {
+	MaxAttempts    int           // 最大尝试次数（含首次），默认 3
+	InitialBackoff time.Duration // 首次重试前的等待时间，默认 100ms
+	MaxBackoff     time.Duration // 等待时间上限，默认 2s
+	Multiplier     float64       // 退避倍数，默认 2
+	Jitter         float64       // 随机抖动比例（0-1），默认 0.2，负数表示不抖动
+	// RetryIf 判断幂等调用的错误是否可重试，默认使用 DefaultRetryIf
+	RetryIf func(err error) bool
+	// Budget 重试预算，多个 Retry 可共享，为空时不限制
+	Budget *RetryBudget
+}
+
+// Retry 指数退避重试
+type Retry struct {
+	name    string
+	cfg     RetryConfig
+	metrics *Metrics
+}
+
+// NewRetry 创建重试策略
+func NewRetry(name string, cfg RetryConfig, metrics *Metrics) *Retry {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 3
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = 100 * time.Millisecond
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = 2 * time.Second
+	}
+	if cfg.Multiplier < 1 {
+		cfg.Multiplier = 2
+	}
+	if cfg.Jitter == 0 || cfg.Jitter > 1 {
+		cfg.Jitter = 0.2
+	} else if cfg.Jitter < 0 {
+		cfg.Jitter = 0
+	}
+	if cfg.RetryIf == nil {
+		cfg.RetryIf = DefaultRetryIf
+	}
+	return &Retry{name: name, cfg: cfg, metrics: metrics}
+}
+
+// DefaultRetryIf 默认的可重试判断
+// 熔断拒绝、舱壁拒绝、上下文取消和 Permanent 错误不重试；
+// 实现了 Retryable() bool 的错误由自身决定
+func DefaultRetryIf(err error) bool {
+	if circuit.IsRejected(err) || errors.Is(err, ErrBulkheadFull) ||
+		errors.Is(err, context.Canceled) {
+		return false
+	}
+	var r interface{ Retryable() bool }
+	if errors.As(err, &r) {
+		return r.Retryable()
+	}
+	return true
+}
+
+// Execute 执行调用，失败时按退避策略重试
+func (r *Retry) Execute(ctx context.Context, fn Func) (interface{}, error) {
+	if r.cfg.Budget != nil {
+		r.cfg.Budget.deposit()
+	}
+
+	backoff := r.cfg.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		result, err := fn(ctx)
+		if err == nil {
+			if attempt > 1 {
+				r.metrics.retry(r.name, retryOutcomeRecovered)
+			}
+			return result, nil
+		}
+
+		if !r.shouldRetry(ctx, err) {
+			return result, err
+		}
+		if attempt >= r.cfg.MaxAttempts {
+			r.metrics.retry(r.name, retryOutcomeExhausted)
+			return result, err
+		}
+		if r.cfg.Budget != nil && !r.cfg.Budget.withdraw() {
+			r.metrics.retry(r.name, retryOutcomeBudget)
+			return result, err
+		}
+
+		timer := time.NewTimer(r.jitter(backoff))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return result, err
+		case <-timer.C:
+		}
+
+		r.metrics.retry(r.name, retryOutcomeRetried)
+		backoff = time.Duration(float64(backoff) * r.cfg.Multiplier)
+		if backoff > r.cfg.MaxBackoff {
+			backoff = r.cfg.MaxBackoff
+		}
+	}
+}
+
+// shouldRetry 是否重试：调用方未取消，且错误经过标记或调用幂等且错误可重试
+func (r *Retry) shouldRetry(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if isMarkedRetryable(err) {
+		return true
+	}
+	return Idempotent(ctx) && r.cfg.RetryIf(err)
+}
+
+// jitter 在退避时间上叠加随机抖动，避免重试同步
+func (r *Retry) jitter(d time.Duration) time.Duration {
+	if r.cfg.Jitter == 0 {
+		return d
+	}
+	delta := float64(d) * r.cfg.Jitter
+	return time.Duration(float64(d) - delta + rand.Float64()*2*delta)
+}
+
+// RetryBudget 重试预算
+// 窗口内的重试次数不超过 请求数 × Ratio + 每秒最少重试数 × 窗口秒数，
+// 防止下游故障时重试放大流量
+type RetryBudget struct {
+	ratio        float64
+	minPerSecond int
+
+	mu      sync.Mutex
+	buckets []budgetBucket
+}
+
+// budgetBucket 一秒内的请求数和重试数
+type budgetBucket struct {
+	second   int64
+	requests int
+	retries  int
+}
+
+// NewRetryBudget 创建重试预算
+// ratio 为重试占请求的比例（如 0.2），minPerSecond 保证低流量时也能重试，window 为统计窗口
+func NewRetryBudget(ratio float64, minPerSecond int, window time.Duration) *RetryBudget {
+	seconds := int(window / time.Second)
+	if seconds <= 0 {
+		seconds = 10
+	}
+	return &RetryBudget{
+		ratio:        ratio,
+		minPerSecond: minPerSecond,
+		buckets:      make([]budgetBucket, seconds),
+	}
+}
+
+// deposit 记录一次请求
+func (b *RetryBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.bucket(time.Now()).requests++
+}
+
+// withdraw 申请一次重试，预算不足时返回 false
+func (b *RetryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	requests, retries := b.totals(now)
+	allowed := float64(requests)*b.ratio + float64(b.minPerSecond*len(b.buckets))
+	if float64(retries+1) > allowed {
+		return false
+	}
+	b.bucket(now).retries++
+	return true
+}
+
+// bucket 当前秒对应的桶
+func (b *RetryBudget) bucket(now time.Time) *budgetBucket {
+	sec := now.Unix()
+	bk := &b.buckets[sec%int64(len(b.buckets))]
+	if bk.second != sec {
+		*bk = budgetBucket{second: sec}
+	}
+	return bk
+}
+
+// totals 窗口内的请求数和重试数
+func (b *RetryBudget) totals(now time.Time) (requests, retries int) {
+	sec := now.Unix()
+	size := int64(len(b.buckets))
+	for _, bk := range b.buckets {
+		if sec-bk.second < size {
+			requests += bk.requests
+			retries += bk.retries
+		}
+	}
+	return requests, retries
+}
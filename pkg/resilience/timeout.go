@@ -0,0 +1,60 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrTimeout 调用超时
+var ErrTimeout = errors.New("resilience: call timed out")
+
+// Timeout 单次调用超时
+// 超时后立即返回 ErrTimeout，调用本身通过 ctx 收到取消信号
+type Timeout struct {
+	name     string
+	duration time.Duration
+	metrics  *Metrics
+}
+
+// NewTimeout 创建超时策略
+func NewTimeout(name string, duration time.Duration, metrics *Metrics) *Timeout {
+	return &Timeout{name: name, duration: duration, metrics: metrics}
+}
+
+// timeoutResult 调用结果
+type timeoutResult struct {
+	value interface{}
+	err   error
+	panic interface{}
+}
+
+// Execute 在超时时间内执行调用
+func (t *Timeout) Execute(ctx context.Context, fn Func) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.duration)
+	defer cancel()
+
+	done := make(chan timeoutResult, 1)
+	go func() {
+		var res timeoutResult
+		defer func() {
+			res.panic = recover()
+			done <- res
+		}()
+		res.value, res.err = fn(ctx)
+	}()
+
+	select {
+	case res := <-done:
+		if res.panic != nil {
+			panic(res.panic)
+		}
+		return res.value, res.err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			t.metrics.timeout(t.name)
+			return nil, ErrTimeout
+		}
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"goweb/pkg/gateway"
+	"goweb/pkg/response"
+)
+
+// callOptions 单次调用的附加参数
+type callOptions struct {
+	headers map[string]string
+	query   map[string]string
+}
+
+// CallOption 调用参数
+type CallOption func(*callOptions)
+
+// WithHeader 添加请求头，覆盖自动透传的同名请求头
+func WithHeader(key, value string) CallOption {
+	return func(o *callOptions) {
+		if o.headers == nil {
+			o.headers = make(map[string]string)
+		}
+		o.headers[key] = value
+	}
+}
+
+// WithHeaders 添加多个请求头
+func WithHeaders(headers map[string]string) CallOption {
+	return func(o *callOptions) {
+		for k, v := range headers {
+			WithHeader(k, v)(o)
+		}
+	}
+}
+
+// WithQuery 添加查询参数
+func WithQuery(key, value string) CallOption {
+	return func(o *callOptions) {
+		if o.query == nil {
+			o.query = make(map[string]string)
+		}
+		o.query[key] = value
+	}
+}
+
+// WithQueryMap 添加多个查询参数
+func WithQueryMap(query map[string]string) CallOption {
+	return func(o *callOptions) {
+		for k, v := range query {
+			WithQuery(k, v)(o)
+		}
+	}
+}
+
+// ServiceClient 服务客户端
+// 传入的 ctx 为 *gin.Context 时自动透传请求 ID、traceparent 和当前用户，也可以用 WithIdentity / WithTraceContext 指定
+type ServiceClient struct {
+	registry    *ServiceRegistry
+	serviceName string
+}
+
+// NewServiceClient 创建服务客户端
+func NewServiceClient(registry *ServiceRegistry, serviceName string) *ServiceClient {
+	return &ServiceClient{
+		registry:    registry,
+		serviceName: serviceName,
+	}
+}
+
+// Name 服务名称
+func (sc *ServiceClient) Name() string {
+	return sc.serviceName
+}
+
+// Call 调用服务方法，返回原始响应；HTTP 状态码为 4xx/5xx 时返回 *Error，响应中的 code 不做检查
+func (sc *ServiceClient) Call(ctx context.Context, method, path string, body interface{}, opts ...CallOption) (*gateway.Response, error) {
+	return sc.registry.CallService(ctx, sc.serviceName, method, path, body, opts...)
+}
+
+// Get 发送GET请求
+func (sc *ServiceClient) Get(ctx context.Context, path string, opts ...CallOption) (*gateway.Response, error) {
+	return sc.Call(ctx, http.MethodGet, path, nil, opts...)
+}
+
+// Head 发送HEAD请求，只关心状态码和响应头
+func (sc *ServiceClient) Head(ctx context.Context, path string, opts ...CallOption) (*gateway.Response, error) {
+	return sc.Call(ctx, http.MethodHead, path, nil, opts...)
+}
+
+// Post 发送POST请求
+func (sc *ServiceClient) Post(ctx context.Context, path string, body interface{}, opts ...CallOption) (*gateway.Response, error) {
+	return sc.Call(ctx, http.MethodPost, path, body, opts...)
+}
+
+// Put 发送PUT请求
+func (sc *ServiceClient) Put(ctx context.Context, path string, body interface{}, opts ...CallOption) (*gateway.Response, error) {
+	return sc.Call(ctx, http.MethodPut, path, body, opts...)
+}
+
+// Patch 发送PATCH请求
+func (sc *ServiceClient) Patch(ctx context.Context, path string, body interface{}, opts ...CallOption) (*gateway.Response, error) {
+	return sc.Call(ctx, http.MethodPatch, path, body, opts...)
+}
+
+// Delete 发送DELETE请求
+func (sc *ServiceClient) Delete(ctx context.Context, path string, opts ...CallOption) (*gateway.Response, error) {
+	return sc.Call(ctx, http.MethodDelete, path, nil, opts...)
+}
+
+// CallJSON 调用服务并把统一响应格式（response.Response）中的 data 解析为 T
+// HTTP 状态码为 4xx/5xx 或 code 不为 0 时返回 *Error，可用 errors.Is(err, ErrNotFound) 等判断类别
+func CallJSON[T any](ctx context.Context, sc *ServiceClient, method, path string, body interface{}, opts ...CallOption) (T, error) {
+	var data T
+	resp, err := sc.Call(ctx, method, path, body, opts...)
+	if err != nil {
+		return data, err
+	}
+
+	envelope := response.Response{Data: &data}
+	if len(resp.Raw) > 0 {
+		if err := json.Unmarshal(resp.Raw, &envelope); err != nil {
+			return data, fmt.Errorf("call %s %s %s: decode response: %w", sc.serviceName, method, path, err)
+		}
+	}
+	if envelope.Code != 0 {
+		var zero T
+		return zero, &Error{
+			Service:    sc.serviceName,
+			Method:     method,
+			Path:       path,
+			StatusCode: resp.StatusCode,
+			Code:       envelope.Code,
+			Message:    envelope.Message,
+			TraceID:    envelope.TraceID,
+		}
+	}
+	return data, nil
+}
+
+// GetJSON 发送GET请求并解析 data
+func GetJSON[T any](ctx context.Context, sc *ServiceClient, path string, opts ...CallOption) (T, error) {
+	return CallJSON[T](ctx, sc, http.MethodGet, path, nil, opts...)
+}
+
+// PostJSON 发送POST请求并解析 data
+func PostJSON[T any](ctx context.Context, sc *ServiceClient, path string, body interface{}, opts ...CallOption) (T, error) {
+	return CallJSON[T](ctx, sc, http.MethodPost, path, body, opts...)
+}
+
+// PutJSON 发送PUT请求并解析 data
+func PutJSON[T any](ctx context.Context, sc *ServiceClient, path string, body interface{}, opts ...CallOption) (T, error) {
+	return CallJSON[T](ctx, sc, http.MethodPut, path, body, opts...)
+}
+
+// PatchJSON 发送PATCH请求并解析 data
+func PatchJSON[T any](ctx context.Context, sc *ServiceClient, path string, body interface{}, opts ...CallOption) (T, error) {
+	return CallJSON[T](ctx, sc, http.MethodPatch, path, body, opts...)
+}
+
+// DeleteJSON 发送DELETE请求并解析 data
+func DeleteJSON[T any](ctx context.Context, sc *ServiceClient, path string, opts ...CallOption) (T, error) {
+	return CallJSON[T](ctx, sc, http.MethodDelete, path, nil, opts...)
+}
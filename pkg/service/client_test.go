@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"goweb/pkg/config"
+	"goweb/pkg/logger"
+	"goweb/pkg/middleware"
+	"goweb/pkg/response"
+)
+
+type testUser struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestCallJSON_PropagatesContextAndMapsErrors(t *testing.T) {
+	t.Chdir("../..") // config.New 读取 configs/dev
+	cfg := config.New()
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(middleware.RequestID(), middleware.GatewayIdentity(cfg.GetString("identity.secret")))
+	r.PATCH("/api/v1/users/:id", func(c *gin.Context) {
+		response.Success(c, testUser{ID: c.Param("id"), Name: c.Query("name") + "|" + c.GetHeader("X-Tenant")})
+	})
+	r.GET("/api/v1/me", func(c *gin.Context) {
+		response.Success(c, gin.H{
+			"id":          c.GetString("user_id"),
+			"name":        c.GetString("username"),
+			"request_id":  c.GetHeader("X-Request-Id"),
+			"traceparent": c.GetHeader("traceparent"),
+		})
+	})
+	r.GET("/api/v1/users/:id", func(c *gin.Context) {
+		response.NotFound(c, "user not found")
+	})
+	r.HEAD("/api/v1/users", func(c *gin.Context) {
+		c.Header("X-Total-Count", "3")
+		c.Status(http.StatusOK)
+	})
+	r.DELETE("/api/v1/users/:id", func(c *gin.Context) {
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"code": 1009, "message": "maintenance"})
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	registry := newMemoryRegistry()
+	require.NoError(t, registry.Register(context.Background(), instanceOf(t, "user-api", srv.URL)))
+	sr := NewServiceRegistry(cfg, logger.New("test", "error", "stdout", ""), registry)
+	defer sr.Close()
+	client := NewServiceClient(sr, "user-api")
+
+	// 模拟正在处理的请求：请求 ID、登录用户和 traceparent 透传给下游
+	incoming, _ := gin.CreateTestContext(httptest.NewRecorder())
+	incoming.Request = httptest.NewRequest(http.MethodGet, "/orders", nil)
+	incoming.Request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	incoming.Set("request_id", "req-1")
+	incoming.Set("user_id", "42")
+	incoming.Set("username", "alice")
+
+	me, err := GetJSON[map[string]string](incoming, client, "/api/v1/me")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"id": "42", "name": "alice", "request_id": "req-1",
+		"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	}, me)
+
+	user, err := PatchJSON[testUser](context.Background(), client, "/api/v1/users/7", gin.H{},
+		WithQuery("name", "bob & co"), WithHeader("X-Tenant", "t1"))
+	require.NoError(t, err)
+	require.Equal(t, testUser{ID: "7", Name: "bob & co|t1"}, user)
+
+	_, err = GetJSON[testUser](incoming, client, "/api/v1/users/8")
+	var callErr *Error
+	require.ErrorAs(t, err, &callErr)
+	require.Equal(t, http.StatusOK, callErr.StatusCode)
+	require.Equal(t, "req-1", callErr.TraceID)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	_, err = client.Delete(context.Background(), "/api/v1/users/8")
+	require.ErrorIs(t, err, ErrUnavailable)
+	require.True(t, errors.As(err, &callErr) && callErr.Message == "maintenance")
+
+	resp, err := client.Head(context.Background(), "/api/v1/users")
+	require.NoError(t, err)
+	require.Equal(t, "3", resp.Header.Get("X-Total-Count"))
+}
@@ -0,0 +1,366 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"goweb/pkg/logger"
+)
+
+// ConsulConfig Consul 服务发现配置（service_discovery.consul）
+type ConsulConfig struct {
+	Address    string   `mapstructure:"address" json:"address"`       // agent 地址 host:port 或完整 URL，默认 127.0.0.1:8500
+	Datacenter string   `mapstructure:"datacenter" json:"datacenter"` // 查询的数据中心，为空使用 agent 所在数据中心
+	Token      string   `mapstructure:"token" json:"token"`           // ACL token
+	Tags       []string `mapstructure:"service_tags" json:"service_tags"`
+	// DeregisterCriticalAfter TTL 检查持续失败多久后由 Consul 删除实例（进程崩溃未注销时），最小 1m
+	DeregisterCriticalAfter time.Duration `mapstructure:"deregister_critical_after" json:"deregister_critical_after"`
+	// WaitTime Watch 阻塞查询的最长等待时间
+	WaitTime time.Duration `mapstructure:"wait_time" json:"wait_time"`
+}
+
+// consul 元数据中保存 ServiceInfo 字段的键
+const (
+	consulMetaVersion = "version"
+	consulMetaStatus  = "status"
+	consulMetaAt      = "registered_at"
+)
+
+const consulRequestTimeout = 10 * time.Second
+
+// errConsulNotFound agent 上不存在该服务或检查（agent 重启后需要重新注册）
+var errConsulNotFound = errors.New("consul: not found")
+
+// ConsulRegistry 基于 Consul HTTP API 的服务注册表
+// 实例注册到本地 agent 并附带 TTL 检查，Heartbeat 更新检查状态；查询只返回检查通过的实例，Watch 使用阻塞查询
+type ConsulRegistry struct {
+	baseURL *url.URL
+	cfg     ConsulConfig
+	ttl     time.Duration
+	client  *http.Client
+	logger  logger.Logger
+}
+
+// NewConsulRegistry 创建 Consul 服务注册表，ttl 为 0 时使用 DefaultTTL
+func NewConsulRegistry(cfg ConsulConfig, ttl time.Duration, log logger.Logger) (*ConsulRegistry, error) {
+	if cfg.Address == "" {
+		cfg.Address = "127.0.0.1:8500"
+	}
+	if !strings.Contains(cfg.Address, "://") {
+		cfg.Address = "http://" + cfg.Address
+	}
+	u, err := url.Parse(cfg.Address)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid service_discovery.consul.address %q", cfg.Address)
+	}
+	if cfg.DeregisterCriticalAfter < time.Minute {
+		cfg.DeregisterCriticalAfter = time.Minute
+	}
+	if cfg.WaitTime <= 0 {
+		cfg.WaitTime = 5 * time.Minute
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &ConsulRegistry{
+		baseURL: u,
+		cfg:     cfg,
+		ttl:     ttl,
+		client:  &http.Client{}, // 超时由每次请求的 context 控制，阻塞查询需要更长的时间
+		logger:  log,
+	}, nil
+}
+
+// consulService agent 注册请求和健康查询结果中的服务
+type consulService struct {
+	ID      string            `json:"ID"`
+	Service string            `json:"Service,omitempty"`
+	Name    string            `json:"Name,omitempty"`
+	Address string            `json:"Address"`
+	Port    int               `json:"Port"`
+	Tags    []string          `json:"Tags,omitempty"`
+	Meta    map[string]string `json:"Meta,omitempty"`
+	Check   *consulCheck      `json:"Check,omitempty"`
+}
+
+type consulCheck struct {
+	CheckID                        string `json:"CheckID"`
+	Name                           string `json:"Name"`
+	TTL                            string `json:"TTL"`
+	DeregisterCriticalServiceAfter string `json:"DeregisterCriticalServiceAfter"`
+}
+
+type consulServiceEntry struct {
+	Node struct {
+		Address string `json:"Address"`
+	} `json:"Node"`
+	Service consulService `json:"Service"`
+}
+
+// checkID 实例 TTL 检查的 ID
+func checkID(info ServiceInfo) string {
+	return "service:" + info.ID
+}
+
+// Register 向 agent 注册实例和 TTL 检查，并立即标记为通过
+func (r *ConsulRegistry) Register(ctx context.Context, info ServiceInfo) error {
+	if err := info.Validate(); err != nil {
+		return err
+	}
+	if info.RegisteredAt.IsZero() {
+		info.RegisteredAt = time.Now()
+	}
+	meta := make(map[string]string, len(info.Metadata)+3)
+	for k, v := range info.Metadata {
+		meta[k] = v
+	}
+	meta[consulMetaVersion] = info.Version
+	meta[consulMetaStatus] = info.Status
+	meta[consulMetaAt] = info.RegisteredAt.UTC().Format(time.RFC3339)
+
+	svc := consulService{
+		ID:      info.ID,
+		Name:    info.Name,
+		Address: info.Host,
+		Port:    info.Port,
+		Tags:    r.cfg.Tags,
+		Meta:    meta,
+		Check: &consulCheck{
+			CheckID:                        checkID(info),
+			Name:                           "service heartbeat",
+			TTL:                            r.ttl.String(),
+			DeregisterCriticalServiceAfter: r.cfg.DeregisterCriticalAfter.String(),
+		},
+	}
+	if _, err := r.do(ctx, http.MethodPut, "/v1/agent/service/register", nil, svc, nil); err != nil {
+		return err
+	}
+	return r.pass(ctx, info)
+}
+
+// Heartbeat 将 TTL 检查标记为通过，agent 上已没有该实例（如 agent 重启）时重新注册
+func (r *ConsulRegistry) Heartbeat(ctx context.Context, info ServiceInfo) error {
+	err := r.pass(ctx, info)
+	if errors.Is(err, errConsulNotFound) {
+		r.logger.Warn("service instance unknown to consul agent, registering again", "name", info.Name, "id", info.ID)
+		return r.Register(ctx, info)
+	}
+	return err
+}
+
+func (r *ConsulRegistry) pass(ctx context.Context, info ServiceInfo) error {
+	_, err := r.do(ctx, http.MethodPut, "/v1/agent/check/pass/"+url.PathEscape(checkID(info)), nil, nil, nil)
+	return err
+}
+
+// Deregister 从 agent 注销实例
+func (r *ConsulRegistry) Deregister(ctx context.Context, info ServiceInfo) error {
+	_, err := r.do(ctx, http.MethodPut, "/v1/agent/service/deregister/"+url.PathEscape(info.ID), nil, nil, nil)
+	if errors.Is(err, errConsulNotFound) {
+		return nil
+	}
+	return err
+}
+
+// Instances 服务当前检查通过的实例
+func (r *ConsulRegistry) Instances(ctx context.Context, name string) ([]ServiceInfo, error) {
+	instances, _, err := r.health(ctx, name, 0)
+	return instances, err
+}
+
+// health 查询检查通过的实例，index 大于 0 时为阻塞查询，返回结果和 X-Consul-Index
+func (r *ConsulRegistry) health(ctx context.Context, name string, index uint64) ([]ServiceInfo, uint64, error) {
+	query := url.Values{"passing": {"true"}}
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", r.cfg.WaitTime.String())
+	}
+
+	var entries []consulServiceEntry
+	header, err := r.do(ctx, http.MethodGet, "/v1/health/service/"+url.PathEscape(name), query, nil, &entries)
+	if err != nil {
+		return nil, 0, err
+	}
+	next, _ := strconv.ParseUint(header.Get("X-Consul-Index"), 10, 64)
+
+	instances := make([]ServiceInfo, 0, len(entries))
+	for _, e := range entries {
+		instances = append(instances, e.info(name))
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].ID < instances[j].ID })
+	return instances, next, nil
+}
+
+// info 转换为 ServiceInfo，服务未设置地址时使用节点地址
+func (e consulServiceEntry) info(name string) ServiceInfo {
+	s := e.Service
+	info := ServiceInfo{
+		ID:       s.ID,
+		Name:     name,
+		Host:     s.Address,
+		Port:     s.Port,
+		Version:  s.Meta[consulMetaVersion],
+		Status:   s.Meta[consulMetaStatus],
+		Metadata: make(map[string]string, len(s.Meta)),
+	}
+	if info.Host == "" {
+		info.Host = e.Node.Address
+	}
+	for k, v := range s.Meta {
+		switch k {
+		case consulMetaVersion, consulMetaStatus:
+		case consulMetaAt:
+			info.RegisteredAt, _ = time.Parse(time.RFC3339, v)
+		default:
+			info.Metadata[k] = v
+		}
+	}
+	return info
+}
+
+// Services 目录中的服务名称（不含 Consul 自身）
+func (r *ConsulRegistry) Services(ctx context.Context) ([]string, error) {
+	var catalog map[string][]string
+	if _, err := r.do(ctx, http.MethodGet, "/v1/catalog/services", nil, nil, &catalog); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(catalog))
+	for name := range catalog {
+		if name != "consul" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Watch 使用阻塞查询监听服务的实例变化，查询失败时按指数退避重试
+func (r *ConsulRegistry) Watch(ctx context.Context, name string) (<-chan []ServiceInfo, error) {
+	instances, index, err := r.health(ctx, name, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan []ServiceInfo, 1)
+	ch <- instances
+	go func() {
+		defer close(ch)
+
+		last := membership(instances)
+		backoff := time.Second
+		for {
+			current, next, err := r.health(ctx, name, index)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				r.logger.Warn("watch service instances failed", "name", name, "error", err)
+				select {
+				case <-time.After(backoff):
+				case <-ctx.Done():
+					return
+				}
+				if backoff *= 2; backoff > 30*time.Second {
+					backoff = 30 * time.Second
+				}
+				continue
+			}
+			backoff = time.Second
+
+			// 索引回退（如 Consul 重建快照）时从头开始
+			if next < index || next == 0 {
+				index = 0
+			} else {
+				index = next
+			}
+			if index == 0 {
+				// 非阻塞查询不能连续发起，等待片刻
+				select {
+				case <-time.After(time.Second):
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			if m := membership(current); m != last {
+				last = m
+				select {
+				case ch <- current:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// do 调用 agent HTTP API，out 不为空时解析 JSON 响应，返回响应头
+func (r *ConsulRegistry) do(ctx context.Context, method, path string, query url.Values, in, out interface{}) (http.Header, error) {
+	timeout := consulRequestTimeout
+	if query.Has("wait") {
+		// Consul 会在等待时间上增加最多 1/16 的随机抖动
+		timeout += r.cfg.WaitTime + r.cfg.WaitTime/16
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if query == nil {
+		query = url.Values{}
+	}
+	if r.cfg.Datacenter != "" && method == http.MethodGet {
+		query.Set("dc", r.cfg.Datacenter)
+	}
+	u := r.baseURL.JoinPath(path)
+	u.RawQuery = query.Encode()
+
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if r.cfg.Token != "" {
+		req.Header.Set("X-Consul-Token", r.cfg.Token)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("consul %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		// 未知的检查或服务：新版本返回 404，旧版本返回 500 "Unknown check/service ID"
+		if resp.StatusCode == http.StatusNotFound || bytes.Contains(msg, []byte("Unknown")) {
+			return nil, fmt.Errorf("%w: %s", errConsulNotFound, strings.TrimSpace(string(msg)))
+		}
+		return nil, fmt.Errorf("consul %s %s: status %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return nil, fmt.Errorf("consul %s %s: decode response: %w", method, path, err)
+		}
+	}
+	return resp.Header, nil
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"goweb/pkg/logger"
+)
+
+// fakeConsul Consul agent HTTP API 的最小实现：服务注册、TTL 检查、健康查询（含阻塞查询）和目录
+type fakeConsul struct {
+	mu       sync.Mutex
+	changed  *sync.Cond
+	index    uint64
+	services map[string]consulService
+	passing  map[string]bool // checkID -> 是否通过
+}
+
+func newFakeConsul() *fakeConsul {
+	f := &fakeConsul{index: 1, services: map[string]consulService{}, passing: map[string]bool{}}
+	f.changed = sync.NewCond(&f.mu)
+	return f
+}
+
+// bump 数据变化，唤醒阻塞查询，调用时需持有锁
+func (f *fakeConsul) bump() {
+	f.index++
+	f.changed.Broadcast()
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch p := r.URL.Path; {
+	case r.Method == http.MethodPut && p == "/v1/agent/service/register":
+		var svc consulService
+		if err := json.NewDecoder(r.Body).Decode(&svc); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		svc.Service = svc.Name
+		f.services[svc.ID] = svc
+		f.passing[svc.Check.CheckID] = false // 新注册的 TTL 检查为 critical
+		f.bump()
+	case r.Method == http.MethodPut && strings.HasPrefix(p, "/v1/agent/check/pass/"):
+		id := strings.TrimPrefix(p, "/v1/agent/check/pass/")
+		if _, ok := f.passing[id]; !ok {
+			http.Error(w, "Unknown check ID", http.StatusNotFound)
+			return
+		}
+		if !f.passing[id] {
+			f.passing[id] = true
+			f.bump()
+		}
+	case r.Method == http.MethodPut && strings.HasPrefix(p, "/v1/agent/service/deregister/"):
+		id := strings.TrimPrefix(p, "/v1/agent/service/deregister/")
+		svc, ok := f.services[id]
+		if !ok {
+			http.Error(w, "Unknown service ID", http.StatusNotFound)
+			return
+		}
+		delete(f.services, id)
+		delete(f.passing, svc.Check.CheckID)
+		f.bump()
+	case r.Method == http.MethodGet && strings.HasPrefix(p, "/v1/health/service/"):
+		name := strings.TrimPrefix(p, "/v1/health/service/")
+		if index, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64); index > 0 {
+			wait, _ := time.ParseDuration(r.URL.Query().Get("wait"))
+			wake := func() {
+				f.mu.Lock()
+				f.changed.Broadcast()
+				f.mu.Unlock()
+			}
+			deadline := time.AfterFunc(wait, wake)
+			stop := context.AfterFunc(r.Context(), wake)
+			start := time.Now()
+			for f.index <= index && time.Since(start) < wait && r.Context().Err() == nil {
+				f.changed.Wait()
+			}
+			deadline.Stop()
+			stop()
+		}
+		entries := []consulServiceEntry{}
+		for _, svc := range f.services {
+			if svc.Service != name || (r.URL.Query().Get("passing") == "true" && !f.passing[svc.Check.CheckID]) {
+				continue
+			}
+			entry := consulServiceEntry{Service: svc}
+			entry.Service.Check = nil
+			entries = append(entries, entry)
+		}
+		w.Header().Set("X-Consul-Index", strconv.FormatUint(f.index, 10))
+		json.NewEncoder(w).Encode(entries)
+	case r.Method == http.MethodGet && p == "/v1/catalog/services":
+		catalog := map[string][]string{"consul": {}}
+		for _, svc := range f.services {
+			catalog[svc.Service] = svc.Tags
+		}
+		json.NewEncoder(w).Encode(catalog)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// expire 模拟 TTL 超时，检查变为 critical
+func (f *fakeConsul) expire(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.passing["service:"+id] = false
+	f.bump()
+}
+
+// restart 模拟 agent 重启，本地注册的服务全部丢失
+func (f *fakeConsul) restart() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.services = map[string]consulService{}
+	f.passing = map[string]bool{}
+	f.bump()
+}
+
+func TestConsulRegistry(t *testing.T) {
+	consul := newFakeConsul()
+	srv := httptest.NewServer(consul)
+	defer srv.Close()
+
+	registry, err := NewConsulRegistry(ConsulConfig{Address: srv.URL, WaitTime: 2 * time.Second}, 10*time.Second,
+		logger.New("test", "error", "stdout", ""))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	a := ServiceInfo{ID: "10.0.0.1:8081", Name: "user-api", Host: "10.0.0.1", Port: 8081, Version: "1.2.0",
+		Status: StatusActive, Metadata: map[string]string{"zone": "a"}}
+	b := ServiceInfo{ID: "10.0.0.2:8081", Name: "user-api", Host: "10.0.0.2", Port: 8081, Version: "1.3.0", Status: StatusActive}
+	require.NoError(t, registry.Register(ctx, a))
+
+	instances, err := registry.Instances(ctx, "user-api")
+	require.NoError(t, err)
+	require.Len(t, instances, 1)
+	require.Equal(t, "http://10.0.0.1:8081", instances[0].URL())
+	require.Equal(t, "1.2.0", instances[0].Version)
+	require.Equal(t, map[string]string{"zone": "a"}, instances[0].Metadata)
+	require.False(t, instances[0].RegisteredAt.IsZero())
+
+	services, err := registry.Services(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"user-api"}, services)
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ch, err := registry.Watch(watchCtx, "user-api")
+	require.NoError(t, err)
+	require.Len(t, <-ch, 1)
+
+	next := func() []ServiceInfo {
+		t.Helper()
+		select {
+		case list := <-ch:
+			return list
+		case <-time.After(3 * time.Second):
+			t.Fatal("no membership change received")
+			return nil
+		}
+	}
+
+	// 新实例注册并通过检查
+	require.NoError(t, registry.Register(ctx, b))
+	require.Len(t, next(), 2)
+
+	// TTL 超时的实例不再返回，心跳恢复后重新出现
+	consul.expire(b.ID)
+	require.Equal(t, []string{a.ID}, ids(next()))
+	require.NoError(t, registry.Heartbeat(ctx, b))
+	require.Len(t, next(), 2)
+
+	// agent 重启后心跳重新注册
+	consul.restart()
+	require.Empty(t, next())
+	require.NoError(t, registry.Heartbeat(ctx, a))
+	require.Equal(t, []string{a.ID}, ids(next()))
+
+	require.NoError(t, registry.Deregister(ctx, a))
+	require.Empty(t, next())
+	require.NoError(t, registry.Deregister(ctx, a))
+
+	cancel()
+	for range ch {
+	}
+}
+
+func ids(instances []ServiceInfo) []string {
+	list := make([]string, len(instances))
+	for i, inst := range instances {
+		list[i] = inst.ID
+	}
+	return list
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	codes "goweb/pkg/errors"
+)
+
+// 服务调用错误的类别，使用 errors.Is 判断 *Error 属于哪一类
+var (
+	ErrBadRequest   = errors.New("bad request")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrUnavailable  = errors.New("service unavailable")
+	ErrInternal     = errors.New("internal error")
+)
+
+// Error 服务调用失败：HTTP 状态码为 4xx/5xx，或统一响应格式中 code 不为 0
+type Error struct {
+	Service    string
+	Method     string
+	Path       string
+	StatusCode int    // HTTP 状态码
+	Code       int    // 响应中的 code，对应 pkg/errors 中的错误码或 HTTP 状态码
+	Message    string // 响应中的 message
+	TraceID    string
+
+	err error // 底层错误（如 *gateway.HTTPError）
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("call %s %s %s: status %d, code %d: %s", e.Service, e.Method, e.Path, e.StatusCode, e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// Is 按错误码和 HTTP 状态码匹配错误类别
+func (e *Error) Is(target error) bool {
+	kind := codeKinds[e.Code]
+	if kind == nil {
+		kind = codeKinds[e.StatusCode]
+	}
+	if kind == nil && (e.Code >= 500 && e.Code < 600 || e.StatusCode >= 500) {
+		kind = ErrInternal
+	}
+	return kind != nil && kind == target
+}
+
+// codeKinds 错误码（含响应中使用的 HTTP 状态码）对应的错误类别
+var codeKinds = map[int]error{
+	http.StatusBadRequest:          ErrBadRequest,
+	http.StatusUnauthorized:        ErrUnauthorized,
+	http.StatusForbidden:           ErrForbidden,
+	http.StatusNotFound:            ErrNotFound,
+	http.StatusConflict:            ErrConflict,
+	http.StatusTooManyRequests:     ErrRateLimited,
+	http.StatusBadGateway:          ErrUnavailable,
+	http.StatusServiceUnavailable:  ErrUnavailable,
+	http.StatusGatewayTimeout:      ErrUnavailable,
+	http.StatusInternalServerError: ErrInternal,
+
+	codes.InvalidParameter: ErrBadRequest,
+	codes.MissingParameter: ErrBadRequest,
+	codes.InvalidFormat:    ErrBadRequest,
+	codes.PasswordWeak:     ErrBadRequest,
+
+	codes.Unauthorized:  ErrUnauthorized,
+	codes.TokenExpired:  ErrUnauthorized,
+	codes.TokenInvalid:  ErrUnauthorized,
+	codes.TokenMissing:  ErrUnauthorized,
+	codes.PasswordError: ErrUnauthorized,
+
+	codes.PermissionDenied: ErrForbidden,
+	codes.AccountDisabled:  ErrForbidden,
+	codes.AccountLocked:    ErrForbidden,
+	codes.PermissionError:  ErrForbidden,
+	codes.RoleError:        ErrForbidden,
+	codes.UserDisabled:     ErrForbidden,
+	codes.UserLocked:       ErrForbidden,
+
+	codes.ResourceNotFound:        ErrNotFound,
+	codes.UserNotFound:            ErrNotFound,
+	codes.MerchantNotFound:        ErrNotFound,
+	codes.ProductNotFound:         ErrNotFound,
+	codes.ProductCategoryNotFound: ErrNotFound,
+	codes.OrderNotFound:           ErrNotFound,
+
+	codes.ResourceExists: ErrConflict,
+	codes.UserExists:     ErrConflict,
+	codes.EmailExists:    ErrConflict,
+	codes.PhoneExists:    ErrConflict,
+	codes.UsernameExists: ErrConflict,
+	codes.MerchantExists: ErrConflict,
+	codes.ShopNameExists: ErrConflict,
+	codes.ProductExists:  ErrConflict,
+	codes.OrderExists:    ErrConflict,
+
+	codes.RateLimitExceeded: ErrRateLimited,
+
+	codes.ServiceUnavailable: ErrUnavailable,
+	codes.GatewayError:       ErrUnavailable,
+	codes.TimeoutError:       ErrUnavailable,
+
+	codes.UnknownError:  ErrInternal,
+	codes.DatabaseError: ErrInternal,
+	codes.CacheError:    ErrInternal,
+	codes.ServiceError:  ErrInternal,
+}
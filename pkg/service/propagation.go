@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"goweb/pkg/middleware"
+)
+
+// 链路追踪请求头（W3C Trace Context）
+const (
+	HeaderTraceparent = "traceparent"
+	HeaderTracestate  = "tracestate"
+)
+
+type contextKey int
+
+const (
+	identityKey contextKey = iota
+	traceKey
+)
+
+// traceContext W3C Trace Context
+type traceContext struct {
+	parent string
+	state  string
+}
+
+// WithIdentity 设置调用下游服务时透传的身份（用户和请求 ID），优先于 gin 上下文中的用户信息
+func WithIdentity(ctx context.Context, id middleware.Identity) context.Context {
+	return context.WithValue(ctx, identityKey, id)
+}
+
+// WithTraceContext 设置调用下游服务时透传的 traceparent/tracestate
+func WithTraceContext(ctx context.Context, traceparent, tracestate string) context.Context {
+	return context.WithValue(ctx, traceKey, traceContext{parent: traceparent, state: tracestate})
+}
+
+// IdentityFrom 从 context 中取出调用方身份
+// 依次查找 WithIdentity 设置的身份，和 *gin.Context 中认证中间件写入的 user_id / username / api_key_owner_id / api_key_id / request_id；
+// 旧代码使用的 trace_id 也视为请求 ID
+func IdentityFrom(ctx context.Context) middleware.Identity {
+	if id, ok := ctx.Value(identityKey).(middleware.Identity); ok {
+		return id
+	}
+	id := middleware.Identity{
+		UserID:      stringValue(ctx, "user_id"),
+		Username:    stringValue(ctx, "username"),
+		APIKeyOwner: stringValue(ctx, "api_key_owner_id"),
+		APIKeyID:    stringValue(ctx, "api_key_id"),
+		RequestID:   stringValue(ctx, "request_id"),
+	}
+	if id.RequestID == "" {
+		id.RequestID = stringValue(ctx, "trace_id")
+	}
+	return id
+}
+
+func stringValue(ctx context.Context, key string) string {
+	s, _ := ctx.Value(key).(string)
+	return s
+}
+
+// incomingRequest 当前正在处理的请求（ctx 为 *gin.Context 时）
+func incomingRequest(ctx context.Context) *http.Request {
+	r, _ := ctx.Value(gin.ContextRequestKey).(*http.Request)
+	return r
+}
+
+// propagate 写入需要透传给下游服务的请求头
+// 直接调用服务实例时用 identity.secret 签名身份请求头（与网关转发相同，绑定 method 和 path，下游用 GatewayIdentity 校验）；
+// 经过网关调用时身份由网关根据令牌重新生成，只透传 Authorization
+func propagate(ctx context.Context, headers map[string]string, method, path, identitySecret string, direct bool) {
+	id := IdentityFrom(ctx)
+	if id.RequestID != "" {
+		headers[middleware.HeaderRequestID] = id.RequestID
+	}
+
+	in := incomingRequest(ctx)
+	if tc, ok := ctx.Value(traceKey).(traceContext); ok {
+		setNonEmpty(headers, HeaderTraceparent, tc.parent)
+		setNonEmpty(headers, HeaderTracestate, tc.state)
+	} else if in != nil {
+		setNonEmpty(headers, HeaderTraceparent, in.Header.Get(HeaderTraceparent))
+		setNonEmpty(headers, HeaderTracestate, in.Header.Get(HeaderTracestate))
+	}
+
+	if direct {
+		if identitySecret == "" || id.Anonymous() {
+			return
+		}
+		h := http.Header{}
+		middleware.SignIdentity(h, identitySecret, id, method, escapedPath(path), time.Now())
+		for k := range h {
+			headers[k] = h.Get(k)
+		}
+		return
+	}
+	if _, set := headers["Authorization"]; !set && in != nil {
+		setNonEmpty(headers, "Authorization", in.Header.Get("Authorization"))
+	}
+}
+
+// escapedPath 请求路径在请求行中的形式（去掉查询参数），与下游 URL.EscapedPath 一致
+func escapedPath(path string) string {
+	u, err := url.Parse(path)
+	if err != nil {
+		return path
+	}
+	return u.EscapedPath()
+}
+
+func setNonEmpty(headers map[string]string, key, value string) {
+	if value != "" {
+		headers[key] = value
+	}
+}
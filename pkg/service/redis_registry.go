@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"goweb/pkg/logger"
+	pkgRedis "goweb/pkg/redis"
+)
+
+// DefaultTTL 实例注册的默认有效期
+const DefaultTTL = 15 * time.Second
+
+// RedisRegistry 基于 Redis 的服务注册表
+// 键：
+//   - registry:instance:<服务>:<实例ID>  实例信息（JSON），TTL 到期即下线
+//   - registry:service:<服务>            有序集合，成员为实例 ID，分值为过期时间，用于列出实例
+//   - registry:services                  已注册过的服务名称
+//
+// 注册和注销时在 registry:events:<服务> 频道发布通知，实例过期由 Watch 定时检查发现
+type RedisRegistry struct {
+	client *pkgRedis.Client
+	ttl    time.Duration
+	logger logger.Logger
+}
+
+// NewRedisRegistry 创建 Redis 服务注册表，ttl 为 0 时使用 DefaultTTL
+func NewRedisRegistry(client *pkgRedis.Client, ttl time.Duration, log logger.Logger) *RedisRegistry {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &RedisRegistry{client: client, ttl: ttl, logger: log}
+}
+
+const servicesKey = "registry:services"
+
+func instanceKey(name, id string) string { return "registry:instance:" + name + ":" + id }
+func serviceKey(name string) string      { return "registry:service:" + name }
+func eventsChannel(name string) string   { return "registry:events:" + name }
+
+func (r *RedisRegistry) redis() (goredis.UniversalClient, error) {
+	if r.client == nil || !r.client.IsEnabled() {
+		return nil, fmt.Errorf("service registry requires redis")
+	}
+	return r.client.GetClient(), nil
+}
+
+// Register 注册实例并通知监听者
+func (r *RedisRegistry) Register(ctx context.Context, info ServiceInfo) error {
+	if err := r.put(ctx, info, false); err != nil {
+		return err
+	}
+	r.publish(ctx, info.Name, "register:"+info.ID)
+	return nil
+}
+
+// Heartbeat 续约，实例已过期（如 Redis 重启或进程长时间停顿）时重新注册
+func (r *RedisRegistry) Heartbeat(ctx context.Context, info ServiceInfo) error {
+	err := r.put(ctx, info, true)
+	if err == goredis.Nil {
+		r.logger.Warn("service instance expired, registering again", "name", info.Name, "id", info.ID)
+		return r.Register(ctx, info)
+	}
+	return err
+}
+
+// put 写入实例信息并设置过期时间，onlyExisting 为 true 时实例不存在返回 redis.Nil
+func (r *RedisRegistry) put(ctx context.Context, info ServiceInfo, onlyExisting bool) error {
+	if err := info.Validate(); err != nil {
+		return err
+	}
+	rdb, err := r.redis()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if info.RegisteredAt.IsZero() {
+		info.RegisteredAt = now
+	}
+	info.LastHeartbeat = now
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	key := instanceKey(info.Name, info.ID)
+	if onlyExisting {
+		ok, err := rdb.SetXX(ctx, key, data, r.ttl).Result()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return goredis.Nil
+		}
+	}
+
+	pipe := rdb.TxPipeline()
+	if !onlyExisting {
+		pipe.Set(ctx, key, data, r.ttl)
+	}
+	pipe.ZAdd(ctx, serviceKey(info.Name), goredis.Z{Score: float64(now.Add(r.ttl).Unix()), Member: info.ID})
+	pipe.SAdd(ctx, servicesKey, info.Name)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// Deregister 注销实例并通知监听者
+func (r *RedisRegistry) Deregister(ctx context.Context, info ServiceInfo) error {
+	rdb, err := r.redis()
+	if err != nil {
+		return err
+	}
+	pipe := rdb.TxPipeline()
+	pipe.Del(ctx, instanceKey(info.Name, info.ID))
+	pipe.ZRem(ctx, serviceKey(info.Name), info.ID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	r.publish(ctx, info.Name, "deregister:"+info.ID)
+	return nil
+}
+
+func (r *RedisRegistry) publish(ctx context.Context, name, event string) {
+	rdb, err := r.redis()
+	if err != nil {
+		return
+	}
+	if err := rdb.Publish(ctx, eventsChannel(name), event).Err(); err != nil {
+		r.logger.Warn("publish registry event failed", "name", name, "event", event, "error", err)
+	}
+}
+
+// Instances 服务当前的全部实例，顺带清理已过期的实例 ID
+func (r *RedisRegistry) Instances(ctx context.Context, name string) ([]ServiceInfo, error) {
+	rdb, err := r.redis()
+	if err != nil {
+		return nil, err
+	}
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	if err := rdb.ZRemRangeByScore(ctx, serviceKey(name), "-inf", "("+now).Err(); err != nil {
+		return nil, err
+	}
+	ids, err := rdb.ZRange(ctx, serviceKey(name), 0, -1).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = instanceKey(name, id)
+	}
+	values, err := rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	instances := make([]ServiceInfo, 0, len(values))
+	for i, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			continue // 实例已过期，有序集合中的 ID 会在续约到期后清理
+		}
+		var info ServiceInfo
+		if err := json.Unmarshal([]byte(s), &info); err != nil {
+			r.logger.Warn("invalid service instance in registry", "key", keys[i], "error", err)
+			continue
+		}
+		instances = append(instances, info)
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].ID < instances[j].ID })
+	return instances, nil
+}
+
+// Services 已注册过的服务名称
+func (r *RedisRegistry) Services(ctx context.Context) ([]string, error) {
+	rdb, err := r.redis()
+	if err != nil {
+		return nil, err
+	}
+	names, err := rdb.SMembers(ctx, servicesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Watch 订阅注册/注销通知，并每半个 TTL 检查一次以发现过期的实例
+func (r *RedisRegistry) Watch(ctx context.Context, name string) (<-chan []ServiceInfo, error) {
+	rdb, err := r.redis()
+	if err != nil {
+		return nil, err
+	}
+	instances, err := r.Instances(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	sub := rdb.Subscribe(ctx, eventsChannel(name))
+	ch := make(chan []ServiceInfo, 1)
+	ch <- instances
+	go func() {
+		defer close(ch)
+		defer sub.Close()
+
+		last := membership(instances)
+		ticker := time.NewTicker(r.ttl / 2)
+		defer ticker.Stop()
+		events := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-events:
+			case <-ticker.C:
+			}
+
+			current, err := r.Instances(ctx, name)
+			if err != nil {
+				if ctx.Err() == nil {
+					r.logger.Warn("watch service instances failed", "name", name, "error", err)
+				}
+				continue
+			}
+			if m := membership(current); m != last {
+				last = m
+				select {
+				case ch <- current:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return ch, nil
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"goweb/pkg/config"
+	"goweb/pkg/logger"
+	"goweb/pkg/redis"
+)
+
+// 服务发现类型（service_discovery.type）
+const (
+	DiscoveryNone   = "none"
+	DiscoveryRedis  = "redis"
+	DiscoveryConsul = "consul"
+)
+
+// NewRegistry 按 service_discovery.type 创建注册表，未启用服务发现时返回 nil
+func NewRegistry(cfg *config.Config, redisClient *redis.Client, log logger.Logger) (Registry, error) {
+	switch typ := cfg.GetString("service_discovery.type"); typ {
+	case "", DiscoveryNone:
+		return nil, nil
+	case DiscoveryRedis:
+		if redisClient == nil || !redisClient.IsEnabled() {
+			return nil, fmt.Errorf("service_discovery.type redis requires redis.enabled")
+		}
+		return NewRedisRegistry(redisClient, cfg.GetDuration("service_discovery.ttl"), log), nil
+	case DiscoveryConsul:
+		var consul ConsulConfig
+		if err := cfg.Unmarshal("service_discovery.consul", &consul); err != nil {
+			return nil, fmt.Errorf("service_discovery.consul: %w", err)
+		}
+		registry, err := NewConsulRegistry(consul, cfg.GetDuration("service_discovery.ttl"), log)
+		if err != nil {
+			return nil, err
+		}
+		return registry, nil
+	default:
+		return nil, fmt.Errorf("unknown service_discovery.type %q", typ)
+	}
+}
+
+// Registration 当前服务实例的注册，启动后定期心跳，Stop 时注销
+type Registration struct {
+	registry Registry
+	info     ServiceInfo
+	interval time.Duration
+	logger   logger.Logger
+	closer   func() error // 注册使用的 Redis 连接，由 RegisterSelf 创建
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewRegistration 创建实例注册，interval 为心跳间隔，需小于注册表的 TTL
+func NewRegistration(registry Registry, info ServiceInfo, interval time.Duration, log logger.Logger) *Registration {
+	if interval <= 0 {
+		interval = DefaultTTL / 3
+	}
+	if info.Status == "" {
+		info.Status = StatusActive
+	}
+	return &Registration{registry: registry, info: info, interval: interval, logger: log}
+}
+
+// Info 注册的实例信息
+func (r *Registration) Info() ServiceInfo {
+	return r.info
+}
+
+// Start 注册实例并在后台心跳
+// 首次注册失败时返回错误，心跳仍会继续，注册表恢复后自动完成注册
+func (r *Registration) Start(ctx context.Context) error {
+	r.info.RegisteredAt = time.Now()
+	hbCtx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.wg.Add(1)
+	go r.heartbeat(hbCtx)
+
+	if err := r.registry.Register(ctx, r.info); err != nil {
+		return err
+	}
+	r.logger.Info("service registered", "name", r.info.Name, "id", r.info.ID, "url", r.info.URL(), "version", r.info.Version)
+	return nil
+}
+
+// heartbeat 定期续约，失败时只记录日志，下一次心跳会重新注册
+func (r *Registration) heartbeat(ctx context.Context) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			hbCtx, cancel := context.WithTimeout(ctx, r.interval)
+			err := r.registry.Heartbeat(hbCtx, r.info)
+			cancel()
+			if err != nil && ctx.Err() == nil {
+				r.logger.Warn("service heartbeat failed", "name", r.info.Name, "id", r.info.ID, "error", err)
+			}
+		}
+	}
+}
+
+// Stop 停止心跳并注销实例，应在关闭 HTTP 服务之前调用，让网关尽快停止转发
+// r 为空时什么也不做，方便在未启用服务发现时直接调用
+func (r *Registration) Stop(ctx context.Context) {
+	if r == nil {
+		return
+	}
+	if r.cancel != nil {
+		r.cancel()
+		r.wg.Wait()
+	}
+	if err := r.registry.Deregister(ctx, r.info); err != nil {
+		r.logger.Warn("service deregister failed", "name", r.info.Name, "id", r.info.ID, "error", err)
+	} else {
+		r.logger.Info("service deregistered", "name", r.info.Name, "id", r.info.ID)
+	}
+	if r.closer != nil {
+		r.closer()
+	}
+}
+
+// SelfInfo 按配置生成当前实例的注册信息
+// 地址取 service_discovery.advertise_host，未配置时使用主机名；实例 ID 为 地址:端口，重启后覆盖原注册
+func SelfInfo(cfg *config.Config, name string, port int) ServiceInfo {
+	host := cfg.GetString("service_discovery.advertise_host")
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+	metadata := make(map[string]string)
+	_ = cfg.Unmarshal("service_discovery.metadata", &metadata)
+	metadata["env"] = cfg.GetEnv()
+	return ServiceInfo{
+		ID:       net.JoinHostPort(host, strconv.Itoa(port)),
+		Name:     name,
+		Host:     host,
+		Port:     port,
+		Version:  cfg.GetString("app.version"),
+		Status:   StatusActive,
+		Metadata: metadata,
+	}
+}
+
+// RegisterSelf 服务启动时注册当前实例（service_discovery）
+// 未启用服务发现或配置无效时返回 nil；注册失败不影响服务启动，由心跳继续重试
+func RegisterSelf(cfg *config.Config, log logger.Logger, name string, port int) *Registration {
+	var redisClient *redis.Client // 只有 redis 类型需要
+	if cfg.GetString("service_discovery.type") == DiscoveryRedis {
+		redisConfig := cfg.GetRedisConfig()
+		redisClient = redis.NewClient(&redisConfig, log)
+	}
+
+	registry, err := NewRegistry(cfg, redisClient, log)
+	if err != nil {
+		log.Error("service registry unavailable, not registering", "name", name, "error", err)
+	}
+	if registry == nil {
+		if redisClient != nil {
+			redisClient.Close()
+		}
+		return nil
+	}
+
+	reg := NewRegistration(registry, SelfInfo(cfg, name, port), cfg.GetDuration("service_discovery.heartbeat_interval"), log)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := reg.Start(ctx); err != nil {
+		log.Error("service register failed, retrying with heartbeat", "name", name, "error", err)
+	}
+	if redisClient != nil {
+		reg.closer = redisClient.Close
+	}
+	return reg
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoInstance 服务没有已注册的可用实例
+var ErrNoInstance = errors.New("no available service instance")
+
+// 实例状态
+const (
+	StatusActive      = "active"
+	StatusInactive    = "inactive"
+	StatusMaintenance = "maintenance"
+)
+
+// ServiceInfo 服务实例信息
+type ServiceInfo struct {
+	ID            string            `json:"id"` // 实例 ID，同一服务内唯一
+	Name          string            `json:"name"`
+	Host          string            `json:"host"`
+	Port          int               `json:"port"`
+	Version       string            `json:"version"`
+	Status        string            `json:"status"` // active, inactive, maintenance
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	RegisteredAt  time.Time         `json:"registered_at"`
+	LastHeartbeat time.Time         `json:"last_heartbeat"`
+}
+
+// URL 实例地址，协议取自 metadata.scheme，默认 http
+func (s ServiceInfo) URL() string {
+	scheme := s.Metadata["scheme"]
+	if scheme == "" {
+		scheme = "http"
+	}
+	return scheme + "://" + net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+}
+
+// Active 实例是否可以接收请求
+func (s ServiceInfo) Active() bool {
+	return s.Status == "" || s.Status == StatusActive
+}
+
+// Validate 校验注册信息
+func (s ServiceInfo) Validate() error {
+	switch {
+	case s.Name == "":
+		return fmt.Errorf("service name is required")
+	case s.ID == "":
+		return fmt.Errorf("service %s: instance id is required", s.Name)
+	case s.Host == "":
+		return fmt.Errorf("service %s: host is required", s.Name)
+	case s.Port <= 0 || s.Port > 65535:
+		return fmt.Errorf("service %s: invalid port %d", s.Name, s.Port)
+	}
+	return nil
+}
+
+// Registry 服务注册表（服务发现后端），按 service_discovery.type 选择 RedisRegistry 或 ConsulRegistry
+// 实例注册后需要定期 Heartbeat，超过 TTL 未续约的实例视为下线
+type Registry interface {
+	// Register 注册实例
+	Register(ctx context.Context, info ServiceInfo) error
+	// Heartbeat 续约，实例已过期时重新注册
+	Heartbeat(ctx context.Context, info ServiceInfo) error
+	// Deregister 注销实例
+	Deregister(ctx context.Context, info ServiceInfo) error
+	// Instances 服务当前的全部实例（含非 active 状态），按实例 ID 排序
+	Instances(ctx context.Context, name string) ([]ServiceInfo, error)
+	// Services 已注册过的服务名称
+	Services(ctx context.Context) ([]string, error)
+	// Watch 监听服务成员变化，立即发送一次当前实例列表，之后每次变化发送完整列表；ctx 结束后关闭 channel
+	Watch(ctx context.Context, name string) (<-chan []ServiceInfo, error)
+}
+
+// ActiveInstances 过滤出 active 状态的实例
+func ActiveInstances(instances []ServiceInfo) []ServiceInfo {
+	active := make([]ServiceInfo, 0, len(instances))
+	for _, inst := range instances {
+		if inst.Active() {
+			active = append(active, inst)
+		}
+	}
+	return active
+}
+
+// SameInstances 两个实例列表的成员是否相同（忽略顺序和心跳时间）
+func SameInstances(a, b []ServiceInfo) bool {
+	return membership(a) == membership(b)
+}
+
+// membership 实例列表的成员标识，心跳时间变化不算成员变化
+func membership(instances []ServiceInfo) string {
+	parts := make([]string, 0, len(instances))
+	for _, inst := range instances {
+		meta := make([]string, 0, len(inst.Metadata))
+		for k, v := range inst.Metadata {
+			meta = append(meta, k+"="+v)
+		}
+		sort.Strings(meta)
+		parts = append(parts, strings.Join([]string{inst.ID, inst.URL(), inst.Version, inst.Status, strings.Join(meta, "&")}, "|"))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "\n")
+}
@@ -2,148 +2,217 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"goweb/pkg/config"
 	"goweb/pkg/gateway"
 	"goweb/pkg/logger"
+	"net/http"
 	"sync"
+	"sync/atomic"
 )
 
-// ServiceRegistry 服务注册表
+// ServiceRegistry 服务注册表客户端
+// 从注册中心解析服务实例并直接调用，实例列表通过 Watch 保持更新；
+// 未启用服务发现（registry 为空）时经过 Gateway 调用 /<服务名><路径>
 type ServiceRegistry struct {
-	services map[string]ServiceInfo
+	registry       Registry
+	gateway        *gateway.Client
+	identitySecret string // 直接调用服务实例时签名身份请求头
+	logger         logger.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
 	mutex    sync.RWMutex
-	gateway  *gateway.Client
-	logger   logger.Logger
+	services map[string]*watchedService
 }
 
-// ServiceInfo 服务信息
-type ServiceInfo struct {
-	Name    string `json:"name"`
-	Host    string `json:"host"`
-	Port    int    `json:"port"`
-	Version string `json:"version"`
-	Status  string `json:"status"` // active, inactive, maintenance
+// watchedService 一个服务的实例缓存
+type watchedService struct {
+	instances atomic.Pointer[[]ServiceInfo] // active 实例
+	next      atomic.Uint64                 // 轮询位置
 }
 
-// NewServiceRegistry 创建服务注册表
-func NewServiceRegistry(cfg *config.Config, log logger.Logger) *ServiceRegistry {
+// NewServiceRegistry 创建服务注册表客户端，registry 可以为空
+func NewServiceRegistry(cfg *config.Config, log logger.Logger, registry Registry) *ServiceRegistry {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &ServiceRegistry{
-		services: make(map[string]ServiceInfo),
-		gateway:  gateway.NewClient(cfg, log),
-		logger:   log,
+		registry:       registry,
+		gateway:        gateway.NewClient(cfg, log),
+		identitySecret: cfg.GetString("identity.secret"),
+		logger:         log,
+		ctx:            ctx,
+		cancel:         cancel,
+		services:       make(map[string]*watchedService),
 	}
 }
 
-// Register 注册服务
-func (sr *ServiceRegistry) Register(service ServiceInfo) {
-	sr.mutex.Lock()
-	defer sr.mutex.Unlock()
-
-	sr.services[service.Name] = service
-	sr.logger.Info("service registered", "name", service.Name, "host", service.Host, "port", service.Port)
+// Gateway 底层 HTTP 客户端，可设置重试、熔断降级等容错策略
+func (sr *ServiceRegistry) Gateway() *gateway.Client {
+	return sr.gateway
 }
 
-// Unregister 注销服务
-func (sr *ServiceRegistry) Unregister(serviceName string) {
-	sr.mutex.Lock()
-	defer sr.mutex.Unlock()
+// Registry 底层注册中心，未启用服务发现时为空
+func (sr *ServiceRegistry) Registry() Registry {
+	return sr.registry
+}
 
-	delete(sr.services, serviceName)
-	sr.logger.Info("service unregistered", "name", serviceName)
+// Close 停止所有实例监听
+func (sr *ServiceRegistry) Close() {
+	sr.cancel()
 }
 
-// GetService 获取服务信息
-func (sr *ServiceRegistry) GetService(serviceName string) (ServiceInfo, bool) {
-	sr.mutex.RLock()
-	defer sr.mutex.RUnlock()
+// Register 注册服务实例
+func (sr *ServiceRegistry) Register(ctx context.Context, info ServiceInfo) error {
+	if sr.registry == nil {
+		return fmt.Errorf("service discovery is not enabled")
+	}
+	return sr.registry.Register(ctx, info)
+}
 
-	service, exists := sr.services[serviceName]
-	return service, exists
+// Unregister 注销服务实例
+func (sr *ServiceRegistry) Unregister(ctx context.Context, info ServiceInfo) error {
+	if sr.registry == nil {
+		return fmt.Errorf("service discovery is not enabled")
+	}
+	return sr.registry.Deregister(ctx, info)
 }
 
-// ListServices 列出所有服务
-func (sr *ServiceRegistry) ListServices() map[string]ServiceInfo {
-	sr.mutex.RLock()
-	defer sr.mutex.RUnlock()
+// GetService 按轮询选择服务的一个 active 实例，没有可用实例时返回 ErrNoInstance
+func (sr *ServiceRegistry) GetService(ctx context.Context, serviceName string) (ServiceInfo, error) {
+	if sr.registry == nil {
+		return ServiceInfo{}, fmt.Errorf("service discovery is not enabled")
+	}
+	ws, err := sr.watch(ctx, serviceName)
+	if err != nil {
+		return ServiceInfo{}, err
+	}
+	instances := *ws.instances.Load()
+	if len(instances) == 0 {
+		return ServiceInfo{}, fmt.Errorf("service %s: %w", serviceName, ErrNoInstance)
+	}
+	return instances[(ws.next.Add(1)-1)%uint64(len(instances))], nil
+}
 
-	services := make(map[string]ServiceInfo)
-	for k, v := range sr.services {
-		services[k] = v
+// ListServices 列出所有服务及其实例
+func (sr *ServiceRegistry) ListServices(ctx context.Context) (map[string][]ServiceInfo, error) {
+	if sr.registry == nil {
+		return nil, fmt.Errorf("service discovery is not enabled")
 	}
-	return services
+	names, err := sr.registry.Services(ctx)
+	if err != nil {
+		return nil, err
+	}
+	services := make(map[string][]ServiceInfo, len(names))
+	for _, name := range names {
+		instances, err := sr.registry.Instances(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		services[name] = instances
+	}
+	return services, nil
 }
 
-// CallService 调用服务
-func (sr *ServiceRegistry) CallService(ctx context.Context, serviceName, method, path string, body interface{}) (*gateway.Response, error) {
-	service, exists := sr.GetService(serviceName)
-	if !exists {
-		return nil, fmt.Errorf("service %s not found", serviceName)
+// watch 获取服务的实例缓存，首次访问时开始监听实例变化
+func (sr *ServiceRegistry) watch(ctx context.Context, serviceName string) (*watchedService, error) {
+	sr.mutex.RLock()
+	ws, ok := sr.services[serviceName]
+	sr.mutex.RUnlock()
+	if ok {
+		return ws, nil
 	}
 
-	if service.Status != "active" {
-		return nil, fmt.Errorf("service %s is not active", serviceName)
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+	if ws, ok := sr.services[serviceName]; ok {
+		return ws, nil
 	}
 
-	// 构建完整路径
-	fullPath := fmt.Sprintf("/%s%s", serviceName, path)
+	watchCtx, cancel := context.WithCancel(sr.ctx)
+	ch, err := sr.registry.Watch(watchCtx, serviceName)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("watch service %s: %w", serviceName, err)
+	}
+	// Watch 会立即发送当前实例列表
+	var initial []ServiceInfo
+	select {
+	case initial = <-ch:
+	case <-ctx.Done():
+		cancel()
+		return nil, ctx.Err()
+	}
 
-	// 通过 Gateway 调用服务
+	ws = &watchedService{}
+	active := ActiveInstances(initial)
+	ws.instances.Store(&active)
+	sr.services[serviceName] = ws
+
+	go func() {
+		defer cancel()
+		for instances := range ch {
+			active := ActiveInstances(instances)
+			ws.instances.Store(&active)
+			sr.logger.Info("service instances changed", "name", serviceName, "instances", len(active))
+		}
+		// 监听结束（注册表关闭），下次访问重新监听
+		sr.mutex.Lock()
+		if sr.services[serviceName] == ws {
+			delete(sr.services, serviceName)
+		}
+		sr.mutex.Unlock()
+	}()
+	return ws, nil
+}
+
+// CallService 调用服务，method 支持 GET/HEAD/POST/PUT/PATCH/DELETE/OPTIONS
+// 请求 ID、链路追踪和用户身份从 ctx 中透传（见 IdentityFrom），失败时返回 *Error
+func (sr *ServiceRegistry) CallService(ctx context.Context, serviceName, method, path string, body interface{}, opts ...CallOption) (*gateway.Response, error) {
 	switch method {
-	case "GET":
-		return sr.gateway.Get(ctx, fullPath, nil, nil)
-	case "POST":
-		return sr.gateway.Post(ctx, fullPath, body, nil)
-	case "PUT":
-		return sr.gateway.Put(ctx, fullPath, body, nil)
-	case "DELETE":
-		return sr.gateway.Delete(ctx, fullPath, nil)
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
 	default:
 		return nil, fmt.Errorf("unsupported method: %s", method)
 	}
-}
 
-// HealthCheck 健康检查
-func (sr *ServiceRegistry) HealthCheck(ctx context.Context, serviceName string) error {
-	_, err := sr.CallService(ctx, serviceName, "GET", "/healthz", nil)
-	return err
-}
-
-// ServiceClient 服务客户端
-type ServiceClient struct {
-	registry    *ServiceRegistry
-	serviceName string
-}
-
-// NewServiceClient 创建服务客户端
-func NewServiceClient(registry *ServiceRegistry, serviceName string) *ServiceClient {
-	return &ServiceClient{
-		registry:    registry,
-		serviceName: serviceName,
+	var call callOptions
+	for _, opt := range opts {
+		opt(&call)
 	}
-}
 
-// Call 调用服务方法
-func (sc *ServiceClient) Call(ctx context.Context, method, path string, body interface{}) (*gateway.Response, error) {
-	return sc.registry.CallService(ctx, sc.serviceName, method, path, body)
-}
-
-// Get 发送GET请求
-func (sc *ServiceClient) Get(ctx context.Context, path string) (*gateway.Response, error) {
-	return sc.Call(ctx, "GET", path, nil)
-}
+	req := &gateway.Request{Service: serviceName, Method: method, Path: path, Body: body, Query: call.query}
+	if sr.registry == nil {
+		// 通过 Gateway 调用服务
+		req.Path = fmt.Sprintf("/%s%s", serviceName, path)
+	} else {
+		instance, err := sr.GetService(ctx, serviceName)
+		if err != nil {
+			return nil, err
+		}
+		req.BaseURL = instance.URL()
+	}
 
-// Post 发送POST请求
-func (sc *ServiceClient) Post(ctx context.Context, path string, body interface{}) (*gateway.Response, error) {
-	return sc.Call(ctx, "POST", path, body)
-}
+	req.Headers = make(map[string]string, len(call.headers)+6)
+	propagate(ctx, req.Headers, method, req.Path, sr.identitySecret, sr.registry != nil)
+	for k, v := range call.headers {
+		req.Headers[k] = v
+	}
 
-// Put 发送PUT请求
-func (sc *ServiceClient) Put(ctx context.Context, path string, body interface{}) (*gateway.Response, error) {
-	return sc.Call(ctx, "PUT", path, body)
+	resp, err := sr.gateway.Call(ctx, req)
+	var httpErr *gateway.HTTPError
+	if errors.As(err, &httpErr) {
+		callErr := &Error{Service: serviceName, Method: method, Path: path, StatusCode: httpErr.StatusCode, Message: httpErr.Message, err: err}
+		if resp != nil {
+			callErr.Code, callErr.TraceID = resp.Code, resp.TraceID
+		}
+		return resp, callErr
+	}
+	return resp, err
 }
 
-// Delete 发送DELETE请求
-func (sc *ServiceClient) Delete(ctx context.Context, path string) (*gateway.Response, error) {
-	return sc.Call(ctx, "DELETE", path, nil)
+// HealthCheck 健康检查
+func (sr *ServiceRegistry) HealthCheck(ctx context.Context, serviceName string) error {
+	_, err := sr.CallService(ctx, serviceName, http.MethodGet, "/healthz", nil)
+	return err
 }
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"goweb/pkg/config"
+	"goweb/pkg/logger"
+)
+
+// memoryRegistry 内存注册表，变化时通知所有监听者
+type memoryRegistry struct {
+	mu        sync.Mutex
+	instances map[string]map[string]ServiceInfo
+	watchers  map[string][]chan []ServiceInfo
+}
+
+func newMemoryRegistry() *memoryRegistry {
+	return &memoryRegistry{
+		instances: make(map[string]map[string]ServiceInfo),
+		watchers:  make(map[string][]chan []ServiceInfo),
+	}
+}
+
+func (m *memoryRegistry) Register(ctx context.Context, info ServiceInfo) error {
+	m.mu.Lock()
+	if m.instances[info.Name] == nil {
+		m.instances[info.Name] = make(map[string]ServiceInfo)
+	}
+	m.instances[info.Name][info.ID] = info
+	m.mu.Unlock()
+	m.notify(info.Name)
+	return nil
+}
+
+func (m *memoryRegistry) Heartbeat(ctx context.Context, info ServiceInfo) error {
+	return m.Register(ctx, info)
+}
+
+func (m *memoryRegistry) Deregister(ctx context.Context, info ServiceInfo) error {
+	m.mu.Lock()
+	delete(m.instances[info.Name], info.ID)
+	m.mu.Unlock()
+	m.notify(info.Name)
+	return nil
+}
+
+func (m *memoryRegistry) Instances(ctx context.Context, name string) ([]ServiceInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var list []ServiceInfo
+	for _, info := range m.instances[name] {
+		list = append(list, info)
+	}
+	return list, nil
+}
+
+func (m *memoryRegistry) Services(ctx context.Context) ([]string, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *memoryRegistry) Watch(ctx context.Context, name string) (<-chan []ServiceInfo, error) {
+	ch := make(chan []ServiceInfo, 8)
+	list, _ := m.Instances(ctx, name)
+	ch <- list
+	m.mu.Lock()
+	m.watchers[name] = append(m.watchers[name], ch)
+	m.mu.Unlock()
+	return ch, nil
+}
+
+func (m *memoryRegistry) notify(name string) {
+	list, _ := m.Instances(context.Background(), name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, ch := range m.watchers[name] {
+		ch <- list
+	}
+}
+
+func instanceOf(t *testing.T, name, rawURL string) ServiceInfo {
+	t.Helper()
+	host, port, err := net.SplitHostPort(rawURL[len("http://"):])
+	require.NoError(t, err)
+	p, _ := strconv.Atoi(port)
+	return ServiceInfo{ID: host + ":" + port, Name: name, Host: host, Port: p, Status: StatusActive}
+}
+
+func TestServiceClient_ResolvesFromRegistry(t *testing.T) {
+	var mu sync.Mutex
+	hits := map[string]int{}
+	handler := func(id string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			hits[id+" "+r.URL.Path]++
+			mu.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"code":0,"message":"ok"}`))
+		})
+	}
+	a := httptest.NewServer(handler("a"))
+	defer a.Close()
+	b := httptest.NewServer(handler("b"))
+	defer b.Close()
+
+	t.Chdir("../..") // config.New 读取 configs/dev
+	registry := newMemoryRegistry()
+	sr := NewServiceRegistry(config.New(), logger.New("test", "error", "stdout", ""), registry)
+	defer sr.Close()
+	client := NewServiceClient(sr, "user-api")
+
+	_, err := client.Get(context.Background(), "/healthz")
+	require.ErrorIs(t, err, ErrNoInstance)
+
+	instA, instB := instanceOf(t, "user-api", a.URL), instanceOf(t, "user-api", b.URL)
+	require.NoError(t, sr.Register(context.Background(), instA))
+	require.NoError(t, sr.Register(context.Background(), instB))
+	require.Eventually(t, func() bool {
+		ws, _ := sr.watch(context.Background(), "user-api")
+		return len(*ws.instances.Load()) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	for i := 0; i < 4; i++ {
+		_, err := client.Get(context.Background(), "/api/v1/users")
+		require.NoError(t, err)
+	}
+	mu.Lock()
+	require.Equal(t, map[string]int{"a /api/v1/users": 2, "b /api/v1/users": 2}, hits)
+	mu.Unlock()
+
+	// 注销后不再调用该实例
+	require.NoError(t, sr.Unregister(context.Background(), instA))
+	require.Eventually(t, func() bool {
+		ws, _ := sr.watch(context.Background(), "user-api")
+		return len(*ws.instances.Load()) == 1
+	}, time.Second, 10*time.Millisecond)
+	_, err = client.Get(context.Background(), "/api/v1/users")
+	require.NoError(t, err)
+	mu.Lock()
+	require.Equal(t, 3, hits["b /api/v1/users"])
+	mu.Unlock()
+}
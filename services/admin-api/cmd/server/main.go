@@ -29,11 +29,15 @@ import (
 	"syscall"
 	"time"
 
+	"goweb/pkg/apikey"
 	"goweb/pkg/config"
 	"goweb/pkg/db"
 	"goweb/pkg/logger"
+	"goweb/pkg/monitor"
 	"goweb/pkg/notification"
 	"goweb/pkg/redis"
+	discovery "goweb/pkg/service"
+	_ "goweb/services/admin-api/docs" // 导入生成的 docs 包
 	"goweb/services/admin-api/internal/model"
 	"goweb/services/admin-api/internal/router"
 )
@@ -68,6 +72,7 @@ func main() {
 		&model.AdminRoleMenu{},
 		&model.AdminOperationLog{},
 		&model.AdminSystemConfig{},
+		&apikey.Key{},
 	); err != nil {
 		log.Warn("failed to auto migrate database", "error", err)
 	}
@@ -95,8 +100,14 @@ func main() {
 		log.Info("notification service initialized successfully")
 	}
 
+	// 监控指标
+	var metrics *monitor.Metrics
+	if cfg.GetBool("monitor.enabled") {
+		metrics = monitor.NewMetrics(serviceName, log)
+	}
+
 	// 初始化路由
-	r := router.NewRouter(database, redisClient, notifyService, log, cfg)
+	r := router.NewRouter(database, redisClient, notifyService, log, cfg, metrics)
 
 	// 启动HTTP服务
 	srv := &http.Server{
@@ -114,6 +125,9 @@ func main() {
 		}
 	}()
 
+	// 注册到服务注册中心（service_discovery），退出时先注销
+	registration := discovery.RegisterSelf(cfg, log, serviceName, cfg.GetInt("services.admin_api.port"))
+
 	// 优雅退出
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -122,6 +136,7 @@ func main() {
 	log.Info("admin-api service shutting down...")
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
+	registration.Stop(ctx)
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Error("admin-api service shutdown error", err)
 	}
@@ -0,0 +1,246 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"goweb/pkg/base"
+	"goweb/pkg/logger"
+	"goweb/pkg/response"
+	"goweb/services/admin-api/internal/model"
+	"goweb/services/admin-api/internal/service"
+)
+
+// APIKeyHandler API Key 管理处理器
+type APIKeyHandler struct {
+	*base.BaseHandler
+	apiKeyService *service.APIKeyService
+	logger        logger.Logger
+}
+
+// NewAPIKeyHandler 创建 API Key 管理处理器
+func NewAPIKeyHandler(apiKeyService *service.APIKeyService, log logger.Logger) *APIKeyHandler {
+	return &APIKeyHandler{
+		BaseHandler:   base.NewBaseHandler(log),
+		apiKeyService: apiKeyService,
+		logger:        log,
+	}
+}
+
+// List 获取 API Key 列表
+// @Summary 获取API Key列表
+// @Description 获取商户API Key列表，支持按商户、状态筛选
+// @Tags API Key管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Param owner_id query string false "商户ID"
+// @Param keyword query string false "名称或前缀"
+// @Param status query string false "状态: active/expired/revoked"
+// @Success 200 {object} response.Response{data=model.APIKeyListResponse}
+// @Router /api/v1/admin/api-keys [get]
+func (h *APIKeyHandler) List(c *gin.Context) {
+	var req model.APIKeyListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 10
+	}
+
+	list, total, err := h.apiKeyService.List(&req)
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+	response.Success(c, model.APIKeyListResponse{List: list, Total: total})
+}
+
+// Get 获取 API Key 详情
+// @Summary 获取API Key详情
+// @Tags API Key管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "API Key ID"
+// @Success 200 {object} response.Response{data=apikey.Key}
+// @Router /api/v1/admin/api-keys/{id} [get]
+func (h *APIKeyHandler) Get(c *gin.Context) {
+	id, ok := h.parseID(c)
+	if !ok {
+		return
+	}
+	key, err := h.apiKeyService.GetByID(id)
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+	response.Success(c, key)
+}
+
+// Create 创建 API Key
+// @Summary 创建API Key
+// @Description 创建商户API Key，明文密钥只在响应中返回一次
+// @Tags API Key管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body model.APIKeyCreateRequest true "创建请求"
+// @Success 200 {object} response.Response{data=model.APIKeySecretResponse}
+// @Router /api/v1/admin/api-keys [post]
+func (h *APIKeyHandler) Create(c *gin.Context) {
+	var req model.APIKeyCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "请求参数错误: "+err.Error())
+		return
+	}
+
+	result, err := h.apiKeyService.Create(c.Request.Context(), &req, c.GetString("username"))
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+	response.Success(c, result)
+}
+
+// Update 更新 API Key
+// @Summary 更新API Key
+// @Description 修改名称、授权范围、配额和过期时间
+// @Tags API Key管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "API Key ID"
+// @Param request body model.APIKeyUpdateRequest true "更新请求"
+// @Success 200 {object} response.Response{data=apikey.Key}
+// @Router /api/v1/admin/api-keys/{id} [put]
+func (h *APIKeyHandler) Update(c *gin.Context) {
+	id, ok := h.parseID(c)
+	if !ok {
+		return
+	}
+	var req model.APIKeyUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "请求参数错误: "+err.Error())
+		return
+	}
+
+	key, err := h.apiKeyService.Update(c.Request.Context(), id, &req)
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+	response.Success(c, key)
+}
+
+// Rotate 轮换 API Key
+// @Summary 轮换API Key
+// @Description 生成新密钥，旧密钥可在宽限期内继续使用，新密钥只在响应中返回一次
+// @Tags API Key管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "API Key ID"
+// @Param request body model.APIKeyRotateRequest false "轮换请求"
+// @Success 200 {object} response.Response{data=model.APIKeySecretResponse}
+// @Router /api/v1/admin/api-keys/{id}/rotate [post]
+func (h *APIKeyHandler) Rotate(c *gin.Context) {
+	id, ok := h.parseID(c)
+	if !ok {
+		return
+	}
+	var req model.APIKeyRotateRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.BadRequest(c, "请求参数错误: "+err.Error())
+			return
+		}
+	}
+
+	result, err := h.apiKeyService.Rotate(c.Request.Context(), id, time.Duration(req.GracePeriod)*time.Second)
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+	response.Success(c, result)
+}
+
+// Revoke 吊销 API Key
+// @Summary 吊销API Key
+// @Tags API Key管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "API Key ID"
+// @Success 200 {object} response.Response
+// @Router /api/v1/admin/api-keys/{id}/revoke [post]
+func (h *APIKeyHandler) Revoke(c *gin.Context) {
+	id, ok := h.parseID(c)
+	if !ok {
+		return
+	}
+	if err := h.apiKeyService.Revoke(c.Request.Context(), id); err != nil {
+		h.fail(c, err)
+		return
+	}
+	response.Success(c, gin.H{"message": "吊销成功"})
+}
+
+// Usage 获取 API Key 调用量
+// @Summary 获取API Key调用量
+// @Description 按月查询每日调用次数，用于计费
+// @Tags API Key管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "API Key ID"
+// @Param month query string false "月份(YYYY-MM)，默认当月"
+// @Success 200 {object} response.Response{data=apikey.Report}
+// @Router /api/v1/admin/api-keys/{id}/usage [get]
+func (h *APIKeyHandler) Usage(c *gin.Context) {
+	id, ok := h.parseID(c)
+	if !ok {
+		return
+	}
+	month := time.Now()
+	if m := c.Query("month"); m != "" {
+		var err error
+		if month, err = time.Parse("2006-01", m); err != nil {
+			response.BadRequest(c, "月份格式错误，应为YYYY-MM")
+			return
+		}
+	}
+
+	report, err := h.apiKeyService.Usage(c.Request.Context(), id, month)
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+	response.Success(c, report)
+}
+
+func (h *APIKeyHandler) parseID(c *gin.Context) (uint64, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "无效的API Key ID")
+		return 0, false
+	}
+	return id, true
+}
+
+func (h *APIKeyHandler) fail(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrAPIKeyNotFound) {
+		response.NotFound(c, err.Error())
+		return
+	}
+	response.InternalError(c, err.Error())
+}
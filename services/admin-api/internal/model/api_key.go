@@ -0,0 +1,52 @@
+package model
+
+import (
+	"time"
+
+	"goweb/pkg/apikey"
+)
+
+// APIKeyListRequest API Key 列表请求
+type APIKeyListRequest struct {
+	Page     int    `form:"page" binding:"omitempty,min=1"`
+	PageSize int    `form:"page_size" binding:"omitempty,min=1,max=100"`
+	OwnerID  string `form:"owner_id"`
+	Keyword  string `form:"keyword"`
+	Status   string `form:"status" binding:"omitempty,oneof=active expired revoked"`
+}
+
+// APIKeyCreateRequest 创建 API Key 请求
+type APIKeyCreateRequest struct {
+	Name         string     `json:"name" binding:"required,max=100"`         // 名称
+	OwnerID      string     `json:"owner_id" binding:"required,max=64"`      // 所属商户ID
+	Scopes       []string   `json:"scopes" binding:"required,min=1"`         // 授权范围，如 "GET /api/v1/merchant/*"
+	DailyQuota   int64      `json:"daily_quota" binding:"omitempty,min=0"`   // 每日配额，0表示不限
+	MonthlyQuota int64      `json:"monthly_quota" binding:"omitempty,min=0"` // 每月配额，0表示不限
+	ExpiresAt    *time.Time `json:"expires_at"`                              // 过期时间，为空表示永不过期
+}
+
+// APIKeyUpdateRequest 更新 API Key 请求
+type APIKeyUpdateRequest struct {
+	Name         string     `json:"name" binding:"required,max=100"`
+	Scopes       []string   `json:"scopes" binding:"required,min=1"`
+	DailyQuota   int64      `json:"daily_quota" binding:"omitempty,min=0"`
+	MonthlyQuota int64      `json:"monthly_quota" binding:"omitempty,min=0"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+}
+
+// APIKeyRotateRequest 轮换 API Key 请求
+type APIKeyRotateRequest struct {
+	GracePeriod int `json:"grace_period" binding:"omitempty,min=0,max=604800"` // 旧密钥继续有效的秒数，0表示立即失效
+}
+
+// APIKeySecretResponse 创建或轮换后返回的明文密钥，只返回一次
+type APIKeySecretResponse struct {
+	*apikey.Key
+	Secret string `json:"secret"`
+}
+
+// APIKeyListResponse API Key 列表响应
+type APIKeyListResponse struct {
+	List  []*apikey.Key `json:"list"`
+	Total int64         `json:"total"`
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"goweb/pkg/apikey"
+	"goweb/services/admin-api/internal/model"
+)
+
+// APIKeyRepository API Key 仓储
+type APIKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewAPIKeyRepository 创建 API Key 仓储
+func NewAPIKeyRepository(db *gorm.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+// Create 创建 API Key
+func (r *APIKeyRepository) Create(key *apikey.Key) error {
+	return r.db.Create(key).Error
+}
+
+// GetByID 根据 ID 获取 API Key
+func (r *APIKeyRepository) GetByID(id uint64) (*apikey.Key, error) {
+	var key apikey.Key
+	if err := r.db.First(&key, id).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// Update 更新 API Key
+func (r *APIKeyRepository) Update(key *apikey.Key) error {
+	return r.db.Save(key).Error
+}
+
+// List 获取 API Key 列表
+func (r *APIKeyRepository) List(req *model.APIKeyListRequest) ([]*apikey.Key, int64, error) {
+	var list []*apikey.Key
+	var total int64
+
+	db := r.db.Model(&apikey.Key{})
+	if req.OwnerID != "" {
+		db = db.Where("owner_id = ?", req.OwnerID)
+	}
+	if req.Keyword != "" {
+		keyword := "%" + req.Keyword + "%"
+		db = db.Where("name LIKE ? OR prefix LIKE ?", keyword, keyword)
+	}
+	now := time.Now()
+	switch req.Status {
+	case "active":
+		db = db.Where("revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", now)
+	case "expired":
+		db = db.Where("revoked_at IS NULL AND expires_at <= ?", now)
+	case "revoked":
+		db = db.Where("revoked_at IS NOT NULL")
+	}
+
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if req.Page > 0 && req.PageSize > 0 {
+		db = db.Offset((req.Page - 1) * req.PageSize).Limit(req.PageSize)
+	}
+	err := db.Order("id DESC").Find(&list).Error
+	return list, total, err
+}
+
+// ListActive 获取全部可用的 API Key
+func (r *APIKeyRepository) ListActive() ([]*apikey.Key, error) {
+	var list []*apikey.Key
+	err := r.db.Where("revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", time.Now()).Find(&list).Error
+	return list, err
+}
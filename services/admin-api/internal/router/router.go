@@ -1,9 +1,11 @@
 package router
 
 import (
+	"context"
 	"goweb/pkg/config"
 	"goweb/pkg/logger"
 	"goweb/pkg/middleware"
+	"goweb/pkg/monitor"
 	"goweb/pkg/notification"
 	"goweb/pkg/redis"
 	"goweb/pkg/response"
@@ -12,11 +14,14 @@ import (
 	"goweb/services/admin-api/internal/service"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
 	"gorm.io/gorm"
 )
 
-// NewRouter 创建路由
-func NewRouter(db *gorm.DB, redisClient *redis.Client, notifyService *notification.Service, log logger.Logger, cfg *config.Config) *gin.Engine {
+// NewRouter 创建路由，metrics 为空时不记录业务指标
+func NewRouter(db *gorm.DB, redisClient *redis.Client, notifyService *notification.Service, log logger.Logger, cfg *config.Config, metrics *monitor.Metrics) *gin.Engine {
 	r := gin.New()
 
 	// 中间件
@@ -37,6 +42,14 @@ func NewRouter(db *gorm.DB, redisClient *redis.Client, notifyService *notificati
 		response.Success(c, "OK")
 	})
 
+	// 监控指标（含菜单缓存命中率）
+	if metrics != nil {
+		r.GET(cfg.GetString("monitor.metrics_path"), gin.WrapH(promhttp.Handler()))
+	}
+
+	// Swagger 文档（网关 /swagger/ 聚合各服务文档时从这里获取）
+	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+
 	// 初始化服务
 	// 先初始化系统服务，其他服务需要依赖它
 	adminSystemService := service.NewAdminSystemService(db, redisClient, notifyService, log)
@@ -49,7 +62,7 @@ func NewRouter(db *gorm.DB, redisClient *redis.Client, notifyService *notificati
 	roleService.SetLogger(log)
 	permissionService := service.NewPermissionService(db, cfg, redisClient)
 	permissionService.SetLogger(log)
-	menuService := service.NewMenuService(db, cfg, redisClient)
+	menuService := service.NewMenuService(db, cfg, redisClient, metrics)
 	menuService.SetLogger(log)
 	notificationService := service.NewNotificationService(db, redisClient, log)
 
@@ -73,6 +86,13 @@ func NewRouter(db *gorm.DB, redisClient *redis.Client, notifyService *notificati
 	articlesHandler := handler.NewArticlesHandler(articlesService, log)
 	notificationHandler.SetLogger(log)
 
+	// 初始化 API Key，启动时将可用密钥同步到 Redis 供网关校验
+	apiKeyService := service.NewAPIKeyService(repository.NewAPIKeyRepository(db), redisClient, log)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyService, log)
+	if err := apiKeyService.Sync(context.Background()); err != nil {
+		log.Error("Failed to sync api keys to redis", "error", err)
+	}
+
 	// API路由组
 	api := r.Group("/api/v1/admin")
 
@@ -174,6 +194,15 @@ func NewRouter(db *gorm.DB, redisClient *redis.Client, notifyService *notificati
 	auth.GET("/system/runtime", adminSystemHandler.GetRuntimeInfo)
 	auth.GET("/system/health", adminSystemHandler.HealthCheck)
 
+	// API Key 管理路由
+	auth.GET("/api-keys", apiKeyHandler.List)
+	auth.GET("/api-keys/:id", apiKeyHandler.Get)
+	auth.POST("/api-keys", apiKeyHandler.Create)
+	auth.PUT("/api-keys/:id", apiKeyHandler.Update)
+	auth.POST("/api-keys/:id/rotate", apiKeyHandler.Rotate)
+	auth.POST("/api-keys/:id/revoke", apiKeyHandler.Revoke)
+	auth.GET("/api-keys/:id/usage", apiKeyHandler.Usage)
+
 	// 通知相关路由
 	auth.POST("/notifications/system", notificationHandler.SendSystemNotification)
 	auth.POST("/notifications/user", notificationHandler.SendUserNotification)
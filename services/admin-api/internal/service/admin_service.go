@@ -170,11 +170,7 @@ func (s *UserService) Login(req *model.AdminUserLoginRequest, loginIP, userAgent
 		sessionTimeout = s.systemService.GetSessionTimeout(ctx)
 	}
 	
-	claims := jwt.MapClaims{
-		"user_id":  fmt.Sprintf("%d", user.ID),
-		"username": user.Username,
-		"exp":      time.Now().Add(time.Duration(sessionTimeout) * time.Minute).Unix(),
-	}
+	claims := loginClaims(user, time.Duration(sessionTimeout)*time.Minute, time.Now())
 
 	tokenObj := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	token, err := tokenObj.SignedString([]byte(s.config.GetString("jwt.secret")))
@@ -222,6 +218,19 @@ func (s *UserService) Login(req *model.AdminUserLoginRequest, loginIP, userAgent
 	}, nil
 }
 
+// loginClaims 登录令牌的声明，role 为用户第一个角色的编码（网关按角色限流），没有角色时不设置
+func loginClaims(user *model.AdminUser, ttl time.Duration, now time.Time) jwt.MapClaims {
+	claims := jwt.MapClaims{
+		"user_id":  fmt.Sprintf("%d", user.ID),
+		"username": user.Username,
+		"exp":      now.Add(ttl).Unix(),
+	}
+	if len(user.Roles) > 0 && user.Roles[0].Code != "" {
+		claims["role"] = user.Roles[0].Code
+	}
+	return claims
+}
+
 // Logout 用户登出
 func (s *UserService) Logout(userID string, username string, token string, ip string) error {
 	s.logger.Info("user logout", "user_id", userID, "username", username, "ip", ip)
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"goweb/services/admin-api/internal/model"
+)
+
+func TestLoginClaims(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	user := &model.AdminUser{
+		ID:       7,
+		Username: "alice",
+		Roles:    []model.AdminRole{{ID: 1, Code: "operator"}, {ID: 2, Code: "admin"}},
+	}
+
+	claims := loginClaims(user, time.Hour, now)
+	require.Equal(t, "7", claims["user_id"])
+	require.Equal(t, "alice", claims["username"])
+	require.Equal(t, now.Add(time.Hour).Unix(), claims["exp"])
+	require.Equal(t, "operator", claims["role"])
+
+	// 没有角色时不设置 role
+	user.Roles = nil
+	require.NotContains(t, loginClaims(user, time.Hour, now), "role")
+}
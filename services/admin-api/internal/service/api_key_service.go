@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"goweb/pkg/apikey"
+	"goweb/pkg/logger"
+	"goweb/pkg/redis"
+	"goweb/services/admin-api/internal/model"
+	"goweb/services/admin-api/internal/repository"
+)
+
+// APIKeyService API Key 管理服务
+// 数据库是密钥的唯一来源，每次变更后同步到 Redis，网关从 Redis 校验密钥并记录调用量
+type APIKeyService struct {
+	repo        *repository.APIKeyRepository
+	redisClient *redis.Client
+	store       *apikey.RedisStore
+	quota       *apikey.Quota
+	logger      logger.Logger
+}
+
+// NewAPIKeyService 创建 API Key 管理服务
+func NewAPIKeyService(repo *repository.APIKeyRepository, redisClient *redis.Client, log logger.Logger) *APIKeyService {
+	return &APIKeyService{
+		repo:        repo,
+		redisClient: redisClient,
+		store:       apikey.NewRedisStore(redisClient),
+		quota:       apikey.NewQuota(redisClient),
+		logger:      log,
+	}
+}
+
+// ErrAPIKeyNotFound API Key 不存在
+var ErrAPIKeyNotFound = errors.New("API Key不存在")
+
+// Create 创建 API Key，返回只显示一次的明文密钥
+func (s *APIKeyService) Create(ctx context.Context, req *model.APIKeyCreateRequest, createdBy string) (*model.APIKeySecretResponse, error) {
+	plain, prefix, hash, err := apikey.Generate()
+	if err != nil {
+		s.logger.Error("Failed to generate api key", "error", err)
+		return nil, errors.New("生成API Key失败")
+	}
+
+	key := &apikey.Key{
+		Name:         req.Name,
+		OwnerID:      req.OwnerID,
+		Prefix:       prefix,
+		KeyHash:      hash,
+		Scopes:       apikey.JoinScopes(req.Scopes),
+		DailyQuota:   req.DailyQuota,
+		MonthlyQuota: req.MonthlyQuota,
+		ExpiresAt:    req.ExpiresAt,
+		CreatedBy:    createdBy,
+	}
+	if err := s.repo.Create(key); err != nil {
+		s.logger.Error("Failed to create api key", "error", err)
+		return nil, errors.New("创建API Key失败")
+	}
+	if err := s.sync(ctx, key); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("api key created", "id", key.ID, "owner_id", key.OwnerID, "prefix", key.Prefix, "created_by", createdBy)
+	return &model.APIKeySecretResponse{Key: key, Secret: plain}, nil
+}
+
+// List 获取 API Key 列表
+func (s *APIKeyService) List(req *model.APIKeyListRequest) ([]*apikey.Key, int64, error) {
+	list, total, err := s.repo.List(req)
+	if err != nil {
+		s.logger.Error("Failed to list api keys", "error", err)
+		return nil, 0, errors.New("获取API Key列表失败")
+	}
+	return list, total, nil
+}
+
+// GetByID 获取 API Key
+func (s *APIKeyService) GetByID(id uint64) (*apikey.Key, error) {
+	key, err := s.repo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAPIKeyNotFound
+		}
+		s.logger.Error("Failed to get api key", "error", err, "id", id)
+		return nil, errors.New("获取API Key失败")
+	}
+	return key, nil
+}
+
+// Update 修改名称、授权范围、配额和过期时间
+func (s *APIKeyService) Update(ctx context.Context, id uint64, req *model.APIKeyUpdateRequest) (*apikey.Key, error) {
+	key, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if key.RevokedAt != nil {
+		return nil, errors.New("API Key已吊销")
+	}
+
+	key.Name = req.Name
+	key.Scopes = apikey.JoinScopes(req.Scopes)
+	key.DailyQuota = req.DailyQuota
+	key.MonthlyQuota = req.MonthlyQuota
+	key.ExpiresAt = req.ExpiresAt
+	if err := s.repo.Update(key); err != nil {
+		s.logger.Error("Failed to update api key", "error", err, "id", id)
+		return nil, errors.New("更新API Key失败")
+	}
+	if err := s.sync(ctx, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Rotate 轮换密钥，调用量和配额保持不变
+// gracePeriod 大于 0 时旧密钥在宽限期内继续有效，方便商户平滑切换
+func (s *APIKeyService) Rotate(ctx context.Context, id uint64, gracePeriod time.Duration) (*model.APIKeySecretResponse, error) {
+	key, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if !key.Active(time.Now()) {
+		return nil, errors.New("API Key已过期或已吊销")
+	}
+
+	plain, prefix, hash, err := apikey.Generate()
+	if err != nil {
+		s.logger.Error("Failed to generate api key", "error", err)
+		return nil, errors.New("生成API Key失败")
+	}
+
+	// 上一次轮换的旧密钥立即失效
+	if key.PreviousHash != nil {
+		if err := s.store.Delete(ctx, &apikey.Key{KeyHash: *key.PreviousHash}); err != nil {
+			s.logger.Error("Failed to delete previous api key from redis", "error", err, "id", id)
+		}
+	}
+
+	previous := key.KeyHash
+	key.PreviousHash, key.PreviousExpiresAt = nil, nil
+	if gracePeriod > 0 {
+		expiresAt := time.Now().Add(gracePeriod)
+		key.PreviousHash, key.PreviousExpiresAt = &previous, &expiresAt
+	}
+	key.KeyHash = hash
+	key.Prefix = prefix
+	if err := s.repo.Update(key); err != nil {
+		s.logger.Error("Failed to rotate api key", "error", err, "id", id)
+		return nil, errors.New("轮换API Key失败")
+	}
+
+	if gracePeriod <= 0 {
+		if err := s.store.Delete(ctx, &apikey.Key{KeyHash: previous}); err != nil {
+			s.logger.Error("Failed to delete rotated api key from redis", "error", err, "id", id)
+		}
+	}
+	if err := s.sync(ctx, key); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("api key rotated", "id", key.ID, "prefix", key.Prefix, "grace_period", gracePeriod)
+	return &model.APIKeySecretResponse{Key: key, Secret: plain}, nil
+}
+
+// Revoke 吊销密钥，重复吊销会重新同步网关
+func (s *APIKeyService) Revoke(ctx context.Context, id uint64) error {
+	key, err := s.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if key.RevokedAt == nil {
+		now := time.Now()
+		key.RevokedAt = &now
+		if err := s.repo.Update(key); err != nil {
+			s.logger.Error("Failed to revoke api key", "error", err, "id", id)
+			return errors.New("吊销API Key失败")
+		}
+	}
+	if err := s.sync(ctx, key); err != nil {
+		return err
+	}
+
+	s.logger.Info("api key revoked", "id", key.ID, "prefix", key.Prefix)
+	return nil
+}
+
+// Usage 获取指定月份的调用量，用于计费
+func (s *APIKeyService) Usage(ctx context.Context, id uint64, month time.Time) (*apikey.Report, error) {
+	if _, err := s.GetByID(id); err != nil {
+		return nil, err
+	}
+	if !s.redisEnabled() {
+		return nil, errors.New("Redis未启用，无法统计调用量")
+	}
+	report, err := s.quota.Report(ctx, id, month)
+	if err != nil {
+		s.logger.Error("Failed to get api key usage", "error", err, "id", id)
+		return nil, errors.New("获取API Key调用量失败")
+	}
+	return report, nil
+}
+
+// Sync 将全部可用的密钥同步到 Redis，启动时调用，用于 Redis 数据丢失后恢复
+func (s *APIKeyService) Sync(ctx context.Context) error {
+	if !s.redisEnabled() {
+		return nil
+	}
+	keys, err := s.repo.ListActive()
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := s.store.Put(ctx, key); err != nil {
+			return err
+		}
+	}
+	s.logger.Info("api keys synced to redis", "count", len(keys))
+	return nil
+}
+
+// sync 将单个密钥的变更同步到 Redis
+func (s *APIKeyService) sync(ctx context.Context, key *apikey.Key) error {
+	if !s.redisEnabled() {
+		s.logger.Warn("Redis is disabled, api key changes are not visible to the gateway", "id", key.ID)
+		return nil
+	}
+	if err := s.store.Put(ctx, key); err != nil {
+		s.logger.Error("Failed to sync api key to redis", "error", err, "id", key.ID)
+		return errors.New("API Key已保存，但同步到网关失败，请重试")
+	}
+	return nil
+}
+
+func (s *APIKeyService) redisEnabled() bool {
+	return s.redisClient != nil && s.redisClient.IsEnabled()
+}
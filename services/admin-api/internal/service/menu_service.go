@@ -1,26 +1,48 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"time"
+
 	"goweb/pkg/config"
+	"goweb/pkg/monitor"
 	pkgRedis "goweb/pkg/redis"
 	"goweb/services/admin-api/internal/model"
 
 	"gorm.io/gorm"
 )
 
+// menuTreeCacheKey 菜单树的缓存键，菜单变更后删除
+const menuTreeCacheKey = "tree"
+
 // MenuService 菜单服务
 type MenuService struct {
 	*AdminService
+	cache *pkgRedis.Cache
 }
 
-// NewMenuService 创建菜单服务实例
-func NewMenuService(db *gorm.DB, cfg *config.Config, redisClient *pkgRedis.Client) *MenuService {
+// NewMenuService 创建菜单服务实例，metrics 不为空时记录菜单缓存的命中率
+func NewMenuService(db *gorm.DB, cfg *config.Config, redisClient *pkgRedis.Client, metrics *monitor.Metrics) *MenuService {
+	opts := pkgRedis.CacheOptions{
+		Name:      "admin_menu",
+		Service:   "admin-api",
+		LocalSize: 16,
+	}
+	if metrics != nil {
+		opts.Metrics = metrics
+	}
 	return &MenuService{
 		AdminService: NewAdminService(db, cfg, redisClient),
+		cache:        pkgRedis.NewCacheWithOptions(redisClient, "admin:menu:", opts),
 	}
 }
 
+// invalidateMenuTree 菜单变更后删除菜单树缓存（包括各实例的本地缓存）
+func (s *MenuService) invalidateMenuTree() {
+	_ = s.cache.Delete(context.Background(), menuTreeCacheKey)
+}
+
 // CreateMenu 创建菜单
 func (s *MenuService) CreateMenu(req *model.AdminMenuCreateRequest) error {
 	// 检查菜单编码是否已存在
@@ -57,6 +79,7 @@ func (s *MenuService) CreateMenu(req *model.AdminMenuCreateRequest) error {
 	if err := s.menuRepo.Create(menu); err != nil {
 		return err
 	}
+	s.invalidateMenuTree()
 
 	return nil
 }
@@ -109,6 +132,7 @@ func (s *MenuService) UpdateMenu(id uint64, req *model.AdminMenuUpdateRequest) e
 	if err := s.menuRepo.Update(menu); err != nil {
 		return err
 	}
+	s.invalidateMenuTree()
 
 	return nil
 }
@@ -126,9 +150,12 @@ func (s *MenuService) GetMenus(req *model.AdminMenuListRequest) (*model.AdminMen
 	}, nil
 }
 
-// GetMenuTree 获取菜单树
+// GetMenuTree 获取菜单树，结果缓存 10 分钟
 func (s *MenuService) GetMenuTree() (*model.AdminMenuTreeResponse, error) {
-	menus, err := s.menuRepo.GetTree()
+	menus, err := pkgRedis.GetOrLoad(context.Background(), s.cache, menuTreeCacheKey, 10*time.Minute,
+		func(ctx context.Context) ([]model.AdminMenu, error) {
+			return s.menuRepo.GetTree()
+		})
 	if err != nil {
 		return nil, err
 	}
@@ -145,7 +172,11 @@ func (s *MenuService) GetMenu(id uint64) (*model.AdminMenu, error) {
 
 // DeleteMenu 删除菜单
 func (s *MenuService) DeleteMenu(id uint64) error {
-	return s.menuRepo.Delete(id)
+	if err := s.menuRepo.Delete(id); err != nil {
+		return err
+	}
+	s.invalidateMenuTree()
+	return nil
 }
 
 // PermissionService 权限服务
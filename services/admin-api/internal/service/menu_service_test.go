@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"goweb/pkg/config"
+	"goweb/pkg/logger"
+	"goweb/pkg/monitor"
+	pkgRedis "goweb/pkg/redis"
+)
+
+// counterValue 默认注册表中带有指定标签的计数器的值
+func counterValue(t *testing.T, name string, labels map[string]string) float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, m := range family.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if v, ok := labels[l.GetName()]; ok && v != l.GetValue() {
+					continue metrics
+				}
+			}
+			return m.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+func TestMenuService_CacheMetrics(t *testing.T) {
+	log := logger.New("admin-api-test", "error", "stdout", "")
+	redisClient := pkgRedis.NewClient(&config.RedisConfig{Enabled: false}, log)
+	s := NewMenuService(nil, nil, redisClient, monitor.NewMetrics("admin-api", log))
+
+	load := func(ctx context.Context) ([]string, error) { return []string{"dashboard"}, nil }
+	for i := 0; i < 2; i++ {
+		menus, err := pkgRedis.GetOrLoad(context.Background(), s.cache, menuTreeCacheKey, time.Minute, load)
+		require.NoError(t, err)
+		require.Equal(t, []string{"dashboard"}, menus)
+	}
+
+	// 第一次未命中本地缓存，第二次命中
+	labels := map[string]string{"cache_type": "local", "key_pattern": "admin_menu", "service": "admin-api"}
+	require.EqualValues(t, 1, counterValue(t, "cache_misses_total", labels))
+	require.EqualValues(t, 1, counterValue(t, "cache_hits_total", labels))
+}
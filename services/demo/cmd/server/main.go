@@ -9,9 +9,12 @@ import (
 	"syscall"
 	"time"
 
+	"goweb/pkg/circuit"
 	"goweb/pkg/config"
 	"goweb/pkg/gateway"
 	"goweb/pkg/logger"
+	"goweb/pkg/monitor"
+	discovery "goweb/pkg/service"
 	"goweb/services/demo/internal/handler"
 	"goweb/services/demo/internal/router"
 	"goweb/services/demo/internal/service"
@@ -28,8 +31,11 @@ func main() {
 		cfg.GetString("log.dir"),
 	)
 
-	// 初始化 Gateway 客户端
+	// 初始化 Gateway 客户端，熔断器状态变化上报到 Prometheus
 	gatewayClient := gateway.NewClient(cfg, log)
+	if cfg.GetBool("monitor.enabled") {
+		circuit.RegisterMetrics(gatewayClient.Breakers(), monitor.NewMetrics(serviceName, log), serviceName)
+	}
 
 	// 初始化服务
 	demoService := service.NewDemoService(gatewayClient, log)
@@ -54,6 +60,9 @@ func main() {
 		}
 	}()
 
+	// 注册到服务注册中心（service_discovery），退出时先注销
+	registration := discovery.RegisterSelf(cfg, log, serviceName, cfg.GetInt("services.demo.port"))
+
 	// 优雅退出
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -62,6 +71,7 @@ func main() {
 	log.Info("demo service shutting down...")
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
+	registration.Stop(ctx)
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Error("demo service shutdown error", err)
 	}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"goweb/pkg/config"
 	"goweb/pkg/logger"
@@ -33,6 +34,11 @@ func NewRouter(cfg *config.Config, log logger.Logger, h *handler.DemoHandler) *g
 	// 健康检查
 	r.GET("/healthz", func(c *gin.Context) { c.JSON(200, gin.H{"status": "ok", "service": "demo"}) })
 
+	// 监控指标（含 Gateway 客户端的熔断器状态）
+	if cfg.GetBool("monitor.enabled") {
+		r.GET(cfg.GetString("monitor.metrics_path"), gin.WrapH(promhttp.Handler()))
+	}
+
 	// 设置处理器日志
 	h.SetLogger(log)
 
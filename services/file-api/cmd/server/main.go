@@ -13,6 +13,7 @@ import (
 	"goweb/pkg/db"
 	"goweb/pkg/logger"
 	"goweb/pkg/security"
+	discovery "goweb/pkg/service"
 	"goweb/pkg/storage"
 	"goweb/pkg/storage/factory"
 	"goweb/services/file-api/internal/handler"
@@ -164,6 +165,9 @@ func main() {
 		}
 	}()
 
+	// 注册到服务注册中心（service_discovery），退出时先注销
+	registration := discovery.RegisterSelf(cfg, log, serviceName, port)
+
 	// 优雅退出
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -172,6 +176,7 @@ func main() {
 	log.Info("file-api service shutting down...")
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
+	registration.Stop(ctx)
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Error("file-api service shutdown error", err)
 	}
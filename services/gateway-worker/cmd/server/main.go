@@ -12,6 +12,7 @@ import (
 	"goweb/pkg/config"
 	"goweb/pkg/logger"
 	"goweb/pkg/redis"
+	discovery "goweb/pkg/service"
 	"goweb/services/gateway-worker/internal/handler"
 	"goweb/services/gateway-worker/internal/service"
 )
@@ -37,8 +38,14 @@ func main() {
 		log.Fatal("Redis connection failed", err)
 	}
 
+	// 消费者配置（并发数、批量大小、处理超时等）
+	var consumerConfig service.ConsumerConfig
+	if err := cfg.Unmarshal("queue", &consumerConfig); err != nil {
+		log.Fatal("Invalid queue config", err)
+	}
+
 	// 初始化服务
-	workerService := service.NewWorkerService(redisManager, log)
+	workerService := service.NewWorkerService(redisManager, consumerConfig, log)
 	workerHandler := handler.NewWorkerHandler(workerService, log)
 
 	// 启动消息消费者
@@ -49,7 +56,7 @@ func main() {
 	// 启动健康检查服务
 	healthServer := &http.Server{
 		Addr:    fmt.Sprintf(":%d", cfg.GetInt("services.gateway_worker.port")),
-		Handler: workerHandler.GetHealthHandler(),
+		Handler: workerHandler.GetHealthHandler(cfg.GetString("gateway_worker.admin.token")),
 	}
 
 	go func() {
@@ -59,6 +66,9 @@ func main() {
 		}
 	}()
 
+	// 注册到服务注册中心（service_discovery），退出时先注销
+	registration := discovery.RegisterSelf(cfg, log, serviceName, cfg.GetInt("services.gateway_worker.port"))
+
 	// 优雅退出
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -66,12 +76,13 @@ func main() {
 
 	log.Info("gateway-worker shutting down...")
 
-	// 停止消息消费者
+	// 停止消息消费者，等待处理中的消息完成
 	workerService.StopConsumers()
 
 	// 关闭健康检查服务
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
+	registration.Stop(shutdownCtx)
 	if err := healthServer.Shutdown(shutdownCtx); err != nil {
 		log.Error("health server shutdown error", err)
 	}
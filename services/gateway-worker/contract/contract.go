@@ -0,0 +1,66 @@
+// Package contract gateway-worker 消费的消息契约，生产者通过 redis.Publish 发布这些类型的消息
+package contract
+
+import (
+	"goweb/pkg/redis"
+)
+
+// 主题
+const (
+	TopicOrderReminder    = "order.reminder"
+	TopicUserNotification = "user.notification"
+	TopicSystemCleanup    = "system.cleanup"
+	TopicPaymentRetry     = "payment.retry"
+	TopicInventoryAlert   = "inventory.alert"
+)
+
+// OrderReminder 订单提醒
+type OrderReminder struct {
+	OrderID string `json:"order_id" validate:"required"`
+	UserID  string `json:"user_id" validate:"required"`
+	Type    string `json:"type" validate:"required"`
+}
+
+// UserNotification 用户通知
+type UserNotification struct {
+	UserID  string `json:"user_id" validate:"required"`
+	Type    string `json:"type" validate:"required"`
+	Content string `json:"content" validate:"required"`
+}
+
+// SystemCleanup 系统清理
+type SystemCleanup struct {
+	Type   string                 `json:"type" validate:"required"`
+	Params map[string]interface{} `json:"params"`
+}
+
+// PaymentRetry 支付重试
+type PaymentRetry struct {
+	PaymentID  string `json:"payment_id" validate:"required"`
+	OrderID    string `json:"order_id" validate:"required"`
+	RetryCount int    `json:"retry_count" validate:"gte=0"`
+}
+
+// InventoryAlert 库存告警
+type InventoryAlert struct {
+	ProductID    string `json:"product_id" validate:"required"`
+	CurrentStock int    `json:"current_stock" validate:"gte=0"`
+	Threshold    int    `json:"threshold" validate:"gte=0"`
+}
+
+// Register 注册所有主题的消息契约（当前均为版本 1）
+func Register(r *redis.ContractRegistry) error {
+	if err := redis.RegisterContract[OrderReminder](r, TopicOrderReminder, "order.reminder", 1); err != nil {
+		return err
+	}
+	if err := redis.RegisterContract[UserNotification](r, TopicUserNotification, "user.notification", 1); err != nil {
+		return err
+	}
+	if err := redis.RegisterContract[SystemCleanup](r, TopicSystemCleanup, "system.cleanup", 1); err != nil {
+		return err
+	}
+	if err := redis.RegisterContract[PaymentRetry](r, TopicPaymentRetry, "payment.retry", 1); err != nil {
+		return err
+	}
+	return redis.RegisterContract[InventoryAlert](r, TopicInventoryAlert, "inventory.alert", 1)
+}
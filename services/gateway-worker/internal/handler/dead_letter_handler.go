@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"goweb/pkg/redis"
+	"goweb/services/gateway-worker/internal/service"
+)
+
+// replayRequest 重放请求，data 不为空时替换消息内容后重放
+type replayRequest struct {
+	Data map[string]interface{} `json:"data"`
+}
+
+// purgeRequest 删除指定的死信消息
+type purgeRequest struct {
+	IDs []string `json:"ids" binding:"required,min=1"`
+}
+
+// RegisterDeadLetterRoutes 注册死信队列管理接口，需携带 Authorization: Bearer <token>
+//
+//	GET    /dlq/topics                                  有死信消息的主题及数量
+//	GET    /dlq/topics/:topic/messages?cursor=&limit=   分页查看死信消息（最后一次错误、重试次数）
+//	GET    /dlq/topics/:topic/messages/:id              查看一条死信消息
+//	POST   /dlq/topics/:topic/messages/:id/replay       重放一条消息，body {"data": {...}} 可修改消息内容
+//	POST   /dlq/topics/:topic/replay                    重放主题下的所有消息
+//	DELETE /dlq/topics/:topic/messages/:id              删除一条消息
+//	POST   /dlq/topics/:topic/purge                     删除多条消息 {"ids": [...]}
+//	DELETE /dlq/topics/:topic                           删除主题下的所有消息
+//	GET    /dlq/audit?limit=                            最近的重放、删除记录
+//
+// 审计记录的操作人为通过认证的身份（共享的管理令牌记为 admin_token），
+// 请求头 X-Operator 只作为未经校验的自称操作人（claimed_operator）一起记录
+func (h *WorkerHandler) RegisterDeadLetterRoutes(r gin.IRouter, token string) {
+	dlq := r.Group("/dlq", adminAuth(token))
+	dlq.GET("/topics", h.DeadLetterTopics)
+	dlq.GET("/topics/:topic/messages", h.DeadLetters)
+	dlq.GET("/topics/:topic/messages/:id", h.GetDeadLetter)
+	dlq.POST("/topics/:topic/messages/:id/replay", h.ReplayDeadLetter)
+	dlq.POST("/topics/:topic/replay", h.ReplayDeadLetters)
+	dlq.DELETE("/topics/:topic/messages/:id", h.DeleteDeadLetter)
+	dlq.POST("/topics/:topic/purge", h.PurgeDeadLetters)
+	dlq.DELETE("/topics/:topic", h.PurgeAllDeadLetters)
+	dlq.GET("/audit", h.DeadLetterAudits)
+}
+
+// DeadLetterTopics 有死信消息的主题
+func (h *WorkerHandler) DeadLetterTopics(c *gin.Context) {
+	topics, err := h.workerService.DeadLetterTopics(c.Request.Context())
+	if err != nil {
+		h.failed(c, "list dead letter topics failed", err)
+		return
+	}
+	h.Success(c, gin.H{"list": topics})
+}
+
+// DeadLetters 分页查看死信消息
+func (h *WorkerHandler) DeadLetters(c *gin.Context) {
+	limit, _ := strconv.ParseInt(c.DefaultQuery("limit", "20"), 10, 64)
+	if limit <= 0 || limit > 100 {
+		h.BadRequest(c, "limit must be between 1 and 100")
+		return
+	}
+	letters, next, err := h.workerService.DeadLetters(c.Request.Context(), c.Param("topic"), c.Query("cursor"), limit)
+	if err != nil {
+		h.failed(c, "list dead letters failed", err)
+		return
+	}
+	h.Success(c, gin.H{"list": letters, "next_cursor": next})
+}
+
+// GetDeadLetter 查看一条死信消息
+func (h *WorkerHandler) GetDeadLetter(c *gin.Context) {
+	letter, err := h.workerService.GetDeadLetter(c.Request.Context(), c.Param("topic"), c.Param("id"))
+	if err != nil {
+		h.failed(c, "get dead letter failed", err)
+		return
+	}
+	h.Success(c, letter)
+}
+
+// ReplayDeadLetter 重放一条死信消息
+func (h *WorkerHandler) ReplayDeadLetter(c *gin.Context) {
+	var req replayRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			h.BadRequest(c, "invalid request body: "+err.Error())
+			return
+		}
+	}
+	letter, err := h.workerService.ReplayDeadLetter(c.Request.Context(), c.Param("topic"), c.Param("id"), req.Data, operator(c))
+	if err != nil {
+		h.failed(c, "replay dead letter failed", err)
+		return
+	}
+	h.Success(c, gin.H{"id": letter.ID, "message_id": letter.Message.ID, "edited": req.Data != nil})
+}
+
+// ReplayDeadLetters 重放主题下的所有死信消息
+func (h *WorkerHandler) ReplayDeadLetters(c *gin.Context) {
+	count, err := h.workerService.ReplayDeadLetters(c.Request.Context(), c.Param("topic"), operator(c))
+	if err != nil {
+		h.failed(c, "replay dead letters failed", err)
+		return
+	}
+	h.Success(c, gin.H{"replayed": count})
+}
+
+// DeleteDeadLetter 删除一条死信消息
+func (h *WorkerHandler) DeleteDeadLetter(c *gin.Context) {
+	h.purge(c, []string{c.Param("id")})
+}
+
+// PurgeDeadLetters 删除多条死信消息
+func (h *WorkerHandler) PurgeDeadLetters(c *gin.Context) {
+	var req purgeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.BadRequest(c, "invalid request body: "+err.Error())
+		return
+	}
+	h.purge(c, req.IDs)
+}
+
+// PurgeAllDeadLetters 删除主题下的所有死信消息
+func (h *WorkerHandler) PurgeAllDeadLetters(c *gin.Context) {
+	h.purge(c, nil)
+}
+
+func (h *WorkerHandler) purge(c *gin.Context, ids []string) {
+	count, err := h.workerService.PurgeDeadLetters(c.Request.Context(), c.Param("topic"), ids, operator(c))
+	if err != nil {
+		h.failed(c, "purge dead letters failed", err)
+		return
+	}
+	h.Success(c, gin.H{"deleted": count})
+}
+
+// DeadLetterAudits 最近的死信操作记录
+func (h *WorkerHandler) DeadLetterAudits(c *gin.Context) {
+	limit, _ := strconv.ParseInt(c.DefaultQuery("limit", "50"), 10, 64)
+	if limit <= 0 || limit > 1000 {
+		h.BadRequest(c, "limit must be between 1 and 1000")
+		return
+	}
+	audits, err := h.workerService.DeadLetterAudits(c.Request.Context(), limit)
+	if err != nil {
+		h.failed(c, "list dead letter audits failed", err)
+		return
+	}
+	h.Success(c, gin.H{"list": audits})
+}
+
+// failed 按错误类型返回 404 或 500
+func (h *WorkerHandler) failed(c *gin.Context, msg string, err error) {
+	if errors.Is(err, redis.ErrDeadLetterNotFound) {
+		h.NotFound(c, err.Error())
+		return
+	}
+	h.LogError(msg, err, "topic", c.Param("topic"), "id", c.Param("id"))
+	h.InternalError(c, msg+": "+err.Error())
+}
+
+// tokenOperator 使用共享管理令牌认证的操作人
+const tokenOperator = "admin_token"
+
+// operator 请求的操作人，身份由 adminAuth 设置，X-Operator 仅作为自称的操作人记录
+func operator(c *gin.Context) service.Operator {
+	name := c.GetString("operator")
+	if name == "" {
+		name = "unknown"
+	}
+	return service.Operator{
+		Name:    name,
+		Claimed: strings.TrimSpace(c.GetHeader("X-Operator")),
+		IP:      c.ClientIP(),
+	}
+}
+
+// adminAuth 校验管理接口令牌，通过后将操作人设置为 admin_token
+func adminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		got := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"code":    http.StatusUnauthorized,
+				"message": "invalid admin token",
+			})
+			return
+		}
+		c.Set("operator", tokenOperator)
+		c.Next()
+	}
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"goweb/services/gateway-worker/internal/service"
+)
+
+func TestAdminAuth_Operator(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var got service.Operator
+	r := gin.New()
+	r.POST("/dlq", adminAuth("secret"), func(c *gin.Context) {
+		got = operator(c)
+		c.Status(http.StatusOK)
+	})
+	do := func(token, claimed string) int {
+		req := httptest.NewRequest(http.MethodPost, "/dlq", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("X-Operator", claimed)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	require.Equal(t, http.StatusUnauthorized, do("wrong", "alice"))
+
+	// 操作人取自认证身份，X-Operator 只作为未经校验的自称操作人记录
+	require.Equal(t, http.StatusOK, do("secret", "alice"))
+	require.Equal(t, tokenOperator, got.Name)
+	require.Equal(t, "alice", got.Claimed)
+}
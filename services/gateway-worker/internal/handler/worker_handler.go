@@ -22,8 +22,8 @@ func NewWorkerHandler(workerService *service.WorkerService, log logger.Logger) *
 	}
 }
 
-// GetHealthHandler 获取健康检查处理器
-func (h *WorkerHandler) GetHealthHandler() *gin.Engine {
+// GetHealthHandler 获取健康检查处理器，adminToken 不为空时开放死信队列管理接口
+func (h *WorkerHandler) GetHealthHandler(adminToken string) *gin.Engine {
 	r := gin.New()
 
 	// 健康检查
@@ -31,6 +31,11 @@ func (h *WorkerHandler) GetHealthHandler() *gin.Engine {
 	r.GET("/ready", h.ReadyCheck)
 	r.GET("/metrics", h.Metrics)
 
+	// 死信队列管理
+	if adminToken != "" {
+		h.RegisterDeadLetterRoutes(r, adminToken)
+	}
+
 	return r
 }
 
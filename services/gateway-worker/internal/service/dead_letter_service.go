@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"goweb/pkg/redis"
+)
+
+// Operator 死信操作人，记录在审计日志中
+type Operator struct {
+	Name    string // 通过认证的身份
+	Claimed string // 调用方自称的操作人，未经校验
+	IP      string
+}
+
+// DeadLetterTopics 有死信消息的主题及消息数
+func (s *WorkerService) DeadLetterTopics(ctx context.Context) ([]redis.DeadLetterTopic, error) {
+	return s.redisManager.GetQueue().DeadLetterTopics(ctx)
+}
+
+// DeadLetters 分页读取主题的死信消息
+func (s *WorkerService) DeadLetters(ctx context.Context, topic, cursor string, limit int64) ([]redis.DeadLetter, string, error) {
+	return s.redisManager.GetQueue().DeadLetters(ctx, topic, cursor, limit)
+}
+
+// GetDeadLetter 读取一条死信消息
+func (s *WorkerService) GetDeadLetter(ctx context.Context, topic, id string) (*redis.DeadLetter, error) {
+	return s.redisManager.GetQueue().GetDeadLetter(ctx, topic, id)
+}
+
+// ReplayDeadLetter 重放一条死信消息，data 不为空时先替换消息内容
+func (s *WorkerService) ReplayDeadLetter(ctx context.Context, topic, id string, data map[string]interface{}, op Operator) (*redis.DeadLetter, error) {
+	letter, err := s.redisManager.GetQueue().ReplayDeadLetter(ctx, topic, id, data)
+	if err != nil {
+		return nil, err
+	}
+	audit := redis.DeadLetterAudit{Action: "replay", Topic: topic, IDs: []string{id}, Count: 1}
+	if data != nil {
+		audit.Edited, audit.Before, audit.After = true, letter.Message.Data, data
+	}
+	s.audit(ctx, audit, op)
+	return letter, nil
+}
+
+// ReplayDeadLetters 重放主题下的所有死信消息
+func (s *WorkerService) ReplayDeadLetters(ctx context.Context, topic string, op Operator) (int64, error) {
+	count, err := s.redisManager.GetQueue().ReplayDeadLetters(ctx, topic)
+	// 部分重放成功时同样记录
+	if count > 0 || err == nil {
+		s.audit(ctx, redis.DeadLetterAudit{Action: "replay_all", Topic: topic, Count: count}, op)
+	}
+	return count, err
+}
+
+// PurgeDeadLetters 删除死信消息，ids 为空时删除主题下的所有死信消息
+func (s *WorkerService) PurgeDeadLetters(ctx context.Context, topic string, ids []string, op Operator) (int64, error) {
+	count, err := s.redisManager.GetQueue().PurgeDeadLetters(ctx, topic, ids...)
+	if err != nil {
+		return 0, err
+	}
+	action := "purge"
+	if len(ids) == 0 {
+		action = "purge_all"
+	}
+	s.audit(ctx, redis.DeadLetterAudit{Action: action, Topic: topic, IDs: ids, Count: count}, op)
+	return count, nil
+}
+
+// DeadLetterAudits 最近的死信操作记录
+func (s *WorkerService) DeadLetterAudits(ctx context.Context, limit int64) ([]redis.DeadLetterAudit, error) {
+	return s.redisManager.GetQueue().DeadLetterAudits(ctx, limit)
+}
+
+// audit 记录死信操作到日志和审计流，审计记录写入失败不影响操作结果
+func (s *WorkerService) audit(ctx context.Context, audit redis.DeadLetterAudit, op Operator) {
+	audit.Operator, audit.Claimed, audit.IP, audit.Time = op.Name, op.Claimed, op.IP, time.Now()
+	s.LogInfo("dead letter operation", "action", audit.Action, "topic", audit.Topic, "ids", audit.IDs,
+		"count", audit.Count, "edited", audit.Edited, "operator", op.Name, "claimed_operator", op.Claimed, "ip", op.IP)
+	if err := s.redisManager.GetQueue().RecordDeadLetterAudit(context.WithoutCancel(ctx), audit); err != nil {
+		s.LogError("failed to record dead letter audit", err, "action", audit.Action, "topic", audit.Topic)
+	}
+}
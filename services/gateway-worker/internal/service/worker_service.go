@@ -2,172 +2,191 @@ package service
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"time"
 
 	"goweb/pkg/base"
 	"goweb/pkg/logger"
 	"goweb/pkg/redis"
+	"goweb/services/gateway-worker/contract"
 )
 
+// ConsumerConfig 消费者配置（queue），topics 中的非零字段覆盖 defaults
+type ConsumerConfig struct {
+	Defaults redis.SubscribeOptions `mapstructure:"defaults"`
+	Topics   []TopicConsumerConfig  `mapstructure:"topics"`
+}
+
+// TopicConsumerConfig 单个主题的消费者配置
+type TopicConsumerConfig struct {
+	Topic                  string `mapstructure:"topic"`
+	redis.SubscribeOptions `mapstructure:",squash"`
+}
+
+// Options 主题的消费者配置
+func (c ConsumerConfig) Options(topic string) redis.SubscribeOptions {
+	opts := c.Defaults
+	for _, t := range c.Topics {
+		if t.Topic != topic {
+			continue
+		}
+		if t.Consumer != "" {
+			opts.Consumer = t.Consumer
+		}
+		if t.Workers > 0 {
+			opts.Workers = t.Workers
+		}
+		if t.BatchSize > 0 {
+			opts.BatchSize = t.BatchSize
+		}
+		if t.Block > 0 {
+			opts.Block = t.Block
+		}
+		if t.VisibilityTimeout > 0 {
+			opts.VisibilityTimeout = t.VisibilityTimeout
+		}
+		if t.ClaimInterval > 0 {
+			opts.ClaimInterval = t.ClaimInterval
+		}
+		if t.TrimInterval != 0 {
+			opts.TrimInterval = t.TrimInterval
+		}
+		if t.ShutdownTimeout > 0 {
+			opts.ShutdownTimeout = t.ShutdownTimeout
+		}
+	}
+	return opts
+}
+
 // WorkerService 网关工作服务
 type WorkerService struct {
 	*base.BaseService
 	redisManager *redis.Manager
+	config       ConsumerConfig
 	consumers    map[string]context.CancelFunc
+	running      sync.WaitGroup
 	mutex        sync.RWMutex
 }
 
 // NewWorkerService 创建网关工作服务
-func NewWorkerService(redisManager *redis.Manager, log logger.Logger) *WorkerService {
+func NewWorkerService(redisManager *redis.Manager, consumerConfig ConsumerConfig, log logger.Logger) *WorkerService {
 	return &WorkerService{
 		BaseService:  base.NewBaseService(log),
 		redisManager: redisManager,
+		config:       consumerConfig,
 		consumers:    make(map[string]context.CancelFunc),
 	}
 }
 
+// consumer 订阅一个主题并阻塞直到 ctx 取消
+type consumer func(ctx context.Context, queue *redis.RedisQueue, topic string, opts redis.SubscribeOptions) error
+
+// typed 使用类型化处理器的消费者，消息按主题的契约解码和校验，无效消息直接进入死信队列
+func typed[T any](handler redis.TypedHandler[T]) consumer {
+	return func(ctx context.Context, queue *redis.RedisQueue, topic string, opts redis.SubscribeOptions) error {
+		return redis.Subscribe(ctx, queue, topic, handler, opts)
+	}
+}
+
 // StartConsumers 启动所有消息消费者
 func (s *WorkerService) StartConsumers(ctx context.Context) error {
 	queue := s.redisManager.GetQueue()
+	if err := contract.Register(queue.Contracts()); err != nil {
+		return err
+	}
 
-	// 启动延时消息处理器
-	topics := []string{
-		"order.reminder",
-		"user.notification",
-		"system.cleanup",
-		"payment.retry",
-		"inventory.alert",
+	consumers := map[string]consumer{
+		contract.TopicOrderReminder:    typed(s.handleOrderReminder),
+		contract.TopicUserNotification: typed(s.handleUserNotification),
+		contract.TopicSystemCleanup:    typed(s.handleSystemCleanup),
+		contract.TopicPaymentRetry:     typed(s.handlePaymentRetry),
+		contract.TopicInventoryAlert:   typed(s.handleInventoryAlert),
 	}
 
-	for _, topic := range topics {
-		if err := s.startConsumer(ctx, queue, topic); err != nil {
-			s.LogError("failed to start consumer", err, "topic", topic)
-			return err
-		}
+	for topic, consume := range consumers {
+		s.startConsumer(ctx, queue, topic, consume)
 	}
 
-	s.LogInfo("all consumers started", "count", len(topics))
+	s.LogInfo("all consumers started", "count", len(consumers))
 	return nil
 }
 
 // startConsumer 启动单个消费者
-func (s *WorkerService) startConsumer(ctx context.Context, queue *redis.RedisQueue, topic string) error {
+func (s *WorkerService) startConsumer(ctx context.Context, queue *redis.RedisQueue, topic string, consume consumer) {
 	consumerCtx, cancel := context.WithCancel(ctx)
 
 	s.mutex.Lock()
 	s.consumers[topic] = cancel
 	s.mutex.Unlock()
 
+	s.running.Add(1)
 	go func() {
+		defer s.running.Done()
 		defer cancel()
 
 		s.LogInfo("starting consumer", "topic", topic)
 
-		if err := queue.Subscribe(consumerCtx, topic, s.getMessageHandler(topic)); err != nil {
+		err := consume(consumerCtx, queue, topic, s.config.Options(topic))
+		if err != nil && !errors.Is(err, context.Canceled) {
 			s.LogError("consumer failed", err, "topic", topic)
 		}
 
 		s.LogInfo("consumer stopped", "topic", topic)
 	}()
-
-	return nil
-}
-
-// getMessageHandler 获取消息处理器
-func (s *WorkerService) getMessageHandler(topic string) redis.MessageHandler {
-	handlers := map[string]redis.MessageHandler{
-		"order.reminder":    s.handleOrderReminder,
-		"user.notification": s.handleUserNotification,
-		"system.cleanup":    s.handleSystemCleanup,
-		"payment.retry":     s.handlePaymentRetry,
-		"inventory.alert":   s.handleInventoryAlert,
-	}
-
-	handler, exists := handlers[topic]
-	if !exists {
-		return s.handleDefault
-	}
-
-	return handler
 }
 
 // handleOrderReminder 处理订单提醒
-func (s *WorkerService) handleOrderReminder(ctx context.Context, msg *redis.Message) error {
-	orderID := msg.Data["order_id"].(string)
-	userID := msg.Data["user_id"].(string)
-	reminderType := msg.Data["type"].(string)
-
-	s.LogInfo("processing order reminder", "order_id", orderID, "user_id", userID, "type", reminderType)
+func (s *WorkerService) handleOrderReminder(ctx context.Context, msg *redis.Message, p contract.OrderReminder) error {
+	s.LogInfo("processing order reminder", "order_id", p.OrderID, "user_id", p.UserID, "type", p.Type)
 
 	// 发送提醒通知
-	return s.sendOrderReminderNotification(ctx, orderID, userID, reminderType)
+	return s.sendOrderReminderNotification(ctx, p.OrderID, p.UserID, p.Type)
 }
 
 // handleUserNotification 处理用户通知
-func (s *WorkerService) handleUserNotification(ctx context.Context, msg *redis.Message) error {
-	userID := msg.Data["user_id"].(string)
-	notificationType := msg.Data["type"].(string)
-	content := msg.Data["content"].(string)
-
-	s.LogInfo("processing user notification", "user_id", userID, "type", notificationType)
+func (s *WorkerService) handleUserNotification(ctx context.Context, msg *redis.Message, p contract.UserNotification) error {
+	s.LogInfo("processing user notification", "user_id", p.UserID, "type", p.Type)
 
 	// 发送用户通知
-	return s.sendUserNotification(ctx, userID, notificationType, content)
+	return s.sendUserNotification(ctx, p.UserID, p.Type, p.Content)
 }
 
 // handleSystemCleanup 处理系统清理
-func (s *WorkerService) handleSystemCleanup(ctx context.Context, msg *redis.Message) error {
-	cleanupType := msg.Data["type"].(string)
-	params := msg.Data["params"].(map[string]interface{})
-
-	s.LogInfo("processing system cleanup", "type", cleanupType)
+func (s *WorkerService) handleSystemCleanup(ctx context.Context, msg *redis.Message, p contract.SystemCleanup) error {
+	s.LogInfo("processing system cleanup", "type", p.Type)
 
 	// 执行系统清理
-	return s.performSystemCleanup(ctx, cleanupType, params)
+	return s.performSystemCleanup(ctx, p.Type, p.Params)
 }
 
 // handlePaymentRetry 处理支付重试
-func (s *WorkerService) handlePaymentRetry(ctx context.Context, msg *redis.Message) error {
-	paymentID := msg.Data["payment_id"].(string)
-	orderID := msg.Data["order_id"].(string)
-	retryCount := int(msg.Data["retry_count"].(float64))
-
-	s.LogInfo("processing payment retry", "payment_id", paymentID, "order_id", orderID, "retry_count", retryCount)
+func (s *WorkerService) handlePaymentRetry(ctx context.Context, msg *redis.Message, p contract.PaymentRetry) error {
+	s.LogInfo("processing payment retry", "payment_id", p.PaymentID, "order_id", p.OrderID, "retry_count", p.RetryCount)
 
 	// 重试支付
-	return s.retryPayment(ctx, paymentID, orderID, retryCount)
+	return s.retryPayment(ctx, p.PaymentID, p.OrderID, p.RetryCount)
 }
 
 // handleInventoryAlert 处理库存告警
-func (s *WorkerService) handleInventoryAlert(ctx context.Context, msg *redis.Message) error {
-	productID := msg.Data["product_id"].(string)
-	currentStock := int(msg.Data["current_stock"].(float64))
-	threshold := int(msg.Data["threshold"].(float64))
-
-	s.LogInfo("processing inventory alert", "product_id", productID, "current_stock", currentStock, "threshold", threshold)
+func (s *WorkerService) handleInventoryAlert(ctx context.Context, msg *redis.Message, p contract.InventoryAlert) error {
+	s.LogInfo("processing inventory alert", "product_id", p.ProductID, "current_stock", p.CurrentStock, "threshold", p.Threshold)
 
 	// 发送库存告警
-	return s.sendInventoryAlert(ctx, productID, currentStock, threshold)
-}
-
-// handleDefault 默认处理器
-func (s *WorkerService) handleDefault(ctx context.Context, msg *redis.Message) error {
-	s.LogWarn("unhandled message", "topic", msg.Topic, "message_id", msg.ID)
-	return nil
+	return s.sendInventoryAlert(ctx, p.ProductID, p.CurrentStock, p.Threshold)
 }
 
-// StopConsumers 停止所有消费者
+// StopConsumers 停止所有消费者，等待处理中的消息完成（每个主题最多 shutdown_timeout）
 func (s *WorkerService) StopConsumers() {
 	s.mutex.Lock()
-	defer s.mutex.Unlock()
-
 	for topic, cancel := range s.consumers {
 		cancel()
-		s.LogInfo("consumer stopped", "topic", topic)
+		s.LogInfo("stopping consumer", "topic", topic)
 	}
-
 	s.consumers = make(map[string]context.CancelFunc)
+	s.mutex.Unlock()
+
+	s.running.Wait()
 }
 
 // 业务方法实现
@@ -11,6 +11,9 @@ import (
 
 	"goweb/pkg/config"
 	"goweb/pkg/logger"
+	"goweb/pkg/monitor"
+	"goweb/pkg/redis"
+	discovery "goweb/pkg/service"
 	"goweb/services/gateway/internal/router"
 )
 
@@ -25,16 +28,30 @@ func main() {
 		cfg.GetString("log.dir"),
 	)
 
-	// 初始化路由
-	r := router.NewRouter(cfg, log)
+	// 初始化网关（路由表来自 gateway.routes，配置变化时自动热更新）
+	// Redis 用于令牌黑名单检查
+	var redisClient *redis.Client
+	redisConfig := cfg.GetRedisConfig()
+	if redisConfig.Enabled {
+		redisClient = redis.NewClient(&redisConfig, log)
+		log.Info("redis client initialized successfully")
+	}
+
+	metrics := monitor.NewMetrics(serviceName, log)
+	gw, err := router.NewGateway(cfg, log, metrics, redisClient)
+	if err != nil {
+		log.Fatal("invalid gateway route config", "error", err)
+	}
+	gw.WatchConfig()
 
 	// 启动HTTP服务
+	// 网关需要流式转发大文件上传和长连接响应，只限制读取请求头的时间，
+	// 不设置 ReadTimeout/WriteTimeout，上游超时由每条路由单独控制
 	srv := &http.Server{
-		Addr:         fmt.Sprintf(":%d", cfg.GetInt("services.gateway.port")),
-		Handler:      r,
-		ReadTimeout:  cfg.GetDuration("app.read_timeout"),
-		WriteTimeout: cfg.GetDuration("app.write_timeout"),
-		IdleTimeout:  cfg.GetDuration("app.idle_timeout"),
+		Addr:              fmt.Sprintf(":%d", cfg.GetInt("services.gateway.port")),
+		Handler:           gw,
+		ReadHeaderTimeout: cfg.GetDuration("app.read_timeout"),
+		IdleTimeout:       cfg.GetDuration("app.idle_timeout"),
 	}
 
 	go func() {
@@ -44,6 +61,9 @@ func main() {
 		}
 	}()
 
+	// 注册到服务注册中心（service_discovery），退出时先注销
+	registration := discovery.RegisterSelf(cfg, log, serviceName, cfg.GetInt("services.gateway.port"))
+
 	// 优雅退出
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -52,6 +72,7 @@ func main() {
 	log.Info("gateway service shutting down...")
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
+	registration.Stop(ctx)
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Error("gateway service shutdown error", err)
 	}
@@ -0,0 +1,337 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"goweb/pkg/logger"
+	"goweb/pkg/middleware"
+	"goweb/pkg/monitor"
+	"goweb/pkg/redis"
+)
+
+// X-Cache 响应头的取值
+const (
+	Hit    = "HIT"    // 新鲜缓存
+	Stale  = "STALE"  // 过期缓存，已触发后台刷新
+	Miss   = "MISS"   // 未命中，转发到上游
+	Bypass = "BYPASS" // 不走缓存（如携带认证信息的请求访问 public 缓存）
+)
+
+// revalidateTimeout 后台刷新的超时时间，同一缓存键在此期间只刷新一次
+const revalidateTimeout = 30 * time.Second
+
+// 不随缓存保存的响应头，由网关在每次响应时重新生成
+var skipHeaders = map[string]bool{
+	"Connection":        true,
+	"Keep-Alive":        true,
+	"Transfer-Encoding": true,
+	"Date":              true,
+	"Age":               true,
+	"Set-Cookie":        true,
+	"X-Cache":           true,
+	"X-Request-Id":      true,
+}
+
+type revalidateKey struct{}
+
+// entry 缓存的响应
+type entry struct {
+	Status     int         `json:"status"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	StoredAt   time.Time   `json:"stored_at"`
+	FreshUntil time.Time   `json:"fresh_until"`
+	StaleUntil time.Time   `json:"stale_until"`
+}
+
+// Cache 网关响应缓存，缓存内容保存在 Redis 中，多个网关实例共享
+// 键格式：gwcache:<路由名>:<方法>:<路径>?<排序后的查询参数>#<请求头和用户的哈希>
+type Cache struct {
+	client     *redis.Client
+	store      *redis.Cache
+	logger     logger.Logger
+	requests   *prometheus.CounterVec
+	revalidate func(*http.Request)
+}
+
+// New 创建响应缓存，revalidate 用于在后台重新执行请求以刷新过期缓存
+func New(client *redis.Client, log logger.Logger, metrics *monitor.Metrics, revalidate func(*http.Request)) *Cache {
+	return &Cache{
+		client: client,
+		store:  redis.NewCache(client, "gwcache:"),
+		logger: log,
+		requests: metrics.CreateCustomCounter(
+			"gateway_cache_requests_total",
+			"Total number of cacheable gateway requests by route and cache result",
+			[]string{"route", "result"},
+		),
+		revalidate: revalidate,
+	}
+}
+
+// Enabled Redis 可用时才启用缓存
+func (c *Cache) Enabled() bool {
+	return c.client != nil && c.client.IsEnabled()
+}
+
+// Middleware 路由的缓存中间件，只缓存 GET 请求，需放在认证之后、代理之前
+func (c *Cache) Middleware(routeName string, cfg Config) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		req := ctx.Request
+		if req.Method != http.MethodGet {
+			ctx.Next()
+			return
+		}
+		if cfg.Scope == ScopePublic && authenticated(ctx) {
+			c.result(ctx, routeName, Bypass)
+			ctx.Next()
+			return
+		}
+
+		key := cacheKey(routeName, cfg, ctx)
+		revalidating := req.Context().Value(revalidateKey{}) != nil
+		if !revalidating {
+			var e entry
+			err := c.store.Get(req.Context(), key, &e)
+			switch {
+			case err == nil:
+				now := time.Now()
+				if now.Before(e.FreshUntil) {
+					c.serve(ctx, routeName, &e, Hit, now)
+					return
+				}
+				if now.Before(e.StaleUntil) {
+					c.serve(ctx, routeName, &e, Stale, now)
+					c.startRevalidate(key, req)
+					return
+				}
+			case !errors.Is(err, redis.ErrCacheMiss):
+				c.logger.Warn("gateway cache lookup failed", "route", routeName, "error", err)
+			}
+		}
+
+		c.result(ctx, routeName, Miss)
+		w := &captureWriter{ResponseWriter: ctx.Writer, limit: cfg.MaxBodySize}
+		ctx.Writer = w
+		ctx.Next()
+		ctx.Writer = w.ResponseWriter
+
+		if revalidating {
+			defer c.store.Delete(context.Background(), lockKey(key))
+		}
+		e, ttl, ok := buildEntry(cfg, w, time.Now())
+		if !ok {
+			return
+		}
+		if err := c.store.Set(req.Context(), key, e, ttl); err != nil {
+			c.logger.Warn("gateway cache store failed", "route", routeName, "error", err)
+		}
+	}
+}
+
+// serve 返回缓存的响应
+func (c *Cache) serve(ctx *gin.Context, routeName string, e *entry, result string, now time.Time) {
+	h := ctx.Writer.Header()
+	for k, v := range e.Header {
+		h[k] = v
+	}
+	h.Set("Age", strconv.Itoa(int(now.Sub(e.StoredAt).Seconds())))
+	c.result(ctx, routeName, result)
+	ctx.Status(e.Status)
+	ctx.Writer.Write(e.Body)
+	ctx.Abort()
+}
+
+func (c *Cache) result(ctx *gin.Context, routeName, result string) {
+	ctx.Header("X-Cache", result)
+	c.requests.WithLabelValues(routeName, result).Inc()
+}
+
+// startRevalidate 后台重新请求上游刷新缓存，同一缓存键同时只刷新一次
+func (c *Cache) startRevalidate(key string, req *http.Request) {
+	if c.revalidate == nil {
+		return
+	}
+	ok, err := c.store.SetNX(req.Context(), lockKey(key), 1, revalidateTimeout)
+	if err != nil || !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), revalidateKey{}, true), revalidateTimeout)
+	clone := req.Clone(ctx)
+	clone.Body = http.NoBody
+	go func() {
+		defer cancel()
+		c.revalidate(clone)
+	}()
+}
+
+func lockKey(key string) string {
+	return "lock:" + key
+}
+
+// Purge 删除缓存，routeName 为空时删除所有路由下匹配前缀的缓存，prefix 为空时删除整个路由的缓存
+func (c *Cache) Purge(ctx context.Context, routeName, prefix string) (int64, error) {
+	if !c.Enabled() {
+		return 0, nil
+	}
+	route := "*"
+	if routeName != "" {
+		route = redis.EscapePattern(routeName)
+	}
+	return c.store.DeletePattern(ctx, route+":"+http.MethodGet+":"+redis.EscapePattern(prefix)+"*")
+}
+
+// authenticated 请求是否携带认证信息
+func authenticated(ctx *gin.Context) bool {
+	return ctx.GetString("user_id") != "" ||
+		ctx.GetString("api_key_owner_id") != "" ||
+		ctx.GetHeader("Authorization") != "" ||
+		ctx.GetHeader(middleware.APIKeyHeader) != ""
+}
+
+// cacheKey 由方法、路径、排序后的查询参数、配置的请求头和用户构造缓存键
+func cacheKey(routeName string, cfg Config, ctx *gin.Context) string {
+	req := ctx.Request
+	h := sha256.New()
+	for _, name := range cfg.Headers {
+		h.Write([]byte(name + ":" + strings.Join(req.Header.Values(name), ",") + "\n"))
+	}
+	if cfg.Scope == ScopeUser {
+		h.Write([]byte("user:" + ctx.GetString("user_id") + "\napi_key_owner:" + ctx.GetString("api_key_owner_id")))
+	}
+	return routeName + ":" + req.Method + ":" + req.URL.EscapedPath() + "?" + normalizeQuery(req.URL.Query()) +
+		"#" + hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// normalizeQuery 按键和值排序查询参数，参数顺序不同的请求共享缓存
+func normalizeQuery(query url.Values) string {
+	for _, values := range query {
+		sort.Strings(values)
+	}
+	return query.Encode()
+}
+
+// buildEntry 根据上游响应构造缓存条目，返回 Redis 过期时间
+// 遵循上游 Cache-Control（no-store、private、no-cache、max-age、s-maxage、stale-while-revalidate）和 Vary，
+// private 响应只在按用户缓存时保存，Vary 中的请求头必须都已配置在 headers 中
+func buildEntry(cfg Config, w *captureWriter, now time.Time) (*entry, time.Duration, bool) {
+	if w.overflow || !cfg.cacheable(w.Status()) {
+		return nil, 0, false
+	}
+	header := w.Header()
+	if header.Get("Set-Cookie") != "" {
+		return nil, 0, false
+	}
+	for _, v := range header.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			if name == "*" || (name != "" && !cfg.varies(name)) {
+				return nil, 0, false
+			}
+		}
+	}
+
+	ttl, swr := cfg.TTL, cfg.StaleWhileRevalidate
+	directives := parseCacheControl(header.Get("Cache-Control"))
+	if _, ok := directives["no-store"]; ok {
+		return nil, 0, false
+	}
+	if _, ok := directives["private"]; ok && cfg.Scope == ScopePublic {
+		return nil, 0, false
+	}
+	if _, ok := directives["no-cache"]; ok {
+		return nil, 0, false
+	}
+	if d, ok := seconds(directives, "s-maxage"); ok {
+		ttl = d
+	} else if d, ok := seconds(directives, "max-age"); ok {
+		ttl = d
+	}
+	if d, ok := seconds(directives, "stale-while-revalidate"); ok {
+		swr = d
+	}
+	if ttl <= 0 {
+		return nil, 0, false
+	}
+
+	stored := make(http.Header, len(header))
+	for k, v := range header {
+		if !skipHeaders[k] && !strings.HasPrefix(k, "Access-Control-") {
+			stored[k] = v
+		}
+	}
+	return &entry{
+		Status:     w.Status(),
+		Header:     stored,
+		Body:       w.body,
+		StoredAt:   now,
+		FreshUntil: now.Add(ttl),
+		StaleUntil: now.Add(ttl + swr),
+	}, ttl + swr, true
+}
+
+// parseCacheControl 解析 Cache-Control 指令
+func parseCacheControl(value string) map[string]string {
+	directives := make(map[string]string)
+	for _, part := range strings.Split(value, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name != "" {
+			directives[strings.ToLower(name)] = strings.Trim(arg, `"`)
+		}
+	}
+	return directives
+}
+
+func seconds(directives map[string]string, name string) (time.Duration, bool) {
+	v, ok := directives[name]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// captureWriter 转发响应的同时保存响应体，超过上限后停止保存
+type captureWriter struct {
+	gin.ResponseWriter
+	body     []byte
+	limit    int64
+	overflow bool
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	w.capture(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *captureWriter) capture(b []byte) {
+	if w.overflow {
+		return
+	}
+	if int64(len(w.body)+len(b)) > w.limit {
+		w.overflow, w.body = true, nil
+		return
+	}
+	w.body = append(w.body, b...)
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func upstreamResponse(t *testing.T, status int, header http.Header, body string) *captureWriter {
+	t.Helper()
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	w := &captureWriter{ResponseWriter: c.Writer, limit: 1 << 20}
+	for k, v := range header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(status)
+	_, err := w.Write([]byte(body))
+	require.NoError(t, err)
+	return w
+}
+
+func TestBuildEntry_CacheControlAndVary(t *testing.T) {
+	cfg := Config{Enabled: true, TTL: time.Minute, StaleWhileRevalidate: 10 * time.Second, Headers: []string{"accept-language"}}
+	require.NoError(t, cfg.Normalize())
+	now := time.Now()
+
+	e, ttl, ok := buildEntry(cfg, upstreamResponse(t, 200, http.Header{"Content-Type": {"application/json"}}, `{"a":1}`), now)
+	require.True(t, ok)
+	require.Equal(t, 70*time.Second, ttl)
+	require.Equal(t, `{"a":1}`, string(e.Body))
+	require.Equal(t, now.Add(time.Minute), e.FreshUntil)
+
+	// 上游 max-age 和 stale-while-revalidate 优先
+	_, ttl, ok = buildEntry(cfg, upstreamResponse(t, 200, http.Header{"Cache-Control": {"public, max-age=5, stale-while-revalidate=1"}}, ""), now)
+	require.True(t, ok)
+	require.Equal(t, 6*time.Second, ttl)
+
+	for _, header := range []http.Header{
+		{"Cache-Control": {"no-store"}},
+		{"Cache-Control": {"private"}},
+		{"Cache-Control": {"max-age=0"}},
+		{"Set-Cookie": {"a=b"}},
+		{"Vary": {"Authorization"}},
+		{"Vary": {"*"}},
+	} {
+		_, _, ok = buildEntry(cfg, upstreamResponse(t, 200, header, ""), now)
+		require.False(t, ok, header)
+	}
+
+	// 已配置的请求头可以出现在 Vary 中
+	_, _, ok = buildEntry(cfg, upstreamResponse(t, 200, http.Header{"Vary": {"Accept-Language"}}, ""), now)
+	require.True(t, ok)
+
+	_, _, ok = buildEntry(cfg, upstreamResponse(t, 500, nil, ""), now)
+	require.False(t, ok)
+
+	// 按用户缓存时允许 private
+	cfg.Scope = ScopeUser
+	_, _, ok = buildEntry(cfg, upstreamResponse(t, 200, http.Header{"Cache-Control": {"private"}}, ""), now)
+	require.True(t, ok)
+}
+
+func TestCacheKey_NormalizesQueryAndHeaders(t *testing.T) {
+	cfg := Config{Enabled: true, Headers: []string{"Accept-Language"}}
+	require.NoError(t, cfg.Normalize())
+
+	key := func(target, lang string) string {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, target, nil)
+		c.Request.Header.Set("Accept-Language", lang)
+		return cacheKey("products", cfg, c)
+	}
+
+	require.Equal(t, key("/api/v1/products?b=2&a=1&a=0", "zh"), key("/api/v1/products?a=0&b=2&a=1", "zh"))
+	require.NotEqual(t, key("/api/v1/products?a=1", "zh"), key("/api/v1/products?a=1", "en"))
+	require.Contains(t, key("/api/v1/products?b=2&a=1", "zh"), "products:GET:/api/v1/products?a=1&b=2#")
+}
@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// 缓存范围
+const (
+	ScopePublic = "public" // 所有匿名请求共享，携带认证信息的请求不走缓存
+	ScopeUser   = "user"   // 按 user_id（API Key 调用按所属商户）区分，适合菜单树等与用户权限相关的接口
+)
+
+// Config 路由级响应缓存配置（gateway.routes[].cache）
+type Config struct {
+	Enabled              bool          `mapstructure:"enabled" json:"enabled"`
+	TTL                  time.Duration `mapstructure:"ttl" json:"ttl"`                                       // 新鲜期，上游 max-age/s-maxage 优先
+	StaleWhileRevalidate time.Duration `mapstructure:"stale_while_revalidate" json:"stale_while_revalidate"` // 过期后继续返回旧值并后台刷新的时长
+	Headers              []string      `mapstructure:"headers" json:"headers"`                               // 参与缓存键的请求头
+	Scope                string        `mapstructure:"scope" json:"scope"`                                   // public / user
+	MaxBodySize          int64         `mapstructure:"max_body_size" json:"max_body_size"`                   // 超过该大小的响应不缓存
+	Statuses             []int         `mapstructure:"statuses" json:"statuses"`                             // 可缓存的状态码
+}
+
+// Normalize 填充默认值并校验
+func (c *Config) Normalize() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.TTL == 0 {
+		c.TTL = time.Minute
+	}
+	if c.TTL < 0 || c.StaleWhileRevalidate < 0 {
+		return fmt.Errorf("cache: ttl and stale_while_revalidate must not be negative")
+	}
+	if c.MaxBodySize <= 0 {
+		c.MaxBodySize = 1 << 20
+	}
+	if len(c.Statuses) == 0 {
+		c.Statuses = []int{http.StatusOK}
+	}
+	for i, h := range c.Headers {
+		c.Headers[i] = http.CanonicalHeaderKey(h)
+	}
+
+	switch c.Scope {
+	case "":
+		c.Scope = ScopePublic
+	case ScopePublic, ScopeUser:
+	default:
+		return fmt.Errorf("cache: unknown scope %q (use public or user)", c.Scope)
+	}
+	return nil
+}
+
+// cacheable 状态码是否可缓存
+func (c *Config) cacheable(status int) bool {
+	for _, s := range c.Statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// varies 请求头是否参与缓存键
+func (c *Config) varies(header string) bool {
+	header = http.CanonicalHeaderKey(header)
+	for _, h := range c.Headers {
+		if h == header {
+			return true
+		}
+	}
+	return false
+}
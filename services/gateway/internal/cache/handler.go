@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"goweb/pkg/response"
+)
+
+// Handler 缓存管理接口
+type Handler struct {
+	cache *Cache
+}
+
+// NewHandler 创建缓存管理接口
+func NewHandler(cache *Cache) *Handler {
+	return &Handler{cache: cache}
+}
+
+// RegisterRoutes 注册管理接口
+//
+//	DELETE /cache?route=<路由名>&prefix=<路径前缀>  按路由和/或路径前缀清除缓存
+func (h *Handler) RegisterRoutes(r gin.IRouter) {
+	r.DELETE("/cache", h.Purge)
+}
+
+// Purge 清除缓存，route 和 prefix 至少指定一个
+func (h *Handler) Purge(c *gin.Context) {
+	routeName, prefix := c.Query("route"), c.Query("prefix")
+	if routeName == "" && prefix == "" {
+		response.BadRequest(c, "route or prefix is required")
+		return
+	}
+
+	deleted, err := h.cache.Purge(c.Request.Context(), routeName, prefix)
+	if err != nil {
+		response.InternalError(c, "purge cache failed: "+err.Error())
+		return
+	}
+	response.Success(c, gin.H{"route": routeName, "prefix": prefix, "deleted": deleted})
+}
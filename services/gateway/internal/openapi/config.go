@@ -0,0 +1,52 @@
+package openapi
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// DefaultSpec swag 生成的文档地址（各服务的 /swagger/*any）
+const DefaultSpec = "/swagger/doc.json"
+
+// Config 路由的接口文档配置（gateway.routes[].openapi）
+type Config struct {
+	Enabled bool   `mapstructure:"enabled" json:"enabled"`
+	Spec    string `mapstructure:"spec" json:"spec"` // 以 / 开头时相对于路由的上游实例，也可以是完整 URL 或 file://<本地路径>
+	Tag     string `mapstructure:"tag" json:"tag"`   // 服务标签，默认为路由名称
+}
+
+// Normalize 校验文档配置并填充默认值
+func (c *Config) Normalize(routeName string) error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Spec == "" {
+		c.Spec = DefaultSpec
+	}
+	if c.Tag == "" {
+		c.Tag = routeName
+	}
+	if strings.HasPrefix(c.Spec, "/") || strings.HasPrefix(c.Spec, "file://") {
+		return nil
+	}
+	u, err := url.Parse(c.Spec)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("openapi.spec %q must be a path, an http(s) URL or file://<path>", c.Spec)
+	}
+	return nil
+}
+
+// SpecURL 文档地址，相对路径拼接在上游实例地址后
+func (c *Config) SpecURL(upstream string) string {
+	if !strings.HasPrefix(c.Spec, "/") {
+		return c.Spec
+	}
+	u, err := url.Parse(upstream)
+	if err != nil {
+		return c.Spec
+	}
+	u.Path = path.Join("/", u.Path, c.Spec)
+	return u.String()
+}
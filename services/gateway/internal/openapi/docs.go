@@ -0,0 +1,234 @@
+package openapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+
+	"goweb/pkg/config"
+	"goweb/pkg/logger"
+)
+
+const (
+	fetchTimeout  = 10 * time.Second
+	retryInterval = 15 * time.Second // 有服务文档获取失败时的重试间隔
+	maxSpecSize   = 16 << 20
+)
+
+// Target 需要聚合文档的路由
+type Target struct {
+	Name       string
+	Tag        string
+	SpecURL    string
+	PublicPath func(string) (string, bool)
+}
+
+// Docs 网关聚合接口文档
+// 从各服务获取 swag 生成的 Swagger 2.0 文档，把路径改写为网关对外路径后合并，在 /swagger/ 提供统一的 Swagger UI。
+// 文档在路由表变化时和每隔 refresh_interval 重新获取，获取失败的服务继续使用上一次成功获取的文档
+type Docs struct {
+	logger   logger.Logger
+	client   *http.Client
+	info     Info
+	interval time.Duration
+
+	mu        sync.Mutex
+	targets   []Target
+	specs     map[string]map[string]interface{} // 路由名 -> 最近一次成功获取的文档
+	conflicts string                            // 上一次合并的冲突，变化时才重新输出日志
+
+	doc     atomic.Pointer[[]byte]
+	refresh chan struct{}
+}
+
+// New 创建聚合文档（gateway.openapi）
+func New(cfg *config.Config, log logger.Logger, transport http.RoundTripper) *Docs {
+	info := Info{
+		Title:       cfg.GetString("gateway.openapi.title"),
+		Description: cfg.GetString("gateway.openapi.description"),
+		Version:     cfg.GetString("gateway.openapi.version"),
+	}
+	if info.Title == "" {
+		info.Title = "GinForge API"
+	}
+	if info.Version == "" {
+		info.Version = "1.0"
+	}
+	interval := cfg.GetDuration("gateway.openapi.refresh_interval")
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+
+	d := &Docs{
+		logger:   log,
+		client:   &http.Client{Transport: transport, Timeout: fetchTimeout},
+		info:     info,
+		interval: interval,
+		specs:    make(map[string]map[string]interface{}),
+		refresh:  make(chan struct{}, 1),
+	}
+	empty, _ := Merge(info, nil)
+	data, _ := json.Marshal(empty)
+	d.doc.Store(&data)
+	return d
+}
+
+// SetTargets 更新需要聚合文档的路由（路由表重载时调用），在后台重新获取文档
+func (d *Docs) SetTargets(targets []Target) {
+	d.mu.Lock()
+	d.targets = targets
+	d.mu.Unlock()
+
+	select {
+	case d.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// Start 在后台定期获取并合并文档
+func (d *Docs) Start() {
+	go func() {
+		timer := time.NewTimer(d.interval)
+		defer timer.Stop()
+		for {
+			select {
+			case <-d.refresh:
+			case <-timer.C:
+			}
+			next := d.interval
+			if !d.Refresh(context.Background()) {
+				next = retryInterval
+			}
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(next)
+		}
+	}()
+}
+
+// Refresh 获取所有服务的文档并重新合并，返回是否全部获取成功
+func (d *Docs) Refresh(ctx context.Context) bool {
+	d.mu.Lock()
+	targets := d.targets
+	d.mu.Unlock()
+
+	type fetched struct {
+		doc map[string]interface{}
+		err error
+	}
+	results := make([]fetched, len(targets))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func(i int, t Target) {
+			defer wg.Done()
+			results[i].doc, results[i].err = d.fetch(ctx, t.SpecURL)
+		}(i, t)
+	}
+	wg.Wait()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ok := true
+	specs := make(map[string]map[string]interface{}, len(targets))
+	for i, t := range targets {
+		if results[i].err != nil {
+			ok = false
+			d.logger.Warn("fetch service api docs failed", "route", t.Name, "url", t.SpecURL, "error", results[i].err)
+			if prev, found := d.specs[t.Name]; found {
+				specs[t.Name] = prev
+			}
+			continue
+		}
+		specs[t.Name] = results[i].doc
+	}
+	d.specs = specs
+
+	var sources []Source
+	for _, t := range targets {
+		if doc, found := specs[t.Name]; found {
+			sources = append(sources, Source{Name: t.Name, Tag: t.Tag, PublicPath: t.PublicPath, Doc: doc})
+		}
+	}
+	doc, conflicts := Merge(d.info, sources)
+	if joined := strings.Join(conflicts, "\n"); joined != d.conflicts {
+		d.conflicts = joined
+		for _, c := range conflicts {
+			d.logger.Warn("api docs merge conflict", "detail", c)
+		}
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		d.logger.Error("marshal merged api docs failed", "error", err)
+		return false
+	}
+	d.doc.Store(&data)
+	d.logger.Debug("api docs merged", "services", len(sources), "conflicts", len(conflicts))
+	return ok
+}
+
+// fetch 获取一个服务的 Swagger 2.0 文档
+func (d *Docs) fetch(ctx context.Context, specURL string) (map[string]interface{}, error) {
+	var body []byte
+	if file, ok := strings.CutPrefix(specURL, "file://"); ok {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		body = data
+	} else {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, specURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := d.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+		}
+		if body, err = io.ReadAll(io.LimitReader(resp.Body, maxSpecSize)); err != nil {
+			return nil, err
+		}
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("invalid api docs: %w", err)
+	}
+	if v, _ := doc["swagger"].(string); v != "2.0" {
+		return nil, fmt.Errorf("unsupported api docs version %q, only swagger 2.0 is supported", v)
+	}
+	return doc, nil
+}
+
+// RegisterRoutes 注册 GET /swagger/*any：doc.json 返回合并后的文档，其他路径为 Swagger UI
+func (d *Docs) RegisterRoutes(r gin.IRoutes) {
+	ui := ginSwagger.WrapHandler(swaggerFiles.Handler, ginSwagger.URL("doc.json"))
+	r.GET("/swagger/*any", func(c *gin.Context) {
+		if c.Param("any") == "/doc.json" {
+			c.Data(http.StatusOK, "application/json; charset=utf-8", *d.doc.Load())
+			return
+		}
+		ui(c)
+	})
+}
@@ -0,0 +1,258 @@
+package openapi
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const definitionsRef = "#/definitions/"
+
+// 路径下的 HTTP 方法（Swagger 2.0 path item 中的其他字段如 parameters 不是操作）
+var operationMethods = map[string]bool{
+	"get": true, "put": true, "post": true, "delete": true,
+	"options": true, "head": true, "patch": true,
+}
+
+// Source 参与合并的服务文档
+type Source struct {
+	Name string // 路由名称
+	Tag  string // 服务标签，合并后加在每个操作原有标签前
+
+	// PublicPath 把上游路径转换为网关对外路径，不经过该路由的路径返回 false
+	PublicPath func(string) (string, bool)
+
+	Doc map[string]interface{} // Swagger 2.0 文档
+}
+
+// Info 合并后文档的基本信息
+type Info struct {
+	Title       string
+	Description string
+	Version     string
+}
+
+// Merge 合并多个服务的 Swagger 2.0 文档
+// 路径改写为网关对外路径，操作标签加上服务前缀；只保留被操作引用到的 definitions。
+// 冲突按以下方式处理并返回说明：重复的路径和方法保留先出现的，重复的 operationId 加服务前缀，
+// 同名但内容不同的 definition 重命名为 <服务>.<名称>
+func Merge(info Info, sources []Source) (map[string]interface{}, []string) {
+	var conflicts []string
+	paths := make(map[string]interface{})
+	definitions := make(map[string]interface{})
+	securityDefinitions := make(map[string]interface{})
+	operationIDs := make(map[string]string) // operationId -> 服务
+	owners := make(map[string]string)       // 路径 + 方法 -> 服务
+	var tags []interface{}
+
+	for _, src := range sources {
+		basePath := strings.TrimSuffix(stringValue(src.Doc["basePath"]), "/")
+		srcDefinitions := mapValue(src.Doc["definitions"])
+		srcInfo := mapValue(src.Doc["info"])
+		tags = append(tags, map[string]interface{}{
+			"name":        src.Tag,
+			"description": stringValue(srcInfo["title"]),
+		})
+
+		// 只保留经过该路由的路径
+		kept := make(map[string]interface{})
+		public := make(map[string]string)
+		var skipped int
+		for p, item := range mapValue(src.Doc["paths"]) {
+			pp, ok := src.PublicPath(basePath + p)
+			if !ok {
+				for method := range mapValue(item) {
+					if operationMethods[method] {
+						skipped++
+					}
+				}
+				continue
+			}
+			kept[p], public[p] = item, pp
+		}
+		if skipped > 0 {
+			conflicts = append(conflicts, fmt.Sprintf("%d operations of %s are not under the route prefix and were dropped", skipped, src.Name))
+		}
+
+		// 同名但内容不同的 definition 重命名，本服务内的引用随之改写
+		renames := make(map[string]string)
+		used := referencedDefinitions(kept, srcDefinitions)
+		for _, name := range used {
+			def := srcDefinitions[name]
+			existing, ok := definitions[name]
+			if !ok || reflect.DeepEqual(existing, def) {
+				continue
+			}
+			renamed := src.Name + "." + name
+			renames[name] = renamed
+			conflicts = append(conflicts, fmt.Sprintf("definition %q of %s differs from an existing one, renamed to %q", name, src.Name, renamed))
+		}
+		for _, name := range used {
+			target := name
+			if renamed, ok := renames[name]; ok {
+				target = renamed
+			}
+			if _, ok := definitions[target]; !ok {
+				definitions[target] = rewriteRefs(srcDefinitions[name], renames)
+			}
+		}
+
+		for _, p := range sortedKeys(kept) {
+			item := mapValue(kept[p])
+			publicItem := mapValue(paths[public[p]])
+			if publicItem == nil {
+				publicItem = make(map[string]interface{})
+				paths[public[p]] = publicItem
+			}
+			for _, method := range sortedKeys(item) {
+				if !operationMethods[method] {
+					if _, ok := publicItem[method]; !ok {
+						publicItem[method] = rewriteRefs(item[method], renames)
+					}
+					continue
+				}
+				key := strings.ToUpper(method) + " " + public[p]
+				if owner, ok := owners[key]; ok {
+					conflicts = append(conflicts, fmt.Sprintf("%s is defined by both %s and %s, keeping %s", key, owner, src.Name, owner))
+					continue
+				}
+				owners[key] = src.Name
+
+				op, _ := rewriteRefs(item[method], renames).(map[string]interface{})
+				if op == nil {
+					continue
+				}
+				op["tags"] = serviceTags(src.Tag, op["tags"])
+				if id := stringValue(op["operationId"]); id != "" {
+					if owner, ok := operationIDs[id]; ok {
+						renamed := src.Name + "_" + id
+						conflicts = append(conflicts, fmt.Sprintf("operationId %q of %s is already used by %s, renamed to %q", id, src.Name, owner, renamed))
+						op["operationId"] = renamed
+						id = renamed
+					}
+					operationIDs[id] = src.Name
+				}
+				publicItem[method] = op
+			}
+			if len(publicItem) == 0 {
+				delete(paths, public[p])
+			}
+		}
+
+		for name, def := range mapValue(src.Doc["securityDefinitions"]) {
+			if existing, ok := securityDefinitions[name]; ok && !reflect.DeepEqual(existing, def) {
+				conflicts = append(conflicts, fmt.Sprintf("securityDefinition %q of %s differs from an existing one, keeping the first", name, src.Name))
+				continue
+			}
+			securityDefinitions[name] = def
+		}
+	}
+
+	doc := map[string]interface{}{
+		"swagger": "2.0",
+		"info": map[string]interface{}{
+			"title":       info.Title,
+			"description": info.Description,
+			"version":     info.Version,
+		},
+		"basePath":    "/",
+		"tags":        tags,
+		"paths":       paths,
+		"definitions": definitions,
+	}
+	if len(securityDefinitions) > 0 {
+		doc["securityDefinitions"] = securityDefinitions
+	}
+	return doc, conflicts
+}
+
+// serviceTags 操作标签加上服务前缀，没有标签时使用服务标签
+func serviceTags(service string, tags interface{}) []interface{} {
+	list, _ := tags.([]interface{})
+	if len(list) == 0 {
+		return []interface{}{service}
+	}
+	out := make([]interface{}, 0, len(list))
+	for _, t := range list {
+		out = append(out, service+" / "+stringValue(t))
+	}
+	return out
+}
+
+// referencedDefinitions 按名称排序返回 paths 直接或间接引用到的 definitions
+func referencedDefinitions(paths, definitions map[string]interface{}) []string {
+	seen := make(map[string]bool)
+	var visit func(v interface{})
+	visit = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if ref, ok := v["$ref"].(string); ok && strings.HasPrefix(ref, definitionsRef) {
+				name := strings.TrimPrefix(ref, definitionsRef)
+				if def, ok := definitions[name]; ok && !seen[name] {
+					seen[name] = true
+					visit(def)
+				}
+			}
+			for _, child := range v {
+				visit(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				visit(child)
+			}
+		}
+	}
+	visit(paths)
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// rewriteRefs 复制 v 并按 renames 改写其中的 definitions 引用
+func rewriteRefs(v interface{}, renames map[string]string) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, child := range v {
+			if ref, ok := child.(string); ok && k == "$ref" && strings.HasPrefix(ref, definitionsRef) {
+				if renamed, ok := renames[strings.TrimPrefix(ref, definitionsRef)]; ok {
+					child = definitionsRef + renamed
+				}
+			}
+			out[k] = rewriteRefs(child, renames)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, child := range v {
+			out[i] = rewriteRefs(child, renames)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func mapValue(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+func stringValue(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,80 @@
+package openapi
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func parseDoc(t *testing.T, s string) map[string]interface{} {
+	t.Helper()
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(s), &doc))
+	return doc
+}
+
+// prefixed 只接受 prefix 下的路径，路径不改写
+func prefixed(prefix string) func(string) (string, bool) {
+	return func(p string) (string, bool) {
+		return p, p == prefix || strings.HasPrefix(p, prefix+"/")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	admin := parseDoc(t, `{
+		"swagger": "2.0", "basePath": "/api/v1", "info": {"title": "Admin API"},
+		"paths": {
+			"/admin/users": {"get": {"operationId": "listUsers", "tags": ["用户管理"],
+				"responses": {"200": {"schema": {"$ref": "#/definitions/model.User"}}}}}
+		},
+		"definitions": {
+			"model.User": {"type": "object", "properties": {"name": {"type": "string"}}},
+			"model.Unused": {"type": "object"}
+		},
+		"securityDefinitions": {"BearerAuth": {"type": "apiKey", "in": "header", "name": "Authorization"}}
+	}`)
+	// 商户服务：路径去掉 /api/v1，网关前缀为 /api/v1/merchant；包含一个不经过网关路由的路径
+	merchant := parseDoc(t, `{
+		"swagger": "2.0", "basePath": "/", "info": {"title": "Merchant API"},
+		"paths": {
+			"/orders/{id}": {"get": {"operationId": "listUsers",
+				"responses": {"200": {"schema": {"$ref": "#/definitions/model.User"}}}}},
+			"/admin/users": {"get": {"operationId": "copied"}}
+		},
+		"definitions": {"model.User": {"type": "object", "properties": {"merchant_id": {"type": "integer"}}}}
+	}`)
+
+	doc, conflicts := Merge(Info{Title: "GinForge API"}, []Source{
+		{Name: "admin", Tag: "admin", PublicPath: prefixed("/api/v1/admin"), Doc: admin},
+		{Name: "merchant", Tag: "merchant", Doc: merchant, PublicPath: func(p string) (string, bool) {
+			if strings.HasPrefix(p, "/orders") {
+				return "/api/v1/merchant" + p, true
+			}
+			return "", false
+		}},
+	})
+
+	paths := doc["paths"].(map[string]interface{})
+	require.Len(t, paths, 2)
+	adminOp := paths["/api/v1/admin/users"].(map[string]interface{})["get"].(map[string]interface{})
+	require.Equal(t, []interface{}{"admin / 用户管理"}, adminOp["tags"])
+
+	merchantOp := paths["/api/v1/merchant/orders/{id}"].(map[string]interface{})["get"].(map[string]interface{})
+	require.Equal(t, []interface{}{"merchant"}, merchantOp["tags"])
+	require.Equal(t, "merchant_listUsers", merchantOp["operationId"])
+	schema := merchantOp["responses"].(map[string]interface{})["200"].(map[string]interface{})["schema"].(map[string]interface{})
+	require.Equal(t, "#/definitions/merchant.model.User", schema["$ref"])
+
+	definitions := doc["definitions"].(map[string]interface{})
+	require.Contains(t, definitions, "model.User")
+	require.Contains(t, definitions, "merchant.model.User")
+	require.NotContains(t, definitions, "model.Unused")
+	require.Contains(t, doc["securityDefinitions"], "BearerAuth")
+
+	require.Len(t, conflicts, 3)
+	require.Contains(t, conflicts[0], "1 operations of merchant are not under the route prefix")
+	require.Contains(t, strings.Join(conflicts, "\n"), `definition "model.User" of merchant differs`)
+	require.Contains(t, strings.Join(conflicts, "\n"), `operationId "listUsers" of merchant is already used by admin`)
+}
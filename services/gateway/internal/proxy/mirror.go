@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HeaderMirror 镜像请求标记，镜像上游可据此跳过发送短信、扣款等外部副作用
+const HeaderMirror = "X-Gateway-Mirror"
+
+// MirrorResult 镜像请求的结果
+type MirrorResult struct {
+	Status  int // 镜像上游的响应状态码，请求失败时为 0
+	Latency time.Duration
+	Err     error
+}
+
+// Mirror 把当前请求的副本发送到镜像上游，响应体被读取后丢弃
+// 请求在调用时复制（gin.Context 会被复用），发送在后台进行，不受客户端断开影响，结果通过返回的 channel 传回；
+// body 为已读取的请求体
+func (p *Proxy) Mirror(c *gin.Context, target *Target, upstream *url.URL, body []byte, timeout time.Duration) <-chan MirrorResult {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	req := c.Request.Clone(ctx)
+	req.Body, req.GetBody, req.ContentLength, req.TransferEncoding = http.NoBody, nil, 0, nil
+	if len(body) > 0 {
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	req.Header.Set(HeaderMirror, "1")
+
+	result := make(chan MirrorResult, 1)
+	rewrite := p.rewrite(target, upstream, identityOf(c))
+	go func() {
+		defer cancel()
+
+		var mirrorErr error
+		w := &discardWriter{header: make(http.Header)}
+		rp := &httputil.ReverseProxy{
+			Rewrite:   rewrite,
+			Transport: p.transport,
+			ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+				mirrorErr = err
+			},
+		}
+		start := time.Now()
+		rp.ServeHTTP(w, req)
+		if mirrorErr != nil {
+			result <- MirrorResult{Latency: time.Since(start), Err: mirrorErr}
+			return
+		}
+		result <- MirrorResult{Status: w.status, Latency: time.Since(start)}
+	}()
+	return result
+}
+
+// discardWriter 记录状态码并丢弃响应体
+type discardWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *discardWriter) Header() http.Header { return w.header }
+
+func (w *discardWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return len(b), nil
+}
+
+func (w *discardWriter) WriteHeader(status int) {
+	if w.status == 0 && status >= http.StatusOK {
+		w.status = status
+	}
+}
@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxy_MirrorSendsCopyAndDiscardsResponse(t *testing.T) {
+	received := make(chan string, 1)
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, "1", r.Header.Get(HeaderMirror))
+		assert.Equal(t, "req-1", r.Header.Get("X-Request-Id"))
+		received <- r.Method + " " + r.URL.RequestURI() + " " + string(body)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("mirror response"))
+	}))
+	defer mirror.Close()
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	}))
+	defer primary.Close()
+
+	mirrorURL, _ := url.Parse(mirror.URL)
+	primaryURL, _ := url.Parse(primary.URL)
+	p := newTestProxy()
+	target := &Target{Name: "merchant"}
+
+	var result <-chan MirrorResult
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/*path", func(c *gin.Context) {
+		c.Set("request_id", "req-1")
+		result = p.Mirror(c, target, mirrorURL, []byte("hello"), time.Second)
+		p.Serve(c, target, primaryURL)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/merchant/orders?a=1", strings.NewReader("hello"))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "hello", w.Body.String())
+
+	m := <-result
+	require.NoError(t, m.Err)
+	assert.Equal(t, http.StatusInternalServerError, m.Status)
+	assert.Equal(t, "POST /api/v1/merchant/orders?a=1 hello", <-received)
+
+	// 镜像上游不可用时返回错误
+	mirror.Close()
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/merchant/orders", nil)
+	result = p.Mirror(c, target, mirrorURL, nil, time.Second)
+	require.Error(t, (<-result).Err)
+}
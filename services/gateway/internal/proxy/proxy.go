@@ -0,0 +1,213 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"goweb/pkg/config"
+	"goweb/pkg/logger"
+	"goweb/pkg/middleware"
+)
+
+// ErrUpstreamTimeout 上游在路由超时时间内没有返回响应头
+var ErrUpstreamTimeout = errors.New("upstream response timeout")
+
+// Target 代理目标（一条路由的转发参数）
+type Target struct {
+	Name    string        // 路由名称，用于日志
+	Timeout time.Duration // 等待上游响应头的超时时间，0 表示使用默认值
+
+	// PathRewrite 转发前改写请求路径，为空时保持原路径
+	PathRewrite func(path string) string
+}
+
+// Proxy 流式反向代理
+// 请求体和响应体均不在内存中缓冲，所有路由共享同一个 Transport 连接池
+type Proxy struct {
+	transport      http.RoundTripper
+	logger         logger.Logger
+	defaultTimeout time.Duration
+	identitySecret string // 身份请求头签名密钥
+}
+
+// New 创建反向代理
+func New(cfg *config.Config, log logger.Logger) *Proxy {
+	timeout := cfg.GetDuration("gateway.timeout")
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+
+	identitySecret := cfg.GetString("identity.secret")
+	if identitySecret == "" {
+		log.Warn("identity.secret is not set, user identity headers will not be forwarded to upstreams")
+	}
+
+	return &Proxy{
+		transport:      NewTransport(TransportConfigFromConfig(cfg)),
+		logger:         log,
+		defaultTimeout: timeout,
+		identitySecret: identitySecret,
+	}
+}
+
+// Transport 获取共享的上游 Transport
+func (p *Proxy) Transport() http.RoundTripper {
+	return p.transport
+}
+
+// Serve 将当前请求转发到上游实例
+// 上游路径 = 实例基础路径 + 改写后的请求路径
+// 返回收到上游响应头的耗时（不含响应体传输）和转发失败的原因（连接失败、超时等），
+// 上游正常返回响应时错误为 nil；失败时错误响应已经写入，耗时为到失败为止
+func (p *Proxy) Serve(c *gin.Context, target *Target, upstream *url.URL) (time.Duration, error) {
+	timeout := target.Timeout
+	if timeout <= 0 {
+		timeout = p.defaultTimeout
+	}
+
+	// 超时只覆盖"发出请求到收到响应头"这一段，
+	// 收到响应头后停止计时，长连接响应可以一直流式返回
+	ctx, cancel := context.WithCancelCause(c.Request.Context())
+	defer cancel(nil)
+	timer := time.AfterFunc(timeout, func() { cancel(ErrUpstreamTimeout) })
+	defer timer.Stop()
+
+	var proxyErr error
+	var headerLatency time.Duration
+	start := time.Now()
+	rp := &httputil.ReverseProxy{
+		Rewrite:       p.rewrite(target, upstream, identityOf(c)),
+		Transport:     p.transport,
+		FlushInterval: -1, // 每次写入后立即刷新，支持 SSE/长轮询等流式响应
+		ModifyResponse: func(resp *http.Response) error {
+			timer.Stop()
+			headerLatency = time.Since(start)
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			proxyErr = err
+			if errors.Is(context.Cause(ctx), ErrUpstreamTimeout) {
+				proxyErr = ErrUpstreamTimeout
+			}
+			p.handleError(c, target, upstream, ctx, err)
+		},
+	}
+
+	rp.ServeHTTP(c.Writer, c.Request.WithContext(ctx))
+	if headerLatency == 0 {
+		headerLatency = time.Since(start)
+	}
+	return headerLatency, proxyErr
+}
+
+// identityOf 当前请求的调用方身份（登录用户或 API Key）
+func identityOf(c *gin.Context) middleware.Identity {
+	id := middleware.Identity{
+		UserID:      c.GetString("user_id"),
+		Username:    c.GetString("username"),
+		APIKeyOwner: c.GetString("api_key_owner_id"),
+		RequestID:   c.GetString("request_id"),
+	}
+	if keyID, ok := c.Get("api_key_id"); ok {
+		id.APIKeyID = fmt.Sprint(keyID)
+	}
+	return id
+}
+
+// rewrite 改写转发到上游的请求：路径改写、X-Forwarded-*、请求 ID 和签名的身份请求头
+func (p *Proxy) rewrite(target *Target, upstream *url.URL, identity middleware.Identity) func(*httputil.ProxyRequest) {
+	return func(pr *httputil.ProxyRequest) {
+		if target.PathRewrite != nil {
+			pr.Out.URL.Path = target.PathRewrite(pr.Out.URL.Path)
+			if pr.Out.URL.RawPath != "" {
+				pr.Out.URL.RawPath = target.PathRewrite(pr.Out.URL.RawPath)
+			}
+		}
+		pr.SetURL(upstream)
+
+		// 保留上一跳（如 nginx）追加的 X-Forwarded-For 链路
+		if xff := pr.In.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+			pr.Out.Header["X-Forwarded-For"] = xff
+		}
+		pr.SetXForwarded()
+		if proto := pr.In.Header.Get("X-Forwarded-Proto"); proto != "" {
+			pr.Out.Header.Set("X-Forwarded-Proto", proto)
+		}
+
+		if identity.RequestID != "" {
+			pr.Out.Header.Set(middleware.HeaderRequestID, identity.RequestID)
+		}
+		// 身份请求头只能由网关写入，签名后后端服务可以直接信任；签名绑定改写后的方法和路径
+		if p.identitySecret != "" {
+			middleware.SignIdentity(pr.Out.Header, p.identitySecret, identity, pr.Out.Method, pr.Out.URL.EscapedPath(), time.Now())
+		} else {
+			middleware.StripIdentity(pr.Out.Header)
+		}
+	}
+}
+
+// handleError 区分客户端断开、连接失败和上游超时
+func (p *Proxy) handleError(c *gin.Context, target *Target, upstream *url.URL, ctx context.Context, err error) {
+	fields := []interface{}{
+		"route", target.Name,
+		"upstream", upstream.Host,
+		"method", c.Request.Method,
+		"path", c.Request.URL.Path,
+		"request_id", c.GetString("request_id"),
+		"error", err,
+	}
+
+	switch {
+	case errors.Is(context.Cause(ctx), ErrUpstreamTimeout):
+		p.logger.Warn("upstream response timeout", fields...)
+		abortWithError(c, http.StatusGatewayTimeout, "upstream timeout")
+	case c.Request.Context().Err() != nil:
+		// 客户端已断开，无需再写响应
+		p.logger.Debug("client closed request", fields...)
+		c.Abort()
+	case isConnectError(err):
+		p.logger.Warn("upstream connect failed", fields...)
+		abortWithError(c, http.StatusBadGateway, "upstream unavailable")
+	case isTimeoutError(err):
+		p.logger.Warn("upstream timeout", fields...)
+		abortWithError(c, http.StatusGatewayTimeout, "upstream timeout")
+	default:
+		p.logger.Error("upstream request failed", fields...)
+		abortWithError(c, http.StatusBadGateway, "bad gateway")
+	}
+}
+
+// abortWithError 返回网关错误响应
+func abortWithError(c *gin.Context, status int, message string) {
+	c.AbortWithStatusJSON(status, gin.H{
+		"code":    status,
+		"message": message,
+	})
+}
+
+// isConnectError 判断是否为建立连接阶段的错误（拒绝连接、DNS 失败、拨号超时等）
+func isConnectError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr)
+}
+
+// isTimeoutError 判断是否为超时错误
+func isTimeoutError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
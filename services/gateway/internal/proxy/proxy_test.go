@@ -0,0 +1,164 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"goweb/pkg/logger"
+	"goweb/pkg/middleware"
+)
+
+func newTestProxy() *Proxy {
+	return &Proxy{
+		transport:      NewTransport(TransportConfig{}),
+		logger:         logger.New("gateway-test", "error", "stdout", ""),
+		defaultTimeout: time.Second,
+	}
+}
+
+func newTestEngine(t *testing.T, p *Proxy, target *Target, rawURL string) *gin.Engine {
+	upstream, err := url.Parse(rawURL)
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Any("/*path", func(c *gin.Context) { p.Serve(c, target, upstream) })
+	return r
+}
+
+func TestProxy_ForwardsRequest(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, "/api/v1/user/profile", r.URL.Path)
+		assert.Equal(t, "a=1", r.URL.RawQuery)
+		assert.Equal(t, "10.0.0.1, 192.0.2.1", r.Header.Get("X-Forwarded-For"))
+		assert.Equal(t, "example.com", r.Header.Get("X-Forwarded-Host"))
+		assert.Empty(t, r.Header.Get("Keep-Alive"))
+		w.Header().Set("X-Upstream", "ok")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	}))
+	defer upstream.Close()
+
+	r := newTestEngine(t, newTestProxy(), &Target{Name: "user"}, upstream.URL)
+
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/api/v1/user/profile?a=1", strings.NewReader("hello"))
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("X-Forwarded-For", "10.0.0.1")
+	req.Header.Set("Keep-Alive", "timeout=5")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, "ok", w.Header().Get("X-Upstream"))
+	require.Equal(t, "hello", w.Body.String())
+}
+
+func TestProxy_UpstreamTimeout(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer upstream.Close()
+
+	r := newTestEngine(t, newTestProxy(), &Target{Name: "slow", Timeout: 50 * time.Millisecond}, upstream.URL)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	require.Equal(t, http.StatusGatewayTimeout, w.Code)
+}
+
+func TestProxy_ConnectFailure(t *testing.T) {
+	// 占用一个端口后立即关闭，保证连接被拒绝
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	r := newTestEngine(t, newTestProxy(), &Target{Name: "down"}, "http://"+addr)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/down", nil))
+	require.Equal(t, http.StatusBadGateway, w.Code)
+}
+
+func TestProxy_SignsIdentityHeaders(t *testing.T) {
+	const secret = "identity-secret"
+
+	// 后端服务使用 GatewayAuth 校验网关签名
+	gin.SetMode(gin.TestMode)
+	backend := gin.New()
+	backend.Use(middleware.GatewayAuth(secret))
+	var signed http.Header
+	backend.Any("/*path", func(c *gin.Context) {
+		signed = c.Request.Header.Clone()
+		c.String(http.StatusOK, c.GetString("user_id")+"/"+c.GetString("username"))
+	})
+	upstream := httptest.NewServer(backend)
+	defer upstream.Close()
+
+	p := newTestProxy()
+	p.identitySecret = secret
+	target, err := url.Parse(upstream.URL)
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.GET("/*path", func(c *gin.Context) {
+		// 模拟网关认证中间件
+		c.Set("user_id", "42")
+		c.Set("username", "alice")
+		c.Set("request_id", "req-1")
+		p.Serve(c, &Target{Name: "user", PathRewrite: func(p string) string { return "/v1" + p }}, target)
+	})
+
+	// 客户端伪造的身份请求头被覆盖
+	req := httptest.NewRequest(http.MethodGet, "/profile", nil)
+	req.Header.Set(middleware.HeaderUserID, "1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "42/alice", w.Body.String())
+
+	// 签名绑定改写后的路径（含转义字符）
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/files/a%2Fb", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	direct := func(method, path string, header http.Header) string {
+		req, err := http.NewRequest(method, upstream.URL+path, nil)
+		require.NoError(t, err)
+		for k := range header {
+			req.Header.Set(k, header.Get(k))
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return string(body)
+	}
+	identity := http.Header{}
+	for _, k := range []string{middleware.HeaderUserID, middleware.HeaderUsername, middleware.HeaderRequestID,
+		middleware.HeaderIdentityTimestamp, middleware.HeaderIdentitySignature} {
+		identity.Set(k, signed.Get(k))
+	}
+
+	// 截获的身份请求头只对原请求的方法和路径有效
+	require.Equal(t, "42/alice", direct(http.MethodGet, "/v1/files/a%2Fb", identity))
+	require.Contains(t, direct(http.MethodGet, "/v1/admin/users", identity), `"code":401`)
+	require.Contains(t, direct(http.MethodDelete, "/v1/files/a%2Fb", identity), `"code":401`)
+
+	// 未经网关签名的身份请求头被拒绝
+	require.Contains(t, direct(http.MethodGet, "/profile", http.Header{middleware.HeaderUserID: {"1"}}), `"code":401`)
+}
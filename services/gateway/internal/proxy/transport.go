@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"goweb/pkg/config"
+)
+
+// TransportConfig 上游连接池配置
+type TransportConfig struct {
+	DialTimeout         time.Duration
+	KeepAlive           time.Duration
+	TLSHandshakeTimeout time.Duration
+	IdleConnTimeout     time.Duration
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+}
+
+// TransportConfigFromConfig 从配置中读取连接池参数
+func TransportConfigFromConfig(cfg *config.Config) TransportConfig {
+	return TransportConfig{
+		DialTimeout:         cfg.GetDuration("gateway.transport.dial_timeout"),
+		KeepAlive:           cfg.GetDuration("gateway.transport.keep_alive"),
+		TLSHandshakeTimeout: cfg.GetDuration("gateway.transport.tls_handshake_timeout"),
+		IdleConnTimeout:     cfg.GetDuration("gateway.transport.idle_conn_timeout"),
+		MaxIdleConns:        cfg.GetInt("gateway.transport.max_idle_conns"),
+		MaxIdleConnsPerHost: cfg.GetInt("gateway.transport.max_idle_conns_per_host"),
+		MaxConnsPerHost:     cfg.GetInt("gateway.transport.max_conns_per_host"),
+	}
+}
+
+// NewTransport 创建所有路由共享的上游 Transport
+// 不设置整体超时：请求体和响应体都是流式转发，超时由每条路由单独控制
+func NewTransport(tc TransportConfig) *http.Transport {
+	if tc.DialTimeout == 0 {
+		tc.DialTimeout = 5 * time.Second
+	}
+	if tc.KeepAlive == 0 {
+		tc.KeepAlive = 30 * time.Second
+	}
+	if tc.TLSHandshakeTimeout == 0 {
+		tc.TLSHandshakeTimeout = 5 * time.Second
+	}
+	if tc.IdleConnTimeout == 0 {
+		tc.IdleConnTimeout = 90 * time.Second
+	}
+	if tc.MaxIdleConns == 0 {
+		tc.MaxIdleConns = 512
+	}
+	if tc.MaxIdleConnsPerHost == 0 {
+		tc.MaxIdleConnsPerHost = 64
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   tc.DialTimeout,
+		KeepAlive: tc.KeepAlive,
+	}
+
+	return &http.Transport{
+		Proxy:                 nil, // 网关直连上游，不走环境变量中的代理
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          tc.MaxIdleConns,
+		MaxIdleConnsPerHost:   tc.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       tc.MaxConnsPerHost,
+		IdleConnTimeout:       tc.IdleConnTimeout,
+		TLSHandshakeTimeout:   tc.TLSHandshakeTimeout,
+		ExpectContinueTimeout: time.Second,
+		// 由网关原样转发压缩内容，避免 Transport 自动解压
+		DisableCompression: true,
+	}
+}
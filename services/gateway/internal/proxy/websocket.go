@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IsWebSocket 是否为 WebSocket 升级请求
+func IsWebSocket(r *http.Request) bool {
+	if r.Method != http.MethodGet || !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	for _, v := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ConnLimiter 按键（路由 + 客户端 IP）统计并限制长连接数，只在当前网关实例内生效
+type ConnLimiter struct {
+	mu    sync.Mutex
+	conns map[string]int
+}
+
+// NewConnLimiter 创建连接数限制器
+func NewConnLimiter() *ConnLimiter {
+	return &ConnLimiter{conns: make(map[string]int)}
+}
+
+// Acquire 占用一个连接名额，max <= 0 表示不限制
+func (l *ConnLimiter) Acquire(key string, max int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if max > 0 && l.conns[key] >= max {
+		return false
+	}
+	l.conns[key]++
+	return true
+}
+
+// Release 释放连接名额
+func (l *ConnLimiter) Release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conns[key] <= 1 {
+		delete(l.conns, key)
+		return
+	}
+	l.conns[key]--
+}
+
+// IdleTimeoutWriter 包装 ResponseWriter，升级后的客户端连接在双向都没有数据超过 timeout 时断开
+// 客户端连接关闭后，ReverseProxy 会随之关闭到上游的连接
+func IdleTimeoutWriter(w gin.ResponseWriter, timeout time.Duration) gin.ResponseWriter {
+	return &idleTimeoutWriter{ResponseWriter: w, timeout: timeout}
+}
+
+type idleTimeoutWriter struct {
+	gin.ResponseWriter
+	timeout time.Duration
+}
+
+func (w *idleTimeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := w.ResponseWriter.Hijack()
+	if err != nil || w.timeout <= 0 {
+		return conn, rw, err
+	}
+	ic := &idleConn{Conn: conn, timeout: w.timeout}
+	ic.extend()
+	return ic, rw, nil
+}
+
+// idleConn 每次读写后顺延读写截止时间
+// 截止时间对阻塞中的读写同样生效，只有一个方向有数据时连接也不会被断开
+type idleConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *idleConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.extend()
+	}
+	return n, err
+}
+
+func (c *idleConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.extend()
+	}
+	return n, err
+}
+
+func (c *idleConn) extend() {
+	c.Conn.SetDeadline(time.Now().Add(c.timeout))
+}
@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxy_WebSocketPassthroughAndIdleTimeout(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			mt, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(mt, append([]byte(r.URL.Path+":"), msg...))
+		}
+	}))
+	defer backend.Close()
+
+	upstream, err := url.Parse(backend.URL)
+	require.NoError(t, err)
+	p := newTestProxy()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/*path", func(c *gin.Context) {
+		require.True(t, IsWebSocket(c.Request))
+		c.Writer = IdleTimeoutWriter(c.Writer, 200*time.Millisecond)
+		p.Serve(c, &Target{Name: "ws"}, upstream)
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	defer conn.Close()
+
+	// 持续有数据时不会超过响应头超时（1s）和空闲超时
+	for i := 0; i < 8; i++ {
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("ping")))
+		_, msg, err := conn.ReadMessage()
+		require.NoError(t, err)
+		require.Equal(t, "/ws:ping", string(msg))
+		time.Sleep(150 * time.Millisecond)
+	}
+
+	// 空闲超过 idle timeout 后网关断开连接
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err = conn.ReadMessage()
+	require.Error(t, err)
+	require.NotContains(t, err.Error(), "timeout")
+}
+
+func TestConnLimiter(t *testing.T) {
+	l := NewConnLimiter()
+	require.True(t, l.Acquire("ws|10.0.0.1", 2))
+	require.True(t, l.Acquire("ws|10.0.0.1", 2))
+	require.False(t, l.Acquire("ws|10.0.0.1", 2))
+	require.True(t, l.Acquire("ws|10.0.0.2", 2))
+
+	l.Release("ws|10.0.0.1")
+	require.True(t, l.Acquire("ws|10.0.0.1", 2))
+	require.True(t, l.Acquire("ws|10.0.0.1", 0))
+}
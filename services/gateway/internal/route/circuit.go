@@ -0,0 +1,97 @@
+package route
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"goweb/pkg/circuit"
+)
+
+// CircuitConfig 路由熔断配置
+// 上游连续失败达到阈值后熔断，熔断期间直接返回 fallback 响应，超时后放行少量探测请求
+type CircuitConfig struct {
+	Disabled            bool           `mapstructure:"disabled" json:"disabled"`
+	ConsecutiveFailures int            `mapstructure:"consecutive_failures" json:"consecutive_failures"` // 连续失败多少次后熔断
+	Interval            time.Duration  `mapstructure:"interval" json:"interval"`                         // 关闭状态下清空计数的周期
+	Timeout             time.Duration  `mapstructure:"timeout" json:"timeout"`                           // 熔断持续时间
+	MaxRequests         int            `mapstructure:"max_requests" json:"max_requests"`                 // 半开状态下的探测请求数
+	Fallback            FallbackConfig `mapstructure:"fallback" json:"fallback"`
+
+	// 滑动窗口失败率熔断，设置后 consecutive_failures 和 interval 不再生效
+	SlidingWindow        string        `mapstructure:"sliding_window" json:"sliding_window"`                 // count / time
+	WindowSize           int           `mapstructure:"window_size" json:"window_size"`                       // 调用次数或秒数
+	MinimumCalls         int           `mapstructure:"minimum_calls" json:"minimum_calls"`                   // 最少调用数
+	FailureRateThreshold float64       `mapstructure:"failure_rate_threshold" json:"failure_rate_threshold"` // 失败率阈值（百分比）
+	SlowCallDuration     time.Duration `mapstructure:"slow_call_duration" json:"slow_call_duration"`         // 上游响应头超过该耗时计为失败（不含响应体传输）
+}
+
+// FallbackConfig 熔断时返回的响应
+type FallbackConfig struct {
+	Status      int    `mapstructure:"status" json:"status"`
+	ContentType string `mapstructure:"content_type" json:"content_type"`
+	Body        string `mapstructure:"body" json:"body"`
+}
+
+// Normalize 校验熔断配置并填充默认值
+func (c *CircuitConfig) Normalize() error {
+	if c.ConsecutiveFailures == 0 {
+		c.ConsecutiveFailures = 5
+	}
+	if c.Interval == 0 {
+		c.Interval = time.Minute
+	}
+	if c.Timeout == 0 {
+		c.Timeout = 30 * time.Second
+	}
+	if c.MaxRequests == 0 {
+		c.MaxRequests = 3
+	}
+	if c.ConsecutiveFailures < 0 || c.Interval < 0 || c.Timeout < 0 || c.MaxRequests < 0 ||
+		c.WindowSize < 0 || c.MinimumCalls < 0 || c.SlowCallDuration < 0 {
+		return fmt.Errorf("circuit_breaker values must not be negative")
+	}
+	switch circuit.WindowType(c.SlidingWindow) {
+	case circuit.WindowNone, circuit.WindowCount, circuit.WindowTime:
+	default:
+		return fmt.Errorf("unknown circuit_breaker.sliding_window %q (use count or time)", c.SlidingWindow)
+	}
+	if c.FailureRateThreshold < 0 || c.FailureRateThreshold > 100 {
+		return fmt.Errorf("circuit_breaker.failure_rate_threshold must be between 0 and 100")
+	}
+
+	f := &c.Fallback
+	if f.Status == 0 {
+		f.Status = http.StatusServiceUnavailable
+	}
+	if f.Status < 200 || f.Status > 599 {
+		return fmt.Errorf("circuit_breaker.fallback.status %d is not a valid HTTP status", f.Status)
+	}
+	if f.Body == "" {
+		f.Body = fmt.Sprintf(`{"code":%d,"message":"service temporarily unavailable"}`, f.Status)
+		f.ContentType = "application/json; charset=utf-8"
+	}
+	if f.ContentType == "" {
+		f.ContentType = "application/json; charset=utf-8"
+	}
+	return nil
+}
+
+// BreakerConfig 转换为 pkg/circuit 的熔断器配置
+// 熔断器的调用包含响应体的流式传输，慢调用由网关按收到响应头的耗时判定，不设置 SlowCallDuration
+func (c *CircuitConfig) BreakerConfig(name string) *circuit.Config {
+	threshold := uint32(c.ConsecutiveFailures)
+	return &circuit.Config{
+		Name:        name,
+		MaxRequests: uint32(c.MaxRequests),
+		Interval:    c.Interval,
+		Timeout:     c.Timeout,
+		ReadyToTrip: func(counts circuit.Counts) bool {
+			return counts.ConsecutiveFailures >= threshold
+		},
+		WindowType:           circuit.WindowType(c.SlidingWindow),
+		WindowSize:           c.WindowSize,
+		MinimumCalls:         c.MinimumCalls,
+		FailureRateThreshold: c.FailureRateThreshold,
+	}
+}
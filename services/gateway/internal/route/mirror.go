@@ -0,0 +1,52 @@
+package route
+
+import (
+	"fmt"
+	"time"
+
+	"goweb/services/gateway/internal/upstream"
+)
+
+// MirrorConfig 路由流量镜像配置
+// 按比例把请求副本发送到镜像上游（如重写中的新版本服务），镜像响应被丢弃，不影响用户
+type MirrorConfig struct {
+	Upstream       string        `mapstructure:"upstream" json:"upstream"`               // 镜像上游地址，为空表示不镜像
+	Percentage     float64       `mapstructure:"percentage" json:"percentage"`           // 采样比例（0-100]，默认 100
+	Timeout        time.Duration `mapstructure:"timeout" json:"timeout"`                 // 镜像请求超时时间
+	MaxBodySize    int64         `mapstructure:"max_body_size" json:"max_body_size"`     // 请求体超过该大小时不镜像
+	MaxConcurrency int           `mapstructure:"max_concurrency" json:"max_concurrency"` // 同时进行的镜像请求上限，超过时跳过
+}
+
+// Enabled 是否开启镜像
+func (c *MirrorConfig) Enabled() bool {
+	return c.Upstream != ""
+}
+
+// Normalize 校验镜像配置并填充默认值
+func (c *MirrorConfig) Normalize() error {
+	if !c.Enabled() {
+		return nil
+	}
+	if err := upstream.NormalizeInstances([]upstream.InstanceConfig{{URL: c.Upstream}}); err != nil {
+		return fmt.Errorf("mirror: %w", err)
+	}
+	if c.Percentage == 0 {
+		c.Percentage = 100
+	}
+	if c.Percentage < 0 || c.Percentage > 100 {
+		return fmt.Errorf("mirror.percentage must be between 0 and 100")
+	}
+	if c.Timeout < 0 || c.MaxBodySize < 0 || c.MaxConcurrency < 0 {
+		return fmt.Errorf("mirror values must not be negative")
+	}
+	if c.Timeout == 0 {
+		c.Timeout = 5 * time.Second
+	}
+	if c.MaxBodySize == 0 {
+		c.MaxBodySize = 1 << 20
+	}
+	if c.MaxConcurrency == 0 {
+		c.MaxConcurrency = 100
+	}
+	return nil
+}
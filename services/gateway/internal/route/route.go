@@ -0,0 +1,304 @@
+package route
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"goweb/pkg/config"
+	"goweb/pkg/middleware"
+	"goweb/pkg/service"
+	"goweb/services/gateway/internal/cache"
+	"goweb/services/gateway/internal/openapi"
+	"goweb/services/gateway/internal/upstream"
+)
+
+// Config 单条路由配置（configs/{env}/gateway.yaml 中的 gateway.routes）
+type Config struct {
+	Name          string        `mapstructure:"name" json:"name"`
+	Prefix        string        `mapstructure:"prefix" json:"prefix"`                 // 匹配的路径前缀
+	Upstream      string        `mapstructure:"upstream" json:"upstream"`             // 单个上游地址（upstreams 的简写）
+	StripPrefix   bool          `mapstructure:"strip_prefix" json:"strip_prefix"`     // 转发前去掉匹配的前缀
+	RewritePrefix string        `mapstructure:"rewrite_prefix" json:"rewrite_prefix"` // 用该前缀替换匹配的前缀
+	Methods       []string      `mapstructure:"methods" json:"methods"`               // 允许的方法，为空表示全部
+	Timeout       time.Duration `mapstructure:"timeout" json:"timeout"`               // 等待上游响应头的超时时间
+	Middlewares   []string      `mapstructure:"middlewares" json:"middlewares"`       // 路由中间件，按顺序执行
+	Auth          string        `mapstructure:"auth" json:"auth"`                     // 认证策略 public / optional / required
+	APIKey        bool          `mapstructure:"api_key" json:"api_key"`               // 携带 X-API-Key 时按 API Key 认证（商户服务端调用）
+	Priority      string        `mapstructure:"priority" json:"priority"`             // 过载时的优先级 low / normal / high / critical，低优先级先被拒绝
+
+	// 从服务注册中心解析上游实例（service_discovery），与 upstream/upstreams 互斥；
+	// 配置 groups 时按实例版本分组，否则使用服务的全部实例
+	Service string `mapstructure:"service" json:"service"`
+
+	// 多实例上游及负载均衡
+	Upstreams        []upstream.InstanceConfig  `mapstructure:"upstreams" json:"upstreams"`
+	LoadBalance      upstream.BalanceConfig     `mapstructure:"load_balance" json:"load_balance"`
+	HealthCheck      upstream.HealthCheckConfig `mapstructure:"health_check" json:"health_check"`
+	OutlierDetection upstream.OutlierConfig     `mapstructure:"outlier_detection" json:"outlier_detection"`
+
+	// 按版本分组的上游（金丝雀发布），与 upstream/upstreams 互斥
+	Groups []upstream.GroupConfig `mapstructure:"groups" json:"groups"`
+	Split  upstream.SplitConfig   `mapstructure:"split" json:"split"`
+
+	// 熔断
+	CircuitBreaker CircuitConfig `mapstructure:"circuit_breaker" json:"circuit_breaker"`
+
+	// 响应缓存
+	Cache cache.Config `mapstructure:"cache" json:"cache"`
+
+	// WebSocket 透传
+	WebSocket WebSocketConfig `mapstructure:"websocket" json:"websocket"`
+
+	// 流量镜像
+	Mirror MirrorConfig `mapstructure:"mirror" json:"mirror"`
+
+	// 聚合到网关的接口文档
+	OpenAPI openapi.Config `mapstructure:"openapi" json:"openapi"`
+
+	versioned bool // 配置了 groups，服务发现时按版本分组
+}
+
+// 认证策略
+const (
+	AuthPublic   = "public"   // 不校验令牌
+	AuthOptional = "optional" // 携带令牌时校验，未携带按匿名转发
+	AuthRequired = "required" // 必须携带有效令牌
+)
+
+// Load 从配置中读取并校验路由表
+func Load(cfg *config.Config) ([]Config, error) {
+	var routes []Config
+	if err := cfg.Unmarshal("gateway.routes", &routes); err != nil {
+		return nil, fmt.Errorf("gateway.routes: %w", err)
+	}
+	if err := Validate(routes); err != nil {
+		return nil, err
+	}
+	return routes, nil
+}
+
+// PoolConfigs 每个上游分组对应的实例池配置
+// 只有一个分组时实例池与路由同名，多个分组时为 路由名@版本
+func (r *Config) PoolConfigs() []upstream.PoolConfig {
+	pools := make([]upstream.PoolConfig, 0, len(r.Groups))
+	for _, g := range r.Groups {
+		name := r.Name
+		if len(r.Groups) > 1 {
+			name = r.Name + "@" + g.Version
+		}
+		pools = append(pools, upstream.PoolConfig{
+			Name:        name,
+			Instances:   g.Upstreams,
+			Balance:     r.LoadBalance,
+			HealthCheck: r.HealthCheck,
+			Outlier:     r.OutlierDetection,
+		})
+	}
+	return pools
+}
+
+// Validate 校验并规范化路由表
+func Validate(routes []Config) error {
+	names := make(map[string]bool, len(routes))
+	for i := range routes {
+		r := &routes[i]
+		if r.Name == "" {
+			return fmt.Errorf("gateway.routes[%d]: name is required", i)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("gateway.routes[%d]: duplicate route name %q", i, r.Name)
+		}
+		names[r.Name] = true
+
+		if err := r.normalize(); err != nil {
+			return fmt.Errorf("gateway.routes[%d] (%s): %w", i, r.Name, err)
+		}
+	}
+
+	// gin 的通配路由不能嵌套，前缀之间不能互相包含；按路径段比较，/a 与 /a-b 不冲突
+	for i := range routes {
+		for j := i + 1; j < len(routes); j++ {
+			a, b := &routes[i], &routes[j]
+			if overlaps(a.Prefix, b.Prefix) {
+				return fmt.Errorf("gateway.routes: prefix %q (%s) overlaps with %q (%s)", b.Prefix, b.Name, a.Prefix, a.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// overlaps 两个前缀是否相同或一个是另一个的上级路径
+func overlaps(a, b string) bool {
+	return a == b || strings.HasPrefix(b, a+"/") || strings.HasPrefix(a, b+"/")
+}
+
+// normalize 规范化单条路由
+func (r *Config) normalize() error {
+	if r.Prefix == "" || !strings.HasPrefix(r.Prefix, "/") {
+		return fmt.Errorf("prefix %q must start with /", r.Prefix)
+	}
+	if strings.ContainsAny(r.Prefix, ":*") {
+		return fmt.Errorf("prefix %q must not contain path parameters", r.Prefix)
+	}
+	r.Prefix = path.Clean(r.Prefix)
+	if r.Prefix == "/" {
+		return fmt.Errorf("prefix must not be the root path")
+	}
+
+	if r.RewritePrefix != "" {
+		if !strings.HasPrefix(r.RewritePrefix, "/") {
+			return fmt.Errorf("rewrite_prefix %q must start with /", r.RewritePrefix)
+		}
+		r.RewritePrefix = strings.TrimSuffix(r.RewritePrefix, "/")
+	}
+
+	if r.Service != "" && (r.Upstream != "" || len(r.Upstreams) > 0) {
+		return fmt.Errorf("service and upstream/upstreams are mutually exclusive")
+	}
+	if len(r.Groups) > 0 {
+		if r.Upstream != "" || len(r.Upstreams) > 0 {
+			return fmt.Errorf("groups and upstream/upstreams are mutually exclusive")
+		}
+		if err := upstream.NormalizeGroups(r.Groups, r.Service != ""); err != nil {
+			return err
+		}
+		r.versioned = true
+	} else if r.Service != "" {
+		// 实例在网关加载路由表时由 ResolveService 填充
+		r.Groups = []upstream.GroupConfig{{Version: "default", Weight: 1}}
+	} else {
+		if r.Upstream != "" {
+			if len(r.Upstreams) > 0 {
+				return fmt.Errorf("upstream and upstreams are mutually exclusive")
+			}
+			r.Upstreams = []upstream.InstanceConfig{{URL: r.Upstream, Weight: 1}}
+		}
+		if err := upstream.NormalizeInstances(r.Upstreams); err != nil {
+			return err
+		}
+		// 单一上游视为只有一个分组
+		r.Groups = []upstream.GroupConfig{{Version: "default", Weight: 1, Upstreams: r.Upstreams}}
+	}
+	if err := r.Split.Normalize(r.Name); err != nil {
+		return err
+	}
+	if err := r.LoadBalance.Normalize(); err != nil {
+		return err
+	}
+	if err := r.HealthCheck.Normalize(); err != nil {
+		return err
+	}
+	if err := r.OutlierDetection.Normalize(); err != nil {
+		return err
+	}
+	if err := r.CircuitBreaker.Normalize(); err != nil {
+		return err
+	}
+	if err := r.Cache.Normalize(); err != nil {
+		return err
+	}
+	if err := r.WebSocket.Normalize(); err != nil {
+		return err
+	}
+	if err := r.Mirror.Normalize(); err != nil {
+		return err
+	}
+	if err := r.OpenAPI.Normalize(r.Name); err != nil {
+		return err
+	}
+
+	switch r.Auth {
+	case "":
+		r.Auth = AuthPublic
+	case AuthPublic, AuthOptional, AuthRequired:
+	default:
+		return fmt.Errorf("unknown auth policy %q (use public, optional or required)", r.Auth)
+	}
+
+	priority, err := middleware.ParsePriority(r.Priority)
+	if err != nil {
+		return err
+	}
+	r.Priority = priority.String()
+
+	for i, m := range r.Methods {
+		m = strings.ToUpper(strings.TrimSpace(m))
+		if !isMethod(m) {
+			return fmt.Errorf("unsupported method %q", r.Methods[i])
+		}
+		r.Methods[i] = m
+	}
+
+	if r.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	return nil
+}
+
+// ResolveService 用注册中心中服务的 active 实例填充各分组的上游
+// 配置了 groups 时实例按版本归入对应分组，否则全部归入默认分组；实例权重取自 metadata.weight
+func (r *Config) ResolveService(instances []service.ServiceInfo) {
+	for i := range r.Groups {
+		g := &r.Groups[i]
+		g.Upstreams = nil
+		for _, inst := range service.ActiveInstances(instances) {
+			if r.versioned && inst.Version != g.Version {
+				continue
+			}
+			weight, err := strconv.Atoi(inst.Metadata["weight"])
+			if err != nil || weight <= 0 {
+				weight = 1
+			}
+			g.Upstreams = append(g.Upstreams, upstream.InstanceConfig{URL: inst.URL(), Weight: weight})
+		}
+	}
+}
+
+// RewritePath 计算转发到上游的路径
+func (r *Config) RewritePath(p string) string {
+	if r.RewritePrefix == "" && !r.StripPrefix {
+		return p
+	}
+	rest := strings.TrimPrefix(p, r.Prefix)
+	if rest != "" && !strings.HasPrefix(rest, "/") {
+		// 前缀没有按路径段匹配（如编码路径），保持原样
+		return p
+	}
+	rest = r.RewritePrefix + rest
+	if rest == "" {
+		rest = "/"
+	}
+	return rest
+}
+
+// PublicPath 由上游路径反推网关对外路径（RewritePath 的逆过程），不经过该路由的路径返回 false
+func (r *Config) PublicPath(p string) (string, bool) {
+	if r.RewritePrefix == "" && !r.StripPrefix {
+		if p != r.Prefix && !strings.HasPrefix(p, r.Prefix+"/") {
+			return "", false
+		}
+		return p, true
+	}
+	rest := strings.TrimPrefix(p, r.RewritePrefix)
+	if (rest == p && r.RewritePrefix != "") || (rest != "" && !strings.HasPrefix(rest, "/")) {
+		return "", false
+	}
+	if rest == "/" {
+		rest = ""
+	}
+	return r.Prefix + rest, true
+}
+
+// isMethod 是否为支持的 HTTP 方法
+func isMethod(m string) bool {
+	switch m {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
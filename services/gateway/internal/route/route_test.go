@@ -0,0 +1,100 @@
+package route
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"goweb/services/gateway/internal/upstream"
+)
+
+func TestValidate(t *testing.T) {
+	up := func(name, prefix string) Config {
+		return Config{Name: name, Prefix: prefix, Upstream: "http://127.0.0.1:8081"}
+	}
+
+	tests := []struct {
+		name   string
+		routes []Config
+		err    string
+	}{
+		{"ok", []Config{up("a", "/a"), up("b", "/b/")}, ""},
+		{"segment boundary", []Config{up("a", "/a"), up("ab", "/a-b")}, ""},
+		{"overlap", []Config{up("a", "/a"), up("ac", "/a/c")}, `overlaps with "/a"`},
+		// 排序后 /a-b 位于 /a 和 /a/c 之间，仍需检测出冲突
+		{"overlap not adjacent", []Config{up("a", "/a"), up("ab", "/a-b"), up("ac", "/a/c")}, `prefix "/a/c" (ac) overlaps with "/a" (a)`},
+		{"same prefix", []Config{up("a", "/a"), up("b", "/a/")}, "overlaps"},
+		{"duplicate name", []Config{up("a", "/a"), up("a", "/b")}, `duplicate route name "a"`},
+		{"missing name", []Config{up("", "/a")}, "name is required"},
+		{"root prefix", []Config{up("a", "/")}, "root path"},
+		{"relative prefix", []Config{up("a", "a")}, "must start with /"},
+		{"path parameter", []Config{up("a", "/a/:id")}, "path parameters"},
+		{"bad method", []Config{{Name: "a", Prefix: "/a", Upstream: "http://127.0.0.1:8081", Methods: []string{"get", "FETCH"}}}, `unsupported method "FETCH"`},
+		{"bad rewrite", []Config{{Name: "a", Prefix: "/a", Upstream: "http://127.0.0.1:8081", RewritePrefix: "v1"}}, "rewrite_prefix"},
+		{"bad auth", []Config{{Name: "a", Prefix: "/a", Upstream: "http://127.0.0.1:8081", Auth: "admin"}}, "unknown auth policy"},
+		{"no upstream", []Config{{Name: "a", Prefix: "/a"}}, "at least one upstream"},
+		{"upstream and upstreams", []Config{{
+			Name: "a", Prefix: "/a", Upstream: "http://127.0.0.1:8081",
+			Upstreams: []upstream.InstanceConfig{{URL: "http://127.0.0.1:8082"}},
+		}}, "upstream and upstreams are mutually exclusive"},
+		{"service and upstream", []Config{{Name: "a", Prefix: "/a", Upstream: "http://127.0.0.1:8081", Service: "user"}}, "service and upstream/upstreams"},
+		{"groups and upstream", []Config{{
+			Name: "a", Prefix: "/a", Upstream: "http://127.0.0.1:8081",
+			Groups: []upstream.GroupConfig{{Version: "v1", Weight: 1, Upstream: "http://127.0.0.1:8082"}},
+		}}, "groups and upstream/upstreams"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.routes)
+			if tt.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestValidate_Normalizes(t *testing.T) {
+	routes := []Config{{
+		Name:          "user",
+		Prefix:        "/api/v1/user/",
+		Upstream:      "http://127.0.0.1:8081",
+		RewritePrefix: "/v2/",
+		Methods:       []string{" get", "post"},
+	}}
+	require.NoError(t, Validate(routes))
+
+	r := routes[0]
+	require.Equal(t, "/api/v1/user", r.Prefix)
+	require.Equal(t, "/v2", r.RewritePrefix)
+	require.Equal(t, []string{"GET", "POST"}, r.Methods)
+	require.Equal(t, AuthPublic, r.Auth)
+	require.Equal(t, "normal", r.Priority)
+	require.Len(t, r.Groups, 1)
+	require.Equal(t, "http://127.0.0.1:8081", r.Groups[0].Upstreams[0].URL)
+}
+
+func TestRewritePath(t *testing.T) {
+	tests := []struct {
+		name  string
+		route Config
+		path  string
+		want  string
+	}{
+		{"keep", Config{Prefix: "/api/v1/user"}, "/api/v1/user/1", "/api/v1/user/1"},
+		{"strip", Config{Prefix: "/api/v1/user", StripPrefix: true}, "/api/v1/user/1", "/1"},
+		{"strip prefix itself", Config{Prefix: "/api/v1/user", StripPrefix: true}, "/api/v1/user", "/"},
+		{"rewrite", Config{Prefix: "/api/v1/user", RewritePrefix: "/v2/users"}, "/api/v1/user/1", "/v2/users/1"},
+		{"rewrite prefix itself", Config{Prefix: "/api/v1/user", RewritePrefix: "/v2/users"}, "/api/v1/user", "/v2/users"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.route.RewritePath(tt.path))
+
+			public, ok := tt.route.PublicPath(tt.want)
+			require.True(t, ok)
+			require.Equal(t, tt.path, public)
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package route
+
+import (
+	"fmt"
+	"time"
+)
+
+// WebSocketConfig 路由 WebSocket 透传配置
+// 未开启的路由拒绝 WebSocket 升级请求
+type WebSocketConfig struct {
+	Enabled       bool          `mapstructure:"enabled" json:"enabled"`
+	IdleTimeout   time.Duration `mapstructure:"idle_timeout" json:"idle_timeout"`         // 双向都没有数据超过该时长时断开
+	MaxConnsPerIP int           `mapstructure:"max_conns_per_ip" json:"max_conns_per_ip"` // 单个客户端 IP 的最大连接数，0 表示不限制
+}
+
+// Normalize 校验 WebSocket 配置并填充默认值
+func (c *WebSocketConfig) Normalize() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.IdleTimeout < 0 || c.MaxConnsPerIP < 0 {
+		return fmt.Errorf("websocket values must not be negative")
+	}
+	if c.IdleTimeout == 0 {
+		c.IdleTimeout = 5 * time.Minute
+	}
+	return nil
+}
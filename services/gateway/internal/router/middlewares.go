@@ -0,0 +1,246 @@
+package router
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"goweb/pkg/apikey"
+	"goweb/pkg/middleware"
+	"goweb/services/gateway/internal/route"
+)
+
+// routeMiddlewares 可在路由配置 middlewares 中引用的中间件
+// 每次构建路由表时重新创建，限流等中间件的状态随路由表一起更新
+func (g *Gateway) routeMiddlewares() map[string]func() gin.HandlerFunc {
+	rps := g.cfg.GetInt("rate_limit.rps")
+	burst := g.cfg.GetInt("rate_limit.burst")
+	distributed := g.distributedRateLimit()
+
+	return map[string]func() gin.HandlerFunc{
+		// JWT 认证（等同于 auth: required）
+		"jwt": func() gin.HandlerFunc {
+			return g.authMiddleware(route.AuthRequired)
+		},
+		// 路由级总限流
+		"rate_limit": func() gin.HandlerFunc {
+			if distributed {
+				return middleware.RateLimit(g.rateLimitConfig(func(c *gin.Context) string {
+					return "route:" + c.FullPath()
+				}))
+			}
+			cfg := middleware.DefaultRateLimitConfig()
+			cfg.RPS = rps
+			cfg.Burst = burst
+			return middleware.RateLimit(cfg)
+		},
+		// 按客户端 IP 限流
+		"ip_rate_limit": func() gin.HandlerFunc {
+			if distributed {
+				return middleware.RateLimit(g.rateLimitConfig(func(c *gin.Context) string {
+					return "ip:" + c.ClientIP()
+				}))
+			}
+			return middleware.IPRateLimit(rps, burst)
+		},
+		// 禁止客户端缓存
+		"no_cache": middleware.NoCache,
+		// 开放接口请求签名（gateway.signature）
+		"signature": g.signatureMiddleware,
+	}
+}
+
+// distributedRateLimit rate_limit.type 为 redis_gcra / redis_sliding_window 时在 Redis 中限流，多个网关实例共享限额
+func (g *Gateway) distributedRateLimit() bool {
+	switch g.cfg.GetString("rate_limit.type") {
+	case middleware.RateLimitRedisGCRA, middleware.RateLimitRedisSlidingWindow:
+		if g.redis == nil || !g.redis.IsEnabled() {
+			g.logger.Warn("distributed rate limit requires redis", "fail_mode", g.cfg.GetString("rate_limit.fail_mode"))
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// rateLimitConfig 分布式限流配置，Redis 出错时按 rate_limit.fail_mode 放行或拒绝，错误日志每 10 秒最多记录一次
+func (g *Gateway) rateLimitConfig(keyFunc func(*gin.Context) string) *middleware.RateLimitConfig {
+	var lastLogged atomic.Int64
+	cfg := middleware.DefaultRateLimitConfig()
+	cfg.Type = g.cfg.GetString("rate_limit.type")
+	cfg.RPS = g.cfg.GetInt("rate_limit.rps")
+	cfg.Burst = g.cfg.GetInt("rate_limit.burst")
+	cfg.Window = g.cfg.GetDuration("rate_limit.window")
+	cfg.Limit = g.cfg.GetInt("rate_limit.limit")
+	cfg.KeyFunc = keyFunc
+	cfg.Redis = g.redis
+	cfg.Prefix = "gateway:" + middleware.DefaultRateLimitPrefix
+	cfg.FailMode = g.cfg.GetString("rate_limit.fail_mode")
+	cfg.OnError = func(c *gin.Context, err error) {
+		now := time.Now().UnixNano()
+		last := lastLogged.Load()
+		if now-last < int64(10*time.Second) || !lastLogged.CompareAndSwap(last, now) {
+			return
+		}
+		g.logger.Warn("rate limiter unavailable", "error", err, "fail_mode", cfg.FailMode, "path", c.FullPath())
+	}
+	return cfg
+}
+
+// policyRateLimit rate_limit.policies 配置的策略限流，在路由认证之后执行，未配置策略时返回 nil
+// 分布式限流（rate_limit.type 为 redis_*）时策略计数同样保存在 Redis 中；
+// 限流配置不变时复用上次创建的中间件，路由表重载（配置变化、服务实例变化）不会清空进程内计数
+func (g *Gateway) policyRateLimit() (gin.HandlerFunc, error) {
+	var policies []middleware.RateLimitPolicy
+	if err := g.cfg.Unmarshal("rate_limit.policies", &policies); err != nil {
+		return nil, fmt.Errorf("invalid rate_limit.policies: %w", err)
+	}
+	if len(policies) == 0 {
+		return nil, nil
+	}
+
+	base := g.rateLimitConfig(nil)
+	cfg := middleware.PolicyRateLimitConfig{
+		Policies: policies,
+		Prefix:   base.Prefix,
+		Capacity: g.cfg.GetInt("rate_limit.capacity"),
+		FailMode: base.FailMode,
+		OnError:  base.OnError,
+	}
+	if g.distributedRateLimit() {
+		cfg.Redis = g.redis
+	}
+
+	key, err := json.Marshal([]interface{}{cfg.Policies, cfg.Capacity, cfg.FailMode, cfg.Redis != nil})
+	if err != nil {
+		return nil, err
+	}
+	if g.policyLimit != nil && string(key) == g.policyLimitKey {
+		return g.policyLimit, nil
+	}
+	handler, err := middleware.PolicyRateLimit(cfg)
+	if err != nil {
+		return nil, err
+	}
+	g.policyLimit, g.policyLimitKey = handler, string(key)
+	return handler, nil
+}
+
+// signatureApp 可调用签名接口的应用
+type signatureApp struct {
+	AppID  string `mapstructure:"app_id"`
+	Secret string `mapstructure:"secret"`
+}
+
+// signatureMiddleware 开放接口请求签名校验，nonce 记录在 Redis 中防重放
+// Redis 未启用时无法防重放，签名路由拒绝所有请求（503）
+func (g *Gateway) signatureMiddleware() gin.HandlerFunc {
+	var apps []signatureApp
+	if err := g.cfg.Unmarshal("gateway.signature.apps", &apps); err != nil {
+		g.logger.Error("invalid gateway.signature.apps", "error", err)
+	}
+	secrets := make(middleware.StaticSecrets, len(apps))
+	for _, app := range apps {
+		secrets[app.AppID] = app.Secret
+	}
+
+	cfg := middleware.SignatureConfig{
+		Secrets: secrets,
+		MaxSkew: g.cfg.GetDuration("gateway.signature.max_skew"),
+	}
+	if g.redis != nil && g.redis.IsEnabled() {
+		cfg.Nonces = middleware.NewRedisNonceStore(g.redis)
+	} else {
+		g.logger.Error("signature middleware requires redis for replay protection, signed routes will reject all requests")
+	}
+	return middleware.VerifySignature(cfg)
+}
+
+// authMiddleware 路由认证策略对应的中间件，public 返回 nil
+// 令牌在网关统一校验（含 Redis 黑名单），用户信息通过签名的身份请求头转发给后端服务
+func (g *Gateway) authMiddleware(policy string) gin.HandlerFunc {
+	secret := g.cfg.GetString("jwt.secret")
+	switch policy {
+	case route.AuthRequired:
+		return middleware.JWTAuthWithRedis(secret, g.redis)
+	case route.AuthOptional:
+		return middleware.OptionalJWTAuthWithRedis(secret, g.redis)
+	default:
+		return nil
+	}
+}
+
+// routeAuth 路由的认证中间件
+// 开启 api_key 的路由在请求携带 X-API-Key 时按 API Key 认证，否则按 auth 策略校验令牌
+func (g *Gateway) routeAuth(rc *route.Config) gin.HandlerFunc {
+	auth := g.authMiddleware(rc.Auth)
+	if !rc.APIKey {
+		return auth
+	}
+	if g.redis == nil || !g.redis.IsEnabled() {
+		g.logger.Warn("api key auth requires redis, ignored", "route", rc.Name)
+		return auth
+	}
+
+	apiKey := middleware.APIKeyAuth(g.apiKeyConfig(rc.Name))
+	return func(c *gin.Context) {
+		if c.GetHeader(middleware.APIKeyHeader) != "" {
+			apiKey(c)
+			return
+		}
+		if auth != nil {
+			auth(c)
+		}
+	}
+}
+
+// apiKeyConfig API Key 认证配置，配额计数出错时按 gateway.api_key.quota_fail_mode 放行或拒绝，
+// 出错次数计入 gateway_api_key_quota_errors_total，错误日志每 10 秒最多记录一次
+func (g *Gateway) apiKeyConfig(routeName string) middleware.APIKeyConfig {
+	failMode := g.cfg.GetString("gateway.api_key.quota_fail_mode")
+	if failMode == "" {
+		failMode = middleware.RateLimitFailOpen
+	}
+	quotaErrors := g.metrics.CreateCustomCounter(
+		"gateway_api_key_quota_errors_total",
+		"Total number of api key quota checks that failed and were let through or rejected by fail_mode",
+		[]string{"route", "fail_mode"},
+	).WithLabelValues(routeName, failMode)
+
+	var lastLogged atomic.Int64
+	return middleware.APIKeyConfig{
+		Store:    apikey.NewRedisStore(g.redis),
+		Quota:    apikey.NewQuota(g.redis),
+		FailMode: failMode,
+		OnError: func(c *gin.Context, err error) {
+			quotaErrors.Inc()
+			now := time.Now().UnixNano()
+			last := lastLogged.Load()
+			if now-last < int64(10*time.Second) || !lastLogged.CompareAndSwap(last, now) {
+				return
+			}
+			g.logger.Warn("api key quota unavailable", "error", err, "fail_mode", failMode, "route", routeName)
+		},
+	}
+}
+
+// adminAuth 网关管理接口认证，请求头 Authorization: Bearer <gateway.admin.token>
+func adminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		got := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"code":    http.StatusUnauthorized,
+				"message": "invalid admin token",
+			})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,121 @@
+package router
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"goweb/services/gateway/internal/proxy"
+	"goweb/services/gateway/internal/route"
+)
+
+// 镜像结果（gateway_mirror_requests_total 的 result 标签）
+const (
+	mirrorMatch    = "match"    // 状态码一致
+	mirrorMismatch = "mismatch" // 状态码不一致
+	mirrorError    = "error"    // 镜像请求失败
+	mirrorSkipped  = "skipped"  // 请求体过大或镜像并发已满
+)
+
+// mirrorHandler 按采样比例把请求副本发送到镜像上游，主请求完成后对比状态码和延迟
+// 放在代理之前，命中缓存的请求不镜像
+func (g *Gateway) mirrorHandler(rc *route.Config, target *proxy.Target) gin.HandlerFunc {
+	cfg := rc.Mirror
+	mirrorURL, _ := url.Parse(cfg.Upstream) // 已在路由校验时检查
+	slots := make(chan struct{}, cfg.MaxConcurrency)
+
+	return func(c *gin.Context) {
+		if proxy.IsWebSocket(c.Request) || rand.Float64()*100 >= cfg.Percentage {
+			c.Next()
+			return
+		}
+
+		body, ok := peekBody(c.Request, cfg.MaxBodySize)
+		if !ok {
+			g.mirrorRequests.WithLabelValues(rc.Name, mirrorSkipped).Inc()
+			c.Next()
+			return
+		}
+		select {
+		case slots <- struct{}{}:
+		default:
+			g.mirrorRequests.WithLabelValues(rc.Name, mirrorSkipped).Inc()
+			c.Next()
+			return
+		}
+
+		result := g.proxy.Mirror(c, target, mirrorURL, body, cfg.Timeout)
+		fields := []interface{}{
+			"route", rc.Name,
+			"mirror", mirrorURL.Host,
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"request_id", c.GetString("request_id"),
+		}
+
+		start := time.Now()
+		defer func() {
+			primaryStatus, primaryLatency := c.Writer.Status(), time.Since(start)
+			go func() {
+				defer func() { <-slots }()
+				g.recordMirror(rc.Name, primaryStatus, primaryLatency, <-result, fields)
+			}()
+		}()
+		c.Next()
+	}
+}
+
+// recordMirror 记录主请求和镜像请求的对比结果
+func (g *Gateway) recordMirror(routeName string, primaryStatus int, primaryLatency time.Duration, m proxy.MirrorResult, fields []interface{}) {
+	g.mirrorLatency.WithLabelValues(routeName, "primary").Observe(primaryLatency.Seconds())
+	g.mirrorLatency.WithLabelValues(routeName, "mirror").Observe(m.Latency.Seconds())
+
+	fields = append(fields,
+		"primary_status", primaryStatus,
+		"mirror_status", m.Status,
+		"primary_latency_ms", primaryLatency.Milliseconds(),
+		"mirror_latency_ms", m.Latency.Milliseconds(),
+	)
+	switch {
+	case m.Err != nil:
+		g.mirrorRequests.WithLabelValues(routeName, mirrorError).Inc()
+		g.logger.Warn("mirror request failed", append(fields, "error", m.Err)...)
+	case m.Status != primaryStatus:
+		g.mirrorRequests.WithLabelValues(routeName, mirrorMismatch).Inc()
+		g.mirrorStatus.WithLabelValues(routeName, strconv.Itoa(primaryStatus), strconv.Itoa(m.Status)).Inc()
+		g.logger.Info("mirror response differs", fields...)
+	default:
+		g.mirrorRequests.WithLabelValues(routeName, mirrorMatch).Inc()
+		g.logger.Debug("mirror response matches", fields...)
+	}
+}
+
+// peekBody 读取请求体并放回，超过 limit 时返回 false（已读部分同样放回，主请求不受影响）
+func peekBody(req *http.Request, limit int64) ([]byte, bool) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, true
+	}
+	if req.ContentLength > limit {
+		return nil, false
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, limit+1))
+	if err != nil || int64(len(body)) > limit {
+		req.Body = readCloser{io.MultiReader(bytes.NewReader(body), req.Body), req.Body}
+		return nil, false
+	}
+	req.Body = readCloser{bytes.NewReader(body), req.Body}
+	return body, true
+}
+
+// readCloser 替换读取来源，关闭时仍关闭原请求体
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -1,38 +1,415 @@
 package router
 
 import (
-	"bytes"
-	"io"
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
-	"strings"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
+	"goweb/pkg/circuit"
 	"goweb/pkg/config"
 	"goweb/pkg/logger"
 	"goweb/pkg/middleware"
+	"goweb/pkg/monitor"
+	"goweb/pkg/redis"
+	"goweb/pkg/service"
+	"goweb/services/gateway/internal/cache"
+	"goweb/services/gateway/internal/openapi"
+	"goweb/services/gateway/internal/proxy"
+	"goweb/services/gateway/internal/route"
+	"goweb/services/gateway/internal/upstream"
 )
 
-func NewRouter(cfg *config.Config, log logger.Logger) *gin.Engine {
+// Gateway 网关入口
+// 路由表来自 gateway.routes，配置变化时重新构建 gin.Engine 并原子替换，
+// 正在处理中的请求继续使用旧的路由表
+type Gateway struct {
+	cfg     *config.Config
+	logger  logger.Logger
+	metrics *monitor.Metrics
+	redis   *redis.Client // 令牌黑名单、API Key 和调用配额，为空时不检查黑名单且不支持 API Key
+	proxy   *proxy.Proxy
+	cache   *cache.Cache  // 路由响应缓存，Redis 未启用时不生效
+	docs    *openapi.Docs // 聚合接口文档，gateway.openapi.enabled 关闭时为空
+	engine  atomic.Pointer[gin.Engine]
+
+	// 熔断器按实例池（路由或路由的版本分组）创建，跨路由表重载保留状态，熔断配置变化时重建
+	breakers *circuit.BreakerManager
+	circuits atomic.Pointer[map[string]route.CircuitConfig]
+
+	// 按路由和版本统计上游请求，用于对比金丝雀版本的错误率和延迟
+	upstreamRequests *prometheus.CounterVec
+	upstreamLatency  *prometheus.HistogramVec
+
+	// WebSocket 连接数，跨路由表重载保留
+	wsConns    *proxy.ConnLimiter
+	wsActive   *prometheus.GaugeVec
+	wsRejected *prometheus.CounterVec
+
+	// 流量镜像对比
+	mirrorRequests *prometheus.CounterVec
+	mirrorStatus   *prometheus.CounterVec
+	mirrorLatency  *prometheus.HistogramVec
+
+	// 自适应并发限制，跨路由表重载保留学习到的限制；gateway.adaptive_limit.enabled 关闭时为空
+	shedder *middleware.AdaptiveLimiter
+
+	// 策略限流，跨路由表重载保留进程内计数，rate_limit 配置变化时重建（由 Reload 串行访问）
+	policyLimit    gin.HandlerFunc
+	policyLimitKey string
+
+	// 服务发现，路由配置 service 时从注册中心解析上游实例，实例变化时重新加载路由表
+	discovery service.Registry
+
+	mu       sync.Mutex                       // 串行化 Reload
+	pools    []*upstream.Pool                 // 当前路由表的上游实例池
+	resolved map[string][]service.ServiceInfo // 各服务最近一次成功解析的实例，注册中心不可用时沿用
+	watches  map[string]context.CancelFunc    // 正在监听实例变化的服务
+}
+
+// errUpstreamFailed 本次转发失败，计入熔断器失败次数
+var errUpstreamFailed = errors.New("upstream request failed")
+
+// errSlowCall 上游响应头超过 slow_call_duration，计入熔断器失败次数
+var errSlowCall = errors.New("upstream slow call")
+
+// errClientAborted 客户端在响应过程中断开，熔断器忽略（既不计为成功也不计为失败）
+var errClientAborted = errors.New("client aborted")
+
+// NewGateway 创建网关，路由配置无效时返回错误
+func NewGateway(cfg *config.Config, log logger.Logger, metrics *monitor.Metrics, redisClient *redis.Client) (*Gateway, error) {
 	if cfg.IsProduction() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	g := &Gateway{
+		cfg:     cfg,
+		logger:  log,
+		metrics: metrics,
+		redis:   redisClient,
+		proxy:   proxy.New(cfg, log),
+		wsConns: proxy.NewConnLimiter(),
+
+		resolved: make(map[string][]service.ServiceInfo),
+		watches:  make(map[string]context.CancelFunc),
+	}
+	discovery, err := service.NewRegistry(cfg, redisClient, log)
+	if err != nil {
+		return nil, err
+	}
+	g.discovery = discovery
+	g.cache = cache.New(redisClient, log, metrics, g.revalidate)
+	g.breakers = circuit.NewBreakerManagerWithConfig(log, g.breakerConfig)
+	circuit.RegisterMetrics(g.breakers, metrics, "gateway")
+	g.upstreamRequests = metrics.CreateCustomCounter(
+		"gateway_upstream_requests_total",
+		"Total number of proxied requests by route and upstream version",
+		[]string{"route", "version", "reason", "status"},
+	)
+	g.upstreamLatency = metrics.CreateCustomHistogram(
+		"gateway_upstream_request_duration_seconds",
+		"Proxied request duration by route and upstream version",
+		[]string{"route", "version"},
+		nil,
+	)
+	g.wsActive = metrics.CreateCustomGauge(
+		"gateway_websocket_connections",
+		"Number of active proxied websocket connections by route",
+		[]string{"route"},
+	)
+	g.wsRejected = metrics.CreateCustomCounter(
+		"gateway_websocket_rejected_total",
+		"Total number of websocket upgrades rejected by the per-IP connection limit",
+		[]string{"route"},
+	)
+	g.mirrorRequests = metrics.CreateCustomCounter(
+		"gateway_mirror_requests_total",
+		"Total number of mirrored requests by route and comparison result",
+		[]string{"route", "result"},
+	)
+	g.mirrorStatus = metrics.CreateCustomCounter(
+		"gateway_mirror_status_mismatch_total",
+		"Mirrored requests whose mirror status differs from the primary status",
+		[]string{"route", "primary_status", "mirror_status"},
+	)
+	g.mirrorLatency = metrics.CreateCustomHistogram(
+		"gateway_mirror_request_duration_seconds",
+		"Duration of mirrored requests on the primary and the mirror upstream",
+		[]string{"route", "target"},
+		nil,
+	)
+
+	if cfg.GetBool("gateway.adaptive_limit.enabled") {
+		var alc middleware.AdaptiveLimitConfig
+		if err := cfg.Unmarshal("gateway.adaptive_limit", &alc); err != nil {
+			return nil, fmt.Errorf("gateway.adaptive_limit: %w", err)
+		}
+		alc.Name = "gateway"
+		if g.shedder, err = middleware.NewAdaptiveLimiter(alc, middleware.NewAdaptiveLimitMetrics(metrics, "gateway")); err != nil {
+			return nil, fmt.Errorf("gateway.adaptive_limit: %w", err)
+		}
+	}
+
+	if cfg.GetBool("gateway.openapi.enabled") {
+		g.docs = openapi.New(cfg, log, g.proxy.Transport())
+	}
+
+	if err := g.Reload(); err != nil {
+		return nil, err
+	}
+	if g.docs != nil {
+		g.docs.Start()
+	}
+	return g, nil
+}
+
+// ServeHTTP 使用当前路由表处理请求
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.engine.Load().ServeHTTP(w, r)
+}
+
+// Reload 重新加载路由表，配置无效时保留当前路由表并返回错误
+func (g *Gateway) Reload() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	routes, err := route.Load(g.cfg)
+	if err != nil {
+		return err
+	}
+
+	// 同名（路由、分组）的实例池沿用重载前实例的健康检查和驱逐状态
+	previous := make(map[string]*upstream.Pool, len(g.pools))
+	for _, pool := range g.pools {
+		previous[pool.Name()] = pool
+	}
+
+	var pools []*upstream.Pool
+	splitters := make([]*upstream.Splitter, 0, len(routes))
+	for i := range routes {
+		rc := &routes[i]
+		if rc.Service != "" {
+			if g.discovery == nil {
+				return fmt.Errorf("route %s: service requires service_discovery", rc.Name)
+			}
+			rc.ResolveService(g.resolveService(rc.Service))
+		}
+		groups := make([]*upstream.Group, 0, len(rc.Groups))
+		for j, pc := range rc.PoolConfigs() {
+			pool, err := upstream.NewPool(pc, previous[pc.Name], g.proxy.Transport(), g.logger)
+			if err != nil {
+				return fmt.Errorf("route %s: %w", rc.Name, err)
+			}
+			pools = append(pools, pool)
+			gc := rc.Groups[j]
+			groups = append(groups, &upstream.Group{Version: gc.Version, Weight: gc.Weight, Canary: gc.Canary, Pool: pool})
+		}
+		splitters = append(splitters, upstream.NewSplitter(rc.Name, groups, rc.Split))
+	}
+
+	engine, err := g.buildEngine(routes, splitters)
+	if err != nil {
+		return err
+	}
+
+	// 先启动新实例池的健康检查再切换路由表，旧实例池随后停止
+	for _, pool := range pools {
+		pool.Start()
+	}
+	g.engine.Store(engine)
+	g.updateCircuits(routes, splitters)
+	g.updateDocs(routes)
+	g.watchServices(routes)
+	old := g.pools
+	g.pools = pools
+	for _, pool := range old {
+		pool.Stop()
+	}
+
+	g.logger.Info("gateway routes loaded", "count", len(routes))
+	return nil
+}
+
+// WatchConfig 监听配置变化并热更新路由表
+func (g *Gateway) WatchConfig() {
+	g.cfg.OnConfigChange(func() {
+		if err := g.Reload(); err != nil {
+			g.logger.Error("gateway routes reload failed, keeping previous routes", "error", err)
+		}
+	})
+	g.cfg.WatchConfig()
+}
+
+// resolveService 从注册中心获取服务实例，失败时沿用上一次的结果
+func (g *Gateway) resolveService(name string) []service.ServiceInfo {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	instances, err := g.discovery.Instances(ctx, name)
+	if err != nil {
+		g.logger.Warn("resolve service instances failed, using last known instances", "service", name, "error", err)
+		return g.resolved[name]
+	}
+	if len(service.ActiveInstances(instances)) == 0 {
+		g.logger.Warn("service has no active instances", "service", name)
+	}
+	g.resolved[name] = instances
+	return instances
+}
+
+// watchServices 监听路由表中服务的实例变化，实例变化时重新加载路由表；不再使用的服务停止监听
+func (g *Gateway) watchServices(routes []route.Config) {
+	wanted := make(map[string]bool)
+	for i := range routes {
+		if routes[i].Service != "" {
+			wanted[routes[i].Service] = true
+		}
+	}
+	for name, cancel := range g.watches {
+		if !wanted[name] {
+			cancel()
+			delete(g.watches, name)
+			delete(g.resolved, name)
+		}
+	}
+
+	for name := range wanted {
+		if _, ok := g.watches[name]; ok {
+			continue
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		ch, err := g.discovery.Watch(ctx, name)
+		if err != nil {
+			cancel()
+			g.logger.Warn("watch service instances failed", "service", name, "error", err)
+			continue
+		}
+		g.watches[name] = cancel
+
+		go func(name string, known []service.ServiceInfo) {
+			first := true
+			for instances := range ch {
+				// 第一次收到的是当前实例列表，与本次加载时解析的结果相同则无需重新加载
+				if first {
+					first = false
+					if service.SameInstances(instances, known) {
+						continue
+					}
+				}
+				g.logger.Info("service instances changed, reloading gateway routes", "service", name,
+					"instances", len(service.ActiveInstances(instances)))
+				if err := g.Reload(); err != nil {
+					g.logger.Error("gateway routes reload failed, keeping previous routes", "error", err)
+				}
+			}
+		}(name, g.resolved[name])
+	}
+}
+
+// updateCircuits 更新熔断配置，删除配置已变化或路由已移除的熔断器
+func (g *Gateway) updateCircuits(routes []route.Config, splitters []*upstream.Splitter) {
+	circuits := make(map[string]route.CircuitConfig, len(routes))
+	for i := range routes {
+		for _, group := range splitters[i].Groups() {
+			circuits[group.Pool.Name()] = routes[i].CircuitBreaker
+		}
+	}
+
+	var previous map[string]route.CircuitConfig
+	if p := g.circuits.Swap(&circuits); p != nil {
+		previous = *p
+	}
+	for name, old := range previous {
+		if cur, ok := circuits[name]; !ok || cur != old {
+			g.breakers.RemoveBreaker(name)
+		}
+	}
+}
+
+// updateDocs 更新需要聚合接口文档的路由，文档地址相对于路由第一个分组的第一个实例
+func (g *Gateway) updateDocs(routes []route.Config) {
+	if g.docs == nil {
+		return
+	}
+	var targets []openapi.Target
+	for i := range routes {
+		rc := &routes[i]
+		if !rc.OpenAPI.Enabled || len(rc.Groups[0].Upstreams) == 0 {
+			continue
+		}
+		targets = append(targets, openapi.Target{
+			Name:       rc.Name,
+			Tag:        rc.OpenAPI.Tag,
+			SpecURL:    rc.OpenAPI.SpecURL(rc.Groups[0].Upstreams[0].URL),
+			PublicPath: rc.PublicPath,
+		})
+	}
+	g.docs.SetTargets(targets)
+}
+
+// breakerConfig 按当前路由表的熔断配置创建熔断器，客户端中途断开的请求不计入
+func (g *Gateway) breakerConfig(name string) *circuit.Config {
+	cfg := circuit.DefaultConfig(name)
+	if circuits := g.circuits.Load(); circuits != nil {
+		if cc, ok := (*circuits)[name]; ok {
+			cfg = cc.BreakerConfig(name)
+		}
+	}
+	cfg.IgnoredErrors = append(cfg.IgnoredErrors, errClientAborted)
+	return cfg
+}
+
+// revalidate 在后台重新执行请求以刷新过期缓存，响应由缓存中间件保存，这里直接丢弃
+func (g *Gateway) revalidate(r *http.Request) {
+	g.ServeHTTP(&discardWriter{header: make(http.Header)}, r)
+}
+
+// discardWriter 丢弃响应内容的 http.ResponseWriter
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardWriter) WriteHeader(int)             {}
+
+// buildEngine 根据路由表构建 gin.Engine
+func (g *Gateway) buildEngine(routes []route.Config, splitters []*upstream.Splitter) (engine *gin.Engine, err error) {
+	// 路由已通过校验，这里只兜底 gin 注册时的 panic
+	defer func() {
+		if r := recover(); r != nil {
+			engine, err = nil, fmt.Errorf("register gateway routes: %v", r)
+		}
+	}()
+
 	r := gin.New()
-	r.Use(middleware.Recovery(log))
+	r.HandleMethodNotAllowed = true
+	r.Use(middleware.Recovery(g.logger))
 	r.Use(middleware.RequestID())
-	r.Use(middleware.AccessLogger(log))
+	r.Use(middleware.AccessLogger(g.logger))
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Request-Id"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Request-Id", middleware.APIKeyHeader},
 		ExposeHeaders:    []string{"X-Request-Id"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
+	// 过载保护，代理路由按配置的优先级拒绝请求
+	priorities := make(map[string]middleware.Priority)
+	if g.shedder != nil {
+		r.Use(g.loadShedding(priorities))
+	}
+
 	// 根路径
 	r.GET("/", func(c *gin.Context) {
 		c.JSON(200, gin.H{
@@ -40,8 +417,8 @@ func NewRouter(cfg *config.Config, log logger.Logger) *gin.Engine {
 			"version": "0.1.0",
 			"status":  "running",
 			"endpoints": gin.H{
-				"health":   "/healthz",
-				"api":      "/api/v1",
+				"health": "/healthz",
+				"api":    "/api/v1",
 			},
 		})
 	})
@@ -51,98 +428,193 @@ func NewRouter(cfg *config.Config, log logger.Logger) *gin.Engine {
 		c.JSON(200, gin.H{"status": "ok", "service": "gateway"})
 	})
 
-	// API路由
-	api := r.Group("/api/v1")
-	{
-		// 用户端API代理
-		user := api.Group("/user")
-		{
-			user.Any("/*path", func(c *gin.Context) {
-				proxyToService(c, cfg.GetString("external_services.user_api_url"), "/api/v1/user")
-			})
-		}
+	// 监控指标
+	if g.cfg.GetBool("monitor.enabled") {
+		r.GET(g.cfg.GetString("monitor.metrics_path"), gin.WrapH(promhttp.Handler()))
+	}
+
+	// 聚合接口文档
+	if g.docs != nil {
+		g.docs.RegisterRoutes(r)
+	}
+
+	// 网关管理接口，未配置 gateway.admin.token 时不开放
+	if token := g.cfg.GetString("gateway.admin.token"); token != "" {
+		admin := r.Group("/_gateway", adminAuth(token))
+		circuit.NewHandler(g.breakers).RegisterRoutes(admin)
+		cache.NewHandler(g.cache).RegisterRoutes(admin)
+	}
+
+	// 配置化的代理路由
+	middlewares := g.routeMiddlewares()
+	policyLimit, err := g.policyRateLimit()
+	if err != nil {
+		return nil, err
+	}
+	for i := range routes {
+		rc := &routes[i]
 
-		// 商户端API代理
-		merchant := api.Group("/merchant")
-		{
-			merchant.Any("/*path", func(c *gin.Context) {
-				proxyToService(c, cfg.GetString("external_services.merchant_api_url"), "/api/v1/merchant")
-			})
+		// 先认证，限流等中间件可以使用用户信息
+		var handlers gin.HandlersChain
+		if rc.WebSocket.Enabled {
+			handlers = append(handlers, websocketQueryToken)
+		}
+		if auth := g.routeAuth(rc); auth != nil {
+			handlers = append(handlers, auth)
+		}
+		if policyLimit != nil {
+			handlers = append(handlers, policyLimit)
+		}
+		for _, name := range rc.Middlewares {
+			factory, ok := middlewares[name]
+			if !ok {
+				return nil, fmt.Errorf("route %s: unknown middleware %q", rc.Name, name)
+			}
+			handlers = append(handlers, factory())
+		}
+		handlers = append(handlers, g.websocketHandler(rc))
+		if rc.Cache.Enabled {
+			if g.cache.Enabled() {
+				handlers = append(handlers, g.cache.Middleware(rc.Name, rc.Cache))
+			} else {
+				g.logger.Warn("route cache requires redis, ignored", "route", rc.Name)
+			}
 		}
 
-		// 管理后台API代理
-		admin := api.Group("/admin")
-		{
-			admin.Any("/*path", func(c *gin.Context) {
-				proxyToService(c, cfg.GetString("external_services.admin_api_url"), "/api/v1/admin")
-			})
+		target := &proxy.Target{
+			Name:        rc.Name,
+			Timeout:     rc.Timeout,
+			PathRewrite: rc.RewritePath,
 		}
+		if rc.Mirror.Enabled() {
+			handlers = append(handlers, g.mirrorHandler(rc, target))
+		}
+		handlers = append(handlers, g.proxyHandler(target, splitters[i], rc.CircuitBreaker))
 
-		// 文件服务API代理
-		files := api.Group("/files")
-		{
-			files.Any("/*path", func(c *gin.Context) {
-				proxyToService(c, cfg.GetString("external_services.file_api_url"), "/api/v1/files")
-			})
+		// 同时注册前缀本身和前缀下的所有路径
+		priority, _ := middleware.ParsePriority(rc.Priority)
+		for _, p := range []string{rc.Prefix, rc.Prefix + "/*path"} {
+			priorities[p] = priority
+			if len(rc.Methods) == 0 {
+				r.Any(p, handlers...)
+				continue
+			}
+			for _, method := range rc.Methods {
+				r.Handle(method, p, handlers...)
+			}
 		}
 	}
 
-	return r
+	return r, nil
 }
 
-// proxyToService 代理到后端服务
-func proxyToService(c *gin.Context, targetAddr, pathPrefix string) {
-	if targetAddr == "" {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "service not configured"})
-		return
-	}
-
-	path := c.Param("path")
-	if path == "" {
-		path = "/"
+// loadShedding 自适应并发限制中间件，按匹配的路由取优先级
+// 网关自身的接口（健康检查、监控指标、管理接口、文档）不在 priorities 中，按 critical 处理，最后被拒绝；
+// 没有匹配任何路由的请求（404/405）按 normal 处理
+func (g *Gateway) loadShedding(priorities map[string]middleware.Priority) gin.HandlerFunc {
+	handlers := make(map[middleware.Priority]gin.HandlerFunc)
+	for _, p := range []middleware.Priority{middleware.PriorityLow, middleware.PriorityNormal, middleware.PriorityHigh, middleware.PriorityCritical} {
+		handlers[p] = g.shedder.Middleware(p)
 	}
-	if !strings.HasPrefix(path, "/") {
-		path = "/" + path
+	return func(c *gin.Context) {
+		fullPath := c.FullPath()
+		priority, ok := priorities[fullPath]
+		switch {
+		case ok:
+		case fullPath == "":
+			priority = middleware.PriorityNormal
+		default:
+			priority = middleware.PriorityCritical
+		}
+		handlers[priority](c)
 	}
+}
 
-	targetURL := targetAddr + pathPrefix + path
-	
-	// 创建HTTP客户端
-	client := &http.Client{Timeout: 30 * time.Second}
+// proxyHandler 选择上游分组，经过分组的熔断器代理到后端服务，熔断期间返回配置的 fallback 响应
+func (g *Gateway) proxyHandler(target *proxy.Target, splitter *upstream.Splitter, cc route.CircuitConfig) gin.HandlerFunc {
+	fallback := []byte(cc.Fallback.Body)
+	return func(c *gin.Context) {
+		group, reason := splitter.Select(c)
+		if reason != upstream.SplitSingle {
+			g.logger.Debug("traffic split", "route", target.Name, "version", group.Version, "reason", reason,
+				"request_id", c.GetString("request_id"))
+		}
 
-	// 读取请求体
-	var body io.Reader
-	if c.Request.Body != nil {
-		bodyBytes, _ := io.ReadAll(c.Request.Body)
-		body = bytes.NewReader(bodyBytes)
-	}
+		// WebSocket 连接时长不计入延迟统计，也不经过熔断器（长连接会被计为慢调用并占用半开探测名额）
+		upgrade := proxy.IsWebSocket(c.Request)
+		start := time.Now()
+		defer func() {
+			g.upstreamRequests.WithLabelValues(target.Name, group.Version, reason, strconv.Itoa(c.Writer.Status()/100)+"xx").Inc()
+			if !upgrade {
+				g.upstreamLatency.WithLabelValues(target.Name, group.Version).Observe(time.Since(start).Seconds())
+			}
+		}()
 
-	// 创建代理请求
-	req, _ := http.NewRequest(c.Request.Method, targetURL, body)
+		if cc.Disabled || upgrade {
+			g.forward(c, target, group.Pool)
+			return
+		}
 
-	// 复制请求头
-	for key, values := range c.Request.Header {
-		for _, value := range values {
-			req.Header.Add(key, value)
+		// 客户端在响应过程中断开时 ReverseProxy 以 http.ErrAbortHandler 中止，
+		// 在回调内恢复并计为忽略，Execute 返回后再继续 panic 由 net/http 关闭连接
+		var aborted interface{}
+		breaker := g.breakers.GetBreaker(group.Pool.Name())
+		_, err := breaker.Execute(func() (_ interface{}, err error) {
+			defer func() {
+				if e := recover(); e != nil {
+					if e != http.ErrAbortHandler || c.Request.Context().Err() == nil {
+						panic(e)
+					}
+					aborted, err = e, errClientAborted
+				}
+			}()
+			latency, failed := g.forward(c, target, group.Pool)
+			if failed {
+				return nil, errUpstreamFailed
+			}
+			// 慢调用只计算到收到响应头，大文件下载、流式响应的传输时间不计入
+			if cc.SlowCallDuration > 0 && latency > cc.SlowCallDuration {
+				return nil, errSlowCall
+			}
+			return nil, nil
+		})
+		if aborted != nil {
+			panic(aborted)
+		}
+		if circuit.IsRejected(err) {
+			g.logger.Warn("circuit breaker rejected request", "route", target.Name, "version", group.Version,
+				"state", breaker.State().String(), "request_id", c.GetString("request_id"))
+			middleware.MarkLocalResponse(c)
+			c.Data(cc.Fallback.Status, cc.Fallback.ContentType, fallback)
+			c.Abort()
 		}
 	}
+}
 
-	// 发送请求
-	resp, err := client.Do(req)
+// forward 选择上游实例并转发请求，返回收到上游响应头的耗时和本次转发是否失败
+func (g *Gateway) forward(c *gin.Context, target *proxy.Target, pool *upstream.Pool) (latency time.Duration, failed bool) {
+	inst, err := pool.Pick(c)
 	if err != nil {
-		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to proxy request"})
-		return
+		g.logger.Warn("no healthy upstream", "route", target.Name, "request_id", c.GetString("request_id"))
+		middleware.MarkLocalResponse(c)
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+			"code":    http.StatusServiceUnavailable,
+			"message": "no healthy upstream",
+		})
+		return 0, true
 	}
-	defer resp.Body.Close()
 
-	// 复制响应头
-	for key, values := range resp.Header {
-		for _, value := range values {
-			c.Header(key, value)
-		}
-	}
+	defer func() { pool.Done(inst, failed) }()
 
-	// 复制响应
-	c.Status(resp.StatusCode)
-	io.Copy(c.Writer, resp.Body)
+	latency, err = g.proxy.Serve(c, target, inst.URL)
+	return latency, upstreamFailed(c, err)
+}
+
+// upstreamFailed 判断本次转发是否算作上游失败
+// 客户端主动断开不计入；连接失败、超时和 5xx 响应计入
+func upstreamFailed(c *gin.Context, err error) bool {
+	if err != nil {
+		return c.Request.Context().Err() == nil
+	}
+	return c.Writer.Status() >= http.StatusInternalServerError
 }
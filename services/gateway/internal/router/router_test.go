@@ -0,0 +1,299 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"goweb/pkg/circuit"
+	"goweb/pkg/config"
+	"goweb/pkg/logger"
+	"goweb/pkg/middleware"
+	"goweb/pkg/monitor"
+	"goweb/pkg/redis"
+)
+
+var (
+	testMetricsOnce sync.Once
+	testMetrics     *monitor.Metrics
+)
+
+// newTestGateway 使用 configs/dev 和给定的路由表创建网关（Redis 未启用）
+func newTestGateway(t *testing.T, settings map[string]interface{}) (*Gateway, *config.Config) {
+	t.Chdir("../../../..") // config.New 读取 configs/dev
+	cfg := config.New()
+	cfg.Set("gateway.openapi.enabled", false)
+	cfg.Set("gateway.adaptive_limit.enabled", false)
+	cfg.Set("service_discovery.type", "none")
+	for k, v := range settings {
+		cfg.Set(k, v)
+	}
+	gin.SetMode(gin.TestMode)
+
+	log := logger.New("gateway-test", "error", "stdout", "")
+	testMetricsOnce.Do(func() { testMetrics = monitor.NewMetrics("gateway-test", log) })
+	redisClient := redis.NewClient(&config.RedisConfig{Enabled: false}, log)
+
+	g, err := NewGateway(cfg, log, testMetrics, redisClient)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		for _, pool := range g.pools {
+			pool.Stop()
+		}
+	})
+	return g, cfg
+}
+
+func testRoute(name, prefix, upstream string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":         name,
+		"prefix":       prefix,
+		"upstream":     upstream,
+		"health_check": map[string]interface{}{"disabled": true},
+	}
+}
+
+func serve(g *Gateway, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+func TestGateway_ReloadKeepsRoutesOnInvalidConfig(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer upstream.Close()
+
+	g, cfg := newTestGateway(t, map[string]interface{}{
+		"gateway.routes": []interface{}{testRoute("user", "/api/v1/user", upstream.URL)},
+	})
+
+	w := serve(g, http.MethodGet, "/api/v1/user/1")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "/api/v1/user/1", w.Body.String())
+
+	// 新增路由后生效
+	cfg.Set("gateway.routes", []interface{}{
+		testRoute("user", "/api/v1/user", upstream.URL),
+		testRoute("order", "/api/v1/order", upstream.URL),
+	})
+	require.NoError(t, g.Reload())
+	require.Equal(t, http.StatusOK, serve(g, http.MethodGet, "/api/v1/order/1").Code)
+
+	// 前缀冲突的配置被拒绝，继续使用之前的路由表
+	cfg.Set("gateway.routes", []interface{}{
+		testRoute("user", "/api/v1/user", upstream.URL),
+		testRoute("user-x", "/api/v1/user-x", upstream.URL),
+		testRoute("user-detail", "/api/v1/user/detail", upstream.URL),
+	})
+	require.ErrorContains(t, g.Reload(), "overlaps")
+	require.Equal(t, http.StatusOK, serve(g, http.MethodGet, "/api/v1/order/1").Code)
+	require.Equal(t, http.StatusNotFound, serve(g, http.MethodGet, "/api/v1/user-x/1").Code)
+}
+
+func TestGateway_RouteBreakerAndAdmin(t *testing.T) {
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer upstream.Close()
+
+	rc := testRoute("user", "/api/v1/user", upstream.URL)
+	rc["outlier_detection"] = map[string]interface{}{"disabled": true}
+	rc["circuit_breaker"] = map[string]interface{}{
+		"consecutive_failures": 2,
+		"fallback":             map[string]interface{}{"status": 503, "content_type": "text/plain", "body": "user down"},
+	}
+	g, _ := newTestGateway(t, map[string]interface{}{
+		"gateway.routes":      []interface{}{rc},
+		"gateway.admin.token": "test-token",
+	})
+	admin := func(method, path, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		g.ServeHTTP(w, req)
+		return w
+	}
+
+	// 连续失败后熔断，返回配置的 fallback，不再转发到上游
+	require.Equal(t, http.StatusBadGateway, serve(g, http.MethodGet, "/api/v1/user/1").Code)
+	require.Equal(t, http.StatusBadGateway, serve(g, http.MethodGet, "/api/v1/user/1").Code)
+	w := serve(g, http.MethodGet, "/api/v1/user/1")
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, "user down", w.Body.String())
+	require.EqualValues(t, 2, calls.Load())
+
+	require.Equal(t, http.StatusUnauthorized, admin(http.MethodGet, "/_gateway/breakers", "wrong").Code)
+	w = admin(http.MethodGet, "/_gateway/breakers", "test-token")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"name":"user","state":"open"`)
+
+	// 手动重置后重新转发到上游
+	require.Equal(t, http.StatusOK, admin(http.MethodPost, "/_gateway/breakers/user/reset", "test-token").Code)
+	require.Equal(t, http.StatusBadGateway, serve(g, http.MethodGet, "/api/v1/user/1").Code)
+	require.EqualValues(t, 3, calls.Load())
+}
+
+func TestGateway_ReloadKeepsPolicyRateLimit(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+
+	policy := func(limit int) []interface{} {
+		return []interface{}{map[string]interface{}{
+			"name":   "user",
+			"routes": []string{"/api/v1/user/*"},
+			"limits": []interface{}{map[string]interface{}{"limit": limit, "period": "24h"}},
+		}}
+	}
+	g, cfg := newTestGateway(t, map[string]interface{}{
+		"gateway.routes":      []interface{}{testRoute("user", "/api/v1/user", upstream.URL)},
+		"rate_limit.policies": policy(1),
+	})
+
+	require.Equal(t, http.StatusOK, serve(g, http.MethodGet, "/api/v1/user/1").Code)
+	require.Equal(t, http.StatusTooManyRequests, serve(g, http.MethodGet, "/api/v1/user/1").Code)
+
+	// 路由表变化不清空限流计数
+	cfg.Set("gateway.routes", []interface{}{
+		testRoute("user", "/api/v1/user", upstream.URL),
+		testRoute("order", "/api/v1/order", upstream.URL),
+	})
+	require.NoError(t, g.Reload())
+	require.Equal(t, http.StatusTooManyRequests, serve(g, http.MethodGet, "/api/v1/user/1").Code)
+
+	// 策略变化时按新配置重建
+	cfg.Set("rate_limit.policies", policy(2))
+	require.NoError(t, g.Reload())
+	require.Equal(t, http.StatusOK, serve(g, http.MethodGet, "/api/v1/user/1").Code)
+	require.Equal(t, http.StatusOK, serve(g, http.MethodGet, "/api/v1/user/1").Code)
+	require.Equal(t, http.StatusTooManyRequests, serve(g, http.MethodGet, "/api/v1/user/1").Code)
+}
+
+func TestGateway_LoadSheddingPriorities(t *testing.T) {
+	g, _ := newTestGateway(t, map[string]interface{}{
+		"gateway.adaptive_limit": map[string]interface{}{
+			"enabled": true, "initial_limit": 1, "min_limit": 1, "max_limit": 1,
+		},
+	})
+	release, ok := g.shedder.Acquire(middleware.PriorityNormal)
+	require.True(t, ok)
+	defer release(-1, false)
+
+	// 网关自身的接口按 critical 处理，未匹配路由的请求按 normal 处理
+	require.Equal(t, http.StatusOK, serve(g, http.MethodGet, "/healthz").Code)
+	require.Equal(t, http.StatusServiceUnavailable, serve(g, http.MethodGet, "/no-such-route").Code)
+}
+
+func TestGateway_ReloadKeepsEjectedInstances(t *testing.T) {
+	var badCalls atomic.Int32
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		badCalls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer bad.Close()
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer good.Close()
+
+	user := map[string]interface{}{
+		"name":   "user",
+		"prefix": "/api/v1/user",
+		"upstreams": []interface{}{
+			map[string]interface{}{"url": bad.URL},
+			map[string]interface{}{"url": good.URL},
+		},
+		"health_check":      map[string]interface{}{"disabled": true},
+		"outlier_detection": map[string]interface{}{"consecutive_failures": 1, "base_ejection_time": "1m", "max_ejection_percent": 50},
+		"circuit_breaker":   map[string]interface{}{"disabled": true},
+	}
+	g, cfg := newTestGateway(t, map[string]interface{}{
+		"gateway.routes": []interface{}{user},
+	})
+
+	// 一次失败后 bad 被驱逐
+	for i := 0; i < 4; i++ {
+		serve(g, http.MethodGet, "/api/v1/user/1")
+	}
+	require.EqualValues(t, 1, badCalls.Load())
+
+	// 路由表重载后仍保持驱逐状态
+	cfg.Set("gateway.routes", []interface{}{user, testRoute("order", "/api/v1/order", good.URL)})
+	require.NoError(t, g.Reload())
+	for i := 0; i < 4; i++ {
+		require.Equal(t, http.StatusOK, serve(g, http.MethodGet, "/api/v1/user/1").Code)
+	}
+	require.EqualValues(t, 1, badCalls.Load())
+}
+
+func TestGateway_ClientAbortNotCountedAsFailure(t *testing.T) {
+	started := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		close(started)
+		<-r.Context().Done()
+	}))
+	defer upstream.Close()
+
+	rc := testRoute("download", "/api/v1/download", upstream.URL)
+	rc["circuit_breaker"] = map[string]interface{}{"consecutive_failures": 1}
+	g, _ := newTestGateway(t, map[string]interface{}{
+		"gateway.routes": []interface{}{rc},
+	})
+	server := httptest.NewServer(g)
+	defer server.Close()
+
+	// 收到响应头后客户端断开，响应体转发中止
+	resp, err := http.Get(server.URL + "/api/v1/download/file")
+	require.NoError(t, err)
+	<-started
+	resp.Body.Close()
+
+	breaker := g.breakers.GetBreaker("download")
+	require.Eventually(t, func() bool {
+		counts := breaker.Counts()
+		return counts.Requests == 0 || counts.TotalFailures > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Zero(t, breaker.Counts().TotalFailures)
+	require.Equal(t, circuit.StateClosed, breaker.State())
+}
+
+func TestGateway_SlowCallMeasuredToHeaders(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/files/slow" {
+			time.Sleep(100 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		if r.URL.Path == "/api/v1/files/download" {
+			time.Sleep(100 * time.Millisecond)
+			w.Write([]byte("content"))
+		}
+	}))
+	defer upstream.Close()
+
+	rc := testRoute("files", "/api/v1/files", upstream.URL)
+	rc["circuit_breaker"] = map[string]interface{}{"consecutive_failures": 1, "slow_call_duration": "50ms"}
+	g, _ := newTestGateway(t, map[string]interface{}{
+		"gateway.routes": []interface{}{rc},
+	})
+	breaker := g.breakers.GetBreaker("files")
+
+	// 响应体传输较慢不算慢调用
+	w := serve(g, http.MethodGet, "/api/v1/files/download")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "content", w.Body.String())
+	require.Equal(t, circuit.StateClosed, breaker.State())
+
+	// 响应头超过 slow_call_duration 计为失败
+	require.Equal(t, http.StatusOK, serve(g, http.MethodGet, "/api/v1/files/slow").Code)
+	require.Equal(t, circuit.StateOpen, breaker.State())
+}
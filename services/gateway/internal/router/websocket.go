@@ -0,0 +1,61 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"goweb/services/gateway/internal/proxy"
+	"goweb/services/gateway/internal/route"
+)
+
+// websocketQueryToken 浏览器建立 WebSocket 连接时无法设置请求头，
+// 升级请求未携带 Authorization 时使用查询参数 token，交给路由的认证策略统一校验
+func websocketQueryToken(c *gin.Context) {
+	if proxy.IsWebSocket(c.Request) && c.GetHeader("Authorization") == "" {
+		if token := c.Query("token"); token != "" {
+			c.Request.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+	c.Next()
+}
+
+// websocketHandler WebSocket 升级请求的连接数限制和空闲超时
+// 未开启 websocket 的路由拒绝升级请求，避免绕过连接数限制
+func (g *Gateway) websocketHandler(rc *route.Config) gin.HandlerFunc {
+	name, cfg := rc.Name, rc.WebSocket
+	return func(c *gin.Context) {
+		if !proxy.IsWebSocket(c.Request) {
+			c.Next()
+			return
+		}
+		if !cfg.Enabled {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"code":    http.StatusBadRequest,
+				"message": "websocket is not enabled on this route",
+			})
+			return
+		}
+
+		key := name + "|" + c.ClientIP()
+		if !g.wsConns.Acquire(key, cfg.MaxConnsPerIP) {
+			g.logger.Warn("too many websocket connections", "route", name, "client_ip", c.ClientIP(),
+				"limit", cfg.MaxConnsPerIP, "request_id", c.GetString("request_id"))
+			g.wsRejected.WithLabelValues(name).Inc()
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"code":    http.StatusTooManyRequests,
+				"message": "too many websocket connections",
+			})
+			return
+		}
+		defer g.wsConns.Release(key)
+
+		g.wsActive.WithLabelValues(name).Inc()
+		defer g.wsActive.WithLabelValues(name).Dec()
+
+		w := c.Writer
+		c.Writer = proxy.IdleTimeoutWriter(w, cfg.IdleTimeout)
+		c.Next()
+		c.Writer = w
+	}
+}
@@ -0,0 +1,175 @@
+package upstream
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// balancer 负载均衡器
+// instances 为路由的全部实例，实现需要跳过当前不可用的实例
+type balancer interface {
+	pick(instances []*Instance, key string, now time.Time) *Instance
+}
+
+// newBalancer 根据策略创建负载均衡器
+func newBalancer(strategy string, instances []*Instance) balancer {
+	switch strategy {
+	case StrategyWeighted:
+		return &weightedBalancer{current: make(map[*Instance]int, len(instances))}
+	case StrategyLeastConn:
+		return &leastConnBalancer{}
+	case StrategyConsistentHash:
+		return newHashRing(instances)
+	default:
+		return &roundRobinBalancer{}
+	}
+}
+
+// roundRobinBalancer 轮询
+type roundRobinBalancer struct {
+	next atomic.Uint64
+}
+
+func (b *roundRobinBalancer) pick(instances []*Instance, key string, now time.Time) *Instance {
+	n := uint64(len(instances))
+	start := b.next.Add(1) - 1
+	for i := uint64(0); i < n; i++ {
+		inst := instances[(start+i)%n]
+		if inst.Available(now) {
+			return inst
+		}
+	}
+	return nil
+}
+
+// weightedBalancer 平滑加权轮询（与 nginx 相同的算法）
+type weightedBalancer struct {
+	mu      sync.Mutex
+	current map[*Instance]int
+}
+
+func (b *weightedBalancer) pick(instances []*Instance, key string, now time.Time) *Instance {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var best *Instance
+	total := 0
+	for _, inst := range instances {
+		if !inst.Available(now) {
+			continue
+		}
+		b.current[inst] += inst.Weight
+		total += inst.Weight
+		if best == nil || b.current[inst] > b.current[best] {
+			best = inst
+		}
+	}
+	if best != nil {
+		b.current[best] -= total
+	}
+	return best
+}
+
+// leastConnBalancer 最少连接（按权重折算）
+type leastConnBalancer struct{}
+
+func (b *leastConnBalancer) pick(instances []*Instance, key string, now time.Time) *Instance {
+	var best *Instance
+	var bestScore float64
+	for _, inst := range instances {
+		if !inst.Available(now) {
+			continue
+		}
+		score := float64(inst.ActiveRequests()) / float64(inst.Weight)
+		if best == nil || score < bestScore {
+			best, bestScore = inst, score
+		}
+	}
+	return best
+}
+
+// hashRing 一致性哈希环
+// 每个实例按权重放置虚拟节点，实例不可用时顺延到环上的下一个可用实例
+type hashRing struct {
+	hashes []uint64
+	nodes  map[uint64]*Instance
+}
+
+// virtualNodes 每单位权重的虚拟节点数
+const virtualNodes = 160
+
+func newHashRing(instances []*Instance) *hashRing {
+	ring := &hashRing{nodes: make(map[uint64]*Instance)}
+	for _, inst := range instances {
+		for i := 0; i < virtualNodes*inst.Weight; i++ {
+			h := hashKey(inst.URL.String() + "#" + strconv.Itoa(i))
+			if _, exists := ring.nodes[h]; exists {
+				continue
+			}
+			ring.nodes[h] = inst
+			ring.hashes = append(ring.hashes, h)
+		}
+	}
+	sort.Slice(ring.hashes, func(i, j int) bool { return ring.hashes[i] < ring.hashes[j] })
+	return ring
+}
+
+func (r *hashRing) pick(instances []*Instance, key string, now time.Time) *Instance {
+	if len(r.hashes) == 0 {
+		return nil
+	}
+	h := hashKey(key)
+	start := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	for i := 0; i < len(r.hashes); i++ {
+		inst := r.nodes[r.hashes[(start+i)%len(r.hashes)]]
+		if inst.Available(now) {
+			return inst
+		}
+	}
+	return nil
+}
+
+// hashKey 计算 64 位 FNV-1a 哈希
+func hashKey(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// KeyFunc 解析一致性哈希键的配置
+// 支持 user_id、ip、header:<名称>、cookie:<名称>，取不到值时退化为客户端 IP
+func KeyFunc(spec string) (func(*gin.Context) string, error) {
+	var extract func(*gin.Context) string
+	switch {
+	case spec == "user_id":
+		extract = func(c *gin.Context) string { return c.GetString("user_id") }
+	case spec == "ip":
+		extract = func(c *gin.Context) string { return c.ClientIP() }
+	case strings.HasPrefix(spec, "header:") && len(spec) > len("header:"):
+		name := spec[len("header:"):]
+		extract = func(c *gin.Context) string { return c.GetHeader(name) }
+	case strings.HasPrefix(spec, "cookie:") && len(spec) > len("cookie:"):
+		name := spec[len("cookie:"):]
+		extract = func(c *gin.Context) string {
+			value, _ := c.Cookie(name)
+			return value
+		}
+	default:
+		return nil, fmt.Errorf("unsupported hash key %q (use user_id, ip, header:<name> or cookie:<name>)", spec)
+	}
+
+	return func(c *gin.Context) string {
+		if key := extract(c); key != "" {
+			return key
+		}
+		return c.ClientIP()
+	}, nil
+}
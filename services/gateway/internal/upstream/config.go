@@ -0,0 +1,247 @@
+package upstream
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 负载均衡策略
+const (
+	StrategyRoundRobin     = "round_robin"
+	StrategyWeighted       = "weighted"
+	StrategyLeastConn      = "least_conn"
+	StrategyConsistentHash = "consistent_hash"
+)
+
+// InstanceConfig 上游实例配置
+type InstanceConfig struct {
+	URL    string `mapstructure:"url" json:"url"`
+	Weight int    `mapstructure:"weight" json:"weight"` // 权重，默认 1
+}
+
+// BalanceConfig 负载均衡配置
+type BalanceConfig struct {
+	Strategy string `mapstructure:"strategy" json:"strategy"` // round_robin / weighted / least_conn / consistent_hash
+	// HashKey 一致性哈希的键：user_id / ip / header:<名称> / cookie:<名称>
+	HashKey string `mapstructure:"hash_key" json:"hash_key"`
+}
+
+// HealthCheckConfig 主动健康检查配置
+type HealthCheckConfig struct {
+	Disabled           bool          `mapstructure:"disabled" json:"disabled"`
+	Path               string        `mapstructure:"path" json:"path"`
+	Interval           time.Duration `mapstructure:"interval" json:"interval"`
+	Timeout            time.Duration `mapstructure:"timeout" json:"timeout"`
+	HealthyThreshold   int           `mapstructure:"healthy_threshold" json:"healthy_threshold"`     // 连续成功多少次后恢复
+	UnhealthyThreshold int           `mapstructure:"unhealthy_threshold" json:"unhealthy_threshold"` // 连续失败多少次后摘除
+}
+
+// OutlierConfig 被动异常检测配置
+// 实例连续失败（连接错误、超时或 5xx）达到阈值后被临时驱逐，到期自动恢复
+type OutlierConfig struct {
+	Disabled            bool          `mapstructure:"disabled" json:"disabled"`
+	ConsecutiveFailures int           `mapstructure:"consecutive_failures" json:"consecutive_failures"`
+	BaseEjectionTime    time.Duration `mapstructure:"base_ejection_time" json:"base_ejection_time"` // 驱逐时长 = 基础时长 × 驱逐次数
+	MaxEjectionPercent  int           `mapstructure:"max_ejection_percent" json:"max_ejection_percent"`
+}
+
+// NormalizeInstances 校验实例列表并填充默认值
+func NormalizeInstances(instances []InstanceConfig) error {
+	if len(instances) == 0 {
+		return fmt.Errorf("at least one upstream is required")
+	}
+	seen := make(map[string]bool, len(instances))
+	for i := range instances {
+		inst := &instances[i]
+		if err := validateURL(inst.URL); err != nil {
+			return err
+		}
+		if seen[inst.URL] {
+			return fmt.Errorf("duplicate upstream %q", inst.URL)
+		}
+		seen[inst.URL] = true
+
+		if inst.Weight < 0 {
+			return fmt.Errorf("upstream %q: weight must not be negative", inst.URL)
+		}
+		if inst.Weight == 0 {
+			inst.Weight = 1
+		}
+	}
+	return nil
+}
+
+// Normalize 校验负载均衡配置并填充默认值
+func (c *BalanceConfig) Normalize() error {
+	switch c.Strategy {
+	case "":
+		c.Strategy = StrategyRoundRobin
+	case StrategyRoundRobin, StrategyWeighted, StrategyLeastConn:
+	case StrategyConsistentHash:
+		if c.HashKey == "" {
+			c.HashKey = "ip"
+		}
+	default:
+		return fmt.Errorf("unknown load_balance.strategy %q", c.Strategy)
+	}
+	if c.HashKey != "" {
+		if _, err := KeyFunc(c.HashKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Normalize 填充健康检查默认值
+func (c *HealthCheckConfig) Normalize() error {
+	if c.Path == "" {
+		c.Path = "/healthz"
+	}
+	if !strings.HasPrefix(c.Path, "/") {
+		return fmt.Errorf("health_check.path %q must start with /", c.Path)
+	}
+	if c.Interval == 0 {
+		c.Interval = 10 * time.Second
+	}
+	if c.Timeout == 0 {
+		c.Timeout = 2 * time.Second
+	}
+	if c.HealthyThreshold == 0 {
+		c.HealthyThreshold = 2
+	}
+	if c.UnhealthyThreshold == 0 {
+		c.UnhealthyThreshold = 3
+	}
+	if c.Interval < 0 || c.Timeout < 0 || c.HealthyThreshold < 0 || c.UnhealthyThreshold < 0 {
+		return fmt.Errorf("health_check values must not be negative")
+	}
+	return nil
+}
+
+// Normalize 填充异常检测默认值
+func (c *OutlierConfig) Normalize() error {
+	if c.ConsecutiveFailures == 0 {
+		c.ConsecutiveFailures = 5
+	}
+	if c.BaseEjectionTime == 0 {
+		c.BaseEjectionTime = 30 * time.Second
+	}
+	if c.MaxEjectionPercent == 0 {
+		c.MaxEjectionPercent = 50
+	}
+	if c.ConsecutiveFailures < 0 || c.BaseEjectionTime < 0 {
+		return fmt.Errorf("outlier_detection values must not be negative")
+	}
+	if c.MaxEjectionPercent < 0 || c.MaxEjectionPercent > 100 {
+		return fmt.Errorf("outlier_detection.max_ejection_percent must be between 0 and 100")
+	}
+	return nil
+}
+
+// validateURL 校验上游地址
+func validateURL(raw string) error {
+	if raw == "" {
+		return fmt.Errorf("upstream url is required")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid upstream %q: %w", raw, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("upstream %q must use http or https", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("upstream %q has no host", raw)
+	}
+	return nil
+}
+
+// GroupConfig 上游分组（版本），用于金丝雀发布按权重分流
+type GroupConfig struct {
+	Version   string           `mapstructure:"version" json:"version"` // 版本标签，对应 ServiceInfo.Version
+	Weight    int              `mapstructure:"weight" json:"weight"`   // 分流权重，0 表示只能通过请求头访问
+	Canary    bool             `mapstructure:"canary" json:"canary"`   // 是否为金丝雀版本
+	Upstream  string           `mapstructure:"upstream" json:"upstream"`
+	Upstreams []InstanceConfig `mapstructure:"upstreams" json:"upstreams"`
+}
+
+// 分流粘性方式
+const (
+	StickyNone   = ""
+	StickyCookie = "cookie"
+	StickyUserID = "user_id"
+)
+
+// SplitConfig 分流配置
+type SplitConfig struct {
+	Header    string        `mapstructure:"header" json:"header"`         // 指定版本的请求头，默认 X-Canary（true/false/版本号）
+	Sticky    string        `mapstructure:"sticky" json:"sticky"`         // cookie / user_id（需要路由开启认证），为空表示每次按权重随机
+	Cookie    string        `mapstructure:"cookie" json:"cookie"`         // 粘性 cookie 名称，默认 gw_version_<路由名>
+	CookieTTL time.Duration `mapstructure:"cookie_ttl" json:"cookie_ttl"` // 粘性 cookie 有效期，默认 24h
+}
+
+// NormalizeGroups 校验分组并填充默认值
+// discovered 为 true 时分组的实例由服务发现按版本解析，不能配置 upstream/upstreams
+func NormalizeGroups(groups []GroupConfig, discovered bool) error {
+	versions := make(map[string]bool, len(groups))
+	total := 0
+	for i := range groups {
+		g := &groups[i]
+		if g.Version == "" {
+			return fmt.Errorf("groups[%d]: version is required", i)
+		}
+		if versions[g.Version] {
+			return fmt.Errorf("duplicate group version %q", g.Version)
+		}
+		versions[g.Version] = true
+
+		if g.Weight < 0 {
+			return fmt.Errorf("group %s: weight must not be negative", g.Version)
+		}
+		total += g.Weight
+
+		if discovered {
+			if g.Upstream != "" || len(g.Upstreams) > 0 {
+				return fmt.Errorf("group %s: upstream/upstreams must not be set when the route uses service", g.Version)
+			}
+			continue
+		}
+		if g.Upstream != "" {
+			if len(g.Upstreams) > 0 {
+				return fmt.Errorf("group %s: upstream and upstreams are mutually exclusive", g.Version)
+			}
+			g.Upstreams = []InstanceConfig{{URL: g.Upstream, Weight: 1}}
+		}
+		if err := NormalizeInstances(g.Upstreams); err != nil {
+			return fmt.Errorf("group %s: %w", g.Version, err)
+		}
+	}
+	if total == 0 {
+		return fmt.Errorf("at least one group must have a positive weight")
+	}
+	return nil
+}
+
+// Normalize 校验分流配置并填充默认值
+func (c *SplitConfig) Normalize(route string) error {
+	if c.Header == "" {
+		c.Header = "X-Canary"
+	}
+	switch c.Sticky {
+	case StickyNone, StickyCookie, StickyUserID:
+	default:
+		return fmt.Errorf("unknown split.sticky %q (use cookie or user_id)", c.Sticky)
+	}
+	if c.Cookie == "" {
+		c.Cookie = "gw_version_" + route
+	}
+	if c.CookieTTL == 0 {
+		c.CookieTTL = 24 * time.Hour
+	}
+	if c.CookieTTL < 0 {
+		return fmt.Errorf("split.cookie_ttl must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,318 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"goweb/pkg/logger"
+)
+
+// ErrNoHealthyUpstream 路由下没有可用的上游实例
+var ErrNoHealthyUpstream = errors.New("no healthy upstream")
+
+// maxEjectionMultiplier 驱逐时长的最大倍数
+const maxEjectionMultiplier = 10
+
+// Instance 上游实例
+type Instance struct {
+	URL    *url.URL
+	Weight int
+
+	healthy atomic.Bool  // 主动健康检查结果
+	active  atomic.Int64 // 进行中的请求数
+
+	mu                  sync.Mutex
+	checkSuccesses      int // 主动检查连续成功次数
+	checkFailures       int // 主动检查连续失败次数
+	consecutiveFailures int // 被动检测连续失败次数
+	ejections           int // 累计驱逐次数，决定下次驱逐时长
+	ejectedUntil        time.Time
+}
+
+// inherit 复制另一个实例的健康检查和驱逐状态（权重变化后重建实例时使用）
+func (i *Instance) inherit(old *Instance) {
+	old.mu.Lock()
+	defer old.mu.Unlock()
+	i.healthy.Store(old.healthy.Load())
+	i.checkSuccesses = old.checkSuccesses
+	i.checkFailures = old.checkFailures
+	i.consecutiveFailures = old.consecutiveFailures
+	i.ejections = old.ejections
+	i.ejectedUntil = old.ejectedUntil
+}
+
+// Available 实例当前是否可以接收请求
+func (i *Instance) Available(now time.Time) bool {
+	if !i.healthy.Load() {
+		return false
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return !now.Before(i.ejectedUntil)
+}
+
+// Healthy 主动健康检查结果
+func (i *Instance) Healthy() bool {
+	return i.healthy.Load()
+}
+
+// ActiveRequests 进行中的请求数
+func (i *Instance) ActiveRequests() int64 {
+	return i.active.Load()
+}
+
+// Pool 一条路由的上游实例池
+type Pool struct {
+	name      string
+	instances []*Instance
+	balancer  balancer
+	keyFunc   func(*gin.Context) string
+	health    HealthCheckConfig
+	outlier   OutlierConfig
+	client    *http.Client
+	logger    logger.Logger
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// PoolConfig 实例池配置
+type PoolConfig struct {
+	Name        string
+	Instances   []InstanceConfig
+	Balance     BalanceConfig
+	HealthCheck HealthCheckConfig
+	Outlier     OutlierConfig
+}
+
+// NewPool 创建实例池，配置需已经过 Normalize
+// prev 为路由表重载前的同名实例池（可以为 nil），地址相同的实例沿用其健康检查和驱逐状态，
+// 只有新增的实例按默认状态开始；transport 用于主动健康检查，与代理共享连接池
+func NewPool(cfg PoolConfig, prev *Pool, transport http.RoundTripper, log logger.Logger) (*Pool, error) {
+	previous := make(map[string]*Instance)
+	if prev != nil {
+		for _, inst := range prev.instances {
+			previous[inst.URL.String()] = inst
+		}
+	}
+
+	instances := make([]*Instance, 0, len(cfg.Instances))
+	for _, ic := range cfg.Instances {
+		u, err := url.Parse(ic.URL)
+		if err != nil {
+			return nil, err
+		}
+		old := previous[u.String()]
+		switch {
+		case old != nil && old.Weight == ic.Weight:
+			// 配置未变的实例直接复用，进行中的请求数（least_conn）也保持连续
+			instances = append(instances, old)
+		case old != nil:
+			inst := &Instance{URL: u, Weight: ic.Weight}
+			inst.inherit(old)
+			instances = append(instances, inst)
+		default:
+			inst := &Instance{URL: u, Weight: ic.Weight}
+			inst.healthy.Store(true) // 启动时默认健康，由主动检查修正
+			instances = append(instances, inst)
+		}
+	}
+
+	var keyFunc func(*gin.Context) string
+	if cfg.Balance.Strategy == StrategyConsistentHash {
+		kf, err := KeyFunc(cfg.Balance.HashKey)
+		if err != nil {
+			return nil, err
+		}
+		keyFunc = kf
+	}
+
+	return &Pool{
+		name:      cfg.Name,
+		instances: instances,
+		balancer:  newBalancer(cfg.Balance.Strategy, instances),
+		keyFunc:   keyFunc,
+		health:    cfg.HealthCheck,
+		outlier:   cfg.Outlier,
+		client:    &http.Client{Transport: transport, Timeout: cfg.HealthCheck.Timeout},
+		logger:    log,
+	}, nil
+}
+
+// Name 路由名称
+func (p *Pool) Name() string {
+	return p.name
+}
+
+// Instances 全部实例
+func (p *Pool) Instances() []*Instance {
+	return p.instances
+}
+
+// Pick 为当前请求选择一个可用实例
+// 调用方必须在请求结束后调用 Done
+func (p *Pool) Pick(c *gin.Context) (*Instance, error) {
+	var key string
+	if p.keyFunc != nil {
+		key = p.keyFunc(c)
+	}
+
+	inst := p.balancer.pick(p.instances, key, time.Now())
+	if inst == nil {
+		return nil, ErrNoHealthyUpstream
+	}
+	inst.active.Add(1)
+	return inst, nil
+}
+
+// Done 上报请求结果，用于最少连接统计和被动异常检测
+// failed 表示连接失败、超时或上游返回 5xx
+func (p *Pool) Done(inst *Instance, failed bool) {
+	inst.active.Add(-1)
+	if p.outlier.Disabled {
+		return
+	}
+
+	now := time.Now()
+	inst.mu.Lock()
+	if !failed {
+		inst.consecutiveFailures = 0
+		// 恢复后持续健康一个基础驱逐周期，重置驱逐倍数
+		if inst.ejections > 0 && now.After(inst.ejectedUntil.Add(p.outlier.BaseEjectionTime)) {
+			inst.ejections = 0
+		}
+		inst.mu.Unlock()
+		return
+	}
+
+	inst.consecutiveFailures++
+	if inst.consecutiveFailures < p.outlier.ConsecutiveFailures || now.Before(inst.ejectedUntil) {
+		inst.mu.Unlock()
+		return
+	}
+	inst.mu.Unlock()
+
+	// 检查驱逐比例上限时不能持有实例锁
+	if !p.canEject(now) {
+		p.logger.Warn("upstream outlier not ejected, max ejection percent reached",
+			"route", p.name, "upstream", inst.URL.Host)
+		return
+	}
+
+	inst.mu.Lock()
+	if inst.ejections < maxEjectionMultiplier {
+		inst.ejections++
+	}
+	duration := p.outlier.BaseEjectionTime * time.Duration(inst.ejections)
+	inst.ejectedUntil = now.Add(duration)
+	inst.consecutiveFailures = 0
+	inst.mu.Unlock()
+
+	p.logger.Warn("upstream instance ejected", "route", p.name, "upstream", inst.URL.Host, "duration", duration)
+}
+
+// canEject 驱逐后被驱逐实例的比例是否仍在上限内
+func (p *Pool) canEject(now time.Time) bool {
+	ejected := 1
+	for _, inst := range p.instances {
+		inst.mu.Lock()
+		if now.Before(inst.ejectedUntil) {
+			ejected++
+		}
+		inst.mu.Unlock()
+	}
+	return ejected*100 <= p.outlier.MaxEjectionPercent*len(p.instances)
+}
+
+// Start 启动主动健康检查
+func (p *Pool) Start() {
+	if p.health.Disabled {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	for _, inst := range p.instances {
+		p.wg.Add(1)
+		go p.checkLoop(ctx, inst)
+	}
+}
+
+// Stop 停止主动健康检查
+func (p *Pool) Stop() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.wg.Wait()
+}
+
+// checkLoop 定时探测单个实例
+func (p *Pool) checkLoop(ctx context.Context, inst *Instance) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.health.Interval)
+	defer ticker.Stop()
+
+	p.check(ctx, inst)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.check(ctx, inst)
+		}
+	}
+}
+
+// check 探测一次健康检查接口，连续成功/失败达到阈值后切换状态
+func (p *Pool) check(ctx context.Context, inst *Instance) {
+	ok := p.probe(ctx, inst)
+	if ctx.Err() != nil {
+		return
+	}
+
+	inst.mu.Lock()
+	defer inst.mu.Unlock()
+
+	if ok {
+		inst.checkFailures = 0
+		inst.checkSuccesses++
+		if !inst.healthy.Load() && inst.checkSuccesses >= p.health.HealthyThreshold {
+			inst.healthy.Store(true)
+			p.logger.Info("upstream instance healthy", "route", p.name, "upstream", inst.URL.Host)
+		}
+		return
+	}
+
+	inst.checkSuccesses = 0
+	inst.checkFailures++
+	if inst.healthy.Load() && inst.checkFailures >= p.health.UnhealthyThreshold {
+		inst.healthy.Store(false)
+		p.logger.Warn("upstream instance unhealthy", "route", p.name, "upstream", inst.URL.Host)
+	}
+}
+
+// probe 请求健康检查接口，2xx 视为健康
+func (p *Pool) probe(ctx context.Context, inst *Instance) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, inst.URL.JoinPath(p.health.Path).String(), nil)
+	if err != nil {
+		return false
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	return resp.StatusCode >= 200 && resp.StatusCode < 300
+}
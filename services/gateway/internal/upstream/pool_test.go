@@ -0,0 +1,141 @@
+package upstream
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"goweb/pkg/logger"
+)
+
+func newTestPool(t *testing.T, balance BalanceConfig, urls ...string) *Pool {
+	instances := make([]InstanceConfig, 0, len(urls))
+	for _, u := range urls {
+		instances = append(instances, InstanceConfig{URL: u})
+	}
+	require.NoError(t, NormalizeInstances(instances))
+	require.NoError(t, balance.Normalize())
+
+	outlier := OutlierConfig{ConsecutiveFailures: 2, BaseEjectionTime: time.Minute, MaxEjectionPercent: 50}
+	pool, err := NewPool(PoolConfig{
+		Name:      "test",
+		Instances: instances,
+		Balance:   balance,
+		Outlier:   outlier,
+	}, nil, nil, logger.New("gateway-test", "error", "stdout", ""))
+	require.NoError(t, err)
+	return pool
+}
+
+func newTestContext(header string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/", nil)
+	c.Request.Header.Set("X-User-Id", header)
+	return c
+}
+
+func TestPool_RoundRobinSkipsEjected(t *testing.T) {
+	pool := newTestPool(t, BalanceConfig{}, "http://a:1", "http://b:1", "http://c:1")
+	c := newTestContext("")
+
+	// 连续失败两次后 a 被驱逐
+	for i := 0; i < 2; i++ {
+		inst := pool.Instances()[0]
+		inst.active.Add(1)
+		pool.Done(inst, true)
+	}
+
+	for i := 0; i < 6; i++ {
+		inst, err := pool.Pick(c)
+		require.NoError(t, err)
+		require.NotEqual(t, "a:1", inst.URL.Host)
+		pool.Done(inst, false)
+	}
+
+	// 超过驱逐比例上限时不再驱逐
+	for i := 0; i < 2; i++ {
+		inst := pool.Instances()[1]
+		inst.active.Add(1)
+		pool.Done(inst, true)
+	}
+	require.True(t, pool.Instances()[1].Available(time.Now()))
+}
+
+func TestNewPool_InheritsInstanceState(t *testing.T) {
+	prev := newTestPool(t, BalanceConfig{}, "http://a:1", "http://b:1")
+	for i := 0; i < 2; i++ {
+		inst := prev.Instances()[0]
+		inst.active.Add(1)
+		prev.Done(inst, true)
+	}
+	prev.Instances()[1].active.Add(1)
+	now := time.Now()
+	require.False(t, prev.Instances()[0].Available(now))
+
+	instances := []InstanceConfig{{URL: "http://a:1", Weight: 5}, {URL: "http://b:1"}, {URL: "http://c:1"}}
+	require.NoError(t, NormalizeInstances(instances))
+	pool, err := NewPool(PoolConfig{Name: "test", Instances: instances, Balance: BalanceConfig{Strategy: StrategyRoundRobin}},
+		prev, nil, logger.New("gateway-test", "error", "stdout", ""))
+	require.NoError(t, err)
+
+	// 权重变化的实例重建但沿用驱逐状态，未变化的实例直接复用，新增实例默认健康
+	a, b, c := pool.Instances()[0], pool.Instances()[1], pool.Instances()[2]
+	require.NotSame(t, prev.Instances()[0], a)
+	require.False(t, a.Available(now))
+	require.Same(t, prev.Instances()[1], b)
+	require.EqualValues(t, 1, b.ActiveRequests())
+	require.True(t, c.Available(now))
+}
+
+func TestPool_ConsistentHashIsSticky(t *testing.T) {
+	pool := newTestPool(t, BalanceConfig{Strategy: StrategyConsistentHash, HashKey: "header:X-User-Id"},
+		"http://a:1", "http://b:1", "http://c:1")
+
+	first, err := pool.Pick(newTestContext("user-42"))
+	require.NoError(t, err)
+	pool.Done(first, false)
+
+	for i := 0; i < 10; i++ {
+		inst, err := pool.Pick(newTestContext("user-42"))
+		require.NoError(t, err)
+		require.Same(t, first, inst)
+		pool.Done(inst, false)
+	}
+}
+
+func TestSplitter_HeaderStickyAndWeight(t *testing.T) {
+	stable := &Group{Version: "v1", Weight: 95}
+	canary := &Group{Version: "v2", Weight: 5, Canary: true}
+	cfg := SplitConfig{Sticky: StickyUserID}
+	require.NoError(t, cfg.Normalize("admin"))
+	s := NewSplitter("admin", []*Group{stable, canary}, cfg)
+
+	// 请求头优先于权重
+	c := newTestContext("")
+	c.Request.Header.Set("X-Canary", "true")
+	g, reason := s.Select(c)
+	require.Same(t, canary, g)
+	require.Equal(t, SplitHeader, reason)
+
+	// 同一用户总是落在同一分组
+	c = newTestContext("")
+	c.Set("user_id", "42")
+	first, reason := s.Select(c)
+	require.Equal(t, SplitSticky, reason)
+	for i := 0; i < 10; i++ {
+		g, _ := s.Select(c)
+		require.Same(t, first, g)
+	}
+
+	// 按权重分流的比例大致符合配置
+	canaryHits := 0
+	for i := 0; i < 10000; i++ {
+		if g, _ := s.Select(newTestContext("")); g == canary {
+			canaryHits++
+		}
+	}
+	require.InDelta(t, 500, canaryHits, 150)
+}
@@ -0,0 +1,131 @@
+package upstream
+
+import (
+	"math/rand"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 分流原因，用于日志和指标
+const (
+	SplitSingle = "single" // 路由只有一个分组
+	SplitHeader = "header" // 请求头指定
+	SplitSticky = "sticky" // 粘性 cookie 或 user_id 哈希
+	SplitWeight = "weight" // 按权重随机
+)
+
+// Group 上游分组，每个分组有独立的实例池
+type Group struct {
+	Version string
+	Weight  int
+	Canary  bool
+	Pool    *Pool
+}
+
+// Splitter 按权重、粘性和请求头在分组之间分流
+type Splitter struct {
+	route  string
+	groups []*Group
+	total  int
+	cfg    SplitConfig
+}
+
+// NewSplitter 创建分流器，分组需已经过 NormalizeGroups
+func NewSplitter(route string, groups []*Group, cfg SplitConfig) *Splitter {
+	total := 0
+	for _, g := range groups {
+		total += g.Weight
+	}
+	return &Splitter{route: route, groups: groups, total: total, cfg: cfg}
+}
+
+// Groups 全部分组
+func (s *Splitter) Groups() []*Group {
+	return s.groups
+}
+
+// Select 为当前请求选择分组，返回分组和分流原因
+// 优先级：请求头指定 > 粘性分配 > 按权重随机
+func (s *Splitter) Select(c *gin.Context) (*Group, string) {
+	if len(s.groups) == 1 {
+		return s.groups[0], SplitSingle
+	}
+
+	if g := s.byHeader(c.GetHeader(s.cfg.Header)); g != nil {
+		return g, SplitHeader
+	}
+
+	switch s.cfg.Sticky {
+	case StickyUserID:
+		if userID := c.GetString("user_id"); userID != "" {
+			return s.byBucket(hashKey(s.route + ":" + userID)), SplitSticky
+		}
+	case StickyCookie:
+		if version, err := c.Cookie(s.cfg.Cookie); err == nil {
+			if g := s.byVersion(version); g != nil && g.Weight > 0 {
+				return g, SplitSticky
+			}
+		}
+		g := s.byBucket(rand.Uint64())
+		http.SetCookie(c.Writer, &http.Cookie{
+			Name:     s.cfg.Cookie,
+			Value:    g.Version,
+			Path:     "/",
+			MaxAge:   int(s.cfg.CookieTTL.Seconds()),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+		return g, SplitWeight
+	}
+
+	return s.byBucket(rand.Uint64()), SplitWeight
+}
+
+// byHeader 请求头为 true/false 时选择金丝雀/稳定分组，否则按版本号匹配
+func (s *Splitter) byHeader(value string) *Group {
+	if value == "" {
+		return nil
+	}
+	switch strings.ToLower(value) {
+	case "true", "1":
+		return s.first(true)
+	case "false", "0":
+		return s.first(false)
+	default:
+		return s.byVersion(value)
+	}
+}
+
+// first 第一个金丝雀或非金丝雀分组
+func (s *Splitter) first(canary bool) *Group {
+	for _, g := range s.groups {
+		if g.Canary == canary {
+			return g
+		}
+	}
+	return nil
+}
+
+// byVersion 按版本号查找分组
+func (s *Splitter) byVersion(version string) *Group {
+	for _, g := range s.groups {
+		if g.Version == version {
+			return g
+		}
+	}
+	return nil
+}
+
+// byBucket 将哈希值映射到权重区间
+func (s *Splitter) byBucket(h uint64) *Group {
+	n := int(h % uint64(s.total))
+	for _, g := range s.groups {
+		if n < g.Weight {
+			return g
+		}
+		n -= g.Weight
+	}
+	return s.groups[len(s.groups)-1]
+}
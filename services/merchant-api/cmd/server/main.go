@@ -11,6 +11,7 @@ import (
 
 	"goweb/pkg/config"
 	"goweb/pkg/logger"
+	discovery "goweb/pkg/service"
 	"goweb/services/merchant-api/internal/handler"
 	"goweb/services/merchant-api/internal/router"
 	"goweb/services/merchant-api/internal/service"
@@ -76,6 +77,9 @@ func main() {
 		}
 	}()
 
+	// 注册到服务注册中心（service_discovery），退出时先注销
+	registration := discovery.RegisterSelf(cfg, log, serviceName, cfg.GetInt("services.merchant_api.port"))
+
 	// 优雅退出
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -84,6 +88,7 @@ func main() {
 	log.Info("merchant-api service shutting down...")
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
+	registration.Stop(ctx)
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Error("merchant-api service shutdown error", err)
 	}
@@ -11,6 +11,7 @@ import (
 
 	"goweb/pkg/config"
 	"goweb/pkg/logger"
+	discovery "goweb/pkg/service"
 	"goweb/services/user-api/internal/handler"
 	"goweb/services/user-api/internal/router"
 	"goweb/services/user-api/internal/service"
@@ -72,6 +73,9 @@ func main() {
 		}
 	}()
 
+	// 注册到服务注册中心（service_discovery），退出时先注销
+	registration := discovery.RegisterSelf(cfg, log, serviceName, cfg.GetInt("services.user_api.port"))
+
 	// 优雅退出
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -80,6 +84,7 @@ func main() {
 	log.Info("user-api service shutting down...")
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
+	registration.Stop(ctx)
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Error("user-api service shutdown error", err)
 	}
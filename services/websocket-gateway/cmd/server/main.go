@@ -12,6 +12,7 @@ import (
 	"goweb/pkg/config"
 	"goweb/pkg/logger"
 	"goweb/pkg/redis"
+	discovery "goweb/pkg/service"
 	"goweb/pkg/websocket"
 	"goweb/pkg/websocket/group"
 	"goweb/pkg/websocket/session"
@@ -93,6 +94,9 @@ func main() {
 		}
 	}()
 
+	// 注册到服务注册中心（service_discovery），退出时先注销
+	registration := discovery.RegisterSelf(cfg, log, serviceName, port)
+
 	// 优雅退出
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -101,6 +105,7 @@ func main() {
 	log.Info("websocket-gateway service shutting down...")
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
+	registration.Stop(ctx)
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Error("websocket-gateway service shutdown error", err)
 	}